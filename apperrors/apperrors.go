@@ -0,0 +1,167 @@
+// Package apperrors định nghĩa lỗi nghiệp vụ có kiểu: mã lỗi, HTTP status, thông báo cho người dùng,
+// key i18n và chi tiết. Controller chỉ cần c.Error(err), middleware ErrorMiddleware trả về response chung.
+package apperrors
+
+import (
+	"errors"
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+// Mã lỗi ổn định để client xử lý, không đổi khi thông báo thay đổi
+const (
+	CodeBadRequest           = "BAD_REQUEST"
+	CodeValidation           = "VALIDATION_FAILED"
+	CodeUnauthorized         = "UNAUTHORIZED"
+	CodeForbidden            = "FORBIDDEN"
+	CodeNotFound             = "NOT_FOUND"
+	CodeConflict             = "CONFLICT"
+	CodeTooManyRequests      = "TOO_MANY_REQUESTS"
+	CodeInternal             = "INTERNAL"
+	CodeBadGateway           = "BAD_GATEWAY"
+	CodeUnavailable          = "UNAVAILABLE"
+	CodeVerificationRequired = "VERIFICATION_REQUIRED"
+	CodeUnavailableDates     = "DATES_UNAVAILABLE"
+	CodeStayRestricted       = "STAY_RESTRICTED"
+	CodeOverCapacity         = "OVER_CAPACITY"
+	CodePayloadTooLarge      = "PAYLOAD_TOO_LARGE"
+	CodeUnsupportedMedia     = "UNSUPPORTED_MEDIA_TYPE"
+)
+
+// Giá trị "code" trong response cho các mã lỗi frontend đang xử lý riêng, mặc định là 0
+var envelopeCodes = map[string]int{
+	CodeVerificationRequired: 2,
+}
+
+type Error struct {
+	Status  int         // HTTP status
+	Code    string      // Mã lỗi, vd: DATES_UNAVAILABLE
+	Message string      // Thông báo hiển thị cho người dùng
+	Key     string      // Key i18n của thông báo, vd: errors.dates_unavailable
+	Details interface{} // Thông tin thêm cho client (trường lỗi, thời gian chờ...)
+	Err     error       // Nguyên nhân gốc, chỉ ghi log, không trả về client
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// EnvelopeCode là giá trị "code" trong response
+func (e *Error) EnvelopeCode() int {
+	return envelopeCodes[e.Code]
+}
+
+// Các hàm With* trả về bản sao, không sửa lỗi gốc (lỗi khai báo sẵn được dùng chung)
+
+func (e *Error) Wrap(err error) *Error {
+	clone := *e
+	clone.Err = err
+	return &clone
+}
+
+func (e *Error) WithDetails(details interface{}) *Error {
+	clone := *e
+	clone.Details = details
+	return &clone
+}
+
+func (e *Error) WithMessage(message string) *Error {
+	clone := *e
+	clone.Message = message
+	return &clone
+}
+
+func (e *Error) WithCode(code, key string) *Error {
+	clone := *e
+	clone.Code = code
+	clone.Key = key
+	return &clone
+}
+
+func New(status int, code, key, message string) *Error {
+	return &Error{Status: status, Code: code, Key: key, Message: message}
+}
+
+func BadRequest(message string) *Error {
+	return New(http.StatusBadRequest, CodeBadRequest, "errors.bad_request", message)
+}
+
+// Validation dùng khi bind/kiểm tra dữ liệu thất bại, lỗi chi tiết nằm trong details
+func Validation(err error) *Error {
+	e := New(http.StatusBadRequest, CodeValidation, "errors.validation", "Dữ liệu không hợp lệ")
+	if err != nil {
+		e.Details = err.Error()
+	}
+	return e
+}
+
+func Unauthorized(message string) *Error {
+	return New(http.StatusUnauthorized, CodeUnauthorized, "errors.unauthorized", message)
+}
+
+func Forbidden(message string) *Error {
+	return New(http.StatusForbidden, CodeForbidden, "errors.forbidden", message)
+}
+
+func NotFound(message string) *Error {
+	return New(http.StatusNotFound, CodeNotFound, "errors.not_found", message)
+}
+
+func Conflict(message string) *Error {
+	return New(http.StatusConflict, CodeConflict, "errors.conflict", message)
+}
+
+func TooManyRequests(message string) *Error {
+	return New(http.StatusTooManyRequests, CodeTooManyRequests, "errors.too_many_requests", message)
+}
+
+func PayloadTooLarge(message string) *Error {
+	return New(http.StatusRequestEntityTooLarge, CodePayloadTooLarge, "errors.payload_too_large", message)
+}
+
+func UnsupportedMediaType(message string) *Error {
+	return New(http.StatusUnsupportedMediaType, CodeUnsupportedMedia, "errors.unsupported_media_type", message)
+}
+
+func Internal(message string) *Error {
+	return New(http.StatusInternalServerError, CodeInternal, "errors.internal", message)
+}
+
+func BadGateway(message string) *Error {
+	return New(http.StatusBadGateway, CodeBadGateway, "errors.bad_gateway", message)
+}
+
+func Unavailable(message string) *Error {
+	return New(http.StatusServiceUnavailable, CodeUnavailable, "errors.unavailable", message)
+}
+
+// Lỗi nghiệp vụ dùng ở nhiều nơi
+var (
+	ErrVerificationRequired = New(http.StatusForbidden, CodeVerificationRequired, "errors.verification_required",
+		"Bạn cần xác nhận email để Đăng nhập")
+	ErrDatesUnavailable = New(http.StatusConflict, CodeUnavailableDates, "errors.dates_unavailable",
+		"Phòng đã được đặt hoặc không khả dụng trong khoảng thời gian này")
+	ErrStayRestricted = New(http.StatusUnprocessableEntity, CodeStayRestricted, "errors.stay_restricted",
+		"Kỳ lưu trú không đáp ứng quy định của chỗ ở")
+	ErrOverCapacity = New(http.StatusUnprocessableEntity, CodeOverCapacity, "errors.over_capacity",
+		"Số khách vượt quá sức chứa của phòng đã chọn")
+)
+
+// From chuyển lỗi bất kỳ thành *Error: lỗi không có kiểu trở thành lỗi 500 (nguyên nhân chỉ ghi log),
+// gorm.ErrRecordNotFound thành 404.
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return NotFound("Không tìm thấy dữ liệu").Wrap(err)
+	}
+	return Internal("Lỗi hệ thống, vui lòng thử lại sau").Wrap(err)
+}
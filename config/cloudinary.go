@@ -0,0 +1,19 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+
+	"github.com/cloudinary/cloudinary-go/v2"
+)
+
+var Cloudinary *cloudinary.Cloudinary
+
+func ConnectCloudinary(cfg CloudinaryConfig) {
+	var err error
+	Cloudinary, err = cloudinary.NewFromParams(cfg.CloudName, cfg.APIKey, cfg.APISecret.Value())
+	if err != nil {
+		slog.Error("Lỗi khi khởi tạo Cloudinary", "error", err)
+		os.Exit(1)
+	}
+}
@@ -1,32 +1,474 @@
 package config
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/cloudinary/cloudinary-go/v2"
 	"github.com/joho/godotenv"
 )
 
-var Cloudinary *cloudinary.Cloudinary
+// Secret giữ giá trị nhạy cảm, luôn được che khi in ra log
+type Secret string
 
-func ConnectCloudinary() {
-	var err error
-	Cloudinary, err = cloudinary.NewFromParams("dqipg0or3", "921786437263773", "cK1ylPWzyoC4bTWWtahq0QDVZUw")
-	if err != nil {
-		log.Fatalf("Lỗi khi khởi tạo Cloudinary: %v", err)
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return "******"
+}
+
+func (s Secret) GoString() string { return s.String() }
+
+func (s Secret) MarshalText() ([]byte, error) { return []byte(s.String()), nil }
+
+// Value trả về giá trị thật, chỉ dùng khi cần truyền cho thư viện
+func (s Secret) Value() string { return string(s) }
+
+type DBConfig struct {
+	Host     string
+	Port     string
+	User     string
+	Password Secret
+	Name     string
+	SSLMode  string
+	TimeZone string
+	// Tự chạy migration khi khởi động, tắt nếu chạy `migrate up` riêng trong pipeline deploy
+	AutoMigrate bool
+}
+
+func (d DBConfig) DSN() string {
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s TimeZone=%s",
+		d.Host, d.User, d.Password.Value(), d.Name, d.Port, d.SSLMode, d.TimeZone)
+}
+
+// RedactedDSN dùng để log, không chứa mật khẩu
+func (d DBConfig) RedactedDSN() string {
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s TimeZone=%s",
+		d.Host, d.User, d.Password, d.Name, d.Port, d.SSLMode, d.TimeZone)
+}
+
+type RedisConfig struct {
+	Addr     string
+	Username string
+	Password Secret
+	DB       int
+}
+
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password Secret
+	From     string
+}
+
+type CloudinaryConfig struct {
+	CloudName string
+	APIKey    string
+	APISecret Secret
+}
+
+// StorageConfig chọn nơi lưu ảnh upload
+type StorageConfig struct {
+	Driver string // cloudinary (mặc định), local hoặc s3
+	// Driver local: file ghi vào LocalDir và được phục vụ tại LocalBaseURL (vd: http://localhost:8083/uploads)
+	LocalDir     string
+	LocalBaseURL string
+	S3           S3Config
+}
+
+// S3Config dùng cho AWS S3 hoặc dịch vụ tương thích S3 (MinIO, Cloudflare R2...)
+type S3Config struct {
+	Endpoint  string // vd: https://s3.ap-southeast-1.amazonaws.com, mặc định suy từ Region
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey Secret
+	// URL công khai của bucket (CDN...), mặc định là Endpoint/Bucket
+	PublicURL string
+	PathStyle bool // MinIO thường cần path-style: endpoint/bucket/key
+}
+
+// UploadConfig giới hạn ảnh upload
+type UploadConfig struct {
+	MaxBytes      int64 // Dung lượng tối đa mỗi file
+	MaxFiles      int   // Số file tối đa mỗi lần multi-upload
+	MaxPixels     int   // Số điểm ảnh tối đa của ảnh gốc, chặn ảnh nhỏ về dung lượng nhưng giải nén rất lớn
+	MaxDimension  int   // Cạnh dài nhất của ảnh lưu lại, ảnh lớn hơn được thu nhỏ
+	ThumbnailSize int   // Cạnh dài nhất của ảnh thu nhỏ
+}
+
+type MapboxConfig struct {
+	AccessToken Secret
+}
+
+// GeocoderConfig cấu hình chuyển địa chỉ thành tọa độ
+type GeocoderConfig struct {
+	// Thứ tự nhà cung cấp, lỗi hoặc không tìm thấy thì thử nhà cung cấp kế tiếp: mapbox, nominatim, stub
+	Providers []string
+	Timeout   time.Duration // Thời gian chờ tối đa mỗi lần gọi nhà cung cấp
+	CacheTTL  time.Duration // Thời gian lưu tọa độ tìm được, địa chỉ không tìm thấy được lưu ngắn hơn
+	// Nominatim (OpenStreetMap) hoặc dịch vụ tương thích, máy chủ công khai yêu cầu User-Agent định danh ứng dụng
+	NominatimURL       string
+	NominatimUserAgent string
+}
+
+type JWTConfig struct {
+	AccessSecret  Secret
+	RefreshSecret Secret
+}
+
+type GoogleConfig struct {
+	ClientID string
+}
+
+type FacebookConfig struct {
+	AppID     string
+	AppSecret Secret
+}
+
+type ZaloConfig struct {
+	AppID     string
+	AppSecret Secret
+}
+
+type AuditConfig struct {
+	RetentionDays int // 0: giữ vĩnh viễn
+}
+
+type ServerConfig struct {
+	Addr              string
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	// Phải lớn hơn thời gian xử lý lâu nhất (vd: upload ảnh lên Cloudinary)
+	WriteTimeout   time.Duration
+	IdleTimeout    time.Duration
+	MaxHeaderBytes int
+	// Thời gian tối đa chờ các request đang xử lý khi tắt server
+	ShutdownTimeout time.Duration
+}
+
+type TracingConfig struct {
+	// none (tắt), otlp (endpoint đọc từ OTEL_EXPORTER_OTLP_ENDPOINT) hoặc stdout
+	Exporter    string
+	ServiceName string
+}
+
+type LogConfig struct {
+	Level slog.Level // debug, info, warn, error
+}
+
+type HealthConfig struct {
+	// Kiểm tra Cloudinary/Mapbox trong /readyz, tắt mặc định để readiness không phụ thuộc mạng ngoài
+	CheckExternal bool
+	Timeout       time.Duration
+}
+
+type Config struct {
+	Env         string
+	FrontendURL string // Dùng để tạo link trong email
+	DB          DBConfig
+	Redis       RedisConfig
+	SMTP        SMTPConfig
+	Cloudinary  CloudinaryConfig
+	Storage     StorageConfig
+	Upload      UploadConfig
+	Mapbox      MapboxConfig
+	Geocoder    GeocoderConfig
+	JWT         JWTConfig
+	Google      GoogleConfig
+	Facebook    FacebookConfig
+	Zalo        ZaloConfig
+	Audit       AuditConfig
+	Health      HealthConfig
+	Log         LogConfig
+	Server      ServerConfig
+	Tracing     TracingConfig
+}
+
+// Cfg là cấu hình đang dùng của ứng dụng, được gán trong Load
+var Cfg = &Config{}
+
+// MissingConfigError liệt kê toàn bộ key bắt buộc còn thiếu
+type MissingConfigError struct {
+	Keys []string
+}
+
+func (e *MissingConfigError) Error() string {
+	return "thiếu cấu hình bắt buộc: " + strings.Join(e.Keys, ", ")
+}
+
+type envReader struct {
+	missing []string
+	invalid []string
+}
+
+func (r *envReader) optional(key, def string) string {
+	if value, ok := os.LookupEnv(key); ok && strings.TrimSpace(value) != "" {
+		return strings.TrimSpace(value)
+	}
+	return def
+}
+
+func (r *envReader) required(key string) string {
+	value := r.optional(key, "")
+	if value == "" {
+		r.missing = append(r.missing, key)
 	}
+	return value
 }
 
-func LoadEnv() error {
-	err := godotenv.Load()
+func (r *envReader) integer(key string, def int) int {
+	value := r.optional(key, "")
+	if value == "" {
+		return def
+	}
+	parsed, err := strconv.Atoi(value)
 	if err != nil {
-		log.Fatalf("Error loading .env file")
+		r.invalid = append(r.invalid, key)
+		return def
 	}
+	return parsed
+}
 
-	return err
+// duration nhận định dạng của time.ParseDuration, vd: 30s, 2m
+func (r *envReader) duration(key string, def time.Duration) time.Duration {
+	value := r.optional(key, "")
+	if value == "" {
+		return def
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed <= 0 {
+		r.invalid = append(r.invalid, key)
+		return def
+	}
+	return parsed
 }
 
-func GetEnv(key string) string {
-	return os.Getenv(key)
+func (r *envReader) boolean(key string, def bool) bool {
+	value := r.optional(key, "")
+	if value == "" {
+		return def
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		r.invalid = append(r.invalid, key)
+		return def
+	}
+	return parsed
+}
+
+// Load nạp cấu hình từ file (mặc định .env, đổi bằng CONFIG_FILE) và biến môi trường.
+// Biến môi trường đã có sẵn được ưu tiên hơn giá trị trong file.
+func Load() (*Config, error) {
+	file := os.Getenv("CONFIG_FILE")
+	if file == "" {
+		file = ".env"
+	}
+	if err := godotenv.Load(file); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("không thể đọc file cấu hình %s: %w", file, err)
+	}
+
+	r := &envReader{}
+	cfg := &Config{
+		Env:         r.optional("ENV", "dev"),
+		FrontendURL: strings.TrimRight(r.optional("FRONTEND_URL", "http://localhost:3001"), "/"),
+	}
+
+	// Cấu hình DB theo môi trường: DEV_DB_*, QC_DB_*, PROD_DB_*
+	var prefix string
+	switch cfg.Env {
+	case "dev":
+		prefix = "DEV_DB_"
+	case "qc":
+		prefix = "QC_DB_"
+	case "prod":
+		prefix = "PROD_DB_"
+	default:
+		return nil, fmt.Errorf("môi trường không hợp lệ: %s (dev, qc, prod)", cfg.Env)
+	}
+	cfg.DB = DBConfig{
+		Host:     r.required(prefix + "HOST"),
+		Port:     r.optional(prefix+"PORT", "5432"),
+		User:     r.required(prefix + "USER"),
+		Password: Secret(r.required(prefix + "PASSWORD")),
+		Name:     r.required(prefix + "NAME"),
+		SSLMode:  r.optional("DB_SSLMODE", "require"),
+		TimeZone: r.optional("DB_TIMEZONE", "Asia/Ho_Chi_Minh"),
+		// Mặc định bật để giữ hành vi cũ: schema được cập nhật khi khởi động
+		AutoMigrate: r.boolean("DB_AUTO_MIGRATE", true),
+	}
+
+	cfg.Redis = RedisConfig{
+		Addr:     r.optional("REDIS_ADDR", ""),
+		Username: r.optional("REDIS_USER", ""),
+		Password: Secret(r.optional("REDIS_PASSWORD", "")),
+		DB:       r.integer("REDIS_DB", 0),
+	}
+
+	cfg.SMTP = SMTPConfig{
+		Host:     r.optional("SMTP_HOST", "smtp.gmail.com"),
+		Port:     r.optional("SMTP_PORT", "587"),
+		Username: r.required("SMTP_USERNAME"),
+		Password: Secret(r.required("SMTP_PASSWORD")),
+	}
+	cfg.SMTP.From = r.optional("SMTP_FROM", cfg.SMTP.Username)
+
+	cfg.Storage = StorageConfig{
+		Driver:       strings.ToLower(r.optional("STORAGE_DRIVER", "cloudinary")),
+		LocalDir:     r.optional("STORAGE_LOCAL_DIR", "./uploads"),
+		LocalBaseURL: strings.TrimRight(r.optional("STORAGE_LOCAL_BASE_URL", "/uploads"), "/"),
+	}
+	switch cfg.Storage.Driver {
+	case "cloudinary":
+		// Chỉ bắt buộc cấu hình Cloudinary khi dùng Cloudinary làm nơi lưu ảnh
+		cfg.Cloudinary = CloudinaryConfig{
+			CloudName: r.required("CLOUDINARY_CLOUD_NAME"),
+			APIKey:    r.required("CLOUDINARY_API_KEY"),
+			APISecret: Secret(r.required("CLOUDINARY_API_SECRET")),
+		}
+	case "local":
+	case "s3":
+		region := r.optional("S3_REGION", "us-east-1")
+		cfg.Storage.S3 = S3Config{
+			Endpoint:  strings.TrimRight(r.optional("S3_ENDPOINT", "https://s3."+region+".amazonaws.com"), "/"),
+			Region:    region,
+			Bucket:    r.required("S3_BUCKET"),
+			AccessKey: r.required("S3_ACCESS_KEY_ID"),
+			SecretKey: Secret(r.required("S3_SECRET_ACCESS_KEY")),
+			PublicURL: strings.TrimRight(r.optional("S3_PUBLIC_URL", ""), "/"),
+			PathStyle: r.boolean("S3_PATH_STYLE", true),
+		}
+	default:
+		r.invalid = append(r.invalid, "STORAGE_DRIVER")
+	}
+
+	cfg.Upload = UploadConfig{
+		MaxBytes:      int64(r.integer("UPLOAD_MAX_BYTES", 10<<20)),
+		MaxFiles:      r.integer("UPLOAD_MAX_FILES", 10),
+		MaxPixels:     r.integer("UPLOAD_MAX_PIXELS", 40_000_000),
+		MaxDimension:  r.integer("UPLOAD_MAX_DIMENSION", 2560),
+		ThumbnailSize: r.integer("UPLOAD_THUMBNAIL_SIZE", 400),
+	}
+	for _, limit := range []struct {
+		key   string
+		value int64
+	}{
+		{"UPLOAD_MAX_BYTES", cfg.Upload.MaxBytes},
+		{"UPLOAD_MAX_FILES", int64(cfg.Upload.MaxFiles)},
+		{"UPLOAD_MAX_PIXELS", int64(cfg.Upload.MaxPixels)},
+		{"UPLOAD_MAX_DIMENSION", int64(cfg.Upload.MaxDimension)},
+		{"UPLOAD_THUMBNAIL_SIZE", int64(cfg.Upload.ThumbnailSize)},
+	} {
+		if limit.value <= 0 {
+			r.invalid = append(r.invalid, limit.key)
+		}
+	}
+
+	cfg.Mapbox = MapboxConfig{
+		AccessToken: Secret(r.optional("MAPBOX_KEY", "")),
+	}
+
+	// Mặc định dùng Mapbox nếu có key, Nominatim làm dự phòng
+	defaultProviders := "nominatim"
+	if cfg.Mapbox.AccessToken != "" {
+		defaultProviders = "mapbox,nominatim"
+	}
+	cfg.Geocoder = GeocoderConfig{
+		Timeout:            r.duration("GEOCODER_TIMEOUT", 5*time.Second),
+		CacheTTL:           r.duration("GEOCODER_CACHE_TTL", 30*24*time.Hour),
+		NominatimURL:       strings.TrimRight(r.optional("NOMINATIM_URL", "https://nominatim.openstreetmap.org"), "/"),
+		NominatimUserAgent: r.optional("NOMINATIM_USER_AGENT", "trothalo-api"),
+	}
+	for _, provider := range strings.Split(strings.ToLower(r.optional("GEOCODER_PROVIDERS", defaultProviders)), ",") {
+		switch provider = strings.TrimSpace(provider); provider {
+		case "mapbox", "nominatim", "stub":
+			cfg.Geocoder.Providers = append(cfg.Geocoder.Providers, provider)
+		case "":
+		default:
+			r.invalid = append(r.invalid, "GEOCODER_PROVIDERS")
+		}
+	}
+	if slices.Contains(cfg.Geocoder.Providers, "mapbox") && cfg.Mapbox.AccessToken == "" {
+		r.missing = append(r.missing, "MAPBOX_KEY")
+	}
+
+	cfg.JWT = JWTConfig{
+		AccessSecret:  Secret(r.required("SECRET_KEY_ACCESS_TOKEN")),
+		RefreshSecret: Secret(r.required("SECRET_KEY_REFRESH_TOKEN")),
+	}
+
+	cfg.Google = GoogleConfig{
+		ClientID: r.optional("GOOGLE_CLIENT_ID", ""),
+	}
+
+	// Đăng nhập mạng xã hội chỉ bật khi có cấu hình tương ứng
+	cfg.Facebook = FacebookConfig{
+		AppID:     r.optional("FACEBOOK_APP_ID", ""),
+		AppSecret: Secret(r.optional("FACEBOOK_APP_SECRET", "")),
+	}
+
+	cfg.Zalo = ZaloConfig{
+		AppID:     r.optional("ZALO_APP_ID", ""),
+		AppSecret: Secret(r.optional("ZALO_APP_SECRET", "")),
+	}
+
+	cfg.Audit = AuditConfig{
+		RetentionDays: r.integer("AUDIT_RETENTION_DAYS", 365),
+	}
+	if cfg.Audit.RetentionDays < 0 {
+		r.invalid = append(r.invalid, "AUDIT_RETENTION_DAYS")
+	}
+
+	cfg.Health = HealthConfig{
+		CheckExternal: r.boolean("HEALTH_CHECK_EXTERNAL", false),
+		Timeout:       time.Duration(r.integer("HEALTH_CHECK_TIMEOUT_MS", 2000)) * time.Millisecond,
+	}
+	if cfg.Health.Timeout <= 0 {
+		r.invalid = append(r.invalid, "HEALTH_CHECK_TIMEOUT_MS")
+	}
+
+	cfg.Server = ServerConfig{
+		Addr:              r.optional("SERVER_ADDR", ":8083"),
+		ReadHeaderTimeout: r.duration("SERVER_READ_HEADER_TIMEOUT", 10*time.Second),
+		ReadTimeout:       r.duration("SERVER_READ_TIMEOUT", 30*time.Second),
+		WriteTimeout:      r.duration("SERVER_WRITE_TIMEOUT", 60*time.Second),
+		IdleTimeout:       r.duration("SERVER_IDLE_TIMEOUT", 120*time.Second),
+		MaxHeaderBytes:    r.integer("SERVER_MAX_HEADER_BYTES", 1<<20),
+		ShutdownTimeout:   r.duration("SERVER_SHUTDOWN_TIMEOUT", 30*time.Second),
+	}
+	if cfg.Server.MaxHeaderBytes <= 0 {
+		r.invalid = append(r.invalid, "SERVER_MAX_HEADER_BYTES")
+	}
+
+	cfg.Tracing = TracingConfig{
+		Exporter:    strings.ToLower(r.optional("TRACING_EXPORTER", "none")),
+		ServiceName: r.optional("OTEL_SERVICE_NAME", "trothalo-api"),
+	}
+	switch cfg.Tracing.Exporter {
+	case "none", "otlp", "stdout":
+	default:
+		r.invalid = append(r.invalid, "TRACING_EXPORTER")
+	}
+
+	if err := cfg.Log.Level.UnmarshalText([]byte(r.optional("LOG_LEVEL", "info"))); err != nil {
+		r.invalid = append(r.invalid, "LOG_LEVEL")
+	}
+
+	if len(r.invalid) > 0 {
+		return nil, fmt.Errorf("cấu hình không hợp lệ: %s", strings.Join(r.invalid, ", "))
+	}
+	if len(r.missing) > 0 {
+		return nil, &MissingConfigError{Keys: r.missing}
+	}
+
+	Cfg = cfg
+	return cfg, nil
 }
@@ -2,56 +2,47 @@ package config
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"time"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/plugin/opentelemetry/tracing"
 )
 
 var DB *gorm.DB
 
-func getDBConfigByEnv(env string) string {
-	var user, password, host, port, name string
-
-	switch env {
-	case "dev":
-		user = os.Getenv("DEV_DB_USER")
-		password = os.Getenv("DEV_DB_PASSWORD")
-		host = os.Getenv("DEV_DB_HOST")
-		port = os.Getenv("DEV_DB_PORT")
-		name = os.Getenv("DEV_DB_NAME")
-	case "qc":
-		user = os.Getenv("QC_DB_USER")
-		password = os.Getenv("QC_DB_PASSWORD")
-		host = os.Getenv("QC_DB_HOST")
-		port = os.Getenv("QC_DB_PORT")
-		name = os.Getenv("QC_DB_NAME")
-	case "prod":
-		user = os.Getenv("PROD_DB_USER")
-		password = os.Getenv("PROD_DB_PASSWORD")
-		host = os.Getenv("PROD_DB_HOST")
-		port = os.Getenv("PROD_DB_PORT")
-		name = os.Getenv("PROD_DB_NAME")
-	default:
-		log.Fatalf("Unknown environment: %s", env)
-	}
+// gormWriter chuyển log của GORM (query chậm, lỗi) sang slog
+type gormWriter struct{}
 
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=require TimeZone=Asia/Ho_Chi_Minh",
-		host, user, password, name, port)
-	println(dsn)
-	return dsn
+func (gormWriter) Printf(format string, args ...interface{}) {
+	slog.Warn(fmt.Sprintf(format, args...), "component", "gorm")
 }
 
-func ConnectDB() {
+func ConnectDB(cfg DBConfig) {
 	var err error
-	env := os.Getenv("ENV")
-	dsn := getDBConfigByEnv(env)
-
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	slog.Info("Kết nối db", "dsn", cfg.RedactedDSN())
+
+	DB, err = gorm.Open(postgres.Open(cfg.DSN()), &gorm.Config{
+		Logger: logger.New(gormWriter{}, logger.Config{
+			SlowThreshold:             200 * time.Millisecond,
+			LogLevel:                  logger.Warn,
+			IgnoreRecordNotFoundError: true,
+			// Không ghi giá trị tham số (mật khẩu, token...) vào log
+			ParameterizedQueries: true,
+		}),
+	})
 	if err != nil {
-		log.Fatalf("Fail to connect to db : %v", err)
+		slog.Error("Không thể kết nối db", "error", err)
+		os.Exit(1)
+	}
+
+	// Mỗi query tạo một span, không ghi giá trị tham số; metric DB đã có ở /metrics
+	if err := DB.Use(tracing.NewPlugin(tracing.WithoutQueryVariables(), tracing.WithoutMetrics())); err != nil {
+		slog.Error("Không thể bật tracing cho GORM", "error", err)
 	}
 
-	fmt.Println("Successfully connected to db")
+	slog.Info("Kết nối db thành công")
 }
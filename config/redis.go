@@ -1,43 +1,32 @@
 package config
 
 import (
-	"context"
-	"log"
-	"os"
+	"log/slog"
+	"time"
 
-	"github.com/joho/godotenv"
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 )
 
-var Ctx = context.Background()
-
-// Hàm nạp biến môi trường từ tệp `.env`
-func loadEnv() {
-	err := godotenv.Load()
-	if err != nil {
-		log.Println("Không thể nạp file .env, sử dụng biến môi trường hệ thống nếu có")
+// NewRedisClient tạo client Redis mà không kiểm tra kết nối, trả về nil nếu không cấu hình REDIS_ADDR.
+// Timeout ngắn để khi Redis gặp sự cố, request rơi về DB nhanh thay vì bị treo.
+func NewRedisClient(cfg RedisConfig) *redis.Client {
+	if cfg.Addr == "" {
+		return nil
 	}
-}
-
-// Hàm kết nối đến Redis
-func ConnectRedis() (*redis.Client, error) {
-	// Nạp biến môi trường từ tệp `.env`
-	loadEnv()
-
-	// Khởi tạo client Redis với các tùy chọn
-	RDB := redis.NewClient(&redis.Options{
-		Addr:     os.Getenv("REDIS_ADDR"),
-		Username: os.Getenv("REDIS_USER"),
-		Password: os.Getenv("REDIS_PASSWORD"),
-		DB:       0,
+	client := redis.NewClient(&redis.Options{
+		Addr:         cfg.Addr,
+		Username:     cfg.Username,
+		Password:     cfg.Password.Value(),
+		DB:           cfg.DB,
+		DialTimeout:  2 * time.Second,
+		ReadTimeout:  time.Second,
+		WriteTimeout: time.Second,
+		MaxRetries:   1,
 	})
-
-	// Kiểm tra kết nối
-	res, err := RDB.Ping(Ctx).Result()
-	if err != nil {
-		return nil, err
+	// Span cho từng lệnh, không ghi tham số vì giá trị cache có thể chứa dữ liệu người dùng
+	if err := redisotel.InstrumentTracing(client, redisotel.WithDBStatement(false)); err != nil {
+		slog.Error("Không thể bật tracing cho Redis", "error", err)
 	}
-
-	log.Println("Kết nối Redis thành công:", res)
-	return RDB, nil
+	return client
 }
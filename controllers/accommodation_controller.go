@@ -1,17 +1,14 @@
 package controllers
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
-	"log"
 	"net/http"
-	"net/url"
-	"new/config"
+	"new/apperrors"
 	"new/models"
+	"new/repositories"
 	"new/services"
-	"os"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -21,18 +18,60 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+type AccommodationController struct {
+	Accommodations repositories.AccommodationRepository
+	Users          repositories.UserRepository
+	RatePlans      repositories.RatePlanRepository
+	Benefits       repositories.BenefitRepository
+	Galleries      repositories.GalleryRepository
+	Geocoder       services.Geocoder
+	Cache          services.Cache
+	Storage        services.Storage
+}
+
+func NewAccommodationController(accommodations repositories.AccommodationRepository, users repositories.UserRepository, ratePlans repositories.RatePlanRepository, benefits repositories.BenefitRepository, galleries repositories.GalleryRepository, geocoder services.Geocoder, cache services.Cache, storage services.Storage) AccommodationController {
+	return AccommodationController{Accommodations: accommodations, Users: users, RatePlans: ratePlans, Benefits: benefits, Galleries: galleries, Geocoder: geocoder, Cache: cache, Storage: storage}
+}
+
+// pinnedLocation đọc tọa độ chủ chỗ ở tự ghim, nil nếu không gửi
+func pinnedLocation(longitude, latitude float64) (*services.Coordinates, error) {
+	if longitude == 0 && latitude == 0 {
+		return nil, nil
+	}
+	coordinates := services.Coordinates{Longitude: longitude, Latitude: latitude}
+	if !coordinates.Valid() {
+		return nil, apperrors.BadRequest("Tọa độ không hợp lệ")
+	}
+	return &coordinates, nil
+}
+
+// locate lấy tọa độ theo địa chỉ của chỗ ở; không tìm được thì giữ tọa độ cũ vì tọa độ không bắt buộc
+func (a AccommodationController) locate(ctx context.Context, accommodation *models.Accommodation) {
+	address := services.Address{
+		Street:   accommodation.Address,
+		Ward:     accommodation.Ward,
+		District: accommodation.District,
+		Province: accommodation.Province,
+	}
+	coordinates, err := a.Geocoder.Geocode(ctx, address)
+	if err != nil {
+		services.Logger(ctx).Warn("Không tìm được tọa độ chỗ ở", "address", address.String(), "error", err)
+		return
+	}
+	accommodation.Longitude = coordinates.Longitude
+	accommodation.Latitude = coordinates.Latitude
+}
+
 type AccommodationRequest struct {
 	ID               uint             `json:"id"`
 	Type             int              `json:"type"`
 	Name             string           `json:"name"`
 	Address          string           `json:"address"`
 	Avatar           string           `json:"avatar"`
-	Img              json.RawMessage  `json:"img" gorm:"type:json"`
 	ShortDescription string           `json:"shortDescription"`
 	Description      string           `json:"description"`
 	Status           int              `json:"status"`
 	Num              int              `json:"num"`
-	Furniture        json.RawMessage  `json:"furniture" gorm:"type:json"`
 	Benefits         []models.Benefit `json:"benefits" gorm:"many2many:accommodation_benefits;"`
 	People           int              `json:"people"`
 	Price            int              `json:"price"`
@@ -43,6 +82,14 @@ type AccommodationRequest struct {
 	Ward             string           `json:"ward"`
 	Longitude        float64          `json:"longitude"`
 	Latitude         float64          `json:"latitude"`
+	LocationManual   *bool            `json:"locationManual"` // true: ghim tọa độ hiện tại, false: bỏ ghim và lấy lại tọa độ theo địa chỉ
+	// Thư viện ảnh và nội thất, gửi mảng để thay toàn bộ; nhận cả dạng cũ là mảng URL/tên
+	Img       []models.Media         `json:"img"`
+	Furniture []models.FurnitureItem `json:"furniture"`
+	// Chính sách khách thêm, trường không gửi (null) thì giữ nguyên
+	MaxExtraGuests  *int `json:"maxExtraGuests"`
+	ExtraAdultPrice *int `json:"extraAdultPrice"`
+	ExtraChildPrice *int `json:"extraChildPrice"`
 }
 
 type Actor struct {
@@ -99,268 +146,98 @@ type AccommodationDetailResponse struct {
 	Price            int              `json:"price"`
 	NumBed           int              `json:"numBed"`
 	NumTolet         int              `json:"numTolet"`
-	Furniture        json.RawMessage  `json:"furniture" gorm:"type:json"`
-	Img              json.RawMessage  `json:"img"`
 	Benefits         []models.Benefit `json:"benefits"`
 	Rates            []RateResponse   `json:"rates"`
 	TimeCheckOut     string           `json:"timeCheckOut"`
 	TimeCheckIn      string           `json:"timeCheckIn"`
 	Longitude        float64          `json:"longitude"`
 	Latitude         float64          `json:"latitude"`
+	ModerationReason string           `json:"moderationReason,omitempty"` // Lý do từ chối/tạm ngưng
+	// Thư viện ảnh theo thứ tự hiển thị và nội thất
+	Img       []models.Media         `json:"img"`
+	Furniture []models.FurnitureItem `json:"furniture"`
+	models.ExtraGuestPolicy
 }
 
-func GetAllAccommodations(c *gin.Context) {
+func (a AccommodationController) GetAllAccommodations(c *gin.Context) {
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Authorization header is missing"})
+		c.Error(apperrors.Unauthorized("Authorization header is missing"))
 		return
 	}
 
 	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 	currentUserID, currentUserRole, err := GetUserIDFromToken(tokenString)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Invalid token"})
+		c.Error(apperrors.Unauthorized("Invalid token"))
 		return
 	}
 
-	// Tạo cache key dựa trên vai trò và user_id
-	var cacheKey string
-	if currentUserRole == 2 {
-		cacheKey = fmt.Sprintf("accommodations:admin:%d", currentUserID)
-	} else if currentUserRole == 3 {
-		cacheKey = fmt.Sprintf("accommodations:receptionist:%d", currentUserID)
-	} else {
-		cacheKey = "accommodations:all"
-	}
-
-	// Kết nối Redis
-	rdb, err := config.ConnectRedis()
+	pageReq, err := parsePageRequest(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể kết nối Redis"})
+		c.Error(apperrors.BadRequest(err.Error()))
 		return
 	}
 
-	var allAccommodations []models.Accommodation
-
-	// Lấy dữ liệu từ Redis
-	if err := services.GetFromRedis(config.Ctx, rdb, cacheKey, &allAccommodations); err != nil || len(allAccommodations) == 0 {
-		tx := config.DB.Model(&models.Accommodation{}).
-			Preload("Rooms").
-			Preload("Rates").
-			Preload("Benefits").
-			Preload("User").
-			Preload("User.Banks")
-		if currentUserRole == 2 {
-			//Lấy data theo vai trò Admin (Role = 2)
-			tx = tx.Where("user_id = ?", currentUserID)
-		} else if currentUserRole == 3 {
-			//Lấy data theo vai trò Receptionist (Role = 3)
-			var adminID int
-			if err := config.DB.Model(&models.User{}).Select("admin_id").Where("id = ?", currentUserID).Scan(&adminID).Error; err != nil {
-				c.JSON(http.StatusForbidden, gin.H{"code": 0, "mess": "Không có quyền truy cập"})
-				return
-			}
-			tx = tx.Where("user_id = ?", adminID)
-		}
-
-		// Lấy dữ liệu từ DB
-		if err := tx.Find(&allAccommodations).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể lấy danh sách chỗ ở"})
-			return
-		}
-
-		// Lưu dữ liệu vào Redis
-		if err := services.SetToRedis(config.Ctx, rdb, cacheKey, allAccommodations, time.Hour); err != nil {
-			log.Printf("Lỗi khi lưu danh sách chỗ ở vào Redis: %v", err)
-		}
+	// Phạm vi dữ liệu theo vai trò: admin (role 2) xem của mình, lễ tân (role 3) xem của admin quản lý
+	ownerID, ok := listOwner(c, a.Users, currentUserID, currentUserRole)
+	if !ok {
+		return
 	}
-
-	// Áp dụng filter từ dữ liệu cache
-	typeFilter := c.Query("type")
-	statusFilter := c.Query("status")
-	nameFilter := c.Query("name")
-	numBedFilter := c.Query("numBed")
-	numToletFilter := c.Query("numTolet")
-	peopleFilter := c.Query("people")
-	provinceFilter := c.Query("province")
-
-	filteredAccommodations := make([]models.Accommodation, 0)
-	for _, acc := range allAccommodations {
-		if typeFilter != "" {
-			parsedTypeFilter, err := strconv.Atoi(typeFilter)
-			if err == nil && acc.Type != parsedTypeFilter {
-				continue
-			}
-		}
-		if statusFilter != "" {
-			parsedStatusFilter, err := strconv.Atoi(statusFilter)
-			if err == nil && acc.Status != parsedStatusFilter {
-				continue
-			}
-		}
-		if provinceFilter != "" {
-			decodedProvinceFilter, _ := url.QueryUnescape(provinceFilter)
-			if !strings.Contains(strings.ToLower(acc.Name), strings.ToLower(decodedProvinceFilter)) {
-				continue
-			}
-		}
-		if nameFilter != "" {
-			decodedNameFilter, _ := url.QueryUnescape(nameFilter)
-			if !strings.Contains(strings.ToLower(acc.Name), strings.ToLower(decodedNameFilter)) {
-				continue
-			}
-		}
-		if numBedFilter != "" {
-			numBed, _ := strconv.Atoi(numBedFilter)
-			if acc.NumBed != numBed {
-				continue
-			}
-		}
-		if numToletFilter != "" {
-			numTolet, _ := strconv.Atoi(numToletFilter)
-			if acc.NumTolet != numTolet {
-				continue
-			}
-		}
-		if peopleFilter != "" {
-			people, _ := strconv.Atoi(peopleFilter)
-			if acc.People != people {
-				continue
-			}
+	var cachePrefix string
+	if currentUserRole == 2 {
+		cachePrefix = fmt.Sprintf("accommodations:admin:%d", currentUserID)
+	} else if currentUserRole == 3 {
+		cachePrefix = fmt.Sprintf("accommodations:receptionist:%d", currentUserID)
+	} else {
+		cachePrefix = "accommodations:all"
+	}
+
+	filter := repositories.AccommodationFilter{
+		OwnerID:  ownerID,
+		Type:     queryInt(c, "type"),
+		Status:   queryInt(c, "status"),
+		Province: c.Query("province"),
+		Name:     c.Query("name"),
+		NumBed:   queryInt(c, "numBed"),
+		NumTolet: queryInt(c, "numTolet"),
+		People:   queryInt(c, "people"),
+	}
+
+	cacheKey := services.PageCacheKey(cachePrefix, filter, pageReq)
+	var page cachedPage[AccommodationResponse]
+	if !cacheGet(c.Request.Context(), a.Cache, cacheKey, &page) {
+		accommodations, info, err := a.Accommodations.List(c.Request.Context(), filter, pageReq)
+		if err != nil {
+			c.Error(apperrors.Internal("Không thể lấy danh sách chỗ ở").Wrap(err))
+			return
 		}
-		filteredAccommodations = append(filteredAccommodations, acc)
-	}
-	total := len(filteredAccommodations)
 
-	//Xếp theo update mới nhất
-	sort.Slice(filteredAccommodations, func(i, j int) bool {
-		return filteredAccommodations[i].UpdateAt.After(filteredAccommodations[j].UpdateAt)
-	})
-	// Pagination
-	page := 0
-	limit := 10
-	if pageStr := c.Query("page"); pageStr != "" {
-		if parsedPage, err := strconv.Atoi(pageStr); err == nil && parsedPage >= 0 {
-			page = parsedPage
-		}
-	}
-	if limitStr := c.Query("limit"); limitStr != "" {
-		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
-			limit = parsedLimit
+		page = cachedPage[AccommodationResponse]{Data: make([]AccommodationResponse, 0, len(accommodations)), Pagination: info}
+		for _, acc := range accommodations {
+			page.Data = append(page.Data, toAccommodationResponse(acc))
 		}
-	}
-
-	start := page * limit
-	end := start + limit
-	if start >= len(filteredAccommodations) {
-		filteredAccommodations = []models.Accommodation{}
-	} else if end > len(filteredAccommodations) {
-		filteredAccommodations = filteredAccommodations[start:]
-	} else {
-		filteredAccommodations = filteredAccommodations[start:end]
-	}
-
-	// Chuẩn bị response
-	accommodationsResponse := make([]AccommodationResponse, 0)
-	for _, acc := range filteredAccommodations {
-		accommodationsResponse = append(accommodationsResponse, AccommodationResponse{
-			ID:               acc.ID,
-			Type:             acc.Type,
-			Name:             acc.Name,
-			Address:          acc.Address,
-			CreateAt:         acc.CreateAt,
-			UpdateAt:         acc.UpdateAt,
-			Avatar:           acc.Avatar,
-			ShortDescription: acc.ShortDescription,
-			Status:           acc.Status,
-			Num:              acc.Num,
-			People:           acc.People,
-			Price:            acc.Price,
-			NumBed:           acc.NumBed,
-			NumTolet:         acc.NumTolet,
-			Province:         acc.Province,
-			District:         acc.District,
-			Ward:             acc.Ward,
-			Longitude:        acc.Longitude,
-			Latitude:         acc.Latitude,
-		})
+		cacheSetWithTTL(c.Request.Context(), a.Cache, cacheKey, page, pageCacheTTL, accommodationCacheTags(accommodations)...)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"code": 1,
-		"mess": "Lấy danh sách chỗ ở thành công",
-		"data": accommodationsResponse,
-		"pagination": gin.H{
-			"page":  page,
-			"limit": limit,
-			"total": total,
-		},
+		"code":       1,
+		"mess":       "Lấy danh sách chỗ ở thành công",
+		"data":       page.Data,
+		"pagination": page.Pagination,
 	})
 }
 
-func GetAllAccommodationsForUser(c *gin.Context) {
-	// Các tham số filter
-	typeFilter := c.Query("type")
-	provinceFilter := c.Query("province")
-	districtFilter := c.Query("district")
-	benefitFilterRaw := c.Query("benefitId")
-	numFilter := c.Query("num")
-	statusFilter := c.Query("status")
-	nameFilter := c.Query("name")
-	numBedFilter := c.Query("numBed")
-	numToletFilter := c.Query("numTolet")
-	peopleFilter := c.Query("people")
-
-	pageStr := c.Query("page")
-	limitStr := c.Query("limit")
-
-	page := 0
-	limit := 10
-
-	if pageStr != "" {
-		if parsedPage, err := strconv.Atoi(pageStr); err == nil && parsedPage >= 0 {
-			page = parsedPage
-		}
-	}
-
-	if limitStr != "" {
-		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
-			limit = parsedLimit
-		}
-	}
-
-	// Redis cache key
-	cacheKey := "accommodations:all"
-	rdb, err := config.ConnectRedis()
+func (a AccommodationController) GetAllAccommodationsForUser(c *gin.Context) {
+	pageReq, err := parsePageRequest(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể kết nối Redis"})
+		c.Error(apperrors.BadRequest(err.Error()))
 		return
 	}
 
-	var allAccommodations []models.Accommodation
-
-	// Lấy dữ liệu từ Redis
-	if err := services.GetFromRedis(config.Ctx, rdb, cacheKey, &allAccommodations); err != nil || len(allAccommodations) == 0 {
-		// Nếu không có dữ liệu trong Redis, lấy từ Database
-		if err := config.DB.Model(&models.Accommodation{}).
-			Preload("Rooms").
-			Preload("Rates").
-			Preload("Benefits").
-			Preload("User").
-			Preload("User.Banks").
-			Find(&allAccommodations).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể lấy danh sách chỗ ở"})
-			return
-		}
-
-		// Lưu dữ liệu vào Redis
-		if err := services.SetToRedis(config.Ctx, rdb, cacheKey, allAccommodations, time.Hour); err != nil {
-			log.Printf("Lỗi khi lưu danh sách chỗ ở vào Redis: %v", err)
-		}
-	}
 	benefitIDs := make([]int, 0)
 	//chuyển đổi thành slice int (query mặc đinh string)
-	if benefitFilterRaw != "" {
+	if benefitFilterRaw := c.Query("benefitId"); benefitFilterRaw != "" {
 		// Loại bỏ các ký tự "[" và "]"
 		benefitFilterRaw = strings.Trim(benefitFilterRaw, "[]")
 
@@ -375,269 +252,129 @@ func GetAllAccommodationsForUser(c *gin.Context) {
 		}
 	}
 
-	// Áp dụng filter trên dữ liệu từ Redis
-	filteredAccommodations := make([]models.Accommodation, 0)
-	for _, acc := range allAccommodations {
-		if typeFilter != "" {
-			parsedTypeFilter, err := strconv.Atoi(typeFilter)
-			if err == nil && acc.Type != parsedTypeFilter {
-				continue
-			}
-		}
-
-		if statusFilter != "" {
-			parsedStatusFilter, err := strconv.Atoi(statusFilter)
-			if err == nil && acc.Status != parsedStatusFilter {
-				continue
-			}
-		}
-
-		if provinceFilter != "" {
-			decodedProvinceFilter, _ := url.QueryUnescape(provinceFilter)
-			if !strings.Contains(strings.ToLower(acc.Province), strings.ToLower(decodedProvinceFilter)) {
-				continue
-			}
-		}
-
-		if districtFilter != "" {
-			decodedDistrictFilter, _ := url.QueryUnescape(districtFilter)
-			if !strings.Contains(strings.ToLower(acc.District), strings.ToLower(decodedDistrictFilter)) {
-				continue
-			}
-		}
-		if nameFilter != "" {
-			decodedNameFilter, _ := url.QueryUnescape(nameFilter)
-			if !strings.Contains(strings.ToLower(acc.Name), strings.ToLower(decodedNameFilter)) {
-				continue
-			}
-		}
-
-		if numBedFilter != "" {
-			numBed, _ := strconv.Atoi(numBedFilter)
-			if acc.NumBed != numBed {
-				continue
-			}
-		}
-		if numToletFilter != "" {
-			numTolet, _ := strconv.Atoi(numToletFilter)
-			if acc.NumTolet != numTolet {
-				continue
-			}
-		}
-		if peopleFilter != "" {
-			people, _ := strconv.Atoi(peopleFilter)
-			if acc.People != people {
-				continue
-			}
+	// Danh sách công khai chỉ gồm chỗ ở đang đăng
+	published := models.ListingPublished
+	filter := repositories.AccommodationFilter{
+		Type:         queryInt(c, "type"),
+		Status:       &published,
+		Province:     c.Query("province"),
+		District:     c.Query("district"),
+		Name:         c.Query("name"),
+		NumBed:       queryInt(c, "numBed"),
+		NumTolet:     queryInt(c, "numTolet"),
+		People:       queryInt(c, "people"),
+		Num:          queryInt(c, "num"),
+		BenefitIDs:   benefitIDs,
+		WithBenefits: true,
+	}
+
+	cacheKey := services.PageCacheKey("accommodations:public", filter, pageReq)
+	var page cachedPage[AccommodationResponse]
+	if !cacheGet(c.Request.Context(), a.Cache, cacheKey, &page) {
+		accommodations, info, err := a.Accommodations.List(c.Request.Context(), filter, pageReq)
+		if err != nil {
+			c.Error(apperrors.Internal("Không thể lấy danh sách chỗ ở").Wrap(err))
+			return
 		}
 
-		if numFilter != "" {
-			num, _ := strconv.Atoi(numFilter)
-			if acc.Num != num {
-				continue
-			}
-		}
-		if len(benefitIDs) > 0 {
-			match := false
-			for _, benefit := range acc.Benefits {
-				for _, id := range benefitIDs {
-					if benefit.Id == id {
-						match = true
-						break
-					}
-				}
-				if match {
-					break
-				}
-			}
-			if !match {
-				continue
-			}
+		page = cachedPage[AccommodationResponse]{Data: make([]AccommodationResponse, 0, len(accommodations)), Pagination: info}
+		for _, acc := range accommodations {
+			response := toAccommodationResponse(acc)
+			response.Benefits = acc.Benefits
+			page.Data = append(page.Data, response)
 		}
-		filteredAccommodations = append(filteredAccommodations, acc)
-	}
-
-	//Xếp theo update mới nhất
-	sort.Slice(filteredAccommodations, func(i, j int) bool {
-		return filteredAccommodations[i].UpdateAt.After(filteredAccommodations[j].UpdateAt)
-	})
-
-	// Pagination
-	// Lấy total sau khi lọc
-	total := len(filteredAccommodations)
-
-	// Áp dụng phân trang
-	start := page * limit
-	end := start + limit
-	if start >= total {
-		filteredAccommodations = []models.Accommodation{}
-	} else if end > total {
-		filteredAccommodations = filteredAccommodations[start:]
-	} else {
-		filteredAccommodations = filteredAccommodations[start:end]
-	}
-
-	// Chuẩn bị response
-	accommodationsResponse := make([]AccommodationResponse, 0)
-	for _, acc := range filteredAccommodations {
-		accommodationsResponse = append(accommodationsResponse, AccommodationResponse{
-			ID:               acc.ID,
-			Type:             acc.Type,
-			Name:             acc.Name,
-			Address:          acc.Address,
-			CreateAt:         acc.CreateAt,
-			UpdateAt:         acc.UpdateAt,
-			Avatar:           acc.Avatar,
-			ShortDescription: acc.ShortDescription,
-			Status:           acc.Status,
-			Num:              acc.Num,
-			People:           acc.People,
-			Price:            acc.Price,
-			NumBed:           acc.NumBed,
-			NumTolet:         acc.NumTolet,
-			Province:         acc.Province,
-			District:         acc.District,
-			Ward:             acc.Ward,
-			Benefits:         acc.Benefits,
-			Longitude:        acc.Longitude,
-			Latitude:         acc.Latitude,
-		})
+		// Danh sách có kèm tiện ích nên cũng làm mới khi tiện ích thay đổi
+		tags := append(accommodationCacheTags(accommodations), services.ListTag("benefit"))
+		cacheSetWithTTL(c.Request.Context(), a.Cache, cacheKey, page, pageCacheTTL, tags...)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"code": 1,
-		"mess": "Lấy danh sách chỗ ở thành công",
-		"data": accommodationsResponse,
-		"pagination": gin.H{
-			"page":  page,
-			"limit": limit,
-			"total": total,
-		},
+		"code":       1,
+		"mess":       "Lấy danh sách chỗ ở thành công",
+		"data":       page.Data,
+		"pagination": page.Pagination,
 	})
 }
 
-// Hàm kiểm tra benefit
-func normalizeBenefitName(name string) string {
-	name = strings.ToLower(name)
-	fields := strings.Fields(name)
-	normalized := strings.Join(fields, " ")
-	return normalized
-}
-
-func CreateAccommodation(c *gin.Context) {
+func (a AccommodationController) CreateAccommodation(c *gin.Context) {
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Authorization header is missing"})
+		c.Error(apperrors.Unauthorized("Authorization header is missing"))
 		return
 	}
 
 	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-	currentUserID, currentUserRole, err := GetUserIDFromToken(tokenString)
+	currentUserID, _, err := GetUserIDFromToken(tokenString)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Invalid token"})
+		c.Error(apperrors.Unauthorized("Invalid token"))
 		return
 	}
 	var newAccommodation models.Accommodation
-	var user models.User
-	if err := config.DB.First(&user, currentUserID).Error; err != nil {
+	user, err := a.Users.FindByID(c.Request.Context(), currentUserID)
+	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Người dùng không tồn tại"})
+			c.Error(apperrors.NotFound("Người dùng không tồn tại"))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Lỗi khi kiểm tra người dùng", "details": err.Error()})
+		c.Error(apperrors.Internal("Lỗi khi kiểm tra người dùng").Wrap(err))
 		return
 	}
 	newAccommodation.UserID = currentUserID
 	newAccommodation.User = user
 	if err := c.ShouldBindJSON(&newAccommodation); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Dữ liệu đầu vào không hợp lệ", "details": err.Error()})
+		c.Error(apperrors.Validation(err))
 		return
 	}
 
 	if err := newAccommodation.ValidateType(); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": err.Error()})
+		c.Error(apperrors.BadRequest(err.Error()))
 		return
 	}
 
-	if err := newAccommodation.ValidateStatus(); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": err.Error()})
+	// Chỗ ở mới luôn là bản nháp của người gọi, trạng thái và thông tin duyệt chỉ do ChangeAccommodationStatus ghi
+	newAccommodation.UserID = currentUserID
+	newAccommodation.Status = models.ListingDraft
+	newAccommodation.ModerationReason = ""
+	newAccommodation.SubmittedAt = nil
+	newAccommodation.ReviewedBy = nil
+	newAccommodation.ReviewedAt = nil
+
+	if err := newAccommodation.ExtraGuestPolicy.Validate(); err != nil {
+		c.Error(apperrors.BadRequest(err.Error()))
 		return
 	}
 
-	imgJSON, err := json.Marshal(newAccommodation.Img)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể mã hóa hình ảnh", "details": err.Error()})
+	if err := prepareGallery(newAccommodation.Img, newAccommodation.Furniture); err != nil {
+		c.Error(err)
 		return
 	}
 
-	newAccommodation.Img = imgJSON
-
-	var benefits []models.Benefit
-
-	for _, benefit := range newAccommodation.Benefits {
-		if benefit.Id != 0 {
-
-			benefits = append(benefits, benefit)
-		} else {
-
-			normalizedBenefitName := normalizeBenefitName(benefit.Name)
-
-			newBenefit := models.Benefit{Name: normalizedBenefitName}
-			if err := config.DB.Where("LOWER(TRIM(name)) = ?", normalizedBenefitName).FirstOrCreate(&newBenefit).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "message": "Không thể tạo mới tiện ích", "details": err.Error()})
-				return
-			}
-
-			benefits = append(benefits, newBenefit)
-		}
+	benefits, err := resolveBenefits(c.Request.Context(), a.Benefits, newAccommodation.Benefits, models.BenefitScopeProperty)
+	if err != nil {
+		c.Error(err)
+		return
 	}
 
 	newAccommodation.Benefits = benefits
 
-	longitude, latitude, err := services.GetCoordinatesFromAddress(
-		newAccommodation.Address,
-		newAccommodation.District,
-		newAccommodation.Province,
-		newAccommodation.Ward,
-		os.Getenv("MAPBOX_KEY"),
-	)
-
-	newAccommodation.Longitude = longitude
-	newAccommodation.Latitude = latitude
-
-	if err := config.DB.Create(&newAccommodation).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể tạo chỗ ở", "details": err.Error()})
+	// Chủ chỗ ở đã ghim tọa độ thì không cần geocode
+	pin, err := pinnedLocation(newAccommodation.Longitude, newAccommodation.Latitude)
+	if err != nil {
+		c.Error(err)
 		return
 	}
-	// Xử lý Redis cache
-	rdb, redisErr := config.ConnectRedis()
-	if redisErr == nil {
-		switch currentUserRole {
-		case 1: // Super Admin
-			_ = services.DeleteFromRedis(config.Ctx, rdb, "accommodations:all")
-		case 2: // Admin
-			// Xóa cache của admin
-			adminCacheKey := fmt.Sprintf("accommodations:admin:%d", currentUserID)
-			_ = services.DeleteFromRedis(config.Ctx, rdb, "accommodations:all")
-			_ = services.DeleteFromRedis(config.Ctx, rdb, adminCacheKey)
-			var receptionistIDs []int
-			if err := config.DB.Model(&models.User{}).Where("admin_id = ?", currentUserID).Pluck("id", &receptionistIDs).Error; err == nil {
-				for _, receptionistID := range receptionistIDs {
-					receptionistCacheKey := fmt.Sprintf("accommodations:receptionist:%d", receptionistID)
-					_ = services.DeleteFromRedis(config.Ctx, rdb, receptionistCacheKey)
-				}
-			}
-		case 3: // Receptionist
-			var adminID int
-			_ = services.DeleteFromRedis(config.Ctx, rdb, "accommodations:all")
-			if err := config.DB.Model(&models.User{}).Select("admin_id").Where("id = ?", currentUserID).Scan(&adminID).Error; err == nil {
-				receptionistCacheKey := fmt.Sprintf("accommodations:receptionist:%d", currentUserID)
-				adminCacheKey := fmt.Sprintf("accommodations:admin:%d", adminID)
-				_ = services.DeleteFromRedis(config.Ctx, rdb, receptionistCacheKey)
-				_ = services.DeleteFromRedis(config.Ctx, rdb, adminCacheKey)
-			}
-		}
+	newAccommodation.LocationManual = pin != nil
+	if pin == nil {
+		a.locate(c.Request.Context(), &newAccommodation)
 	}
+
+	if err := a.Accommodations.Create(c.Request.Context(), &newAccommodation); err != nil {
+		c.Error(apperrors.Internal("Không thể tạo chỗ ở").Wrap(err))
+		return
+	}
+	recordAudit(c, "accommodation.create", "accommodation", newAccommodation.ID, nil, newAccommodation)
+	// Xóa cache các danh sách chỗ ở
+	invalidateCache(c.Request.Context(), a.Cache, services.ListTag("accommodation"))
 	response := AccommodationDetailResponse{
 		ID:               newAccommodation.ID,
 		Type:             newAccommodation.Type,
@@ -649,6 +386,7 @@ func CreateAccommodation(c *gin.Context) {
 		ShortDescription: newAccommodation.ShortDescription,
 		Status:           newAccommodation.Status,
 		Num:              newAccommodation.Num,
+		Img:              newAccommodation.Img,
 		Furniture:        newAccommodation.Furniture,
 		People:           newAccommodation.People,
 		Price:            newAccommodation.Price,
@@ -662,6 +400,8 @@ func CreateAccommodation(c *gin.Context) {
 		Ward:             newAccommodation.Ward,
 		Longitude:        newAccommodation.Longitude,
 		Latitude:         newAccommodation.Latitude,
+		ModerationReason: newAccommodation.ModerationReason,
+		ExtraGuestPolicy: newAccommodation.ExtraGuestPolicy,
 
 		User: Actor{
 			Name:        user.Name,
@@ -673,81 +413,57 @@ func CreateAccommodation(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Tạo chỗ ở thành công", "data": response})
 }
 
-func GetAccommodationDetail(c *gin.Context) {
-	accommodationId := c.Param("id")
-
-	// Kết nối Redis
-	rdb, redisErr := config.ConnectRedis()
-	if redisErr != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể kết nối Redis"})
+func (a AccommodationController) GetAccommodationDetail(c *gin.Context) {
+	accommodationId := paramID(c, "id")
+
+	// Cache công khai chỉ chứa chỗ ở đang đăng
+	cacheKey := fmt.Sprintf("accommodation:%d:detail", accommodationId)
+	var response AccommodationDetailResponse
+	if cacheGet(c.Request.Context(), a.Cache, cacheKey, &response) {
+		c.JSON(http.StatusOK, gin.H{
+			"code": 1,
+			"mess": "Lấy thông tin chỗ ở thành công (từ cache)",
+			"data": response,
+		})
 		return
 	}
 
-	// Key cache cho tất cả accommodations
-	cacheKey := "allaccommodations:all"
-
-	// Lấy danh sách accommodations từ cache
-	var cachedAccommodations []models.Accommodation
-	if err := services.GetFromRedis(config.Ctx, rdb, cacheKey, &cachedAccommodations); err == nil {
-		for _, acc := range cachedAccommodations {
-			if fmt.Sprintf("%d", acc.ID) == accommodationId {
-				// Tạo response từ cache
-				response := AccommodationDetailResponse{
-					ID:               acc.ID,
-					Type:             acc.Type,
-					Name:             acc.Name,
-					Address:          acc.Address,
-					CreateAt:         acc.CreateAt,
-					UpdateAt:         acc.UpdateAt,
-					Avatar:           acc.Avatar,
-					Img:              acc.Img,
-					ShortDescription: acc.ShortDescription,
-					Description:      acc.Description,
-					Status:           acc.Status,
-					Num:              acc.Num,
-					People:           acc.People,
-					Price:            acc.Price,
-					NumBed:           acc.NumBed,
-					NumTolet:         acc.NumTolet,
-					Furniture:        acc.Furniture,
-					Benefits:         acc.Benefits,
-					TimeCheckIn:      acc.TimeCheckIn,
-					TimeCheckOut:     acc.TimeCheckOut,
-					Province:         acc.Province,
-					District:         acc.District,
-					Ward:             acc.Ward,
-					Longitude:        acc.Longitude,
-					Latitude:         acc.Latitude,
-					User: Actor{
-						Name:          acc.User.Name,
-						Email:         acc.User.Email,
-						PhoneNumber:   acc.User.PhoneNumber,
-						BankShortName: acc.User.Banks[0].BankShortName,
-						AccountNumber: acc.User.Banks[0].AccountNumber,
-						BankName:      acc.User.Banks[0].BankName,
-					},
-				}
-				c.JSON(http.StatusOK, gin.H{
-					"code": 1,
-					"mess": "Lấy thông tin chỗ ở thành công (từ cache)",
-					"data": response,
-				})
-				return
-			}
+	accommodation, err := a.Accommodations.FindByID(c.Request.Context(), accommodationId)
+	if err != nil {
+		c.Error(apperrors.NotFound("Chỗ ở không tồn tại"))
+		return
+	}
+	// Chỗ ở chưa đăng chỉ chủ, lễ tân của chủ và quản trị xem được, người khác nhận 404 như chỗ ở không tồn tại.
+	// Kết quả lưu ở key cache riêng để không lẫn vào cache công khai.
+	if accommodation.Status != models.ListingPublished {
+		currentUserID, currentUserRole := auditActor(c)
+		manages, err := canManageAccommodation(c.Request.Context(), a.Users, accommodation, currentUserID, currentUserRole)
+		if err != nil {
+			c.Error(apperrors.Internal("Lỗi khi kiểm tra quyền").Wrap(err))
+			return
+		}
+		if !manages {
+			c.Error(apperrors.NotFound("Chỗ ở không tồn tại"))
+			return
+		}
+		cacheKey += ":owner"
+		if cacheGet(c.Request.Context(), a.Cache, cacheKey, &response) {
+			c.JSON(http.StatusOK, gin.H{
+				"code": 1,
+				"mess": "Lấy thông tin chỗ ở thành công (từ cache)",
+				"data": response,
+			})
+			return
 		}
 	}
 
-	// Nếu không tìm thấy trong cache, truy vấn từ database
-	var accommodation models.Accommodation
-	if err := config.DB.Preload("Rooms").
-		Preload("Rates").
-		Preload("Benefits").
-		Preload("User").Preload("User.Banks").First(&accommodation, accommodationId).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"code": 0, "mess": "Chỗ ở không tồn tại"})
+	accommodation, err = a.Accommodations.FindDetail(c.Request.Context(), accommodationId)
+	if err != nil {
+		c.Error(apperrors.NotFound("Chỗ ở không tồn tại"))
 		return
 	}
 
-	response := AccommodationDetailResponse{
+	response = AccommodationDetailResponse{
 		ID:               accommodation.ID,
 		Type:             accommodation.Type,
 		Name:             accommodation.Name,
@@ -773,15 +489,24 @@ func GetAccommodationDetail(c *gin.Context) {
 		Ward:             accommodation.Ward,
 		Longitude:        accommodation.Longitude,
 		Latitude:         accommodation.Latitude,
+		ModerationReason: accommodation.ModerationReason,
+		ExtraGuestPolicy: accommodation.ExtraGuestPolicy,
 		User: Actor{
-			Name:          accommodation.User.Name,
-			Email:         accommodation.User.Email,
-			PhoneNumber:   accommodation.User.PhoneNumber,
-			BankShortName: accommodation.User.Banks[0].BankShortName,
-			AccountNumber: accommodation.User.Banks[0].AccountNumber,
-			BankName:      accommodation.User.Banks[0].BankName,
+			Name:        accommodation.User.Name,
+			Email:       accommodation.User.Email,
+			PhoneNumber: accommodation.User.PhoneNumber,
 		},
 	}
+	if len(accommodation.User.Banks) > 0 {
+		response.User.BankShortName = accommodation.User.Banks[0].BankShortName
+		response.User.AccountNumber = accommodation.User.Banks[0].AccountNumber
+		response.User.BankName = accommodation.User.Banks[0].BankName
+	}
+
+	cacheSet(c.Request.Context(), a.Cache, cacheKey, response,
+		services.EntityTag("accommodation", accommodation.ID),
+		services.EntityTag("user", accommodation.UserID),
+		services.ListTag("benefit"))
 
 	c.JSON(http.StatusOK, gin.H{
 		"code": 1,
@@ -790,52 +515,44 @@ func GetAccommodationDetail(c *gin.Context) {
 	})
 }
 
-func UpdateAccommodation(c *gin.Context) {
+func (a AccommodationController) UpdateAccommodation(c *gin.Context) {
 	var request AccommodationRequest
-	authHeader := c.GetHeader("Authorization")
-	if authHeader == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Authorization header is missing"})
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Error(apperrors.Validation(err))
 		return
 	}
 
-	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-	currentUserID, currentUserRole, err := GetUserIDFromToken(tokenString)
+	accommodation, err := a.Accommodations.FindWithRelations(c.Request.Context(), request.ID)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Invalid token"})
+		c.Error(apperrors.NotFound("Chỗ ở không tồn tại"))
 		return
 	}
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Dữ liệu đầu vào không hợp lệ", "details": err.Error()})
+	// Sửa chỗ ở có thể thay thư viện ảnh và xóa file cũ khỏi nơi lưu trữ, chỉ người quản lý chỗ ở được sửa
+	if !requireAccommodationManager(c, a.Users, accommodation) {
 		return
 	}
+	before := accommodation
 
-	var accommodation models.Accommodation
-
-	if err := config.DB.Preload("User").Preload("Rooms").Preload("Rates").First(&accommodation, request.ID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"code": 0, "mess": "Chỗ ở không tồn tại"})
+	if err := prepareGallery(request.Img, request.Furniture); err != nil {
+		c.Error(err)
 		return
 	}
 
-	// Xử lý trường Img
-	imgJSON, err := json.Marshal(request.Img)
+	pin, err := pinnedLocation(request.Longitude, request.Latitude)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể mã hóa hình ảnh", "details": err.Error()})
+		c.Error(err)
 		return
 	}
-
-	// Xử lý trường Furniture
-	furnitureJson, err := json.Marshal(request.Furniture)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể mã hóa nội thất", "details": err.Error()})
-		return
+	// Client gửi lại tọa độ đang lưu mỗi lần sửa, chỉ coi là ghim khi yêu cầu ghim hoặc tọa độ thực sự đổi
+	if pin != nil && (request.LocationManual == nil || !*request.LocationManual) &&
+		pin.Longitude == accommodation.Longitude && pin.Latitude == accommodation.Latitude {
+		pin = nil
 	}
-	longitude, latitude, err := services.GetCoordinatesFromAddress(
-		request.Address,
-		request.District,
-		request.Province,
-		request.Ward,
-		os.Getenv("MAPBOX_KEY"),
-	)
+	// Chỉ geocode lại khi địa chỉ thực sự đổi
+	addressChanged := request.Address != "" && request.Address != accommodation.Address ||
+		request.Ward != "" && request.Ward != accommodation.Ward ||
+		request.District != "" && request.District != accommodation.District ||
+		request.Province != "" && request.Province != accommodation.Province
 
 	if request.Type != -1 {
 		accommodation.Type = request.Type
@@ -861,18 +578,6 @@ func UpdateAccommodation(c *gin.Context) {
 		accommodation.Description = request.Description
 	}
 
-	if request.Status != 0 {
-		accommodation.Status = request.Status
-	}
-
-	if len(request.Img) > 0 {
-		accommodation.Img = imgJSON
-	}
-
-	if len(request.Furniture) > 0 {
-		accommodation.Furniture = furnitureJson
-	}
-
 	if request.People != 0 {
 		accommodation.People = request.People
 	}
@@ -897,67 +602,68 @@ func UpdateAccommodation(c *gin.Context) {
 		accommodation.Ward = request.Ward
 	}
 
-	if longitude != 0 && latitude != 0 {
-		accommodation.Longitude = longitude
-		accommodation.Latitude = latitude
+	switch {
+	case pin != nil:
+		accommodation.Longitude = pin.Longitude
+		accommodation.Latitude = pin.Latitude
+		accommodation.LocationManual = true
+	case request.LocationManual != nil && *request.LocationManual:
+		// Ghim tọa độ đang có
+		accommodation.LocationManual = services.Coordinates{Longitude: accommodation.Longitude, Latitude: accommodation.Latitude}.Valid()
+	case request.LocationManual != nil:
+		accommodation.LocationManual = false
+		a.locate(c.Request.Context(), &accommodation)
+	case addressChanged && !accommodation.LocationManual:
+		a.locate(c.Request.Context(), &accommodation)
 	}
-	var benefits []models.Benefit
-	for _, benefit := range request.Benefits {
-		if benefit.Id != 0 {
-			benefits = append(benefits, benefit)
-		} else {
 
-			newBenefit := models.Benefit{Name: benefit.Name}
-			if err := config.DB.Where("name = ?", benefit.Name).FirstOrCreate(&newBenefit).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "message": "Không thể tạo mới tiện ích", "details": err.Error()})
-				return
-			}
-			benefits = append(benefits, newBenefit)
-		}
+	if request.MaxExtraGuests != nil {
+		accommodation.MaxExtraGuests = *request.MaxExtraGuests
+	}
+
+	if request.ExtraAdultPrice != nil {
+		accommodation.ExtraAdultPrice = *request.ExtraAdultPrice
 	}
 
-	if err := config.DB.Model(&accommodation).Association("Benefits").Replace(benefits); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể cập nhật tiện ích", "details": err.Error()})
+	if request.ExtraChildPrice != nil {
+		accommodation.ExtraChildPrice = *request.ExtraChildPrice
+	}
+
+	if err := accommodation.ExtraGuestPolicy.Validate(); err != nil {
+		c.Error(apperrors.BadRequest(err.Error()))
+		return
+	}
+	benefits, err := resolveBenefits(c.Request.Context(), a.Benefits, request.Benefits, models.BenefitScopeProperty)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	if err := config.DB.Save(&accommodation).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể cập nhật chỗ ở", "details": err.Error()})
+	if err := a.Accommodations.ReplaceBenefits(c.Request.Context(), &accommodation, benefits); err != nil {
+		c.Error(apperrors.Internal("Không thể cập nhật tiện ích").Wrap(err))
 		return
 	}
-	// Xử lý Redis cache
-	rdb, redisErr := config.ConnectRedis()
-	if redisErr == nil {
-		switch currentUserRole {
-		case 1: // Super Admin
-			_ = services.DeleteFromRedis(config.Ctx, rdb, "accommodations:all")
-			_ = services.DeleteFromRedis(config.Ctx, rdb, "benefits:all")
-		case 2: // Admin
-			// Xóa cache của admin
-			adminCacheKey := fmt.Sprintf("accommodations:admin:%d", currentUserID)
-			_ = services.DeleteFromRedis(config.Ctx, rdb, adminCacheKey)
-			_ = services.DeleteFromRedis(config.Ctx, rdb, "accommodations:all")
-			_ = services.DeleteFromRedis(config.Ctx, rdb, "benefits:all")
-			var receptionistIDs []int
-			if err := config.DB.Model(&models.User{}).Where("admin_id = ?", currentUserID).Pluck("id", &receptionistIDs).Error; err == nil {
-				for _, receptionistID := range receptionistIDs {
-					receptionistCacheKey := fmt.Sprintf("accommodations:receptionist:%d", receptionistID)
-					_ = services.DeleteFromRedis(config.Ctx, rdb, receptionistCacheKey)
-				}
-			}
-		case 3: // Receptionist
-			var adminID int
-			_ = services.DeleteFromRedis(config.Ctx, rdb, "benefits:all")
-			_ = services.DeleteFromRedis(config.Ctx, rdb, "accommodations:all")
-			if err := config.DB.Model(&models.User{}).Select("admin_id").Where("id = ?", currentUserID).Scan(&adminID).Error; err == nil {
-				receptionistCacheKey := fmt.Sprintf("accommodations:receptionist:%d", currentUserID)
-				adminCacheKey := fmt.Sprintf("accommodations:admin:%d", adminID)
-				_ = services.DeleteFromRedis(config.Ctx, rdb, receptionistCacheKey)
-				_ = services.DeleteFromRedis(config.Ctx, rdb, adminCacheKey)
-			}
 
-		}
+	if err := a.Accommodations.Save(c.Request.Context(), &accommodation); err != nil {
+		c.Error(apperrors.Internal("Không thể cập nhật chỗ ở").Wrap(err))
+		return
+	}
+	owner := models.AccommodationGallery(accommodation.ID)
+	if err := replaceGallery(c.Request.Context(), a.Galleries, a.Storage, owner, request.Img, request.Furniture); err != nil {
+		c.Error(err)
+		return
+	}
+	if accommodation.Img, err = a.Galleries.ListMedia(c.Request.Context(), owner); err != nil {
+		c.Error(apperrors.Internal("Không thể lấy hình ảnh").Wrap(err))
+		return
+	}
+	if accommodation.Furniture, err = a.Galleries.ListFurniture(c.Request.Context(), owner); err != nil {
+		c.Error(apperrors.Internal("Không thể lấy nội thất").Wrap(err))
+		return
 	}
+	recordAudit(c, "accommodation.update", "accommodation", accommodation.ID, before, accommodation)
+	// Xóa cache các danh sách chứa chỗ ở này và danh sách tiện ích
+	invalidateCache(c.Request.Context(), a.Cache, services.EntityTag("accommodation", accommodation.ID), services.ListTag("benefit"))
 	response := AccommodationDetailResponse{
 		ID:               accommodation.ID,
 		Type:             accommodation.Type,
@@ -980,73 +686,135 @@ func UpdateAccommodation(c *gin.Context) {
 		TimeCheckOut:     accommodation.TimeCheckOut,
 		Longitude:        accommodation.Longitude,
 		Latitude:         accommodation.Latitude,
+		ModerationReason: accommodation.ModerationReason,
+		ExtraGuestPolicy: accommodation.ExtraGuestPolicy,
 	}
 
 	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Cập nhật chỗ ở thành công", "data": response})
 }
 
-func ChangeAccommodationStatus(c *gin.Context) {
+// ChangeAccommodationStatus godoc
+// @Summary Chuyển trạng thái duyệt tin của chỗ ở
+// @Description Chủ chỗ ở gửi duyệt (0/3 → 1), đăng (2 → 4) và gỡ đăng (4 → 2); quản trị viên cao nhất duyệt (1 → 2),
+// @Description từ chối (1 → 3, kèm lý do), tạm ngưng (2/4 → 5, kèm lý do) và mở lại (5 → 4). Chủ được email báo kết quả.
+// @Tags accommodation
+// @Accept json
+// @Produce json
+// @Success 200 {object} gin.H {"code": 1, "mess": "...", "data": models.Accommodation}
+// @Router /accommodationStatus [put]
+func (a AccommodationController) ChangeAccommodationStatus(c *gin.Context) {
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Authorization header is missing"})
+		c.Error(apperrors.Unauthorized("Authorization header is missing"))
 		return
 	}
 
 	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 	currentUserID, currentUserRole, err := GetUserIDFromToken(tokenString)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Invalid token"})
+		c.Error(apperrors.Unauthorized("Invalid token"))
 		return
 	}
 
 	var input struct {
-		ID     uint `json:"id"`
-		Status int  `json:"status"`
+		ID     uint   `json:"id"`
+		Status int    `json:"status"`
+		Reason string `json:"reason"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Dữ liệu đầu vào không hợp lệ"})
+		c.Error(apperrors.BadRequest("Dữ liệu đầu vào không hợp lệ"))
+		return
+	}
+
+	accommodation, err := a.Accommodations.FindByID(c.Request.Context(), input.ID)
+	if err != nil {
+		c.Error(apperrors.NotFound("Chỗ ở không tồn tại"))
 		return
 	}
 
-	var accommodation models.Accommodation
+	if _, ok := models.ListingStatusNames[input.Status]; !ok {
+		c.Error(apperrors.BadRequest("Trạng thái không hợp lệ"))
+		return
+	}
 
-	if err := config.DB.First(&accommodation, input.ID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"code": 0, "mess": "Chỗ ở không tồn tại"})
+	transition, ok := models.FindListingTransition(accommodation.Status, input.Status)
+	if !ok {
+		c.Error(apperrors.Conflict(fmt.Sprintf("Không thể chuyển chỗ ở từ trạng thái %q sang %q",
+			models.ListingStatusNames[accommodation.Status], models.ListingStatusNames[input.Status])))
+		return
+	}
+	// Quản trị viên cao nhất làm được mọi bước, chủ chỗ ở chỉ làm các bước của chủ trên chỗ ở của mình
+	manages, err := canManageAccommodation(c.Request.Context(), a.Users, accommodation, currentUserID, currentUserRole)
+	if err != nil {
+		c.Error(apperrors.Internal("Lỗi khi kiểm tra quyền").Wrap(err))
+		return
+	}
+	if currentUserRole != 1 && (transition.AdminOnly || currentUserRole != 2 || !manages) {
+		c.Error(apperrors.Forbidden("Bạn không có quyền thực hiện thao tác này"))
+		return
+	}
+	input.Reason = strings.TrimSpace(input.Reason)
+	if transition.NeedsReason && input.Reason == "" {
+		c.Error(apperrors.BadRequest("Vui lòng nhập lý do"))
 		return
 	}
 
+	before := accommodation
+	now := time.Now()
 	accommodation.Status = input.Status
-	if err := config.DB.Save(&accommodation).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể thay đổi trạng thái chỗ ở"})
+	switch {
+	case transition.Action == "submit":
+		accommodation.SubmittedAt = &now
+	case transition.AdminOnly:
+		accommodation.ReviewedBy = &currentUserID
+		accommodation.ReviewedAt = &now
+		accommodation.ModerationReason = input.Reason
+	}
+	if err := a.Accommodations.Save(c.Request.Context(), &accommodation); err != nil {
+		c.Error(apperrors.Internal("Không thể thay đổi trạng thái chỗ ở").Wrap(err))
 		return
 	}
-	// Xử lý Redis cache
-	rdb, redisErr := config.ConnectRedis()
-	if redisErr == nil {
-		switch currentUserRole {
-		case 1: // Super Admin
-			_ = services.DeleteFromRedis(config.Ctx, rdb, "accommodations:all")
-		case 2: // Admin
-			// Xóa cache của admin
-			adminCacheKey := fmt.Sprintf("accommodations:admin:%d", currentUserID)
-			_ = services.DeleteFromRedis(config.Ctx, rdb, adminCacheKey)
-			var receptionistIDs []int
-			if err := config.DB.Model(&models.User{}).Where("admin_id = ?", currentUserID).Pluck("id", &receptionistIDs).Error; err == nil {
-				for _, receptionistID := range receptionistIDs {
-					receptionistCacheKey := fmt.Sprintf("accommodations:receptionist:%d", receptionistID)
-					_ = services.DeleteFromRedis(config.Ctx, rdb, receptionistCacheKey)
-				}
-			}
-		case 3: // Receptionist
-			var adminID int
-			if err := config.DB.Model(&models.User{}).Select("admin_id").Where("id = ?", currentUserID).Scan(&adminID).Error; err == nil {
-				receptionistCacheKey := fmt.Sprintf("accommodations:receptionist:%d", currentUserID)
-				adminCacheKey := fmt.Sprintf("accommodations:admin:%d", adminID)
-				_ = services.DeleteFromRedis(config.Ctx, rdb, receptionistCacheKey)
-				_ = services.DeleteFromRedis(config.Ctx, rdb, adminCacheKey)
-			}
+	recordAudit(c, "accommodation."+transition.Action, "accommodation", accommodation.ID, before, accommodation)
+	if transition.Notify {
+		if owner, err := a.Users.FindByID(c.Request.Context(), accommodation.UserID); err == nil {
+			services.NotifyListingDecision(c.Request.Context(), owner.Email, accommodation.Name, accommodation.Status, input.Reason)
 		}
 	}
+	// Trạng thái quyết định chỗ ở và các phòng có nằm trong danh sách công khai hay không
+	invalidateCache(c.Request.Context(), a.Cache, services.EntityTag("accommodation", accommodation.ID), services.ListTag("accommodation"), services.ListTag("room"))
 	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Thay đổi trạng thái chỗ ở thành công", "data": accommodation})
 }
+
+func toAccommodationResponse(acc models.Accommodation) AccommodationResponse {
+	return AccommodationResponse{
+		ID:               acc.ID,
+		Type:             acc.Type,
+		Name:             acc.Name,
+		Address:          acc.Address,
+		CreateAt:         acc.CreateAt,
+		UpdateAt:         acc.UpdateAt,
+		Avatar:           acc.Avatar,
+		ShortDescription: acc.ShortDescription,
+		Status:           acc.Status,
+		Num:              acc.Num,
+		People:           acc.People,
+		Price:            acc.Price,
+		NumBed:           acc.NumBed,
+		NumTolet:         acc.NumTolet,
+		Province:         acc.Province,
+		District:         acc.District,
+		Ward:             acc.Ward,
+		Longitude:        acc.Longitude,
+		Latitude:         acc.Latitude,
+	}
+}
+
+// Tag cache cho danh sách chỗ ở: tag từng chỗ ở và chủ sở hữu
+func accommodationCacheTags(accommodations []models.Accommodation) []string {
+	tags := []string{services.ListTag("accommodation")}
+	for _, acc := range accommodations {
+		tags = append(tags, services.EntityTag("accommodation", acc.ID), services.EntityTag("user", acc.UserID))
+	}
+	return tags
+}
@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"new/apperrors"
+	"new/services"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Lấy người thực hiện từ AuthMiddleware, nếu route không dùng middleware thì đọc token
+func auditActor(c *gin.Context) (uint, int) {
+	if id, ok := c.Get("currentUserID"); ok {
+		role, _ := c.Get("currentUserRole")
+		userID, _ := id.(uint)
+		userRole, _ := role.(int)
+		return userID, userRole
+	}
+
+	tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if tokenString == "" {
+		return 0, 0
+	}
+	userID, role, err := GetUserIDFromToken(tokenString)
+	if err != nil {
+		return 0, 0
+	}
+	return userID, role
+}
+
+// recordAudit ghi nhật ký cho thao tác thay đổi dữ liệu.
+// Lỗi ghi nhật ký chỉ được log, không làm hỏng request đã thành công.
+func recordAudit(c *gin.Context, action string, entityType string, entityID interface{}, before, after interface{}) {
+	actorID, actorRole := auditActor(c)
+	entry := services.AuditEntry{
+		ActorID:    actorID,
+		ActorRole:  actorRole,
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   fmt.Sprint(entityID),
+		IP:         c.ClientIP(),
+	}
+	if err := services.RecordAudit(entry, before, after); err != nil {
+		services.Logger(c.Request.Context()).Error("Lỗi khi ghi nhật ký kiểm toán",
+			"action", action, "entity_type", entityType, "entity_id", entityID, "error", err)
+	}
+}
+
+// GetAuditLogs godoc
+// @Summary Xem nhật ký kiểm toán
+// @Description Lọc theo actorId, action, entityType, entityId, fromDate, toDate (dd/mm/yyyy), phân trang page/limit
+// @Tags audit
+// @Produce json
+// @Success 200 {object} gin.H {"code": 1, "mess": "Lấy nhật ký kiểm toán thành công", "data": []models.AuditLog{}}
+// @Router /audit [get]
+func GetAuditLogs(c *gin.Context) {
+	filter := services.AuditFilter{
+		Action:     c.Query("action"),
+		EntityType: c.Query("entityType"),
+		EntityID:   c.Query("entityId"),
+		Page:       0,
+		Limit:      20,
+	}
+
+	if actorStr := c.Query("actorId"); actorStr != "" {
+		actorID, err := strconv.ParseUint(actorStr, 10, 64)
+		if err != nil {
+			c.Error(apperrors.BadRequest("actorId không hợp lệ"))
+			return
+		}
+		id := uint(actorID)
+		filter.ActorID = &id
+	}
+
+	if fromStr := c.Query("fromDate"); fromStr != "" {
+		from, err := time.ParseInLocation(layout, fromStr, time.Local)
+		if err != nil {
+			c.Error(apperrors.BadRequest("fromDate không hợp lệ, định dạng dd/mm/yyyy"))
+			return
+		}
+		filter.From = &from
+	}
+	if toStr := c.Query("toDate"); toStr != "" {
+		to, err := time.ParseInLocation(layout, toStr, time.Local)
+		if err != nil {
+			c.Error(apperrors.BadRequest("toDate không hợp lệ, định dạng dd/mm/yyyy"))
+			return
+		}
+		// Bao gồm cả ngày toDate
+		to = to.AddDate(0, 0, 1)
+		filter.To = &to
+	}
+
+	if parsedPage, err := strconv.Atoi(c.Query("page")); err == nil && parsedPage >= 0 {
+		filter.Page = parsedPage
+	}
+	if parsedLimit, err := strconv.Atoi(c.Query("limit")); err == nil && parsedLimit > 0 {
+		filter.Limit = parsedLimit
+	}
+	if filter.Limit > 100 {
+		filter.Limit = 100
+	}
+
+	logs, total, err := services.ListAuditLogs(filter)
+	if err != nil {
+		c.Error(apperrors.Internal("Không thể lấy nhật ký kiểm toán").Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":       1,
+		"mess":       "Lấy nhật ký kiểm toán thành công",
+		"data":       logs,
+		"pagination": gin.H{"page": filter.Page, "limit": filter.Limit, "total": total},
+	})
+}
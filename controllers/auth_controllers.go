@@ -1,23 +1,20 @@
 package controllers
 
 import (
-	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"net/http"
+	"new/apperrors"
 	"new/config"
 	"new/models"
 	"new/services"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
-	"google.golang.org/api/idtoken"
 	"gorm.io/gorm"
 )
 
@@ -32,6 +29,11 @@ type LoginInput struct {
 	Password   string `json:"password" binding:"required"`
 }
 
+type ResetPasswordInput struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
 type Bank struct {
 	BankName      string `json:"bankName"`
 	AccountNumber string `json:"accountNumber"`
@@ -75,30 +77,30 @@ type UserLoginResponse struct {
 func Login(c *gin.Context) {
 	var input LoginInput
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": err.Error()})
+		c.Error(apperrors.Validation(err))
 		return
 	}
 
 	var user models.User
 	if err := config.DB.Preload("Banks").Where("email = ? OR phone_number = ?", input.Identifier, input.Identifier).First(&user).Error; err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Email hoặc mật khẩu không hợp lệ"})
+		c.Error(apperrors.BadRequest("Email hoặc mật khẩu không hợp lệ"))
 		return
 	}
 
 	if user.Role == 0 {
 		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Email hoặc mật khẩu không hợp lệ"})
+			c.Error(apperrors.BadRequest("Email hoặc mật khẩu không hợp lệ"))
 			return
 		}
 	} else {
 
-		if err := services.LoginCode(user); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"code": 2, "mess": err.Error()})
+		if err := services.LoginCode(c.Request.Context(), user); err != nil {
+			c.Error(err)
 			return
 		}
 
 		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Email hoặc mật khẩu không hợp lệ"})
+			c.Error(apperrors.BadRequest("Email hoặc mật khẩu không hợp lệ"))
 			return
 		}
 	}
@@ -110,7 +112,7 @@ func Login(c *gin.Context) {
 
 	accessToken, err := services.GenerateToken(userInfo, 60*24*3, true)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": err.Error()})
+		c.Error(apperrors.BadRequest(err.Error()))
 		return
 	}
 
@@ -150,38 +152,26 @@ func Logout(c *gin.Context) {
 
 		c.SetCookie(cookie.Name, "", -1, "/", "", cookie.Secure, cookie.HttpOnly)
 	}
-	redisClient, err := config.ConnectRedis()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể kết nối Redis"})
-		return
-	}
-
-	err = redisClient.FlushDB(config.Ctx).Err()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể xóa dữ liệu Redis"})
-		return
-	}
-
 	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Đăng xuất thành công"})
 }
 
 func VerifyEmail(c *gin.Context) {
 	code := c.Query("token")
 	if code == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Cần mã xác thực"})
+		c.Error(apperrors.BadRequest("Cần mã xác thực"))
 		return
 	}
 
 	var user models.User
 	result := config.DB.Where("code = ?", code).First(&user)
 	if result.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": result.Error.Error()})
+		c.Error(apperrors.BadRequest(result.Error.Error()))
 		return
 	}
 
-	// Kiểm tra xem mã xác thực đã hết hạn chưa (5 phút)
-	if time.Since(user.CodeCreatedAt) > 5*time.Minute {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Mã xác thực đã hết hạn. Vui lòng yêu cầu mã mới."})
+	// Kiểm tra mã xác thực và hạn dùng
+	if err := services.VerifyUserCode(user, code); err != nil {
+		c.Error(apperrors.BadRequest(err.Error()))
 		return
 	}
 
@@ -195,13 +185,13 @@ func VerifyEmail(c *gin.Context) {
 func RegisterUser(c *gin.Context) {
 	var input models.User
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": err.Error()})
+		c.Error(apperrors.Validation(err))
 		return
 	}
 
-	user, err := services.CreateUser(input)
+	user, err := services.CreateUser(c.Request.Context(), input)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": err.Error()})
+		c.Error(apperrors.BadRequest(err.Error()))
 		return
 	}
 
@@ -214,20 +204,20 @@ func ResendVerificationCode(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": err.Error()})
+		c.Error(apperrors.Validation(err))
 		return
 	}
 
 	var user models.User
 	result := config.DB.Where("email = ? OR phone_number = ?", input.Identifier, input.Identifier).First(&user)
 	if result.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Người dùng không tồn tại."})
+		c.Error(apperrors.NotFound("Người dùng không tồn tại."))
 		return
 	}
 
-	err := services.RegenerateVerificationCode(user.ID)
+	err := services.RegenerateVerificationCode(c.Request.Context(), user.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Lỗi trong quá trình tạo mã xác thực mới."})
+		c.Error(apperrors.Internal("Lỗi trong quá trình tạo mã xác thực mới.").Wrap(err))
 		return
 	}
 
@@ -240,44 +230,48 @@ func ForgetPassword(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": err.Error()})
+		c.Error(apperrors.Validation(err))
 		return
 	}
 
+	// Phản hồi giống nhau dù tài khoản có tồn tại hay không để không dò được tài khoản
 	var user models.User
 	result := config.DB.Where("email = ? OR phone_number = ?", input.Identifier, input.Identifier).First(&user)
-	if result.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Người dùng không tồn tại."})
+	if result.Error != nil && !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		c.Error(apperrors.Internal("Không thể gửi liên kết đặt lại mật khẩu").Wrap(result.Error))
 		return
 	}
 
-	err := services.ResetPass(user)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể gửi mã xác nhận: " + err.Error()})
-		return
+	if result.Error == nil {
+		if err := services.ResetPass(c.Request.Context(), user); err != nil {
+			c.Error(apperrors.Internal("Không thể gửi liên kết đặt lại mật khẩu").Wrap(err))
+			return
+		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Mã xác nhận để đặt lại mật khẩu đã được gửi đến email của bạn."})
+	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Nếu tài khoản tồn tại, liên kết đặt lại mật khẩu đã được gửi đến email của tài khoản."})
 }
 
 func ResetPassword(c *gin.Context) {
-	var input LoginInput
+	var input ResetPasswordInput
 
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": err.Error()})
+		c.Error(apperrors.Validation(err))
 		return
 	}
 
-	var user models.User
-	result := config.DB.Where("email = ? OR phone_number = ?", input.Identifier, input.Identifier).First(&user)
-	if result.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Người dùng không tồn tại."})
+	if err := services.ValidatePasswordStrength(input.Password); err != nil {
+		c.Error(apperrors.BadRequest(err.Error()))
 		return
 	}
 
-	err := services.NewPass(user, input.Password)
+	err := services.ResetPasswordWithToken(c.Request.Context(), input.Token, input.Password)
+	if errors.Is(err, services.ErrInvalidResetToken) {
+		c.Error(apperrors.BadRequest(err.Error()))
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể đổi mật khẩu: " + err.Error()})
+		c.Error(apperrors.Internal("Không thể đổi mật khẩu").Wrap(err))
 		return
 	}
 
@@ -291,24 +285,19 @@ func VerifyCode(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Dữ liệu không hợp lệ", "error": err.Error()})
+		c.Error(apperrors.Validation(err))
 		return
 	}
 
 	var user models.User
 	result := config.DB.Where("email = ?", input.Email).First(&user)
 	if result.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Không tìm thấy người dùng với email này"})
-		return
-	}
-
-	if user.Code != input.Code {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Mã xác thực không hợp lệ"})
+		c.Error(apperrors.NotFound("Không tìm thấy người dùng với email này"))
 		return
 	}
 
-	if time.Since(user.CodeCreatedAt) > 5*time.Minute {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Mã xác thực đã hết hạn. Vui lòng yêu cầu mã mới."})
+	if err := services.VerifyUserCode(user, input.Code); err != nil {
+		c.Error(apperrors.BadRequest(err.Error()))
 		return
 	}
 
@@ -387,57 +376,75 @@ func GetIDFromToken(tokenString string) (uint, error) {
 	return uint(userID), nil
 }
 
-type GoogleUser struct {
-	Name          string `json:"name"`
-	Email         string `json:"email"`
-	VerifiedEmail bool   `json:"verified_email"`
-	Picture       string `json:"picture"`
+type SocialLoginInput struct {
+	Token string `json:"token" binding:"required"`
 }
 
-// AuthGoogle function để xử lý yêu cầu xác thực từ Google
-func AuthGoogle(c *gin.Context) {
-	var token struct {
-		TokenId string `json:"tokenId"`
+type PhoneNumberInput struct {
+	PhoneNumber string `json:"phoneNumber" binding:"required"`
+}
+
+// Trả lỗi xác thực mạng xã hội theo đúng mã HTTP
+func socialAuthError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrUnknownProvider):
+		c.Error(apperrors.NotFound(err.Error()))
+	case errors.Is(err, services.ErrInvalidSocialAuth):
+		c.Error(apperrors.Unauthorized(err.Error()))
+	case errors.Is(err, services.ErrStaffSocialLogin):
+		c.Error(apperrors.Forbidden(err.Error()))
+	case errors.Is(err, services.ErrIdentityLinked), errors.Is(err, services.ErrLastLoginMethod), errors.Is(err, services.ErrUnverifiedAccount):
+		c.Error(apperrors.Conflict(err.Error()))
+	default:
+		c.Error(apperrors.BadGateway(err.Error()).Wrap(err))
 	}
+}
 
-	// Bind dữ liệu token từ request
-	if err := c.ShouldBindJSON(&token); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+func verifySocialToken(c *gin.Context, provider string, token string) (*services.ExternalIdentity, error) {
+	p, err := services.GetIdentityProvider(provider)
+	if err != nil {
+		return nil, err
 	}
-	// Xác minh tokenId từ Google
-	payload, err := verifyGoogleIDToken(token.TokenId)
+	return p.Verify(c.Request.Context(), token)
+}
+
+func socialLogin(c *gin.Context, provider string, token string) {
+	identity, err := verifySocialToken(c, provider, token)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid Google token"})
+		socialAuthError(c, err)
 		return
 	}
-	// Lấy thông tin người dùng từ payload
-	googleUser := GoogleUser{
-		Name:          payload.Claims["name"].(string),
-		Email:         payload.Claims["email"].(string),
-		VerifiedEmail: payload.Claims["email_verified"].(bool),
-		Picture:       payload.Claims["picture"].(string),
-	}
-	// Kiểm tra nếu email chưa được xác thực
-	if !googleUser.VerifiedEmail {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Email has not been verified"})
+
+	user, err := services.LoginWithIdentity(identity)
+	if err != nil {
+		if errors.Is(err, services.ErrStaffSocialLogin) || errors.Is(err, services.ErrUnverifiedAccount) {
+			socialAuthError(c, err)
+			return
+		}
+		c.Error(apperrors.Internal("Không thể đăng nhập").Wrap(err))
 		return
 	}
 
-	user := models.User{}
-	result := config.DB.Where("email = ?", googleUser.Email).First(&user)
+	userInfo := services.UserInfo{
+		UserId: user.ID,
+		Role:   user.Role,
+	}
 
-	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-		user, err = services.CreateGoogleUser(googleUser.Name, googleUser.Email, googleUser.Picture)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create new user"})
-			return
-		}
-	} else if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query user: " + result.Error.Error()})
+	accessToken, err := services.GenerateToken(userInfo, 60*24*3, true)
+	if err != nil {
+		c.Error(apperrors.BadRequest(err.Error()))
 		return
 	}
 
+	var banks []Bank
+	for _, bank := range user.Banks {
+		banks = append(banks, Bank{
+			BankName:      bank.BankName,
+			AccountNumber: bank.AccountNumber,
+			BankShortName: bank.BankShortName,
+		})
+	}
+
 	userResponse := UserLoginResponse{
 		UserID:       user.ID,
 		UserName:     user.Name,
@@ -448,36 +455,130 @@ func AuthGoogle(c *gin.Context) {
 		UpdatedAt:    user.UpdatedAt,
 		CreatedAt:    user.CreatedAt,
 		UserAvatar:   user.Avatar,
+		UserBanks:    banks,
 		Gender:       user.Gender,
 		DateOfBirth:  user.DateOfBirth,
 	}
-	userInfo := services.UserInfo{
-		UserId: user.ID,
-		Role:   user.Role,
+
+	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Đăng nhập thành công", "data": gin.H{
+		"user_info":   userResponse,
+		"accessToken": accessToken,
+		// Tài khoản mạng xã hội cần bổ sung số điện thoại qua PUT /users/phone
+		"needPhoneNumber": user.PhoneNumber == "",
+	}})
+}
+
+// AuthSocial đăng nhập bằng token của nhà cung cấp (google, facebook, zalo)
+func AuthSocial(c *gin.Context) {
+	var input SocialLoginInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+
+	socialLogin(c, c.Param("provider"), input.Token)
+}
+
+// AuthGoogle giữ lại cho client cũ gửi tokenId
+func AuthGoogle(c *gin.Context) {
+	var input struct {
+		TokenId string `json:"tokenId" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+
+	socialLogin(c, models.ProviderGoogle, input.TokenId)
+}
+
+// LinkSocialAccount liên kết thêm phương thức đăng nhập cho người dùng hiện tại
+func LinkSocialAccount(c *gin.Context) {
+	var input SocialLoginInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+
+	identity, err := verifySocialToken(c, c.Param("provider"), input.Token)
+	if err != nil {
+		socialAuthError(c, err)
+		return
+	}
+
+	userID := c.MustGet("currentUserID").(uint)
+	if err := services.LinkIdentity(userID, identity); err != nil {
+		if errors.Is(err, services.ErrIdentityLinked) {
+			socialAuthError(c, err)
+			return
+		}
+		c.Error(err)
+		return
 	}
 
-	accessToken, err := services.GenerateToken(userInfo, 15, true)
+	recordAudit(c, "identity.link", "user", userID, nil, gin.H{"provider": identity.Provider})
+
+	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Liên kết tài khoản thành công"})
+}
+
+// UnlinkSocialAccount hủy liên kết phương thức đăng nhập của người dùng hiện tại
+func UnlinkSocialAccount(c *gin.Context) {
+	userID := c.MustGet("currentUserID").(uint)
+	err := services.UnlinkIdentity(userID, c.Param("provider"))
 	if err != nil {
-		log.Println("Error generating access token:", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.Error(apperrors.NotFound("Chưa liên kết tài khoản này"))
+		case errors.Is(err, services.ErrLastLoginMethod):
+			socialAuthError(c, err)
+		default:
+			c.Error(err)
+		}
+		return
+	}
+
+	recordAudit(c, "identity.unlink", "user", userID, gin.H{"provider": c.Param("provider")}, nil)
+
+	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Hủy liên kết tài khoản thành công"})
+}
+
+// GetLinkedAccounts trả về các phương thức đăng nhập đã liên kết
+func GetLinkedAccounts(c *gin.Context) {
+	var identities []models.UserIdentity
+	if err := config.DB.Where("user_id = ?", c.MustGet("currentUserID").(uint)).Find(&identities).Error; err != nil {
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"code": 1,
-		"message": "Login successful",
-		"data": gin.H{
-			"user_info":   userResponse,
-			"accessToken": accessToken,
-		},
-	})
+	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Lấy danh sách liên kết thành công", "data": identities})
 }
 
-// verifyGoogleIDToken function - Xác thực ID token từ Google
-func verifyGoogleIDToken(tokenId string) (*idtoken.Payload, error) {
-	clientID := os.Getenv("GOOGLE_CLIENT_ID")
-	payload, err := idtoken.Validate(context.Background(), tokenId, clientID)
+// UpdatePhoneNumber bổ sung hoặc đổi số điện thoại sau khi đăng ký bằng mạng xã hội
+func UpdatePhoneNumber(c *gin.Context) {
+	var input PhoneNumberInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+
+	user, err := services.AddPhoneNumber(c.MustGet("currentUserID").(uint), strings.TrimSpace(input.PhoneNumber))
 	if err != nil {
-		return nil, err
+		switch {
+		case errors.Is(err, services.ErrInvalidPhone):
+			c.Error(apperrors.BadRequest(err.Error()))
+		case errors.Is(err, services.ErrPhoneUsed):
+			c.Error(apperrors.Conflict(err.Error()))
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.Error(apperrors.NotFound("Người dùng không tồn tại"))
+		default:
+			c.Error(err)
+		}
+		return
 	}
-	return payload, nil
+
+	recordAudit(c, "user.phone", "user", user.ID, nil, gin.H{"phoneNumber": user.PhoneNumber})
+
+	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Cập nhật số điện thoại thành công", "data": gin.H{
+		"phone": user.PhoneNumber,
+	}})
 }
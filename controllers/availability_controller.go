@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"net/http"
+	"new/apperrors"
+	"new/models"
+	"new/services"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AvailabilityResponse là tình trạng có thể đặt của chỗ ở trong một kỳ lưu trú
+type AvailabilityResponse struct {
+	AccommodationID uint   `json:"accommodationId"`
+	CheckInDate     string `json:"checkInDate"`
+	CheckOutDate    string `json:"checkOutDate"`
+	// Còn ít nhất một phòng/hạng phòng (hoặc cả căn) có thể đặt
+	Available bool `json:"available"`
+	// Vi phạm quy định lưu trú của chỗ ở, áp dụng cho mọi phòng
+	Restrictions []services.StayViolation `json:"restrictions"`
+	Rooms        []RoomAvailability       `json:"rooms,omitempty"`
+	RoomTypes    []RoomTypeAvailability   `json:"roomTypes,omitempty"`
+}
+
+type RoomAvailability struct {
+	ID         uint   `json:"id"`
+	RoomName   string `json:"roomName"`
+	RoomTypeID *uint  `json:"roomTypeId,omitempty"`
+	Available  bool   `json:"available"`
+	// Vi phạm quy định lưu trú riêng của phòng
+	Restrictions []services.StayViolation `json:"restrictions"`
+}
+
+type RoomTypeAvailability struct {
+	ID        uint   `json:"id"`
+	Name      string `json:"name"`
+	Available int    `json:"available"` // Số phòng còn có thể đặt
+}
+
+// GetAvailability godoc
+// @Summary Lịch trống và quy định lưu trú của chỗ ở trong một kỳ lưu trú
+// @Tags order
+// @Produce json
+// @Param id path int true "ID chỗ ở"
+// @Param checkInDate query string true "Ngày nhận phòng (02/01/2006)"
+// @Param checkOutDate query string true "Ngày trả phòng (02/01/2006)"
+// @Success 200 {object} gin.H {"code": 1, "mess": "...", "data": AvailabilityResponse}
+// @Router /accommodation/{id}/availability [get]
+func (o OrderController) GetAvailability(c *gin.Context) {
+	ctx := c.Request.Context()
+	checkInDate, err := time.Parse("02/01/2006", c.Query("checkInDate"))
+	if err != nil {
+		c.Error(apperrors.BadRequest("Ngày nhận phòng không hợp lệ"))
+		return
+	}
+	checkOutDate, err := time.Parse("02/01/2006", c.Query("checkOutDate"))
+	if err != nil {
+		c.Error(apperrors.BadRequest("Ngày trả phòng không hợp lệ"))
+		return
+	}
+	if !checkOutDate.After(checkInDate) {
+		c.Error(apperrors.BadRequest("Ngày trả phòng phải sau ngày nhận phòng"))
+		return
+	}
+
+	accommodation, err := o.Accommodations.FindByID(ctx, paramID(c, "id"))
+	if err != nil {
+		c.Error(apperrors.NotFound("Chỗ ở không tồn tại"))
+		return
+	}
+
+	var rooms []models.Room
+	if accommodation.Type == 0 {
+		if rooms, err = o.Rooms.ListByAccommodation(ctx, accommodation.ID); err != nil {
+			c.Error(apperrors.Internal("Không thể lấy danh sách phòng").Wrap(err))
+			return
+		}
+	}
+	roomIDs := make([]uint, 0, len(rooms))
+	for _, room := range rooms {
+		roomIDs = append(roomIDs, room.RoomId)
+	}
+
+	restrictions, err := o.Restrictions.ForStay(ctx, accommodation.ID, roomIDs)
+	if err != nil {
+		c.Error(apperrors.Internal("Không thể lấy quy định lưu trú").Wrap(err))
+		return
+	}
+	// Tách quy định của chỗ ở và quy định riêng từng phòng
+	var accommodationRules []models.StayRestriction
+	roomRules := map[uint][]models.StayRestriction{}
+	for _, restriction := range restrictions {
+		if restriction.RoomID != nil {
+			roomRules[*restriction.RoomID] = append(roomRules[*restriction.RoomID], restriction)
+		} else {
+			accommodationRules = append(accommodationRules, restriction)
+		}
+	}
+
+	now := time.Now()
+	response := AvailabilityResponse{
+		AccommodationID: accommodation.ID,
+		CheckInDate:     checkInDate.Format("02/01/2006"),
+		CheckOutDate:    checkOutDate.Format("02/01/2006"),
+		Restrictions:    services.CheckStay(accommodationRules, checkInDate, checkOutDate, now),
+	}
+	if response.Restrictions == nil {
+		response.Restrictions = []services.StayViolation{}
+	}
+
+	if accommodation.Type != 0 {
+		booked, err := o.Orders.IsAccommodationBooked(ctx, accommodation.ID, checkInDate, checkOutDate)
+		if err != nil {
+			c.Error(apperrors.Internal("Lỗi kiểm tra trạng thái chỗ ở").Wrap(err))
+			return
+		}
+		response.Available = !booked
+	} else {
+		for _, room := range rooms {
+			booked, err := o.Orders.IsRoomBooked(ctx, room.RoomId, checkInDate, checkOutDate)
+			if err != nil {
+				c.Error(apperrors.Internal("Lỗi kiểm tra trạng thái phòng").Wrap(err))
+				return
+			}
+			item := RoomAvailability{
+				ID:           room.RoomId,
+				RoomName:     room.RoomName,
+				RoomTypeID:   room.RoomTypeID,
+				Restrictions: services.CheckStay(roomRules[room.RoomId], checkInDate, checkOutDate, now),
+			}
+			if item.Restrictions == nil {
+				item.Restrictions = []services.StayViolation{}
+			}
+			item.Available = !booked && len(item.Restrictions) == 0
+			response.Available = response.Available || item.Available
+			response.Rooms = append(response.Rooms, item)
+		}
+
+		roomTypes, err := o.RoomTypes.ListByAccommodation(ctx, accommodation.ID)
+		if err != nil {
+			c.Error(apperrors.Internal("Không thể lấy danh sách hạng phòng").Wrap(err))
+			return
+		}
+		for _, roomType := range roomTypes {
+			if roomType.Status != 0 {
+				continue
+			}
+			free, pending, err := roomTypeAvailability(ctx, o.RoomTypes, roomType.ID, checkInDate, checkOutDate)
+			if err != nil {
+				c.Error(apperrors.Internal("Lỗi kiểm tra trạng thái phòng").Wrap(err))
+				return
+			}
+			available := max(len(free)-pending, 0)
+			response.Available = response.Available || available > 0
+			response.RoomTypes = append(response.RoomTypes, RoomTypeAvailability{ID: roomType.ID, Name: roomType.Name, Available: available})
+		}
+	}
+	response.Available = response.Available && len(response.Restrictions) == 0
+
+	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Lấy lịch trống thành công", "data": response})
+}
@@ -2,6 +2,7 @@ package controllers
 
 import (
     "net/http"
+    "new/apperrors"
     "new/models"
     "strings"
     "new/config"
@@ -15,7 +16,7 @@ func CreateBank(c *gin.Context) {
     var bank models.BankFake
 
     if err := c.ShouldBindJSON(&bank); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Lỗi khi ràng buộc dữ liệu", "error": err.Error()})
+        c.Error(apperrors.Validation(err))
         return
     }
 
@@ -23,31 +24,31 @@ func CreateBank(c *gin.Context) {
 
     var existingBankByName models.BankFake
     if err := config.DB.Where("bank_name = ?", bank.BankName).First(&existingBankByName).Error; err == nil {
-        c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Ngân hàng đã tồn tại"})
+        c.Error(apperrors.BadRequest("Ngân hàng đã tồn tại"))
         return
     }
 
     var existingBankByShortName models.BankFake
     if err := config.DB.Where("bank_short_name = ?", bank.BankShortName).First(&existingBankByShortName).Error; err == nil {
-        c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Tên viết tắt ngân hàng đã tồn tại"})
+        c.Error(apperrors.BadRequest("Tên viết tắt ngân hàng đã tồn tại"))
         return
     }
 
     if err := bank.Validate(); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": err.Error()})
+        c.Error(apperrors.BadRequest(err.Error()))
         return
     }
 
     var accountNumbers []string
     if err := json.Unmarshal(bank.AccountNumbers, &accountNumbers); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Lỗi khi giải mã danh sách số tài khoản", "error": err.Error()})
+        c.Error(apperrors.BadRequest("Lỗi khi giải mã danh sách số tài khoản").WithDetails(err.Error()))
         return
     }
 
     accountSet := make(map[string]struct{})
     for _, accountNumber := range accountNumbers {
         if _, exists := accountSet[accountNumber]; exists {
-            c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Danh sách số tài khoản chứa số tài khoản trùng lặp"})
+            c.Error(apperrors.BadRequest("Danh sách số tài khoản chứa số tài khoản trùng lặp"))
             return
         }
         accountSet[accountNumber] = struct{}{}
@@ -56,9 +57,10 @@ func CreateBank(c *gin.Context) {
     bank.AccountNumbers, _ = json.Marshal(accountNumbers)
 
     if err := config.DB.Create(&bank).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Lỗi khi tạo ngân hàng", "error": err.Error()})
+        c.Error(apperrors.Internal("Lỗi khi tạo ngân hàng").Wrap(err))
         return
     }
+    recordAudit(c, "bank.create", "bank", bank.ID, nil, bank)
 
     c.JSON(http.StatusOK, gin.H{
         "code": 1,
@@ -74,20 +76,21 @@ func AddAccountNumbers(c *gin.Context) {
     }
 
     if err := c.ShouldBindJSON(&request); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Lỗi khi ràng buộc dữ liệu: " + err.Error()})
+        c.Error(apperrors.Validation(err))
         return
     }
 
     var bank models.BankFake
     if err := config.DB.First(&bank, request.BankID).Error; err != nil {
-        c.JSON(http.StatusNotFound, gin.H{"code": 0, "mess": "Ngân hàng không tồn tại với ID: " + fmt.Sprint(request.BankID)})
+        c.Error(apperrors.NotFound("Ngân hàng không tồn tại với ID: " + fmt.Sprint(request.BankID)))
         return
     }
+    before := bank
 
     if request.AccountNumbers != nil {
         var accounts []string
         if err := json.Unmarshal(request.AccountNumbers, &accounts); err != nil {
-            c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Định dạng số tài khoản không hợp lệ: " + err.Error()})
+            c.Error(apperrors.BadRequest("Định dạng số tài khoản không hợp lệ").WithDetails(err.Error()))
             return
         }
 
@@ -102,7 +105,7 @@ func AddAccountNumbers(c *gin.Context) {
         }
 
         if len(duplicates) > 0 {
-            c.JSON(http.StatusOK, gin.H{"code": 0, "mess": "Có số tài khoản trùng lặp"})
+            c.Error(apperrors.Conflict("Có số tài khoản trùng lặp"))
             return
         }
 
@@ -111,7 +114,7 @@ func AddAccountNumbers(c *gin.Context) {
             var count int64
             err := config.DB.Model(&models.BankFake{}).Where("id = ? AND account_numbers::jsonb @> ?::jsonb", bank.ID, fmt.Sprintf(`["%s"]`, account)).Count(&count).Error
             if err != nil {
-                c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Lỗi khi kiểm tra số tài khoản: " + err.Error()})
+                c.Error(apperrors.Internal("Lỗi khi kiểm tra số tài khoản").Wrap(err))
                 return
             }
             if count > 0 {
@@ -120,28 +123,29 @@ func AddAccountNumbers(c *gin.Context) {
         }
 
         if len(existingAccounts) > 0 {
-            c.JSON(http.StatusOK, gin.H{"code": 0, "mess": "Có số tài khoản trùng lặp trong cơ sở dữ liệu"})
+            c.Error(apperrors.Conflict("Có số tài khoản trùng lặp trong cơ sở dữ liệu"))
             return
         }
 
         var existingAccountNumbers []string
         if err := json.Unmarshal(bank.AccountNumbers, &existingAccountNumbers); err != nil {
-            c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Lỗi khi giải mã số tài khoản hiện có: " + err.Error()})
+            c.Error(apperrors.Internal("Lỗi khi giải mã số tài khoản hiện có").Wrap(err))
             return
         }
         existingAccountNumbers = append(existingAccountNumbers, accounts...)
         bank.AccountNumbers, _ = json.Marshal(existingAccountNumbers)
 
         if err := bank.Validate(); err != nil {
-            c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Số tài khoản không hợp lệ: " + err.Error()})
+            c.Error(apperrors.BadRequest("Số tài khoản không hợp lệ").WithDetails(err.Error()))
             return
         }
     }
 
     if err := config.DB.Save(&bank).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể cập nhật ngân hàng: " + err.Error()})
+        c.Error(apperrors.Internal("Không thể cập nhật ngân hàng").Wrap(err))
         return
     }
+    recordAudit(c, "bank.update", "bank", bank.ID, before, bank)
 
     c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Cập nhật ngân hàng thành công"})
 }
@@ -152,7 +156,7 @@ func GetAllBanks(c *gin.Context) {
 
     
     if err := config.DB.Find(&banks).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Lỗi khi lấy danh sách ngân hàng", "error": err.Error()})
+        c.Error(apperrors.Internal("Lỗi khi lấy danh sách ngân hàng").Wrap(err))
         return
     }
 
@@ -165,11 +169,19 @@ func GetAllBanks(c *gin.Context) {
 }
 
 func DeleteAllBanks(c *gin.Context) {
-    
+    var banks []models.BankFake
+    if err := config.DB.Find(&banks).Error; err != nil {
+        c.Error(apperrors.Internal("Lỗi khi lấy danh sách ngân hàng").Wrap(err))
+        return
+    }
+
     if err := config.DB.Exec("DELETE FROM bank_fakes").Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Lỗi khi xóa tất cả ngân hàng", "error": err.Error()})
+        c.Error(apperrors.Internal("Lỗi khi xóa tất cả ngân hàng").Wrap(err))
         return
     }
+    for _, bank := range banks {
+        recordAudit(c, "bank.delete", "bank", bank.ID, bank, nil)
+    }
 
     
     c.JSON(http.StatusOK, gin.H{
@@ -1,26 +1,56 @@
 package controllers
 
 import (
-	"log"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
-	"new/config"
+	"new/apperrors"
 	"new/models"
+	"new/repositories"
 	"new/services"
+	"slices"
 	"strconv"
 	"strings"
-	"time"
+
+	"gorm.io/gorm"
 
 	"github.com/gin-gonic/gin"
 )
 
+type BenefitController struct {
+	Benefits repositories.BenefitRepository
+	Cache    services.Cache
+}
+
+func NewBenefitController(benefits repositories.BenefitRepository, cache services.Cache) BenefitController {
+	return BenefitController{Benefits: benefits, Cache: cache}
+}
+
+// UpdateBenefitRequest cập nhật tiện ích, trường rỗng giữ nguyên giá trị cũ (trừ name)
 type UpdateBenefitRequest struct {
-	ID   uint   `json:"id"`
-	Name string `json:"name"`
+	ID           uint            `json:"id"`
+	Name         string          `json:"name"`
+	Category     string          `json:"category"`
+	Icon         string          `json:"icon"`
+	Scope        string          `json:"scope"`
+	Translations json.RawMessage `json:"translations"`
 }
 
 type CreateBenefitRequest struct {
-	Name string `json:"name" binding:"required"`
+	Name         string          `json:"name" binding:"required"`
+	Category     string          `json:"category"`     // general, kitchen, bathroom, safety, accessibility...
+	Icon         string          `json:"icon"`         // Tên icon hoặc URL ảnh
+	Scope        string          `json:"scope"`        // property (mặc định), room hoặc all
+	Translations json.RawMessage `json:"translations"` // {"en": "Free Wi-Fi"}
+}
+
+// MergeBenefitsRequest gộp các tiện ích trùng vào tiện ích chuẩn
+type MergeBenefitsRequest struct {
+	CanonicalID  int   `json:"canonicalId" binding:"required"`
+	DuplicateIDs []int `json:"duplicateIds" binding:"required,min=1"`
 }
 
 type ChangeBenefitStatusRequest struct {
@@ -29,28 +59,56 @@ type ChangeBenefitStatusRequest struct {
 }
 
 type BenefitResponse struct {
-	Id   int    `json:"id"`
-	Name string `json:"name"`
+	Id       int    `json:"id"`
+	Name     string `json:"name"`
+	Category string `json:"category"`
+	Icon     string `json:"icon"`
+	Scope    string `json:"scope"`
+}
+
+// benefitListFilter là bộ lọc dùng chung cho cms và web user
+type benefitListFilter struct {
+	Category string
+	Scope    string // room: tiện ích dùng được cho phòng (gồm cả scope all)
+	Lang     string // Trả tên theo ngôn ngữ này nếu có bản dịch
+}
+
+func (f benefitListFilter) match(b models.Benefit) bool {
+	if f.Category != "" && b.Category != f.Category {
+		return false
+	}
+	return f.Scope == "" || b.AllowedFor(f.Scope)
+}
+
+func (f benefitListFilter) response(b models.Benefit) BenefitResponse {
+	return BenefitResponse{
+		Id:       b.Id,
+		Name:     b.LocalizedName(f.Lang),
+		Category: b.Category,
+		Icon:     b.Icon,
+		Scope:    b.Scope,
+	}
 }
 
 // Lọc benefit theo status
-func filterBenefitsByStatus(benefits []models.Benefit, status int) []BenefitResponse {
+func filterBenefitsByStatus(benefits []models.Benefit, status int, filter benefitListFilter) []BenefitResponse {
 	var filtered []BenefitResponse
 	for _, b := range benefits {
-		if b.Status == status {
-			filtered = append(filtered, BenefitResponse{
-				Id:   b.Id,
-				Name: b.Name,
-			})
+		if b.Status == status && filter.match(b) {
+			filtered = append(filtered, filter.response(b))
 		}
 	}
 	return filtered
 }
 
 // Lọc Benefit cho cms
-func filterBenefits(benefits []models.Benefit, statusFilter, nameFilter string) []BenefitResponse {
+func filterBenefits(benefits []models.Benefit, statusFilter, nameFilter string, filter benefitListFilter) []BenefitResponse {
 	var filtered []BenefitResponse
 	for _, b := range benefits {
+		if !filter.match(b) {
+			continue
+		}
+
 		// Filter theo status
 		if statusFilter != "" {
 			parsedStatus, err := strconv.Atoi(statusFilter)
@@ -67,22 +125,28 @@ func filterBenefits(benefits []models.Benefit, statusFilter, nameFilter string)
 			}
 		}
 
-		filtered = append(filtered, BenefitResponse{
-			Id:   b.Id,
-			Name: b.Name,
-		})
+		filtered = append(filtered, filter.response(b))
 	}
 	return filtered
 }
 
-func GetAllBenefit(c *gin.Context) {
+// GetAllBenefit godoc
+// @Summary Danh sách tiện ích (không gồm tiện ích đã gộp)
+// @Tags benefit
+// @Produce json
+// @Param category query string false "Nhóm tiện ích"
+// @Param scope query string false "property hoặc room"
+// @Param lang query string false "Mã ngôn ngữ của tên tiện ích, vd: en"
+// @Success 200 {object} gin.H {"code": 1, "mess": "...", "data": []BenefitResponse}
+// @Router /benefit [get]
+func (b BenefitController) GetAllBenefit(c *gin.Context) {
 	authHeader := c.GetHeader("Authorization")
 	currentUserRole := 0
 	if authHeader != "" {
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 		_, role, err := GetUserIDFromToken(tokenString)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Invalid token"})
+			c.Error(apperrors.Unauthorized("Invalid token"))
 			return
 		}
 		currentUserRole = role
@@ -92,6 +156,7 @@ func GetAllBenefit(c *gin.Context) {
 	nameFilter := c.Query("name")
 	pageStr := c.Query("page")
 	limitStr := c.Query("limit")
+	filter := benefitListFilter{Category: c.Query("category"), Scope: c.Query("scope"), Lang: c.Query("lang")}
 
 	page := 0
 	limit := 10
@@ -108,37 +173,31 @@ func GetAllBenefit(c *gin.Context) {
 		}
 	}
 
-	// Redis cache key
+	// Cache key
 	cacheKey := "benefits:all"
-	rdb, err := config.ConnectRedis()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể kết nối Redis", "error": err.Error()})
-		return
-	}
 
 	var allBenefits []models.Benefit
 
-	err = services.GetFromRedis(config.Ctx, rdb, cacheKey, &allBenefits)
-	if err != nil || len(allBenefits) == 0 {
+	if !cacheGet(c.Request.Context(), b.Cache, cacheKey, &allBenefits) {
 
-		if err := config.DB.Find(&allBenefits).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể lấy danh sách lợi ích", "error": err.Error()})
+		benefits, err := b.Benefits.List(c.Request.Context())
+		if err != nil {
+			c.Error(apperrors.Internal("Không thể lấy danh sách lợi ích").Wrap(err))
 			return
 		}
+		allBenefits = benefits
 
-		// Lưu vào Redis
-		if err := services.SetToRedis(config.Ctx, rdb, cacheKey, allBenefits, time.Hour); err != nil {
-			log.Printf("Lỗi khi lưu danh sách lợi ích vào Redis: %v", err)
-		}
+		// Lưu vào cache
+		cacheSet(c.Request.Context(), b.Cache, cacheKey, allBenefits, services.ListTag("benefit"))
 	}
 
 	var filteredBenefits []BenefitResponse
 
 	//filter role = 1,2,3 cho sidebar cms, còn lại filter cho web user
 	if currentUserRole != 0 {
-		filteredBenefits = filterBenefits(allBenefits, statusFilter, nameFilter)
+		filteredBenefits = filterBenefits(allBenefits, statusFilter, nameFilter, filter)
 	} else {
-		filteredBenefits = filterBenefitsByStatus(allBenefits, 0)
+		filteredBenefits = filterBenefitsByStatus(allBenefits, 0, filter)
 	}
 
 	// Pagination
@@ -179,43 +238,53 @@ func GetAllBenefit(c *gin.Context) {
 	})
 }
 
-func CreateBenefit(c *gin.Context) {
+func (b BenefitController) CreateBenefit(c *gin.Context) {
 	var benefitRequests []CreateBenefitRequest
 
 	if err := c.ShouldBindJSON(&benefitRequests); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Dữ liệu không hợp lệ", "error": err.Error()})
+		c.Error(apperrors.Validation(err))
 		return
 	}
 
 	var benefit []models.Benefit
 	for _, benefitRequest := range benefitRequests {
-		benefit = append(benefit, models.Benefit{Name: benefitRequest.Name})
+		newBenefit := models.Benefit{
+			Name:         strings.Join(strings.Fields(benefitRequest.Name), " "),
+			Category:     benefitRequest.Category,
+			Icon:         benefitRequest.Icon,
+			Scope:        benefitRequest.Scope,
+			Translations: benefitRequest.Translations,
+		}
+		if err := newBenefit.Validate(); err != nil {
+			c.Error(apperrors.BadRequest(err.Error()))
+			return
+		}
+		benefit = append(benefit, newBenefit)
 	}
-	if err := config.DB.Create(&benefit).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể tạo lợi ích", "error": err.Error()})
+	if err := b.Benefits.Create(c.Request.Context(), benefit); err != nil {
+		c.Error(apperrors.Internal("Không thể tạo lợi ích").Wrap(err))
 		return
 	}
-
-	//Xóa redis
-	rdb, redisErr := config.ConnectRedis()
-	if redisErr == nil {
-		cacheKey := "benefits:all"
-		_ = services.DeleteFromRedis(config.Ctx, rdb, cacheKey)
+	for _, created := range benefit {
+		recordAudit(c, "benefit.create", "benefit", created.Id, nil, created)
 	}
 
+	// Xóa cache danh sách tiện ích
+	invalidateCache(c.Request.Context(), b.Cache, services.ListTag("benefit"))
+
 	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Tạo lợi ích thành công", "data": benefit})
 }
 
-func GetBenefitDetail(c *gin.Context) {
+func (b BenefitController) GetBenefitDetail(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "ID không hợp lệ", "error": err.Error()})
+		c.Error(apperrors.BadRequest("ID không hợp lệ").WithDetails(err.Error()))
 		return
 	}
 
-	var benefit models.Benefit
-	if err := config.DB.First(&benefit, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"code": 0, "mess": "Không tìm thấy lợi ích", "error": err.Error()})
+	benefit, err := b.Benefits.FindByID(c.Request.Context(), uint(id))
+	if err != nil {
+		c.Error(apperrors.NotFound("Không tìm thấy lợi ích").WithDetails(err.Error()))
 		return
 	}
 
@@ -226,71 +295,190 @@ func GetBenefitDetail(c *gin.Context) {
 	})
 }
 
-func UpdateBenefit(c *gin.Context) {
+func (b BenefitController) UpdateBenefit(c *gin.Context) {
 	var request UpdateBenefitRequest
 
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Dữ liệu không hợp lệ", "error": err.Error()})
+		c.Error(apperrors.Validation(err))
 		return
 	}
 
-	var benefit models.Benefit
-	if err := config.DB.First(&benefit, request.ID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"code": 0, "mess": "Không tìm thấy lợi ích", "error": err.Error()})
+	benefit, err := b.Benefits.FindByID(c.Request.Context(), request.ID)
+	if err != nil {
+		c.Error(apperrors.NotFound("Không tìm thấy lợi ích").WithDetails(err.Error()))
 		return
 	}
 
-	benefit.Name = request.Name
+	before := benefit
+	benefit.Name = strings.Join(strings.Fields(request.Name), " ")
+
+	if request.Category != "" {
+		benefit.Category = request.Category
+	}
 
-	if err := config.DB.Save(&benefit).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể cập nhật lợi ích", "error": err.Error()})
+	if request.Icon != "" {
+		benefit.Icon = request.Icon
+	}
+
+	if request.Scope != "" {
+		benefit.Scope = request.Scope
+	}
+
+	if len(request.Translations) > 0 {
+		benefit.Translations = request.Translations
+	}
+
+	if err := benefit.Validate(); err != nil {
+		c.Error(apperrors.BadRequest(err.Error()))
 		return
 	}
 
-	//Xóa redis
-	rdb, redisErr := config.ConnectRedis()
-	if redisErr == nil {
-		cacheKey := "benefits:all"
-		_ = services.DeleteFromRedis(config.Ctx, rdb, cacheKey)
+	if err := b.Benefits.Save(c.Request.Context(), &benefit); err != nil {
+		c.Error(apperrors.Internal("Không thể cập nhật lợi ích").Wrap(err))
+		return
 	}
+	recordAudit(c, "benefit.update", "benefit", benefit.Id, before, benefit)
+
+	// Xóa cache danh sách tiện ích
+	invalidateCache(c.Request.Context(), b.Cache, services.ListTag("benefit"))
 
 	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Cập nhật lợi ích thành công", "data": benefit})
 }
 
-func ChangeBenefitStatus(c *gin.Context) {
+func (b BenefitController) ChangeBenefitStatus(c *gin.Context) {
 	var request ChangeBenefitStatusRequest
 
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Dữ liệu không hợp lệ", "error": err.Error()})
+		c.Error(apperrors.Validation(err))
 		return
 	}
 
-	var benefit models.Benefit
-	if err := config.DB.First(&benefit, request.ID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"code": 0, "mess": "Không tìm thấy lợi ích", "error": err.Error()})
+	benefit, err := b.Benefits.FindByID(c.Request.Context(), request.ID)
+	if err != nil {
+		c.Error(apperrors.NotFound("Không tìm thấy lợi ích").WithDetails(err.Error()))
 		return
 	}
 
+	before := benefit
 	benefit.Status = request.Status
 
 	if err := benefit.ValidateStatus(); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": err.Error()})
+		c.Error(apperrors.BadRequest(err.Error()))
 		return
 	}
 
-	if err := config.DB.Model(&benefit).Update("status", request.Status).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể thay đổi trạng thái lợi ích", "error": err.Error()})
+	if err := b.Benefits.UpdateStatus(c.Request.Context(), &benefit, request.Status); err != nil {
+		c.Error(apperrors.Internal("Không thể thay đổi trạng thái lợi ích").Wrap(err))
 		return
 	}
 
 	benefit.Status = request.Status
+	recordAudit(c, "benefit.status", "benefit", benefit.Id, before, benefit)
 
-	//Xóa redis
-	rdb, redisErr := config.ConnectRedis()
-	if redisErr == nil {
-		cacheKey := "benefits:all"
-		_ = services.DeleteFromRedis(config.Ctx, rdb, cacheKey)
-	}
+	// Xóa cache danh sách tiện ích
+	invalidateCache(c.Request.Context(), b.Cache, services.ListTag("benefit"))
 
 	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Thay đổi trạng thái lợi ích thành công", "data": benefit})
 }
+
+// MergeBenefits godoc
+// @Summary Gộp các tiện ích trùng vào một tiện ích chuẩn
+// @Description Liên kết chỗ ở/phòng của tiện ích trùng được chuyển sang tiện ích chuẩn, tiện ích trùng bị ẩn
+// @Description và tên của chúng từ nay trỏ về tiện ích chuẩn khi tạo chỗ ở/phòng
+// @Tags benefit
+// @Accept json
+// @Produce json
+// @Param request body MergeBenefitsRequest true "Tiện ích chuẩn và các tiện ích trùng"
+// @Success 200 {object} gin.H {"code": 1, "mess": "...", "data": repositories.BenefitMergeResult}
+// @Router /benefitMerge [post]
+func (b BenefitController) MergeBenefits(c *gin.Context) {
+	var request MergeBenefitsRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+
+	duplicateIDs := slices.Compact(slices.Sorted(slices.Values(request.DuplicateIDs)))
+	if slices.Contains(duplicateIDs, request.CanonicalID) {
+		c.Error(apperrors.BadRequest("Tiện ích chuẩn không được nằm trong danh sách tiện ích trùng"))
+		return
+	}
+
+	canonical, err := b.Benefits.FindByID(c.Request.Context(), uint(request.CanonicalID))
+	if err != nil {
+		c.Error(apperrors.NotFound("Không tìm thấy tiện ích chuẩn"))
+		return
+	}
+	if canonical.MergedIntoID != nil {
+		c.Error(apperrors.BadRequest(fmt.Sprintf("Tiện ích chuẩn đã được gộp vào tiện ích %d", *canonical.MergedIntoID)))
+		return
+	}
+
+	duplicates, err := b.Benefits.FindByIDs(c.Request.Context(), duplicateIDs)
+	if err != nil {
+		c.Error(apperrors.Internal("Không thể lấy danh sách tiện ích").Wrap(err))
+		return
+	}
+	if len(duplicates) != len(duplicateIDs) {
+		c.Error(apperrors.NotFound("Có tiện ích trùng không tồn tại"))
+		return
+	}
+
+	result, err := b.Benefits.Merge(c.Request.Context(), canonical.Id, duplicateIDs)
+	var merged repositories.BenefitMergedError
+	if errors.As(err, &merged) {
+		c.Error(apperrors.Conflict(merged.Error()))
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.Error(apperrors.NotFound("Có tiện ích không còn tồn tại"))
+		return
+	}
+	if err != nil {
+		c.Error(apperrors.Internal("Không thể gộp tiện ích").Wrap(err))
+		return
+	}
+	recordAudit(c, "benefit.merge", "benefit", canonical.Id, duplicates, result)
+
+	// Xóa cache danh sách tiện ích và các chỗ ở/phòng có liên kết bị chuyển
+	tags := []string{services.ListTag("benefit"), services.ListTag("accommodation"), services.ListTag("room")}
+	for _, id := range result.AccommodationIDs {
+		tags = append(tags, services.EntityTag("accommodation", id))
+	}
+	for _, id := range result.RoomIDs {
+		tags = append(tags, services.EntityTag("room", id))
+	}
+	invalidateCache(c.Request.Context(), b.Cache, tags...)
+
+	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Gộp tiện ích thành công", "data": result})
+}
+
+// resolveBenefits chuyển tiện ích gửi lên (theo ID hoặc tên) thành tiện ích đã lưu cho phạm vi scope:
+// tiện ích đã gộp được thay bằng tiện ích chuẩn, tên mới được tạo thành tiện ích nhóm "general"
+func resolveBenefits(ctx context.Context, repo repositories.BenefitRepository, requested []models.Benefit, scope string) ([]models.Benefit, error) {
+	benefits := make([]models.Benefit, 0, len(requested))
+	for _, item := range requested {
+		var benefit models.Benefit
+		var err error
+		if item.Id != 0 {
+			benefit, err = repo.FindCanonical(ctx, item.Id)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, apperrors.BadRequest(fmt.Sprintf("Tiện ích %d không tồn tại", item.Id))
+			}
+		} else if strings.TrimSpace(item.Name) != "" {
+			benefit, err = repo.FindOrCreateByName(ctx, item.Name, scope)
+		} else {
+			continue
+		}
+		if err != nil {
+			return nil, apperrors.Internal("Không thể lấy tiện ích").Wrap(err)
+		}
+		if !benefit.AllowedFor(scope) {
+			return nil, apperrors.BadRequest(fmt.Sprintf("Tiện ích %q không áp dụng cho phạm vi %s", benefit.Name, scope))
+		}
+		if !slices.ContainsFunc(benefits, func(b models.Benefit) bool { return b.Id == benefit.Id }) {
+			benefits = append(benefits, benefit)
+		}
+	}
+	return benefits, nil
+}
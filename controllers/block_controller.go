@@ -0,0 +1,329 @@
+package controllers
+
+import (
+	"net/http"
+	"new/apperrors"
+	"new/models"
+	"new/repositories"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BlockController struct {
+	Blocks         repositories.BlockRepository
+	Rooms          repositories.RoomRepository
+	Accommodations repositories.AccommodationRepository
+	Users          repositories.UserRepository
+}
+
+func NewBlockController(blocks repositories.BlockRepository, rooms repositories.RoomRepository, accommodations repositories.AccommodationRepository, users repositories.UserRepository) BlockController {
+	return BlockController{Blocks: blocks, Rooms: rooms, Accommodations: accommodations, Users: users}
+}
+
+type BlockRequest struct {
+	Status   int    `json:"status"`   // 2: bảo trì, 3: chủ sử dụng, 4: tạm đóng
+	FromDate string `json:"fromDate"` // 02/01/2006
+	ToDate   string `json:"toDate"`   // 02/01/2006, ngày mở lại (không bị chặn)
+	Reason   string `json:"reason"`
+}
+
+type BlockResponse struct {
+	ID        uint      `json:"id"`
+	Status    int       `json:"status"`
+	FromDate  string    `json:"fromDate"`
+	ToDate    string    `json:"toDate"`
+	Reason    string    `json:"reason"`
+	CreatedBy uint      `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Đọc và kiểm tra khoảng chặn lịch. Trả về false nếu đã phản hồi lỗi.
+func bindBlockRequest(c *gin.Context) (BlockRequest, time.Time, time.Time, bool) {
+	var request BlockRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Error(apperrors.Validation(err))
+		return request, time.Time{}, time.Time{}, false
+	}
+	if !models.IsCalendarBlock(request.Status) {
+		c.Error(apperrors.BadRequest("Trạng thái chặn lịch phải là 2 (bảo trì), 3 (chủ sử dụng) hoặc 4 (tạm đóng)"))
+		return request, time.Time{}, time.Time{}, false
+	}
+	fromDate, err := time.Parse("02/01/2006", request.FromDate)
+	if err != nil {
+		c.Error(apperrors.BadRequest("Sai định dạng fromDate"))
+		return request, time.Time{}, time.Time{}, false
+	}
+	toDate, err := time.Parse("02/01/2006", request.ToDate)
+	if err != nil {
+		c.Error(apperrors.BadRequest("Sai định dạng toDate"))
+		return request, time.Time{}, time.Time{}, false
+	}
+	if !toDate.After(fromDate) {
+		c.Error(apperrors.BadRequest("toDate phải sau fromDate"))
+		return request, time.Time{}, time.Time{}, false
+	}
+	return request, fromDate, toDate, true
+}
+
+// Lịch chặn đã kết thúc trước hôm nay không còn được liệt kê
+func blockListSince() time.Time {
+	now := time.Now().UTC()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func toRoomBlockResponse(block models.RoomStatus) BlockResponse {
+	return BlockResponse{
+		ID:        block.ID,
+		Status:    block.Status,
+		FromDate:  block.FromDate.Format("02/01/2006"),
+		ToDate:    block.ToDate.Format("02/01/2006"),
+		Reason:    block.Reason,
+		CreatedBy: block.CreatedBy,
+		CreatedAt: block.CreatedAt,
+	}
+}
+
+func toAccommodationBlockResponse(block models.AccommodationStatus) BlockResponse {
+	return BlockResponse{
+		ID:        block.ID,
+		Status:    block.Status,
+		FromDate:  block.FromDate.Format("02/01/2006"),
+		ToDate:    block.ToDate.Format("02/01/2006"),
+		Reason:    block.Reason,
+		CreatedBy: block.CreatedBy,
+		CreatedAt: block.CreatedAt,
+	}
+}
+
+// GetRoomBlocks godoc
+// @Summary Lịch chặn của phòng chưa kết thúc
+// @Tags block
+// @Produce json
+// @Param id path int true "ID phòng"
+// @Success 200 {object} gin.H {"code": 1, "mess": "...", "data": []BlockResponse}
+// @Router /room/{id}/blocks [get]
+func (b BlockController) GetRoomBlocks(c *gin.Context) {
+	room, err := b.Rooms.FindByID(c.Request.Context(), paramID(c, "id"))
+	if err != nil {
+		c.Error(apperrors.NotFound("Phòng không tồn tại"))
+		return
+	}
+	if !requireRoomManager(c, b.Users, b.Accommodations, room) {
+		return
+	}
+
+	blocks, err := b.Blocks.ListRoomBlocks(c.Request.Context(), room.RoomId, blockListSince())
+	if err != nil {
+		c.Error(apperrors.Internal("Không thể lấy lịch chặn của phòng").Wrap(err))
+		return
+	}
+
+	response := make([]BlockResponse, 0, len(blocks))
+	for _, block := range blocks {
+		response = append(response, toRoomBlockResponse(block))
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Lấy lịch chặn thành công", "data": response})
+}
+
+// CreateRoomBlock godoc
+// @Summary Chặn lịch phòng (bảo trì, chủ sử dụng, tạm đóng)
+// @Description Không được chặn khoảng thời gian phòng đã có đơn đặt
+// @Tags block
+// @Accept json
+// @Produce json
+// @Param id path int true "ID phòng"
+// @Param request body BlockRequest true "Khoảng chặn"
+// @Success 201 {object} gin.H {"code": 1, "mess": "...", "data": BlockResponse}
+// @Router /room/{id}/blocks [post]
+func (b BlockController) CreateRoomBlock(c *gin.Context) {
+	request, fromDate, toDate, ok := bindBlockRequest(c)
+	if !ok {
+		return
+	}
+
+	room, err := b.Rooms.FindByID(c.Request.Context(), paramID(c, "id"))
+	if err != nil {
+		c.Error(apperrors.NotFound("Phòng không tồn tại"))
+		return
+	}
+	if !requireRoomManager(c, b.Users, b.Accommodations, room) {
+		return
+	}
+
+	booked, err := b.Blocks.RoomHasBookings(c.Request.Context(), room.RoomId, fromDate, toDate)
+	if err != nil {
+		c.Error(apperrors.Internal("Lỗi kiểm tra trạng thái phòng").Wrap(err))
+		return
+	}
+	if booked {
+		c.Error(apperrors.Conflict("Phòng đã có đơn đặt trong khoảng thời gian này"))
+		return
+	}
+
+	actorID, _ := auditActor(c)
+	block := models.RoomStatus{
+		RoomID:    room.RoomId,
+		FromDate:  fromDate,
+		ToDate:    toDate,
+		Status:    request.Status,
+		Reason:    request.Reason,
+		CreatedBy: actorID,
+	}
+	if err := b.Blocks.CreateRoomBlock(c.Request.Context(), &block); err != nil {
+		c.Error(apperrors.Internal("Không thể chặn lịch phòng").Wrap(err))
+		return
+	}
+	recordAudit(c, "room.block.create", "room", room.RoomId, nil, toRoomBlockResponse(block))
+
+	c.JSON(http.StatusCreated, gin.H{"code": 1, "mess": "Chặn lịch phòng thành công", "data": toRoomBlockResponse(block)})
+}
+
+// DeleteRoomBlock godoc
+// @Summary Bỏ chặn lịch phòng
+// @Tags block
+// @Produce json
+// @Param id path int true "ID phòng"
+// @Param blockId path int true "ID lịch chặn"
+// @Success 200 {object} gin.H {"code": 1, "mess": "..."}
+// @Router /room/{id}/blocks/{blockId} [delete]
+func (b BlockController) DeleteRoomBlock(c *gin.Context) {
+	room, err := b.Rooms.FindByID(c.Request.Context(), paramID(c, "id"))
+	if err != nil {
+		c.Error(apperrors.NotFound("Phòng không tồn tại"))
+		return
+	}
+	if !requireRoomManager(c, b.Users, b.Accommodations, room) {
+		return
+	}
+
+	block, err := b.Blocks.FindRoomBlock(c.Request.Context(), room.RoomId, paramID(c, "blockId"))
+	if err != nil {
+		c.Error(apperrors.NotFound("Lịch chặn không tồn tại"))
+		return
+	}
+
+	if err := b.Blocks.DeleteRoomBlock(c.Request.Context(), &block); err != nil {
+		c.Error(apperrors.Internal("Không thể bỏ chặn lịch phòng").Wrap(err))
+		return
+	}
+	recordAudit(c, "room.block.delete", "room", block.RoomID, toRoomBlockResponse(block), nil)
+
+	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Bỏ chặn lịch phòng thành công"})
+}
+
+// GetAccommodationBlocks godoc
+// @Summary Lịch chặn của chỗ ở chưa kết thúc
+// @Tags block
+// @Produce json
+// @Param id path int true "ID chỗ ở"
+// @Success 200 {object} gin.H {"code": 1, "mess": "...", "data": []BlockResponse}
+// @Router /accommodation/{id}/blocks [get]
+func (b BlockController) GetAccommodationBlocks(c *gin.Context) {
+	accommodation, err := b.Accommodations.FindByID(c.Request.Context(), paramID(c, "id"))
+	if err != nil {
+		c.Error(apperrors.NotFound("Chỗ ở không tồn tại"))
+		return
+	}
+	if !requireAccommodationManager(c, b.Users, accommodation) {
+		return
+	}
+
+	blocks, err := b.Blocks.ListAccommodationBlocks(c.Request.Context(), accommodation.ID, blockListSince())
+	if err != nil {
+		c.Error(apperrors.Internal("Không thể lấy lịch chặn của chỗ ở").Wrap(err))
+		return
+	}
+
+	response := make([]BlockResponse, 0, len(blocks))
+	for _, block := range blocks {
+		response = append(response, toAccommodationBlockResponse(block))
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Lấy lịch chặn thành công", "data": response})
+}
+
+// CreateAccommodationBlock godoc
+// @Summary Chặn lịch cả chỗ ở
+// @Description Chặn cả chỗ ở và mọi phòng của chỗ ở; không được chặn khoảng thời gian đã có đơn đặt
+// @Tags block
+// @Accept json
+// @Produce json
+// @Param id path int true "ID chỗ ở"
+// @Param request body BlockRequest true "Khoảng chặn"
+// @Success 201 {object} gin.H {"code": 1, "mess": "...", "data": BlockResponse}
+// @Router /accommodation/{id}/blocks [post]
+func (b BlockController) CreateAccommodationBlock(c *gin.Context) {
+	request, fromDate, toDate, ok := bindBlockRequest(c)
+	if !ok {
+		return
+	}
+
+	accommodation, err := b.Accommodations.FindByID(c.Request.Context(), paramID(c, "id"))
+	if err != nil {
+		c.Error(apperrors.NotFound("Chỗ ở không tồn tại"))
+		return
+	}
+	if !requireAccommodationManager(c, b.Users, accommodation) {
+		return
+	}
+
+	booked, err := b.Blocks.AccommodationHasBookings(c.Request.Context(), accommodation.ID, fromDate, toDate)
+	if err != nil {
+		c.Error(apperrors.Internal("Lỗi kiểm tra trạng thái chỗ ở").Wrap(err))
+		return
+	}
+	if booked {
+		c.Error(apperrors.Conflict("Chỗ ở đã có đơn đặt trong khoảng thời gian này"))
+		return
+	}
+
+	actorID, _ := auditActor(c)
+	block := models.AccommodationStatus{
+		AccommodationID: accommodation.ID,
+		FromDate:        fromDate,
+		ToDate:          toDate,
+		Status:          request.Status,
+		Reason:          request.Reason,
+		CreatedBy:       actorID,
+	}
+	if err := b.Blocks.CreateAccommodationBlock(c.Request.Context(), &block); err != nil {
+		c.Error(apperrors.Internal("Không thể chặn lịch chỗ ở").Wrap(err))
+		return
+	}
+	recordAudit(c, "accommodation.block.create", "accommodation", accommodation.ID, nil, toAccommodationBlockResponse(block))
+
+	c.JSON(http.StatusCreated, gin.H{"code": 1, "mess": "Chặn lịch chỗ ở thành công", "data": toAccommodationBlockResponse(block)})
+}
+
+// DeleteAccommodationBlock godoc
+// @Summary Bỏ chặn lịch chỗ ở
+// @Tags block
+// @Produce json
+// @Param id path int true "ID chỗ ở"
+// @Param blockId path int true "ID lịch chặn"
+// @Success 200 {object} gin.H {"code": 1, "mess": "..."}
+// @Router /accommodation/{id}/blocks/{blockId} [delete]
+func (b BlockController) DeleteAccommodationBlock(c *gin.Context) {
+	accommodation, err := b.Accommodations.FindByID(c.Request.Context(), paramID(c, "id"))
+	if err != nil {
+		c.Error(apperrors.NotFound("Chỗ ở không tồn tại"))
+		return
+	}
+	if !requireAccommodationManager(c, b.Users, accommodation) {
+		return
+	}
+
+	block, err := b.Blocks.FindAccommodationBlock(c.Request.Context(), accommodation.ID, paramID(c, "blockId"))
+	if err != nil {
+		c.Error(apperrors.NotFound("Lịch chặn không tồn tại"))
+		return
+	}
+
+	if err := b.Blocks.DeleteAccommodationBlock(c.Request.Context(), &block); err != nil {
+		c.Error(apperrors.Internal("Không thể bỏ chặn lịch chỗ ở").Wrap(err))
+		return
+	}
+	recordAudit(c, "accommodation.block.delete", "accommodation", block.AccommodationID, toAccommodationBlockResponse(block), nil)
+
+	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Bỏ chặn lịch chỗ ở thành công"})
+}
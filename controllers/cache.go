@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	"context"
+	"new/services"
+	"time"
+)
+
+// Thời gian lưu mặc định cho các danh sách
+const cacheTTL = time.Hour
+
+// Trang kết quả của API danh sách có bộ lọc chỉ lưu ngắn hạn
+const pageCacheTTL = time.Minute
+
+// Đọc cache, lỗi kết nối được coi như không có dữ liệu
+func cacheGet(ctx context.Context, cache services.Cache, key string, dest interface{}) bool {
+	found, err := cache.Get(ctx, key, dest)
+	if err != nil {
+		services.Logger(ctx).Warn("Lỗi khi đọc cache", "key", key, "error", err)
+		return false
+	}
+	return found
+}
+
+func cacheSet(ctx context.Context, cache services.Cache, key string, value interface{}, tags ...string) {
+	cacheSetWithTTL(ctx, cache, key, value, cacheTTL, tags...)
+}
+
+func cacheSetWithTTL(ctx context.Context, cache services.Cache, key string, value interface{}, ttl time.Duration, tags ...string) {
+	if err := cache.Set(ctx, key, value, ttl, tags...); err != nil {
+		services.Logger(ctx).Warn("Lỗi khi lưu cache", "key", key, "error", err)
+	}
+}
+
+// Xóa mọi key gắn với các tag sau khi dữ liệu thay đổi
+func invalidateCache(ctx context.Context, cache services.Cache, tags ...string) {
+	if err := cache.InvalidateTags(ctx, tags...); err != nil {
+		services.Logger(ctx).Warn("Lỗi khi xóa cache theo tag", "tags", tags, "error", err)
+	}
+}
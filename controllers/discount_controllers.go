@@ -1,13 +1,11 @@
 package controllers
 
 import (
-	"log"
 	"net/http"
-	"net/url"
-	"new/config"
+	"new/apperrors"
 	"new/models"
+	"new/repositories"
 	"new/services"
-	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -50,6 +48,15 @@ type ChangeDiscountStatusRequest struct {
 	Status int  `json:"status"`
 }
 
+type DiscountController struct {
+	Discounts repositories.DiscountRepository
+	Cache     services.Cache
+}
+
+func NewDiscountController(discounts repositories.DiscountRepository, cache services.Cache) DiscountController {
+	return DiscountController{Discounts: discounts, Cache: cache}
+}
+
 var layout = "02/01/2006"
 
 func ConvertDateToComparableFormat(dateStr string) (string, error) {
@@ -59,182 +66,124 @@ func ConvertDateToComparableFormat(dateStr string) (string, error) {
 	}
 	return parsedDate.Format("20060102"), nil
 }
-func GetDiscounts(c *gin.Context) {
-	cacheKey := "discounts:all"
-	rdb, err := config.ConnectRedis()
+func (d DiscountController) GetDiscounts(c *gin.Context) {
+	pageReq, err := parsePageRequest(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể kết nối Redis", "error": err.Error()})
+		c.Error(apperrors.BadRequest(err.Error()))
 		return
 	}
-	var discounts []models.Discount
-	err = services.GetFromRedis(config.Ctx, rdb, cacheKey, &discounts)
-	if err == nil && len(discounts) > 0 {
-		c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Lấy danh sách giảm giá thành công từ cache", "data": discounts})
+
+	// Tắt các mã đã hết hạn (trừ mã mặc định id = 1) trước khi đọc danh sách
+	expired, err := d.Discounts.ExpireOverdue(c.Request.Context())
+	if err != nil {
+		c.Error(apperrors.Internal("Không thể cập nhật trạng thái mã giảm giá đã hết hạn").Wrap(err))
 		return
 	}
+	if expired > 0 {
+		invalidateCache(c.Request.Context(), d.Cache, services.ListTag("discount"))
+	}
 
-	currentDate := time.Now()
-
-	if err := config.DB.Find(&discounts).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Lỗi khi lấy danh sách chương trình giảm giá"})
+	fromDate, toDate, ok := parseDateRange(c)
+	if !ok {
 		return
 	}
+	filter := repositories.DiscountFilter{
+		Name:     c.Query("name"),
+		Status:   queryInt(c, "status"),
+		Discount: queryFloat(c, "discount"),
+		Quantity: queryFloat(c, "quantity"),
+		FromDate: fromDate,
+		ToDate:   toDate,
+	}
 
-	for i, discount := range discounts {
-
-		toDate, err := time.Parse("02/01/2006", discount.ToDate)
+	cacheKey := services.PageCacheKey("discounts", filter, pageReq)
+	var page cachedPage[DiscountResponse]
+	if !cacheGet(c.Request.Context(), d.Cache, cacheKey, &page) {
+		discounts, info, err := d.Discounts.List(c.Request.Context(), filter, pageReq)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Định dạng ngày không hợp lệ trong mã giảm giá"})
+			c.Error(apperrors.Internal("Không thể lấy danh sách mã giảm giá").Wrap(err))
 			return
 		}
 
-		if discount.ID != 1 && toDate.Before(currentDate) && discount.Status == 1 {
-			discounts[i].Status = 0
-			if err := config.DB.Save(&discounts[i]).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể cập nhật trạng thái mã giảm giá đã hết hạn"})
-				return
-			}
-		}
-	}
-	// Lưu vào Redis
-	if err := services.SetToRedis(config.Ctx, rdb, cacheKey, discounts, time.Hour); err != nil {
-		log.Printf("Lỗi khi lưu danh sách giảm giá vào Redis: %v", err)
-	}
-	pageStr := c.Query("page")
-	limitStr := c.Query("limit")
-	statusFilter := c.Query("status")
-	nameFilter := c.Query("name")
-	discountStr := c.Query("discount")
-	quantityStr := c.Query("quantity")
-	fromDateStr := c.Query("fromDate")
-	toDateStr := c.Query("toDate")
-	page := 0
-	limit := 10
-
-	if pageStr != "" {
-		if parsedPage, err := strconv.Atoi(pageStr); err == nil && parsedPage >= 0 {
-			page = parsedPage
-		}
-	}
-
-	if limitStr != "" {
-		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
-			limit = parsedLimit
+		page = cachedPage[DiscountResponse]{Data: make([]DiscountResponse, 0, len(discounts)), Pagination: info}
+		for _, discount := range discounts {
+			page.Data = append(page.Data, DiscountResponse{
+				ID:        discount.ID,
+				Name:      discount.Name,
+				Quantity:  discount.Quantity,
+				FromDate:  discount.FromDate,
+				ToDate:    discount.ToDate,
+				Discount:  discount.Discount,
+				Status:    discount.Status,
+				CreatedAt: discount.CreatedAt,
+				UpdatedAt: discount.UpdatedAt,
+			})
 		}
+		cacheSetWithTTL(c.Request.Context(), d.Cache, cacheKey, page, pageCacheTTL, services.ListTag("discount"))
 	}
 
-	var discountResponses []DiscountResponse
+	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Lấy danh sách chương trình giảm giá thành công", "data": page.Data,
+		"pagination": page.Pagination})
+}
 
-	tx := config.DB.Model(&models.Discount{})
-	if nameFilter != "" {
-		decodedNameFilter, err := url.QueryUnescape(nameFilter)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể giải mã tham số name"})
-			return
-		}
-		tx = tx.Where("name ILIKE ?", "%"+decodedNameFilter+"%")
-	}
-	if statusFilter != "" {
-		tx = tx.Where("status = ?", statusFilter)
-	}
-	if discountStr != "" {
-		discount, err := strconv.ParseFloat(discountStr, 64)
-		if err == nil {
-			tx = tx.Where("discount = ?", discount)
-		}
+// Đọc khoảng fromDate/toDate (dd/mm/yyyy) và chuyển sang yyyymmdd để so sánh,
+// toDate chỉ được xét khi có fromDate. Trả về false nếu đã phản hồi lỗi.
+func parseDateRange(c *gin.Context) (string, string, bool) {
+	fromDateStr := c.Query("fromDate")
+	if fromDateStr == "" {
+		return "", "", true
 	}
-	if quantityStr != "" {
-		quantity, err := strconv.ParseFloat(quantityStr, 64)
-		if err == nil {
-			tx = tx.Where("quantity = ?", quantity)
-		}
+	fromDate, err := ConvertDateToComparableFormat(fromDateStr)
+	if err != nil {
+		c.Error(apperrors.BadRequest("Sai định dạng fromDate"))
+		return "", "", false
 	}
-	if fromDateStr != "" {
-		fromDateComparable, err := ConvertDateToComparableFormat(fromDateStr)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Sai định dạng fromDate"})
-			return
-		}
 
-		if toDateStr != "" {
-			toDateComparable, err := ConvertDateToComparableFormat(toDateStr)
-			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Sai định dạng toDate"})
-				return
-			}
-			tx = tx.Where("SUBSTRING(from_date, 7, 4) || SUBSTRING(from_date, 4, 2) || SUBSTRING(from_date, 1, 2) >= ? AND SUBSTRING(to_date, 7, 4) || SUBSTRING(to_date, 4, 2) || SUBSTRING(to_date, 1, 2) <= ?", fromDateComparable, toDateComparable)
-		} else {
-			tx = tx.Where("SUBSTRING(from_date, 7, 4) || SUBSTRING(from_date, 4, 2) || SUBSTRING(from_date, 1, 2) >= ?", fromDateComparable)
+	var toDate string
+	if toDateStr := c.Query("toDate"); toDateStr != "" {
+		toDate, err = ConvertDateToComparableFormat(toDateStr)
+		if err != nil {
+			c.Error(apperrors.BadRequest("Sai định dạng toDate"))
+			return "", "", false
 		}
 	}
-
-	var totalDiscounts int64
-	if err := tx.Count(&totalDiscounts).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể đếm số lượng mã giảm giá"})
-		return
-	}
-	tx = tx.Order("updated_at desc")
-
-	if err := tx.Offset(page * limit).Limit(limit).Find(&discounts).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể lấy danh sách mã giảm giá"})
-		return
-	}
-	for _, discount := range discounts {
-		discountResponses = append(discountResponses, DiscountResponse{
-			ID:        discount.ID,
-			Name:      discount.Name,
-			Quantity:  discount.Quantity,
-			FromDate:  discount.FromDate,
-			ToDate:    discount.ToDate,
-			Discount:  discount.Discount,
-			Status:    discount.Status,
-			CreatedAt: discount.CreatedAt,
-			UpdatedAt: discount.UpdatedAt,
-		})
-	}
-
-	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Lấy danh sách chương trình giảm giá thành công", "data": discountResponses,
-		"pagination": gin.H{
-			"page":  page,
-			"limit": limit,
-			"total": totalDiscounts,
-		}})
+	return fromDate, toDate, true
 }
-func GetDiscountDetail(c *gin.Context) {
-	var discount models.Discount
-	discountId := c.Param("id")
-	if err := config.DB.Where("id = ?", discountId).First(&discount).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"code": 0, "mess": "Không tìm thấy mã giảm giá!"})
+
+func (d DiscountController) GetDiscountDetail(c *gin.Context) {
+	discount, err := d.Discounts.FindByID(c.Request.Context(), paramID(c, "id"))
+	if err != nil {
+		c.Error(apperrors.NotFound("Không tìm thấy mã giảm giá!"))
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Lấy thông tin chi tiết của mã giảm giá thành công", "data": discount})
 }
-func CreateDiscount(c *gin.Context) {
+func (d DiscountController) CreateDiscount(c *gin.Context) {
 	var request CreateDiscountRequest
 
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Dữ liệu không hợp lệ"})
+		c.Error(apperrors.BadRequest("Dữ liệu không hợp lệ"))
 		return
 	}
 
 	if request.Discount < 0 || request.Discount > 100 {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Mức giảm giá phải nằm trong khoảng từ 0 đến 100"})
+		c.Error(apperrors.BadRequest("Mức giảm giá phải nằm trong khoảng từ 0 đến 100"))
 		return
 	}
 
 	fromDate, err := time.Parse(layout, request.FromDate)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Định dạng ngày bắt đầu không hợp lệ"})
+		c.Error(apperrors.BadRequest("Định dạng ngày bắt đầu không hợp lệ"))
 		return
 	}
 	toDate, err := time.Parse(layout, request.ToDate)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Định dạng ngày kết thúc không hợp lệ"})
+		c.Error(apperrors.BadRequest("Định dạng ngày kết thúc không hợp lệ"))
 		return
 	}
 
 	if !toDate.After(fromDate) {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Ngày kết thúc phải sau ngày bắt đầu"})
+		c.Error(apperrors.BadRequest("Ngày kết thúc phải sau ngày bắt đầu"))
 		return
 	}
 	discount := models.Discount{
@@ -248,32 +197,30 @@ func CreateDiscount(c *gin.Context) {
 		UpdatedAt:   time.Now(),
 	}
 
-	if err := config.DB.Create(&discount).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể tạo chương trình giảm giá", "detail": err})
+	if err := d.Discounts.Create(c.Request.Context(), &discount); err != nil {
+		c.Error(apperrors.Internal("Không thể tạo chương trình giảm giá").Wrap(err))
 		return
 	}
+	recordAudit(c, "discount.create", "discount", discount.ID, nil, discount)
 
-	//Xóa redis
-	rdb, redisErr := config.ConnectRedis()
-	if redisErr == nil {
-		cacheKey := "benefits:all"
-		_ = services.DeleteFromRedis(config.Ctx, rdb, cacheKey)
-	}
+	// Xóa cache danh sách giảm giá
+	invalidateCache(c.Request.Context(), d.Cache, services.ListTag("discount"))
 	c.JSON(http.StatusCreated, gin.H{"code": 1, "mess": "Tạo chương trình giảm giá thành công", "data": discount})
 }
 
-func UpdateDiscount(c *gin.Context) {
+func (d DiscountController) UpdateDiscount(c *gin.Context) {
 	var request UpdateDiscountRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Dữ liệu không hợp lệ"})
+		c.Error(apperrors.BadRequest("Dữ liệu không hợp lệ"))
 		return
 	}
 
-	var discount models.Discount
-	if err := config.DB.First(&discount, request.ID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"code": 0, "mess": "Chương trình giảm giá không tồn tại"})
+	discount, err := d.Discounts.FindByID(c.Request.Context(), request.ID)
+	if err != nil {
+		c.Error(apperrors.NotFound("Chương trình giảm giá không tồn tại"))
 		return
 	}
+	before := discount
 
 	if request.Name != "" {
 		discount.Name = request.Name
@@ -296,72 +243,69 @@ func UpdateDiscount(c *gin.Context) {
 	discount.UpdatedAt = time.Now()
 	discount.Status = request.Status
 
-	if err := config.DB.Save(&discount).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể cập nhật chương trình giảm giá"})
+	if err := d.Discounts.Save(c.Request.Context(), &discount); err != nil {
+		c.Error(apperrors.Internal("Không thể cập nhật chương trình giảm giá").Wrap(err))
 		return
 	}
+	recordAudit(c, "discount.update", "discount", discount.ID, before, discount)
 
-	//Xóa redis
-	rdb, redisErr := config.ConnectRedis()
-	if redisErr == nil {
-		cacheKey := "benefits:all"
-		_ = services.DeleteFromRedis(config.Ctx, rdb, cacheKey)
-	}
+	// Xóa cache danh sách giảm giá
+	invalidateCache(c.Request.Context(), d.Cache, services.ListTag("discount"))
 
 	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Cập nhật chương trình giảm giá thành công", "data": discount})
 }
 
-func DeleteDiscount(c *gin.Context) {
-	id := c.Param("id")
-	if err := config.DB.Delete(&models.Discount{}, id).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể xóa chương trình giảm giá"})
+func (d DiscountController) DeleteDiscount(c *gin.Context) {
+	discount, err := d.Discounts.FindByID(c.Request.Context(), paramID(c, "id"))
+	if err != nil {
+		c.Error(apperrors.NotFound("Chương trình giảm giá không tồn tại"))
 		return
 	}
 
-	//Xóa redis
-	rdb, redisErr := config.ConnectRedis()
-	if redisErr == nil {
-		cacheKey := "benefits:all"
-		_ = services.DeleteFromRedis(config.Ctx, rdb, cacheKey)
+	if err := d.Discounts.Delete(c.Request.Context(), &discount); err != nil {
+		c.Error(apperrors.Internal("Không thể xóa chương trình giảm giá").Wrap(err))
+		return
 	}
+	recordAudit(c, "discount.delete", "discount", discount.ID, discount, nil)
+
+	// Xóa cache danh sách giảm giá
+	invalidateCache(c.Request.Context(), d.Cache, services.ListTag("discount"))
 
 	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Xóa chương trình giảm giá thành công"})
 }
 
-func ChangeDiscountStatus(c *gin.Context) {
+func (d DiscountController) ChangeDiscountStatus(c *gin.Context) {
 	var request ChangeDiscountStatusRequest
 
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Dữ liệu không hợp lệ", "error": err.Error()})
+		c.Error(apperrors.Validation(err))
 		return
 	}
 
-	var discount models.Discount
-	if err := config.DB.First(&discount, request.ID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"code": 0, "mess": "Không tìm thấy mã giảm giá", "error": err.Error()})
+	discount, err := d.Discounts.FindByID(c.Request.Context(), request.ID)
+	if err != nil {
+		c.Error(apperrors.NotFound("Không tìm thấy mã giảm giá").WithDetails(err.Error()))
 		return
 	}
 
+	before := discount
 	discount.Status = request.Status
 
 	if err := discount.ValidateStatusDiscount(); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": err.Error()})
+		c.Error(apperrors.BadRequest(err.Error()))
 		return
 	}
 
-	if err := config.DB.Model(&discount).Update("status", request.Status).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể thay đổi trạng thái mã giảm giá", "error": err.Error()})
+	if err := d.Discounts.UpdateStatus(c.Request.Context(), &discount, request.Status); err != nil {
+		c.Error(apperrors.Internal("Không thể thay đổi trạng thái mã giảm giá").Wrap(err))
 		return
 	}
 
 	discount.Status = request.Status
+	recordAudit(c, "discount.status", "discount", discount.ID, before, discount)
 
-	//Xóa redis
-	rdb, redisErr := config.ConnectRedis()
-	if redisErr == nil {
-		cacheKey := "benefits:all"
-		_ = services.DeleteFromRedis(config.Ctx, rdb, cacheKey)
-	}
+	// Xóa cache danh sách giảm giá
+	invalidateCache(c.Request.Context(), d.Cache, services.ListTag("discount"))
 
 	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Thay đổi trạng thái mã giảm giá thành công", "data": discount})
 }
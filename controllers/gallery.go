@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"context"
+	"new/apperrors"
+	"new/models"
+	"new/repositories"
+	"new/services"
+	"path"
+	"strings"
+	"time"
+)
+
+// prepareGallery kiểm tra ảnh và nội thất gửi lên trước khi lưu
+func prepareGallery(img []models.Media, furniture []models.FurnitureItem) error {
+	if err := models.PrepareGallery(img); err != nil {
+		return apperrors.BadRequest(err.Error())
+	}
+	if err := models.PrepareFurniture(furniture); err != nil {
+		return apperrors.BadRequest(err.Error())
+	}
+	return nil
+}
+
+// replaceGallery ghi đè ảnh và nội thất của owner, danh sách rỗng thì giữ nguyên; ảnh bị bỏ được xóa khỏi storage
+func replaceGallery(ctx context.Context, galleries repositories.GalleryRepository, storage services.Storage, owner models.GalleryOwner, img []models.Media, furniture []models.FurnitureItem) error {
+	if len(img) > 0 {
+		orphaned, err := galleries.ReplaceMedia(ctx, owner, img)
+		if err != nil {
+			return apperrors.Internal("Không thể cập nhật hình ảnh").Wrap(err)
+		}
+		deleteOrphanedMedia(ctx, storage, orphaned)
+	}
+	if len(furniture) > 0 {
+		if err := galleries.ReplaceFurniture(ctx, owner, furniture); err != nil {
+			return apperrors.Internal("Không thể cập nhật nội thất").Wrap(err)
+		}
+	}
+	return nil
+}
+
+// deleteOrphanedMedia xóa ảnh (và ảnh thu nhỏ) không còn dùng khỏi nơi lưu trữ ở nền, không làm chậm request.
+// Chỉ xóa ảnh do server tạo trong thư mục ảnh thư viện và có URL khớp với key,
+// tránh client gửi publicId của ảnh khác (avatar...) rồi bỏ đi để xóa ảnh đó.
+func deleteOrphanedMedia(ctx context.Context, storage services.Storage, orphaned []models.Media) {
+	if storage == nil || len(orphaned) == 0 {
+		return
+	}
+	logger := services.Logger(ctx)
+	var keys []string
+	for _, m := range orphaned {
+		key := m.PublicID
+		if !strings.HasPrefix(key, galleryFolder+"/") || !strings.Contains(m.URL, strings.TrimSuffix(key, path.Ext(key))) {
+			continue
+		}
+		keys = append(keys, key, services.ThumbnailKey(key))
+	}
+	if len(keys) == 0 {
+		return
+	}
+	services.BackgroundWorkers.Go(func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+		defer cancel()
+		for _, key := range keys {
+			if err := storage.Delete(ctx, key); err != nil {
+				logger.Warn("Không thể xóa ảnh không còn dùng", "key", key, "error", err)
+			}
+		}
+	})
+}
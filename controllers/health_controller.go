@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"net/http"
+	"new/services"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthController struct {
+	// Checks là các phụ thuộc được kiểm tra trong /readyz, chạy song song
+	Checks  []services.HealthCheck
+	Timeout time.Duration
+	// Breaker của Redis, nil khi chạy cache trong bộ nhớ
+	Breaker *services.CircuitBreaker
+}
+
+func NewHealthController(checks []services.HealthCheck, timeout time.Duration, breaker *services.CircuitBreaker) HealthController {
+	return HealthController{Checks: checks, Timeout: timeout, Breaker: breaker}
+}
+
+// Healthz godoc
+// @Summary Tiến trình còn chạy
+// @Description Không kiểm tra phụ thuộc nào, dùng cho liveness probe
+// @Tags health
+// @Produce json
+// @Success 200 {object} gin.H {"code": 1, "mess": "ok"}
+// @Router /healthz [get]
+func (hc HealthController) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "ok"})
+}
+
+// Readyz godoc
+// @Summary Dịch vụ sẵn sàng nhận request
+// @Description Phụ thuộc bắt buộc (Postgres) lỗi trả về 503, phụ thuộc tùy chọn (Redis, Cloudinary, Mapbox) lỗi chỉ làm trạng thái "degraded"
+// @Tags health
+// @Produce json
+// @Success 200 {object} gin.H {"code": 1, "mess": "ok", "data": {}}
+// @Failure 503 {object} gin.H {"code": 0, "mess": "unavailable", "data": {}}
+// @Router /readyz [get]
+func (hc HealthController) Readyz(c *gin.Context) {
+	results := services.RunHealthChecks(c.Request.Context(), hc.Timeout, hc.Checks)
+
+	status := "ok"
+	httpStatus := http.StatusOK
+	for _, result := range results {
+		if result.State == "up" {
+			continue
+		}
+		if result.Critical {
+			status = "unavailable"
+			httpStatus = http.StatusServiceUnavailable
+			break
+		}
+		status = "degraded"
+	}
+
+	data := gin.H{"checks": results}
+	if hc.Breaker != nil {
+		breaker := hc.Breaker.Status()
+		data["cacheBreaker"] = breaker
+		if breaker.State != services.BreakerClosed && status == "ok" {
+			status = "degraded"
+		}
+	}
+
+	code := 1
+	if httpStatus != http.StatusOK {
+		code = 0
+	}
+	c.JSON(httpStatus, gin.H{"code": code, "mess": status, "data": data})
+}
@@ -1,18 +1,24 @@
 package controllers
 
 import (
-	"log"
 	"net/http"
-	"net/url"
+	"new/apperrors"
 	"new/config"
 	"new/models"
 	"new/services"
-	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+type HolidayController struct {
+	Cache services.Cache
+}
+
+func NewHolidayController(cache services.Cache) HolidayController {
+	return HolidayController{Cache: cache}
+}
+
 // HolidayResponse định nghĩa cấu trúc phản hồi cho kỳ nghỉ
 type HolidayResponse struct {
 	ID        uint      `json:"id"`
@@ -34,135 +40,76 @@ type CreateHolidayRequest struct {
 }
 
 // GetHolidays lấy tất cả kỳ nghỉ
-func GetHolidays(c *gin.Context) {
-	cacheKey := "holidays:all"
-
-	// Kết nối Redis
-	rdb, err := config.ConnectRedis()
+func (h HolidayController) GetHolidays(c *gin.Context) {
+	pageReq, err := parsePageRequest(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể kết nối Redis", "error": err.Error()})
+		c.Error(apperrors.BadRequest(err.Error()))
 		return
 	}
 
-	var holidays []models.Holiday
-	err = services.GetFromRedis(config.Ctx, rdb, cacheKey, &holidays)
-	if err == nil && len(holidays) > 0 {
-
-		c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Lấy danh sách kỳ nghỉ thành công từ cache", "data": holidays})
+	fromDate, toDate, ok := parseDateRange(c)
+	if !ok {
 		return
 	}
-	if err := config.DB.Find(&holidays).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Lỗi khi lấy danh sách kỳ nghỉ"})
-		return
-	}
-	if err := services.SetToRedis(config.Ctx, rdb, cacheKey, holidays, time.Hour); err != nil {
-		log.Printf("Lỗi khi lưu danh sách kỳ nghỉ vào Redis: %v", err)
-	}
-	pageStr := c.Query("page")
-	limitStr := c.Query("limit")
-	nameFilter := c.Query("name")
-	priceStr := c.Query("price")
-	fromDateStr := c.Query("fromDate")
-	toDateStr := c.Query("toDate")
-	page := 0
-	limit := 10
+	q := services.NewListQuery(config.DB.Model(&models.Holiday{})).
+		Contains("name", c.Query("name")).
+		EqFloat("price", c.Query("price")).
+		DateRange("from_date", "to_date", fromDate, toDate)
 
-	if pageStr != "" {
-		if parsedPage, err := strconv.Atoi(pageStr); err == nil && parsedPage >= 0 {
-			page = parsedPage
-		}
-	}
-
-	if limitStr != "" {
-		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
-			limit = parsedLimit
-		}
-	}
-	var holidayResponses []HolidayResponse
-	tx := config.DB.Model(&models.Holiday{})
-	if nameFilter != "" {
-		decodedNameFilter, err := url.QueryUnescape(nameFilter)
+	cacheKey := q.CacheKey("holidays", pageReq)
+	var page cachedPage[HolidayResponse]
+	if !cacheGet(c.Request.Context(), h.Cache, cacheKey, &page) {
+		holidays, info, err := services.Find(q, pageReq, holidayKeyset, holidayCursor)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể giải mã tham số name"})
-			return
-		}
-		tx = tx.Where("name ILIKE ?", "%"+decodedNameFilter+"%")
-	}
-	if priceStr != "" {
-		price, err := strconv.ParseFloat(priceStr, 64)
-		if err == nil {
-			tx = tx.Where("price = ?", price)
-		}
-	}
-	if fromDateStr != "" {
-		fromDateComparable, err := ConvertDateToComparableFormat(fromDateStr)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Sai định dạng fromDate"})
+			c.Error(apperrors.Internal("Không thể lấy danh sách ngày lễ").Wrap(err))
 			return
 		}
 
-		if toDateStr != "" {
-			toDateComparable, err := ConvertDateToComparableFormat(toDateStr)
-			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Sai định dạng toDate"})
-				return
-			}
-			tx = tx.Where("SUBSTRING(from_date, 7, 4) || SUBSTRING(from_date, 4, 2) || SUBSTRING(from_date, 1, 2) >= ? AND SUBSTRING(to_date, 7, 4) || SUBSTRING(to_date, 4, 2) || SUBSTRING(to_date, 1, 2) <= ?", fromDateComparable, toDateComparable)
-		} else {
-			tx = tx.Where("SUBSTRING(from_date, 7, 4) || SUBSTRING(from_date, 4, 2) || SUBSTRING(from_date, 1, 2) >= ?", fromDateComparable)
+		page = cachedPage[HolidayResponse]{Data: make([]HolidayResponse, 0, len(holidays)), Pagination: info}
+		for _, holiday := range holidays {
+			page.Data = append(page.Data, HolidayResponse{
+				ID:        holiday.ID,
+				Name:      holiday.Name,
+				FromDate:  holiday.FromDate,
+				ToDate:    holiday.ToDate,
+				Price:     holiday.Price,
+				CreatedAt: holiday.CreatedAt,
+				UpdatedAt: holiday.UpdatedAt,
+			})
 		}
+		cacheSetWithTTL(c.Request.Context(), h.Cache, cacheKey, page, pageCacheTTL, services.ListTag("holiday"))
 	}
-	var totalHolidays int64
-	if err := tx.Count(&totalHolidays).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể đếm số lượng ngày lễ"})
-		return
-	}
-	tx = tx.Order("updated_at desc")
 
-	if err := tx.Offset(page * limit).Limit(limit).Find(&holidays).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể lấy danh sách ngày lễ"})
-		return
-	}
+	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Lấy danh sách kỳ nghỉ thành công", "data": page.Data, "pagination": page.Pagination})
+}
 
-	for _, holiday := range holidays {
-		holidayResponses = append(holidayResponses, HolidayResponse{
-			ID:        holiday.ID,
-			Name:      holiday.Name,
-			FromDate:  holiday.FromDate,
-			ToDate:    holiday.ToDate,
-			Price:     holiday.Price,
-			CreatedAt: holiday.CreatedAt,
-			UpdatedAt: holiday.UpdatedAt,
-		})
-	}
+// Danh sách kỳ nghỉ xếp theo cập nhật mới nhất, cần index (updated_at DESC, id DESC)
+var holidayKeyset = services.Keyset{TimeColumn: "updated_at", IDColumn: "id"}
 
-	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Lấy danh sách kỳ nghỉ thành công", "data": holidayResponses, "pagination": gin.H{
-		"page":  page,
-		"limit": limit,
-		"total": totalHolidays,
-	}})
+func holidayCursor(holiday models.Holiday) services.Cursor {
+	return services.Cursor{Time: holiday.UpdatedAt, ID: holiday.ID}
 }
 
 // CreateHoliday tạo một kỳ nghỉ mới
-func CreateHoliday(c *gin.Context) {
+func (h HolidayController) CreateHoliday(c *gin.Context) {
 	var request CreateHolidayRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Dữ liệu không hợp lệ"})
+		c.Error(apperrors.BadRequest("Dữ liệu không hợp lệ"))
 		return
 	}
 	fromDate, err := time.Parse(layout, request.FromDate)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Định dạng ngày bắt đầu không hợp lệ"})
+		c.Error(apperrors.BadRequest("Định dạng ngày bắt đầu không hợp lệ"))
 		return
 	}
 	toDate, err := time.Parse(layout, request.ToDate)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Định dạng ngày kết thúc không hợp lệ"})
+		c.Error(apperrors.BadRequest("Định dạng ngày kết thúc không hợp lệ"))
 		return
 	}
 
 	if toDate.Before(fromDate) {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Ngày kết thúc phải sau ngày bắt đầu"})
+		c.Error(apperrors.BadRequest("Ngày kết thúc phải sau ngày bắt đầu"))
 		return
 	}
 	holiday := models.Holiday{
@@ -175,23 +122,20 @@ func CreateHoliday(c *gin.Context) {
 	}
 
 	if err := config.DB.Create(&holiday).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể tạo kỳ nghỉ", "detail": err})
+		c.Error(apperrors.Internal("Không thể tạo kỳ nghỉ").Wrap(err))
 		return
 	}
+	recordAudit(c, "holiday.create", "holiday", holiday.ID, nil, holiday)
 
-	//Xóa redis
-	rdb, redisErr := config.ConnectRedis()
-	if redisErr == nil {
-		cacheKey := "holidays:all"
-		_ = services.DeleteFromRedis(config.Ctx, rdb, cacheKey)
-	}
+	// Xóa cache danh sách kỳ nghỉ
+	invalidateCache(c.Request.Context(), h.Cache, services.ListTag("holiday"))
 
 	c.JSON(http.StatusCreated, gin.H{"code": 1, "mess": "Tạo kỳ nghỉ thành công", "data": holiday})
 }
-func GetDetailHoliday(c *gin.Context) {
+func (h HolidayController) GetDetailHoliday(c *gin.Context) {
 	var holiday models.Holiday
 	if err := config.DB.Where("id = ?", c.Param("id")).First(&holiday).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"code": 0, "message": "Không tìm thấy ngày lễ!"})
+		c.Error(apperrors.NotFound("Không tìm thấy ngày lễ!"))
 		return
 	}
 
@@ -199,18 +143,19 @@ func GetDetailHoliday(c *gin.Context) {
 }
 
 // UpdateHoliday cập nhật một kỳ nghỉ
-func UpdateHoliday(c *gin.Context) {
+func (h HolidayController) UpdateHoliday(c *gin.Context) {
 	var holiday models.Holiday
 	var request CreateHolidayRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Dữ liệu không hợp lệ"})
+		c.Error(apperrors.BadRequest("Dữ liệu không hợp lệ"))
 		return
 	}
 	if err := config.DB.First(&holiday, request.ID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"code": 0, "mess": "Không tìm thấy ngày lễ"})
+		c.Error(apperrors.NotFound("Không tìm thấy ngày lễ"))
 		return
 	}
 
+	before := holiday
 	holiday.Name = request.Name
 	holiday.FromDate = request.FromDate
 	holiday.ToDate = request.ToDate
@@ -218,45 +163,47 @@ func UpdateHoliday(c *gin.Context) {
 	holiday.UpdatedAt = time.Now()
 
 	if err := config.DB.Save(&holiday).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể cập nhật kỳ nghỉ"})
+		c.Error(apperrors.Internal("Không thể cập nhật kỳ nghỉ").Wrap(err))
 		return
 	}
+	recordAudit(c, "holiday.update", "holiday", holiday.ID, before, holiday)
 
-	//Xóa redis
-	rdb, redisErr := config.ConnectRedis()
-	if redisErr == nil {
-		cacheKey := "holidays:all"
-		_ = services.DeleteFromRedis(config.Ctx, rdb, cacheKey)
-	}
+	// Xóa cache danh sách kỳ nghỉ
+	invalidateCache(c.Request.Context(), h.Cache, services.ListTag("holiday"))
 
 	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Cập nhật kỳ nghỉ thành công", "data": holiday})
 }
 
-func DeleteHoliday(c *gin.Context) {
+func (h HolidayController) DeleteHoliday(c *gin.Context) {
 	var request struct {
 		IDs []uint `json:"ids"`
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Dữ liệu không hợp lệ"})
+		c.Error(apperrors.BadRequest("Dữ liệu không hợp lệ"))
 		return
 	}
 	if len(request.IDs) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Không có ID nào được cung cấp"})
+		c.Error(apperrors.BadRequest("Không có ID nào được cung cấp"))
 		return
 	}
 
-	if err := config.DB.Delete(&models.Holiday{}, request.IDs).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể xóa các kỳ nghỉ"})
+	var holidays []models.Holiday
+	if err := config.DB.Find(&holidays, request.IDs).Error; err != nil {
+		c.Error(apperrors.Internal("Không thể lấy các kỳ nghỉ").Wrap(err))
 		return
 	}
 
-	//Xóa redis
-	rdb, redisErr := config.ConnectRedis()
-	if redisErr == nil {
-		cacheKey := "holidays:all"
-		_ = services.DeleteFromRedis(config.Ctx, rdb, cacheKey)
+	if err := config.DB.Delete(&models.Holiday{}, request.IDs).Error; err != nil {
+		c.Error(apperrors.Internal("Không thể xóa các kỳ nghỉ").Wrap(err))
+		return
+	}
+	for _, holiday := range holidays {
+		recordAudit(c, "holiday.delete", "holiday", holiday.ID, holiday, nil)
 	}
 
+	// Xóa cache danh sách kỳ nghỉ
+	invalidateCache(c.Request.Context(), h.Cache, services.ListTag("holiday"))
+
 	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Xóa kỳ nghỉ thành công"})
 }
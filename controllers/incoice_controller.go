@@ -4,14 +4,13 @@ import (
 	"database/sql"
 	"fmt"
 	"net/http"
-	"new/config"
-	"new/models"
+	"new/apperrors"
+	"new/repositories"
+	"new/services"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/goccy/go-json"
-
 	"github.com/gin-gonic/gin"
 )
 
@@ -29,23 +28,32 @@ type InvoiceResponse struct {
 	User            UserResponse `json:"user"`
 }
 
-func GetInvoices(c *gin.Context) {
+type InvoiceController struct {
+	Invoices repositories.InvoiceRepository
+	Orders   repositories.OrderRepository
+	Users    repositories.UserRepository
+	Cache    services.Cache
+}
+
+func NewInvoiceController(invoices repositories.InvoiceRepository, orders repositories.OrderRepository, users repositories.UserRepository, cache services.Cache) InvoiceController {
+	return InvoiceController{Invoices: invoices, Orders: orders, Users: users, Cache: cache}
+}
+
+func (i InvoiceController) GetInvoices(c *gin.Context) {
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Authorization header is missing"})
+		c.Error(apperrors.Unauthorized("Authorization header is missing"))
 		return
 	}
 
 	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 	currentUserID, currentUserRole, err := GetUserIDFromToken(tokenString)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Invalid token"})
+		c.Error(apperrors.Unauthorized("Invalid token"))
 		return
 	}
 
-	var invoices []models.Invoice
 	var invoiceResponses []InvoiceResponse
-	var totalInvoices int64
 
 	pageStr := c.DefaultQuery("page", "0")
 	limitStr := c.DefaultQuery("limit", "10")
@@ -60,51 +68,23 @@ func GetInvoices(c *gin.Context) {
 		limit = 10
 	}
 
-	redisClient, err := config.ConnectRedis()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Unable to connect to Redis"})
-		return
-	}
-
-	cacheKey := fmt.Sprintf("invoices:all:page=%d:limit=%d", page, limit)
-
-	cachedData, err := redisClient.Get(config.Ctx, cacheKey).Result()
-	if err == nil && cachedData != "" {
-		var cachedResponse gin.H
-		if json.Unmarshal([]byte(cachedData), &cachedResponse) == nil {
-			c.JSON(http.StatusOK, cachedResponse)
-			return
-		}
-	}
+	// Kết quả phụ thuộc người xem nên key gồm cả user id
+	cacheKey := fmt.Sprintf("invoices:user:%d:page=%d:limit=%d", currentUserID, page, limit)
 
-	tx := config.DB.Model(&models.Invoice{})
-	if currentUserRole == 2 {
-		tx = tx.Where("order_id IN (?)", config.DB.Table("orders").
-			Select("orders.id").
-			Joins("JOIN accommodations ON accommodations.id = orders.accommodation_id").
-			Where("accommodations.user_id = ?", currentUserID))
-	} else if currentUserRole == 3 {
-		var user models.User
-		if err := config.DB.Where("id = ?", currentUserID).First(&user).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể tìm thấy người dùng"})
-			return
-		}
-		tx = tx.Where("order_id IN (?)", config.DB.Table("orders").
-			Select("orders.id").
-			Joins("JOIN accommodations ON accommodations.id = orders.accommodation_id").
-			Where("accommodations.user_id = ?", user.AdminId))
+	var cachedResponse gin.H
+	if cacheGet(c.Request.Context(), i.Cache, cacheKey, &cachedResponse) {
+		c.JSON(http.StatusOK, cachedResponse)
+		return
 	}
 
-	if err := tx.Count(&totalInvoices).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Unable to count invoices"})
+	ownerID, ok := listOwner(c, i.Users, currentUserID, currentUserRole)
+	if !ok {
 		return
 	}
 
-	if err := tx.Order("updated_at DESC").
-		Offset(page * limit).
-		Limit(limit).
-		Find(&invoices).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Unable to fetch invoices"})
+	invoices, totalInvoices, err := i.Invoices.List(c.Request.Context(), ownerID, page, limit)
+	if err != nil {
+		c.Error(apperrors.Internal("Unable to fetch invoices").Wrap(err))
 		return
 	}
 
@@ -134,37 +114,29 @@ func GetInvoices(c *gin.Context) {
 		},
 	}
 
-	jsonData, err := json.Marshal(responseData)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Error processing data"})
-		return
-	}
+	cacheSetWithTTL(c.Request.Context(), i.Cache, cacheKey, responseData, 15*time.Minute, services.ListTag("invoice"))
 
-	ttl := 15 * time.Minute
+	c.JSON(http.StatusOK, responseData)
+}
 
-	err = redisClient.Set(config.Ctx, cacheKey, jsonData, ttl).Err()
+func (i InvoiceController) GetDetailInvoice(c *gin.Context) {
+	invoice, err := i.Invoices.FindByID(c.Request.Context(), paramID(c, "id"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Unable to save cache"})
+		c.Error(apperrors.NotFound("Không tìm thấy hóa đơn!"))
 		return
 	}
-
-	c.JSON(http.StatusOK, responseData)
-}
-
-func GetDetailInvoice(c *gin.Context) {
-	var invoice models.Invoice
-	if err := config.DB.Where("id = ?", c.Param("id")).First(&invoice).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"code": 0, "message": "Không tìm thấy hóa đơn!"})
+	order, err := i.Orders.FindByID(c.Request.Context(), invoice.OrderID)
+	if err != nil {
+		c.Error(apperrors.NotFound("Không tìm thấy đơn hàng liên quan!"))
 		return
 	}
-	var order models.Order
-	if err := config.DB.Where("id = ?", invoice.OrderID).First(&order).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"code": 0, "message": "Không tìm thấy đơn hàng liên quan!"})
+	if order.UserID == nil {
+		c.Error(apperrors.NotFound("Không tìm thấy người dùng liên quan!"))
 		return
 	}
-	var user models.User
-	if err := config.DB.Where("id = ?", order.UserID).First(&user).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"code": 0, "message": "Không tìm thấy người dùng liên quan!"})
+	user, err := i.Users.FindByID(c.Request.Context(), *order.UserID)
+	if err != nil {
+		c.Error(apperrors.NotFound("Không tìm thấy người dùng liên quan!"))
 		return
 	}
 	invoiceResponse := InvoiceResponse{
@@ -202,61 +174,40 @@ type RevenueResponse struct {
 	MonthlyRevenue      []MonthRevenue `json:"monthlyRevenue"`
 }
 
-func GetTotalRevenue(c *gin.Context) {
+func (i InvoiceController) GetTotalRevenue(c *gin.Context) {
 	var totalRevenue, currentMonthRevenue, currentWeekRevenue float64
 	var lastMonthRevenue sql.NullFloat64
 	var monthlyRevenue []MonthRevenue
 
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Authorization header is missing"})
+		c.Error(apperrors.Unauthorized("Authorization header is missing"))
 		return
 	}
 
 	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 	currentUserID, currentUserRole, err := GetUserIDFromToken(tokenString)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Invalid token"})
+		c.Error(apperrors.Unauthorized("Invalid token"))
 		return
 	}
 
-	redisClient, err := config.ConnectRedis()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Unable to connect to Redis"})
+	// Doanh thu phụ thuộc người xem nên key gồm cả user id
+	cacheKey := fmt.Sprintf("total_revenue:user:%d", currentUserID)
+	var cachedResponse RevenueResponse
+	if cacheGet(c.Request.Context(), i.Cache, cacheKey, &cachedResponse) {
+		c.JSON(http.StatusOK, cachedResponse)
 		return
 	}
 
-	cacheKey := "total_revenue"
-	cachedData, err := redisClient.Get(config.Ctx, cacheKey).Result()
-	if err == nil && cachedData != "" {
-		var cachedResponse RevenueResponse
-		if json.Unmarshal([]byte(cachedData), &cachedResponse) == nil {
-			c.JSON(http.StatusOK, cachedResponse)
-			return
-		}
-	}
-
-	tx := config.DB.Model(&models.Invoice{})
-	if currentUserRole == 2 {
-		tx = tx.Where("order_id IN (?)", config.DB.Table("orders").
-			Select("orders.id").
-			Joins("JOIN accommodations ON accommodations.id = orders.accommodation_id").
-			Where("accommodations.user_id = ?", currentUserID))
-	} else if currentUserRole == 3 {
-		var user models.User
-		if err := config.DB.Where("id = ?", currentUserID).First(&user).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể tìm thấy người dùng"})
-			return
-		}
-		tx = tx.Where("order_id IN (?)", config.DB.Table("orders").
-			Select("orders.id").
-			Joins("JOIN accommodations ON accommodations.id = orders.accommodation_id").
-			Where("accommodations.user_id = ?", user.AdminId))
+	ownerID, ok := listOwner(c, i.Users, currentUserID, currentUserRole)
+	if !ok {
+		return
 	}
 
-	var invoices []models.Invoice
-	if err := tx.Find(&invoices).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy danh sách hóa đơn"})
+	invoices, err := i.Invoices.ListAll(c.Request.Context(), ownerID)
+	if err != nil {
+		c.Error(apperrors.Internal("Không thể lấy danh sách hóa đơn").Wrap(err))
 		return
 	}
 
@@ -317,126 +268,46 @@ func GetTotalRevenue(c *gin.Context) {
 		MonthlyRevenue:      monthlyRevenue,
 	}
 
-	jsonData, err := json.Marshal(response)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Error processing data"})
-		return
-	}
-
-	ttl := 15 * time.Minute
-	err = redisClient.Set(config.Ctx, cacheKey, jsonData, ttl).Err()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Unable to save cache"})
-		return
-	}
+	cacheSetWithTTL(c.Request.Context(), i.Cache, cacheKey, response, 15*time.Minute, services.ListTag("invoice"))
 
 	c.JSON(http.StatusOK, response)
 }
 
-func UpdatePaymentStatus(c *gin.Context) {
+func (i InvoiceController) UpdatePaymentStatus(c *gin.Context) {
 	var request struct {
 		ID          uint `json:"id"`
 		PaymentType int  `json:"paymentType"`
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Dữ liệu yêu cầu không hợp lệ"})
+		c.Error(apperrors.BadRequest("Dữ liệu yêu cầu không hợp lệ"))
 		return
 	}
 
-	var invoice models.Invoice
-	if err := config.DB.Where("id = ?", request.ID).First(&invoice).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"code": 0, "mess": "Hóa đơn không tìm thấy"})
+	invoice, err := i.Invoices.FindByID(c.Request.Context(), request.ID)
+	if err != nil {
+		c.Error(apperrors.NotFound("Hóa đơn không tìm thấy"))
 		return
 	}
 
+	before := invoice
 	invoice.PaymentType = &request.PaymentType
 	currentTime := time.Now()
 	invoice.PaymentDate = &currentTime
 	invoice.Status = 1
 
-	if err := config.DB.Save(&invoice).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể cập nhật trạng thái thanh toán"})
+	if err := i.Invoices.Save(c.Request.Context(), &invoice); err != nil {
+		c.Error(apperrors.Internal("Không thể cập nhật trạng thái thanh toán").Wrap(err))
 		return
 	}
-
-	redisClient, err := config.ConnectRedis()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể kết nối Redis"})
-		return
+	recordAudit(c, "invoice.payment", "invoice", invoice.ID, before, invoice)
+	// Chỉ ghi nhận doanh thu ở lần thanh toán đầu tiên của hóa đơn
+	if before.Status != 1 {
+		services.RevenueRecorded.Add(invoice.TotalAmount)
 	}
 
-	cacheKeyPattern := "invoices:*"
-	keys, err := redisClient.Keys(config.Ctx, cacheKeyPattern).Result()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể tìm kiếm cache Redis"})
-		return
-	}
-
-	if len(keys) > 0 {
-		err := redisClient.Del(config.Ctx, keys...).Err()
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể xóa cache Redis"})
-			return
-		}
-	}
-
-	page, limit := 0, 10
-	cacheKey := fmt.Sprintf("invoices:all:page=%d:limit=%d", page, limit)
-
-	var invoices []models.Invoice
-	var invoiceResponses []InvoiceResponse
-	var totalInvoices int64
-
-	tx := config.DB.Model(&models.Invoice{})
-	if err := tx.Count(&totalInvoices).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể đếm số lượng hóa đơn"})
-		return
-	}
-
-	if err := tx.Offset(page * limit).Limit(limit).Find(&invoices).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể lấy danh sách hóa đơn"})
-		return
-	}
-
-	for _, invoice := range invoices {
-		invoiceResponses = append(invoiceResponses, InvoiceResponse{
-			ID:              invoice.ID,
-			InvoiceCode:     invoice.InvoiceCode,
-			OrderID:         invoice.OrderID,
-			TotalAmount:     invoice.TotalAmount,
-			PaidAmount:      invoice.PaidAmount,
-			RemainingAmount: invoice.RemainingAmount,
-			Status:          invoice.Status,
-			PaymentDate:     nil,
-			CreatedAt:       invoice.CreatedAt.Format("2006-01-02 15:04:05"),
-			UpdatedAt:       invoice.UpdatedAt.Format("2006-01-02 15:04:05"),
-		})
-	}
-
-	responseData := gin.H{
-		"code": 1,
-		"mess": "Cập nhật trạng thái thanh toán thành công",
-		"data": invoiceResponses,
-		"pagination": gin.H{
-			"page":  page,
-			"limit": limit,
-			"total": totalInvoices,
-		},
-	}
-
-	jsonData, err := json.Marshal(responseData)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Lỗi khi xử lý dữ liệu"})
-		return
-	}
-
-	ttl := 15 * time.Minute
-	err = redisClient.Set(config.Ctx, cacheKey, jsonData, ttl).Err()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể lưu cache Redis"})
-		return
-	}
+	// Xóa cache hóa đơn và doanh thu
+	invalidateCache(c.Request.Context(), i.Cache, services.ListTag("invoice"))
 
 	c.JSON(http.StatusOK, gin.H{
 		"code": 1,
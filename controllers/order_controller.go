@@ -1,14 +1,13 @@
 package controllers
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"net/http"
-	"net/url"
-	"new/config"
+	"new/apperrors"
 	"new/models"
+	"new/repositories"
 	"new/services"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -31,8 +30,12 @@ type OrderUserResponse struct {
 	CheckInRushPrice float64                    `json:"checkInRushPrice"` // Giá check-in gấp
 	SoldOutPrice     float64                    `json:"soldOutPrice"`     // Giá sold out
 	DiscountPrice    float64                    `json:"discountPrice"`    // Giá discount
+	ExtraGuestPrice  float64                    `json:"extraGuestPrice"`  // Phụ thu khách thêm
+	Adults           int                        `json:"adults"`
+	Children         int                        `json:"children"`
 	TotalPrice       float64                    `json:"totalPrice"`
 	InvoiceCode      string                     `json:"invoiceCode"`
+	RoomTypes        []OrderRoomTypeResponse    `json:"roomTypes,omitempty"`
 }
 
 type OrderAccommodationResponse struct {
@@ -60,6 +63,61 @@ type CreateOrderRequest struct {
 	GuestName       string `json:"guestName,omitempty"`
 	GuestEmail      string `json:"guestEmail,omitempty"`
 	GuestPhone      string `json:"guestPhone,omitempty"`
+	Adults          int    `json:"adults"`   // Không gửi số khách thì tính là 1 người lớn
+	Children        int    `json:"children"` // Trẻ em
+	// Đặt theo hạng phòng thay cho chọn từng phòng, có thể dùng cùng roomId
+	RoomTypes []RoomTypeQuantity `json:"roomTypes,omitempty"`
+}
+
+type RoomTypeQuantity struct {
+	RoomTypeID uint `json:"roomTypeId"`
+	Quantity   int  `json:"quantity"`
+}
+
+type OrderRoomTypeResponse struct {
+	RoomTypeID uint   `json:"roomTypeId"`
+	Name       string `json:"name"`
+	Quantity   int    `json:"quantity"`
+	Assigned   int    `json:"assigned"` // Số phòng thật đã được gán
+}
+
+type OrderController struct {
+	Orders         repositories.OrderRepository
+	Accommodations repositories.AccommodationRepository
+	Rooms          repositories.RoomRepository
+	Invoices       repositories.InvoiceRepository
+	Users          repositories.UserRepository
+	RatePlans      repositories.RatePlanRepository
+	RoomTypes      repositories.RoomTypeRepository
+	Restrictions   repositories.StayRestrictionRepository
+	Discounts      repositories.DiscountRepository
+	Tx             repositories.TxRunner
+	Cache          services.Cache
+}
+
+func NewOrderController(orders repositories.OrderRepository, accommodations repositories.AccommodationRepository,
+	rooms repositories.RoomRepository, invoices repositories.InvoiceRepository, users repositories.UserRepository,
+	ratePlans repositories.RatePlanRepository, roomTypes repositories.RoomTypeRepository,
+	restrictions repositories.StayRestrictionRepository, discounts repositories.DiscountRepository,
+	tx repositories.TxRunner, cache services.Cache) OrderController {
+	return OrderController{
+		Orders:         orders,
+		Accommodations: accommodations,
+		Rooms:          rooms,
+		Invoices:       invoices,
+		Users:          users,
+		RatePlans:      ratePlans,
+		RoomTypes:      roomTypes,
+		Restrictions:   restrictions,
+		Discounts:      discounts,
+		Tx:             tx,
+		Cache:          cache,
+	}
+}
+
+// withRepositories trả về bản sao controller dùng các repository của transaction tx
+func (o OrderController) withRepositories(tx repositories.Repositories) OrderController {
+	return NewOrderController(tx.Orders, tx.Accommodations, tx.Rooms, tx.Invoices, tx.Users, tx.RatePlans, tx.RoomTypes, tx.Restrictions, tx.Discounts, o.Tx, o.Cache)
 }
 
 func convertToOrderAccommodationResponse(accommodation models.Accommodation) OrderAccommodationResponse {
@@ -82,6 +140,34 @@ func convertToOrderRoomResponse(room models.Room) OrderRoomResponse {
 	}
 }
 
+func toOrderRoomTypeResponses(reservations []models.OrderRoomType) []OrderRoomTypeResponse {
+	var responses []OrderRoomTypeResponse
+	for _, reservation := range reservations {
+		responses = append(responses, OrderRoomTypeResponse{
+			RoomTypeID: reservation.RoomTypeID,
+			Name:       reservation.RoomType.Name,
+			Quantity:   reservation.Quantity,
+			Assigned:   reservation.Assigned,
+		})
+	}
+	return responses
+}
+
+// excludeRooms bỏ khỏi rooms các phòng có trong taken
+func excludeRooms(rooms, taken []uint) []uint {
+	skip := make(map[uint]bool, len(taken))
+	for _, id := range taken {
+		skip[id] = true
+	}
+	var result []uint
+	for _, id := range rooms {
+		if !skip[id] {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
 // Chuyển chuỗi ngày string thành dạng timestamp
 func ConvertDateToISOFormat(dateStr string) (time.Time, error) {
 	parsedDate, err := time.Parse("02/01/2006", dateStr)
@@ -91,11 +177,11 @@ func ConvertDateToISOFormat(dateStr string) (time.Time, error) {
 	return parsedDate, nil
 }
 
-func GetOrders(c *gin.Context) {
+func (o OrderController) GetOrders(c *gin.Context) {
 	// Lấy Authorization Header
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Authorization header is missing"})
+		c.Error(apperrors.Unauthorized("Authorization header is missing"))
 		return
 	}
 
@@ -103,230 +189,152 @@ func GetOrders(c *gin.Context) {
 	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 	currentUserID, currentUserRole, err := GetUserIDFromToken(tokenString)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Invalid token"})
+		c.Error(apperrors.Unauthorized("Invalid token"))
 		return
 	}
 
-	// Kết nối Redis
-	cacheKey := fmt.Sprintf("orders:all:user:%d", currentUserID)
-	rdb, err := config.ConnectRedis()
+	pageReq, err := parsePageRequest(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể kết nối Redis"})
+		c.Error(apperrors.BadRequest(err.Error()))
 		return
 	}
 
-	var allOrders []models.Order
-
-	// Lấy dữ liệu từ Redis Cache
-	if err := services.GetFromRedis(config.Ctx, rdb, cacheKey, &allOrders); err != nil || len(allOrders) == 0 {
-		// Nếu không có cache hoặc Redis gặp lỗi, thực hiện truy vấn từ DB
-		baseTx := config.DB.Model(&models.Order{}).
-			Preload("Accommodation").
-			Preload("Room").
-			Preload("User")
-
-		// Áp dụng quyền truy cập
-		if currentUserRole == 2 {
-			// Admin: Lọc theo các chỗ ở thuộc về Admin
-			baseTx = baseTx.Where("orders.accommodation_id IN (?)",
-				config.DB.Model(&models.Accommodation{}).Select("id").Where("user_id = ?", currentUserID))
-		} else if currentUserRole == 3 {
-			// Receptionist: Lọc theo các chỗ ở thuộc về Admin của Receptionist
-			var adminID int
-			if err := config.DB.Model(&models.User{}).Select("admin_id").Where("id = ?", currentUserID).Scan(&adminID).Error; err != nil || adminID == 0 {
-				c.JSON(http.StatusForbidden, gin.H{"code": 0, "mess": "Không có quyền truy cập"})
-				return
-			}
-			baseTx = baseTx.Where("orders.accommodation_id IN (?)",
-				config.DB.Model(&models.Accommodation{}).Select("id").Where("user_id = ?", adminID))
-		}
-
-		// Truy vấn tất cả đơn hàng từ DB
-		if err := baseTx.Find(&allOrders).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể lấy danh sách đơn hàng"})
-			return
-		}
-
-		// Lưu vào Redis Cache
-		if err := services.SetToRedis(config.Ctx, rdb, cacheKey, allOrders, time.Hour); err != nil {
-			log.Printf("Lỗi khi lưu danh sách đơn hàng vào Redis: %v", err)
-		}
+	// Áp dụng quyền truy cập: admin xem đơn của chỗ ở mình, lễ tân xem đơn của admin quản lý
+	ownerID, ok := listOwner(c, o.Users, currentUserID, currentUserRole)
+	if !ok {
+		return
 	}
 
-	// Lấy các tham số filter từ query
-	pageStr := c.Query("page")
-	limitStr := c.Query("limit")
-	nameFilter := c.Query("name")
-	phoneStr := c.Query("phoneNumber")
-	priceStr := c.Query("price")
-	fromDateStr := c.Query("fromDate")
-	toDateStr := c.Query("toDate")
-
-	// Xử lý phân trang
-	page := 0
-	limit := 10
-	if pageStr != "" {
-		if parsedPage, err := strconv.Atoi(pageStr); err == nil && parsedPage >= 0 {
-			page = parsedPage
+	// Áp dụng bộ lọc
+	filter := repositories.OrderFilter{
+		OwnerID:           ownerID,
+		AccommodationName: c.Query("name"),
+		PhoneNumber:       c.Query("phoneNumber"),
+		MinPrice:          queryFloat(c, "price"),
+	}
+	if fromDateStr := c.Query("fromDate"); fromDateStr != "" {
+		fromDateISO, err := ConvertDateToISOFormat(fromDateStr)
+		if err != nil {
+			c.Error(apperrors.BadRequest("Sai định dạng fromDate"))
+			return
 		}
+		filter.CreatedFrom = &fromDateISO
 	}
-	if limitStr != "" {
-		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
-			limit = parsedLimit
+	if toDateStr := c.Query("toDate"); toDateStr != "" {
+		toDateISO, err := ConvertDateToISOFormat(toDateStr)
+		if err != nil {
+			c.Error(apperrors.BadRequest("Sai định dạng toDate"))
+			return
 		}
+		filter.UpdatedTo = &toDateISO
 	}
 
-	// Áp dụng bộ lọc
-	filteredOrders := make([]models.Order, 0)
-	for _, order := range allOrders {
-		if nameFilter != "" {
-			decodedName, _ := url.QueryUnescape(nameFilter)
-			if !strings.Contains(strings.ToLower(order.Accommodation.Name), strings.ToLower(decodedName)) {
-				continue
-			}
-		}
-		if phoneStr != "" {
-			if order.User != nil && !strings.Contains(strings.ToLower(order.User.PhoneNumber), strings.ToLower(phoneStr)) {
-				continue
-			}
-			if order.User == nil && !strings.Contains(strings.ToLower(order.GuestPhone), strings.ToLower(phoneStr)) {
-				continue
-			}
-		}
-		if priceStr != "" {
-			price, err := strconv.ParseFloat(priceStr, 64)
-			if err == nil && order.TotalPrice < price {
-				continue
-			}
-		}
-		if fromDateStr != "" {
-			fromDateISO, err := ConvertDateToISOFormat(fromDateStr)
-			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Sai định dạng fromDate"})
-				return
-			}
-			if order.CreatedAt.Before(fromDateISO) {
-				continue
-			}
-		}
-		if toDateStr != "" {
-			toDateISO, err := ConvertDateToISOFormat(toDateStr)
-			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Sai định dạng toDate"})
-				return
-			}
-			if order.UpdatedAt.After(toDateISO) {
-				continue
-			}
+	// Cache key theo người xem và bộ lọc
+	cacheKey := services.PageCacheKey(fmt.Sprintf("orders:user:%d", currentUserID), filter, pageReq)
+	var page cachedPage[OrderUserResponse]
+	if !cacheGet(c.Request.Context(), o.Cache, cacheKey, &page) {
+		orders, info, err := o.Orders.List(c.Request.Context(), filter, pageReq)
+		if err != nil {
+			c.Error(apperrors.Internal("Không thể lấy danh sách đơn hàng").Wrap(err))
+			return
 		}
-		filteredOrders = append(filteredOrders, order)
-	}
 
-	// Tính toán lại tổng số đơn hàng sau khi lọc
-	totalFiltered := len(filteredOrders)
+		// Chuẩn bị phản hồi
+		page = cachedPage[OrderUserResponse]{Data: make([]OrderUserResponse, 0, len(orders)), Pagination: info}
+		for _, order := range orders {
+			var user Actor
+			if order.UserID != nil && order.User != nil {
+				user = Actor{Name: order.User.Name, Email: order.User.Email, PhoneNumber: order.User.PhoneNumber}
+			} else {
+				user = Actor{Name: order.GuestName, Email: order.GuestEmail, PhoneNumber: order.GuestPhone}
+			}
 
-	//Xếp theo update mới nhất
-	sort.Slice(filteredOrders, func(i, j int) bool {
-		return filteredOrders[i].UpdatedAt.After(filteredOrders[j].UpdatedAt)
-	})
-	// Áp dụng phân trang
-	start := page * limit
-	end := start + limit
-	if start >= totalFiltered {
-		filteredOrders = []models.Order{}
-	} else if end > totalFiltered {
-		filteredOrders = filteredOrders[start:]
-	} else {
-		filteredOrders = filteredOrders[start:end]
-	}
+			accommodationResponse := convertToOrderAccommodationResponse(order.Accommodation)
+			var roomResponses []OrderRoomResponse
+			for _, room := range order.Room {
+				roomResponse := convertToOrderRoomResponse(room)
+				roomResponses = append(roomResponses, roomResponse)
+			}
 
-	// Chuẩn bị phản hồi
-	var orderResponses []OrderUserResponse
-	for _, order := range filteredOrders {
-		var user Actor
-		if order.UserID != nil {
-			user = Actor{Name: order.User.Name, Email: order.User.Email, PhoneNumber: order.User.PhoneNumber}
-		} else {
-			user = Actor{Name: order.GuestName, Email: order.GuestEmail, PhoneNumber: order.GuestPhone}
+			page.Data = append(page.Data, OrderUserResponse{
+				ID:               order.ID,
+				User:             user,
+				Accommodation:    accommodationResponse,
+				Room:             roomResponses,
+				CheckInDate:      order.CheckInDate,
+				CheckOutDate:     order.CheckOutDate,
+				Status:           order.Status,
+				CreatedAt:        order.CreatedAt,
+				UpdatedAt:        order.UpdatedAt,
+				Price:            order.Price,
+				HolidayPrice:     order.HolidayPrice,
+				CheckInRushPrice: order.CheckInRushPrice,
+				SoldOutPrice:     order.SoldOutPrice,
+				DiscountPrice:    order.DiscountPrice,
+				ExtraGuestPrice:  order.ExtraGuestPrice,
+				Adults:           order.Adults,
+				Children:         order.Children,
+				TotalPrice:       order.TotalPrice,
+			})
 		}
 
-		accommodationResponse := convertToOrderAccommodationResponse(order.Accommodation)
-		var roomResponses []OrderRoomResponse
-		for _, room := range order.Room {
-			roomResponse := convertToOrderRoomResponse(room)
-			roomResponses = append(roomResponses, roomResponse)
+		// Gắn tag chỗ ở để đổi thông tin chỗ ở sẽ làm mới danh sách
+		tags := []string{services.ListTag("order")}
+		for _, order := range orders {
+			tags = append(tags, services.EntityTag("accommodation", order.AccommodationID))
 		}
-
-		orderResponse := OrderUserResponse{
-			ID:               order.ID,
-			User:             user,
-			Accommodation:    accommodationResponse,
-			Room:             roomResponses,
-			CheckInDate:      order.CheckInDate,
-			CheckOutDate:     order.CheckOutDate,
-			Status:           order.Status,
-			CreatedAt:        order.CreatedAt,
-			UpdatedAt:        order.UpdatedAt,
-			Price:            order.Price,
-			HolidayPrice:     order.HolidayPrice,
-			CheckInRushPrice: order.CheckInRushPrice,
-			SoldOutPrice:     order.SoldOutPrice,
-			DiscountPrice:    order.DiscountPrice,
-			TotalPrice:       order.TotalPrice,
-		}
-		orderResponses = append(orderResponses, orderResponse)
+		cacheSetWithTTL(c.Request.Context(), o.Cache, cacheKey, page, pageCacheTTL, tags...)
 	}
 
 	// Phản hồi kết quả
 	c.JSON(http.StatusOK, gin.H{
 		"code":       1,
 		"mess":       "Lấy danh sách đơn hàng thành công",
-		"data":       orderResponses,
-		"pagination": gin.H{"page": page, "limit": limit, "total": totalFiltered},
+		"data":       page.Data,
+		"pagination": page.Pagination,
 	})
 }
 
-func CreateOrder(c *gin.Context) {
-	authHeader := c.GetHeader("Authorization")
-
-	var currentUserID uint
-	if authHeader != "" {
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		userID, _, err := GetUserIDFromToken(tokenString)
-
-		if err == nil {
-			currentUserID = userID
-		}
-	}
-
-	var request CreateOrderRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Dữ liệu không hợp lệ"})
-		return
-	}
+// orderQuote là đơn chưa lưu đã được kiểm tra lịch trống, quy định lưu trú và tính giá
+type orderQuote struct {
+	Order         models.Order
+	Accommodation models.Accommodation
+	CheckIn       time.Time
+	CheckOut      time.Time
+	Nights        int
+	GuestFee      services.GuestFee
+	Reservations  []models.OrderRoomType // Số phòng đặt theo hạng, OrderID được gán khi lưu đơn
+	Discount      *models.Discount       // Mã giảm giá được áp dụng, lượt dùng chỉ được ghi khi lưu đơn
+}
 
+// quoteOrder kiểm tra yêu cầu đặt phòng và tính giá, dùng chung cho tạo đơn và báo giá
+func (o OrderController) quoteOrder(ctx context.Context, request CreateOrderRequest) (orderQuote, error) {
 	checkInDate, err := time.Parse("02/01/2006", request.CheckInDate)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Ngày nhận phòng không hợp lệ"})
-		return
+		return orderQuote{}, apperrors.BadRequest("Ngày nhận phòng không hợp lệ")
 	}
 
 	if checkInDate.Before(time.Now()) {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Ngày nhận phòng không được nhỏ hơn ngày hiện tại"})
-		return
+		return orderQuote{}, apperrors.BadRequest("Ngày nhận phòng không được nhỏ hơn ngày hiện tại")
 	}
 
 	checkOutDate, err := time.Parse("02/01/2006", request.CheckOutDate)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Ngày trả phòng không hợp lệ"})
-		return
+		return orderQuote{}, apperrors.BadRequest("Ngày trả phòng không hợp lệ")
+	}
+
+	if request.Adults == 0 && request.Children == 0 {
+		request.Adults = 1
+	}
+	if request.Adults < 1 || request.Children < 0 {
+		return orderQuote{}, apperrors.BadRequest("Số khách không hợp lệ, đơn phải có ít nhất 1 người lớn")
 	}
-	var info models.User
+	// Tài khoản đăng ký qua mạng xã hội có số điện thoại rỗng, đơn không có số điện thoại không được gắn vào tài khoản nào
 	var userId *uint
-	if err := config.DB.Where("phone_number =?", request.GuestPhone).First(&info).Error; err != nil {
-		userId = nil
-	} else {
-		userId = &info.ID
+	if request.GuestPhone != "" {
+		if info, err := o.Users.FindByPhone(ctx, request.GuestPhone); err == nil {
+			userId = &info.ID
+		}
 	}
 	order := models.Order{
 		UserID:          userId,
@@ -337,111 +345,207 @@ func CreateOrder(c *gin.Context) {
 		GuestName:       request.GuestName,
 		GuestEmail:      request.GuestEmail,
 		GuestPhone:      request.GuestPhone,
+		Adults:          request.Adults,
+		Children:        request.Children,
 		CreatedAt:       time.Now(),
 		UpdatedAt:       time.Now(),
 	}
 
 	numDays := int(checkOutDate.Sub(checkInDate).Hours() / 24)
 	if numDays <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Ngày trả phòng phải sau ngày nhận phòng"})
-		return
+		return orderQuote{}, apperrors.BadRequest("Ngày trả phòng phải sau ngày nhận phòng")
 	}
 
 	price := 0
 	soldOutPrice := 0.0
 
-	var accommodation models.Accommodation
-	if err := config.DB.First(&accommodation, request.AccommodationID).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể tìm thấy thông tin chỗ ở"})
-		return
+	accommodation, err := o.Accommodations.FindByID(ctx, request.AccommodationID)
+	if err != nil {
+		return orderQuote{}, apperrors.Internal("Không thể tìm thấy thông tin chỗ ở").Wrap(err)
+	}
+	if accommodation.Status != models.ListingPublished {
+		return orderQuote{}, apperrors.BadRequest("Chỗ ở chưa mở nhận đặt phòng")
 	}
 
-	if accommodation.Type == 0 && len(order.RoomID) > 0 {
-		var rooms []models.Room
-		if err := config.DB.Where("room_id IN ?", order.RoomID).Find(&rooms).Error; err != nil || len(rooms) != len(order.RoomID) {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể tìm thấy phòng"})
-			return
+	// Sức chứa tiêu chuẩn và số phòng (căn) được đặt, phòng chưa khai báo sức chứa thì không kiểm tra số khách
+	capacity, units, capacityKnown := 0, 0, true
+	var reservations []models.OrderRoomType
+	if accommodation.Type == 0 && (len(order.RoomID) > 0 || len(request.RoomTypes) > 0) {
+		rooms, err := o.Rooms.FindByIDs(ctx, order.RoomID)
+		if err != nil || len(rooms) != len(order.RoomID) {
+			return orderQuote{}, apperrors.NotFound("Không thể tìm thấy phòng").Wrap(err)
+		}
+
+		plans, err := o.RatePlans.FindByRooms(ctx, order.RoomID)
+		if err != nil {
+			return orderQuote{}, apperrors.Internal("Không thể lấy bảng giá phòng").Wrap(err)
 		}
 
+		typedRooms := map[uint]int{}
 		for _, room := range rooms {
 			if room.AccommodationID != request.AccommodationID {
-				c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "AccommodationID không hợp lệ"})
-				return
+				return orderQuote{}, apperrors.BadRequest("AccommodationID không hợp lệ")
 			}
 
-			var roomStatus []models.RoomStatus
-			err := config.DB.Where("room_id = ? AND status = 1 AND ((from_date < ? AND to_date > ?) OR (from_date < ? AND to_date > ?))",
-				room.RoomId, checkOutDate, checkInDate, checkOutDate, checkInDate).Find(&roomStatus).Error
+			booked, err := o.Orders.IsRoomBooked(ctx, room.RoomId, checkInDate, checkOutDate)
+			if err != nil {
+				return orderQuote{}, apperrors.Internal("Lỗi kiểm tra trạng thái phòng").Wrap(err)
+			}
+
+			if booked {
+				return orderQuote{}, apperrors.ErrDatesUnavailable
+			}
+			// Giá từng đêm theo bảng giá của phòng, phòng thuộc hạng dùng bảng giá của hạng, không có thì dùng giá phòng
+			plan := plans[room.RoomId]
+			if plan == nil && room.RoomTypeID != nil {
+				if plan, err = o.RatePlans.FindByRoomType(ctx, *room.RoomTypeID); err != nil {
+					return orderQuote{}, apperrors.Internal("Không thể lấy bảng giá hạng phòng").Wrap(err)
+				}
+			}
+			roomPrice, _ := services.PriceStay(plan, room.Price, checkInDate, checkOutDate)
+			price += roomPrice
+			capacity += room.People
+			units++
+			capacityKnown = capacityKnown && room.People > 0
+			if room.RoomTypeID != nil {
+				typedRooms[*room.RoomTypeID]++
+			}
+		}
 
+		// Phòng thuộc hạng chọn trực tiếp không được lấy mất chỗ của các đơn đặt theo hạng chưa gán phòng
+		for roomTypeID, count := range typedRooms {
+			free, pending, err := roomTypeAvailability(ctx, o.RoomTypes, roomTypeID, checkInDate, checkOutDate)
 			if err != nil {
-				c.JSON(http.StatusCreated, gin.H{"code": 0, "mess": "Lỗi kiểm tra trạng thái phòng"})
-				return
+				return orderQuote{}, apperrors.Internal("Lỗi kiểm tra trạng thái phòng").Wrap(err)
 			}
+			if len(free)-pending < count {
+				return orderQuote{}, apperrors.ErrDatesUnavailable
+			}
+		}
 
-			if len(roomStatus) > 0 {
-				c.JSON(http.StatusCreated, gin.H{"code": 0, "mess": "Phòng đã được đặt hoặc không khả dụng trong khoảng thời gian này"})
-				return
+		// Đặt theo hạng phòng: giữ số lượng phòng, phòng thật được gán ngay hoặc khi nhận phòng tùy hạng
+		for _, item := range request.RoomTypes {
+			if item.Quantity <= 0 {
+				return orderQuote{}, apperrors.BadRequest("Số lượng phòng phải lớn hơn 0")
+			}
+
+			roomType, err := o.RoomTypes.FindByID(ctx, item.RoomTypeID)
+			if err != nil || roomType.AccommodationID != request.AccommodationID || roomType.Status != 0 {
+				return orderQuote{}, apperrors.NotFound("Hạng phòng không tồn tại")
 			}
-			price += room.Price * numDays
 
+			free, pending, err := roomTypeAvailability(ctx, o.RoomTypes, roomType.ID, checkInDate, checkOutDate)
+			if err != nil {
+				return orderQuote{}, apperrors.Internal("Lỗi kiểm tra trạng thái phòng").Wrap(err)
+			}
+			// Bỏ các phòng đã chọn trong roomId hoặc đã gán cho hạng khác của đơn này
+			free = excludeRooms(free, order.RoomID)
+			if len(free)-pending < item.Quantity {
+				return orderQuote{}, apperrors.ErrDatesUnavailable.WithMessage(fmt.Sprintf("Hạng phòng %s không còn đủ phòng trống trong khoảng thời gian này", roomType.Name))
+			}
+
+			plan, err := o.RatePlans.FindByRoomType(ctx, roomType.ID)
+			if err != nil {
+				return orderQuote{}, apperrors.Internal("Không thể lấy bảng giá hạng phòng").Wrap(err)
+			}
+			typePrice, _ := services.PriceStay(plan, roomType.Price, checkInDate, checkOutDate)
+			price += typePrice * item.Quantity
+			capacity += roomType.People * item.Quantity
+			units += item.Quantity
+			capacityKnown = capacityKnown && roomType.People > 0
+
+			reservation := models.OrderRoomType{RoomTypeID: roomType.ID, RoomType: roomType, Quantity: item.Quantity, FromDate: checkInDate, ToDate: checkOutDate}
+			if !roomType.AssignAtCheckIn {
+				order.RoomID = append(order.RoomID, free[:item.Quantity]...)
+				reservation.Assigned = item.Quantity
+			}
+			reservations = append(reservations, reservation)
 		}
 	} else {
 
-		var accommodationStatus []models.AccommodationStatus
-		if err := config.DB.Where("accommodation_id = ? AND status = 1 AND ((from_date < ? AND to_date > ?) OR (from_date < ? AND to_date > ?))",
-			request.AccommodationID, checkOutDate, checkInDate, checkOutDate, checkInDate).Find(&accommodationStatus).Error; err != nil {
-			c.JSON(http.StatusCreated, gin.H{"code": 0, "mess": "Lỗi kiểm tra trạng thái chỗ ở"})
-			return
+		booked, err := o.Orders.IsAccommodationBooked(ctx, request.AccommodationID, checkInDate, checkOutDate)
+		if err != nil {
+			return orderQuote{}, apperrors.Internal("Lỗi kiểm tra trạng thái chỗ ở").Wrap(err)
 		}
 
-		if len(accommodationStatus) > 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Chỗ ở đã được đặt hoặc không khả dụng trong khoảng thời gian này"})
-			return
+		if booked {
+			return orderQuote{}, apperrors.ErrDatesUnavailable.WithMessage("Chỗ ở đã được đặt hoặc không khả dụng trong khoảng thời gian này")
 		}
 
+		plan, err := o.RatePlans.FindByAccommodation(ctx, request.AccommodationID)
+		if err != nil {
+			return orderQuote{}, apperrors.Internal("Không thể lấy bảng giá chỗ ở").Wrap(err)
+		}
+
+		// Chỗ ở chưa có bảng giá giữ cách tính cũ: một giá cho cả kỳ lưu trú
 		price = accommodation.Price
+		if plan != nil {
+			price, _ = services.PriceStay(plan, accommodation.Price, checkInDate, checkOutDate)
+		}
+		capacity, units = accommodation.People, 1
+		capacityKnown = accommodation.People > 0
+	}
+
+	// Quy định lưu trú của chỗ ở và của các phòng được đặt (kể cả phòng gán tự động theo hạng)
+	restrictions, err := o.Restrictions.ForStay(ctx, request.AccommodationID, order.RoomID)
+	if err != nil {
+		return orderQuote{}, apperrors.Internal("Không thể lấy quy định lưu trú").Wrap(err)
+	}
+	if violations := services.CheckStay(restrictions, checkInDate, checkOutDate, order.CreatedAt); len(violations) > 0 {
+		return orderQuote{}, apperrors.ErrStayRestricted.WithMessage(violations[0].Message).WithDetails(violations)
+	}
+
+	// Số khách tối đa là sức chứa tiêu chuẩn cộng số khách thêm mỗi phòng, khách vượt sức chứa tiêu chuẩn tính phụ thu
+	var guestFee services.GuestFee
+	if capacityKnown {
+		maxGuests := capacity + accommodation.MaxExtraGuests*units
+		if request.Adults+request.Children > maxGuests {
+			return orderQuote{}, apperrors.ErrOverCapacity.
+				WithMessage(fmt.Sprintf("Phòng đã chọn chỉ nhận tối đa %d khách", maxGuests)).
+				WithDetails(map[string]int{"capacity": capacity, "maxGuests": maxGuests})
+		}
+		guestFee = services.ExtraGuestFee(accommodation.ExtraGuestPolicy, request.Adults, request.Children, capacity, numDays)
 	}
 
 	order.Price = price
 	order.SoldOutPrice = soldOutPrice
+	order.ExtraGuestPrice = float64(guestFee.Price)
 
+	var discount *models.Discount
 	if request.UserID != 0 {
 		order.UserID = &request.UserID
-		isEligibleForDiscount := services.CheckUserEligibilityForDiscount(request.UserID)
-		if isEligibleForDiscount {
-			var user models.User
-			if err := config.DB.First(&user, request.UserID).Error; err == nil {
-				discountPrice, err := services.ApplyDiscountForUser(user)
-				if err != nil {
-					fmt.Println(err.Error())
-					return
-				}
-				order.DiscountPrice = float64(price) * discountPrice / 100
-			} else {
-				fmt.Println("Không tìm thấy người dùng")
-				return
-			}
+		if _, err := o.Users.FindByID(ctx, request.UserID); err != nil {
+			return orderQuote{}, apperrors.NotFound("Không tìm thấy người dùng").Wrap(err)
+		}
+		discounts, err := o.Discounts.Active(ctx)
+		if err != nil {
+			return orderQuote{}, apperrors.Internal("Không thể lấy danh sách mã giảm giá").Wrap(err)
+		}
+		usage, err := o.Discounts.UsageByUser(ctx, request.UserID)
+		if err != nil {
+			return orderQuote{}, apperrors.Internal("Lỗi khi kiểm tra lịch sử sử dụng mã giảm giá").Wrap(err)
+		}
+		if picked, ok := services.PickDiscount(discounts, usage); ok {
+			discount = &picked
+			order.DiscountPrice = float64(price) * float64(picked.Discount) / 100
 		}
 	}
 
-	var holidays []models.Holiday
-	if err := config.DB.Find(&holidays).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể lấy thông tin ngày lễ"})
-		return
+	holidays, err := o.Orders.Holidays(ctx)
+	if err != nil {
+		return orderQuote{}, apperrors.Internal("Không thể lấy thông tin ngày lễ").Wrap(err)
 	}
 
 	holidayPrice := 0
 	for _, holiday := range holidays {
 		fromDate, err := time.Parse("02/01/2006", holiday.FromDate)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Ngày bắt đầu kỳ nghỉ không hợp lệ"})
-			return
+			return orderQuote{}, apperrors.Internal("Ngày bắt đầu kỳ nghỉ không hợp lệ").Wrap(err)
 		}
 
 		toDate, err := time.Parse("02/01/2006", holiday.ToDate)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Ngày kết thúc kỳ nghỉ không hợp lệ"})
-			return
+			return orderQuote{}, apperrors.Internal("Ngày kết thúc kỳ nghỉ không hợp lệ").Wrap(err)
 		}
 
 		if (checkInDate.Before(toDate) && checkOutDate.After(fromDate)) ||
@@ -460,57 +564,97 @@ func CreateOrder(c *gin.Context) {
 		order.CheckInRushPrice = 0
 	}
 
-	order.TotalPrice = float64(price) + order.HolidayPrice + order.CheckInRushPrice + order.SoldOutPrice - order.DiscountPrice
-
-	if len(request.RoomID) > 0 {
-		order.RoomID = request.RoomID
-	} else {
-		order.RoomID = []uint{}
-	}
+	order.TotalPrice = float64(price) + order.HolidayPrice + order.CheckInRushPrice + order.SoldOutPrice + order.ExtraGuestPrice - order.DiscountPrice
+
+	return orderQuote{
+		Order:         order,
+		Accommodation: accommodation,
+		CheckIn:       checkInDate,
+		CheckOut:      checkOutDate,
+		Nights:        numDays,
+		GuestFee:      guestFee,
+		Reservations:  reservations,
+		Discount:      discount,
+	}, nil
+}
 
-	if err := config.DB.Create(&order).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể tạo đơn", "detai": err})
+func (o OrderController) CreateOrder(c *gin.Context) {
+	var request CreateOrderRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Error(apperrors.BadRequest("Dữ liệu không hợp lệ"))
 		return
 	}
 
-	if accommodation.Type == 0 && len(order.RoomID) > 0 {
-		var roomsToAppend []models.Room
-		for _, roomID := range request.RoomID {
-			roomsToAppend = append(roomsToAppend, models.Room{RoomId: roomID})
+	var (
+		order models.Order
+		price int
+	)
+	// Kiểm tra lịch trống và giữ chỗ trong cùng một transaction, chỗ ở bị khóa để hai đơn đồng thời không đặt trùng phòng
+	// và đơn không được lưu nếu giữ chỗ thất bại
+	err := o.Tx.Transaction(c.Request.Context(), func(tx repositories.Repositories) error {
+		ctx := c.Request.Context()
+		if err := tx.Orders.LockAccommodation(ctx, request.AccommodationID); err != nil {
+			return apperrors.Internal("Lỗi kiểm tra trạng thái chỗ ở").Wrap(err)
 		}
+		quote, err := o.withRepositories(tx).quoteOrder(ctx, request)
+		if err != nil {
+			return err
+		}
+		order = quote.Order
+		accommodation, reservations := quote.Accommodation, quote.Reservations
+		checkInDate, checkOutDate := quote.CheckIn, quote.CheckOut
+		price = order.Price
 
-		if err := config.DB.Model(&order).Association("Room").Append(roomsToAppend); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể liên kết phòng với đơn hàng", "detail": err})
-			return
+		if order.RoomID == nil {
+			order.RoomID = []uint{}
 		}
 
-		for _, roomID := range request.RoomID {
-			roomStatus := models.RoomStatus{
-				RoomID:   roomID,
-				Status:   1,
-				FromDate: checkInDate,
-				ToDate:   checkOutDate,
+		if err := tx.Orders.Create(ctx, &order); err != nil {
+			return apperrors.Internal("Không thể tạo đơn").Wrap(err)
+		}
+		// Lượt dùng mã giảm giá ghi trong cùng transaction, báo giá hay đơn lưu thất bại không làm mất lượt
+		if quote.Discount != nil {
+			if err := tx.Discounts.RecordUsage(ctx, *order.UserID, quote.Discount.ID); err != nil {
+				return apperrors.Internal("Không thể cập nhật thông tin sử dụng mã giảm giá").Wrap(err)
 			}
-			if err := config.DB.Create(&roomStatus).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể cập nhật trạng thái phòng", "detail": err})
-				return
+		}
+
+		if accommodation.Type == 0 && (len(order.RoomID) > 0 || len(reservations) > 0) {
+			if len(order.RoomID) > 0 {
+				if err := tx.Orders.AttachRooms(ctx, &order, order.RoomID); err != nil {
+					return apperrors.Internal("Không thể liên kết phòng với đơn hàng").Wrap(err)
+				}
+			}
+
+			for i := range reservations {
+				reservations[i].OrderID = order.ID
+			}
+			if err := tx.RoomTypes.CreateReservations(ctx, reservations); err != nil {
+				return apperrors.Internal("Không thể lưu số phòng đặt theo hạng").Wrap(err)
+			}
+
+			for _, roomID := range order.RoomID {
+				if err := tx.Orders.BlockRoom(ctx, roomID, checkInDate, checkOutDate); err != nil {
+					return apperrors.Internal("Không thể cập nhật trạng thái phòng").Wrap(err)
+				}
 			}
+			return nil
 		}
-	} else {
-		roomStatus := models.AccommodationStatus{
-			AccommodationID: request.AccommodationID,
-			Status:          1,
-			FromDate:        checkInDate,
-			ToDate:          checkOutDate,
-		}
-		if err := config.DB.Create(&roomStatus).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể cập nhật trạng thái phòng", "detail": err})
-			return
+		if err := tx.Orders.BlockAccommodation(ctx, request.AccommodationID, checkInDate, checkOutDate); err != nil {
+			return apperrors.Internal("Không thể cập nhật trạng thái phòng").Wrap(err)
 		}
+		return nil
+	})
+	if err != nil {
+		c.Error(err)
+		return
 	}
 
-	if err := config.DB.Preload("User").Preload("Accommodation").Preload("Room").First(&order, order.ID).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể tải dữ liệu đơn hàng sau khi tạo"})
+	recordAudit(c, "order.create", "order", order.ID, nil, order)
+
+	order, err = o.Orders.FindDetail(c.Request.Context(), order.ID)
+	if err != nil {
+		c.Error(apperrors.Internal("Không thể tải dữ liệu đơn hàng sau khi tạo").Wrap(err))
 		return
 	}
 
@@ -523,11 +667,9 @@ func CreateOrder(c *gin.Context) {
 
 	accommodationResponse := convertToOrderAccommodationResponse(order.Accommodation)
 	var roomResponses []OrderRoomResponse
-	if len(request.RoomID) > 0 {
-		for _, room := range order.Room {
-			roomResponse := convertToOrderRoomResponse(room)
-			roomResponses = append(roomResponses, roomResponse)
-		}
+	for _, room := range order.Room {
+		roomResponse := convertToOrderRoomResponse(room)
+		roomResponses = append(roomResponses, roomResponse)
 	}
 
 	orderResponse := OrderUserResponse{
@@ -545,25 +687,91 @@ func CreateOrder(c *gin.Context) {
 		CheckInRushPrice: order.CheckInRushPrice,
 		SoldOutPrice:     order.SoldOutPrice,
 		DiscountPrice:    order.DiscountPrice,
+		ExtraGuestPrice:  order.ExtraGuestPrice,
+		Adults:           order.Adults,
+		Children:         order.Children,
 		TotalPrice:       order.TotalPrice,
+		RoomTypes:        toOrderRoomTypeResponses(order.RoomTypes),
 	}
 
-	//Xóa redis
-	rdb, redisErr := config.ConnectRedis()
-	if redisErr == nil {
-		cacheKey := "orders:all"
-		cacheKeyUser := fmt.Sprintf("orders:all:user:%d", currentUserID)
+	// Xóa cache đơn hàng, hóa đơn và doanh thu
+	invalidateCache(c.Request.Context(), o.Cache, services.ListTag("order"), services.ListTag("invoice"))
+	services.OrdersCreated.Inc()
 
-		_ = services.DeleteFromRedis(config.Ctx, rdb, cacheKey)
-		_ = services.DeleteFromRedis(config.Ctx, rdb, "invoices:all")
-		_ = services.DeleteFromRedis(config.Ctx, rdb, "total_revenue")
-		_ = services.DeleteFromRedis(config.Ctx, rdb, cacheKeyUser)
+	c.JSON(http.StatusCreated, gin.H{"code": 1, "mess": "Tạo đơn thành công", "data": orderResponse})
+}
+
+// OrderQuoteResponse là báo giá của một yêu cầu đặt phòng, chưa giữ chỗ
+type OrderQuoteResponse struct {
+	AccommodationID  uint                    `json:"accommodationId"`
+	RoomID           []uint                  `json:"roomId"` // Gồm cả phòng sẽ được gán tự động theo hạng
+	CheckInDate      string                  `json:"checkInDate"`
+	CheckOutDate     string                  `json:"checkOutDate"`
+	Nights           int                     `json:"nights"`
+	Price            int                     `json:"price"`
+	HolidayPrice     float64                 `json:"holidayPrice"`
+	CheckInRushPrice float64                 `json:"checkInRushPrice"`
+	SoldOutPrice     float64                 `json:"soldOutPrice"`
+	DiscountPrice    float64                 `json:"discountPrice"`
+	ExtraGuestPrice  float64                 `json:"extraGuestPrice"`
+	Adults           int                     `json:"adults"`
+	Children         int                     `json:"children"`
+	ExtraAdults      int                     `json:"extraAdults"`   // Số người lớn vượt sức chứa tiêu chuẩn
+	ExtraChildren    int                     `json:"extraChildren"` // Số trẻ em vượt sức chứa tiêu chuẩn
+	TotalPrice       float64                 `json:"totalPrice"`
+	RoomTypes        []OrderRoomTypeResponse `json:"roomTypes,omitempty"`
+}
+
+// QuoteOrder godoc
+// @Summary Báo giá đơn đặt phòng
+// @Description Kiểm tra lịch trống, quy định lưu trú và tính giá giống tạo đơn nhưng không lưu đơn
+// @Tags order
+// @Accept json
+// @Produce json
+// @Param request body CreateOrderRequest true "Yêu cầu đặt phòng"
+// @Success 200 {object} gin.H {"code": 1, "mess": "...", "data": OrderQuoteResponse}
+// @Router /orderQuote [post]
+func (o OrderController) QuoteOrder(c *gin.Context) {
+	var request CreateOrderRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Error(apperrors.BadRequest("Dữ liệu không hợp lệ"))
+		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"code": 1, "mess": "Tạo đơn thành công", "data": orderResponse})
+	quote, err := o.quoteOrder(c.Request.Context(), request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	order := quote.Order
+	response := OrderQuoteResponse{
+		AccommodationID:  order.AccommodationID,
+		RoomID:           order.RoomID,
+		CheckInDate:      order.CheckInDate,
+		CheckOutDate:     order.CheckOutDate,
+		Nights:           quote.Nights,
+		Price:            order.Price,
+		HolidayPrice:     order.HolidayPrice,
+		CheckInRushPrice: order.CheckInRushPrice,
+		SoldOutPrice:     order.SoldOutPrice,
+		DiscountPrice:    order.DiscountPrice,
+		ExtraGuestPrice:  order.ExtraGuestPrice,
+		Adults:           order.Adults,
+		Children:         order.Children,
+		TotalPrice:       order.TotalPrice,
+		ExtraAdults:      quote.GuestFee.ExtraAdults,
+		ExtraChildren:    quote.GuestFee.ExtraChildren,
+		RoomTypes:        toOrderRoomTypeResponses(quote.Reservations),
+	}
+	if response.RoomID == nil {
+		response.RoomID = []uint{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Báo giá thành công", "data": response})
 }
 
-func ChangeOrderStatus(c *gin.Context) {
+func (o OrderController) ChangeOrderStatus(c *gin.Context) {
 	type StatusUpdateRequest struct {
 		ID         uint    `json:"id"`
 		Status     int     `json:"status"`
@@ -572,60 +780,69 @@ func ChangeOrderStatus(c *gin.Context) {
 
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Authorization header is missing"})
+		c.Error(apperrors.Unauthorized("Authorization header is missing"))
 		return
 	}
 
 	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 
-	currentUserID, currentUserRole, err := GetUserIDFromToken(tokenString)
+	_, currentUserRole, err := GetUserIDFromToken(tokenString)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Invalid token"})
+		c.Error(apperrors.Unauthorized("Invalid token"))
 		return
 	}
 
 	var req StatusUpdateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Dữ liệu không hợp lệ"})
+		c.Error(apperrors.BadRequest("Dữ liệu không hợp lệ"))
 		return
 	}
 
-	var order models.Order
-	if err := config.DB.First(&order, req.ID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"code": 0, "mess": "Đơn hàng không tồn tại"})
+	order, err := o.Orders.FindByID(c.Request.Context(), req.ID)
+	if err != nil {
+		c.Error(apperrors.NotFound("Đơn hàng không tồn tại"))
 		return
 	}
+	before := order
 
 	//Kiểm tra nếu người dùng (userRol == 0) đặt đơn chưa quá 24h thì cho Hủy đơn
 	if currentUserRole == 0 && req.Status == 2 {
 		timeSinceCreation := time.Since(order.CreatedAt)
 		if timeSinceCreation.Hours() > 24 {
-			c.JSON(http.StatusAccepted, gin.H{"code": 0, "mess": "Liên hệ Admin để được hủy đơn"})
+			c.Error(apperrors.Forbidden("Liên hệ Admin để được hủy đơn"))
 			return
 		}
 	}
 
 	if req.Status == 2 {
-		if len(order.RoomID) > 0 {
-			for _, room := range order.Room {
-				var roomStatus models.RoomStatus
-				if err := config.DB.Where("room_id = ? AND status = ?", room.RoomId, 1).First(&roomStatus).Error; err == nil {
-					roomStatus.Status = 0
-					if err := config.DB.Save(&roomStatus).Error; err != nil {
-						c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Lỗi khi cập nhật trạng thái phòng"})
-						return
-					}
-				}
-			}
-		} else {
-			var accommodationStatus models.AccommodationStatus
-			if err := config.DB.Where("accommodation_id = ? AND status = ?", order.AccommodationID, 1).First(&accommodationStatus).Error; err == nil {
-				accommodationStatus.Status = 0
-				if err := config.DB.Save(&accommodationStatus).Error; err != nil {
-					c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Lỗi khi cập nhật trạng thái accommodation"})
+		checkInDate, err := time.Parse("02/01/2006", order.CheckInDate)
+		if err != nil {
+			c.Error(apperrors.Internal("Ngày nhận phòng của đơn không hợp lệ").Wrap(err))
+			return
+		}
+		checkOutDate, err := time.Parse("02/01/2006", order.CheckOutDate)
+		if err != nil {
+			c.Error(apperrors.Internal("Ngày trả phòng của đơn không hợp lệ").Wrap(err))
+			return
+		}
+		// FindByID không nạp phòng của đơn, cần bản chi tiết để biết phòng nào phải trả lại
+		detail, err := o.Orders.FindDetail(c.Request.Context(), order.ID)
+		if err != nil {
+			c.Error(apperrors.Internal("Không thể lấy phòng của đơn hàng").Wrap(err))
+			return
+		}
+		if len(detail.Room) > 0 {
+			for _, room := range detail.Room {
+				if err := o.Orders.ReleaseRoom(c.Request.Context(), room.RoomId, checkInDate, checkOutDate); err != nil {
+					c.Error(apperrors.Internal("Lỗi khi cập nhật trạng thái phòng").Wrap(err))
 					return
 				}
 			}
+		} else if len(detail.RoomTypes) == 0 {
+			if err := o.Orders.ReleaseAccommodation(c.Request.Context(), order.AccommodationID, checkInDate, checkOutDate); err != nil {
+				c.Error(apperrors.Internal("Lỗi khi cập nhật trạng thái accommodation").Wrap(err))
+				return
+			}
 		}
 	}
 
@@ -639,47 +856,157 @@ func ChangeOrderStatus(c *gin.Context) {
 			RemainingAmount: Remaining,
 		}
 
-		if err := config.DB.Create(&invoice).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Lỗi khi tạo hóa đơn"})
+		if err := o.Invoices.Create(c.Request.Context(), &invoice); err != nil {
+			c.Error(apperrors.Internal("Lỗi khi tạo hóa đơn").Wrap(err))
 			return
 		}
+		recordAudit(c, "invoice.create", "invoice", invoice.ID, nil, invoice)
 	}
 
 	order.Status = req.Status
 	order.UpdatedAt = time.Now()
 
-	if err := config.DB.Save(&order).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể chuyển trạng thái đơn hàng"})
+	if err := o.Orders.Save(c.Request.Context(), &order); err != nil {
+		c.Error(apperrors.Internal("Không thể chuyển trạng thái đơn hàng").Wrap(err))
 		return
 	}
+	recordAudit(c, "order.status", "order", order.ID, before, order)
+	if req.Status == 2 && before.Status != 2 {
+		services.OrdersCancelled.Inc()
+	}
 
-	//Xóa redis
-	rdb, redisErr := config.ConnectRedis()
-	if redisErr == nil {
-		cacheKey := "orders:all"
-		cacheKeyUser := fmt.Sprintf("orders:all:user:%d", currentUserID)
+	// Xóa cache đơn hàng, hóa đơn và doanh thu
+	invalidateCache(c.Request.Context(), o.Cache, services.ListTag("order"), services.ListTag("invoice"))
 
-		_ = services.DeleteFromRedis(config.Ctx, rdb, cacheKey)
-		_ = services.DeleteFromRedis(config.Ctx, rdb, "invoices:all")
-		_ = services.DeleteFromRedis(config.Ctx, rdb, "total_revenue")
-		_ = services.DeleteFromRedis(config.Ctx, rdb, cacheKeyUser)
+	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Trạng thái đơn hàng đã được cập nhật"})
+}
 
+// AssignRooms godoc
+// @Summary Lễ tân gán phòng thật cho đơn đặt theo hạng phòng
+// @Description Mỗi phòng phải thuộc một hạng còn phòng chưa gán trong đơn và còn trống trong thời gian lưu trú
+// @Tags order
+// @Accept json
+// @Produce json
+// @Success 200 {object} gin.H {"code": 1, "mess": "...", "data": []OrderRoomTypeResponse}
+// @Router /orderAssignRooms [put]
+func (o OrderController) AssignRooms(c *gin.Context) {
+	var request struct {
+		ID      uint   `json:"id"`
+		RoomIDs []uint `json:"roomIds"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil || len(request.RoomIDs) == 0 {
+		c.Error(apperrors.Validation(err))
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Trạng thái đơn hàng đã được cập nhật"})
-}
+	order, err := o.Orders.FindByID(c.Request.Context(), request.ID)
+	if err != nil {
+		c.Error(apperrors.NotFound("Đơn hàng không tồn tại"))
+		return
+	}
+	accommodation, err := o.Accommodations.FindByID(c.Request.Context(), order.AccommodationID)
+	if err != nil {
+		c.Error(apperrors.NotFound("Chỗ ở không tồn tại"))
+		return
+	}
+	if !requireAccommodationManager(c, o.Users, accommodation) {
+		return
+	}
+
+	checkInDate, err := time.Parse("02/01/2006", order.CheckInDate)
+	if err != nil {
+		c.Error(apperrors.Internal("Ngày nhận phòng của đơn không hợp lệ").Wrap(err))
+		return
+	}
+	checkOutDate, err := time.Parse("02/01/2006", order.CheckOutDate)
+	if err != nil {
+		c.Error(apperrors.Internal("Ngày trả phòng của đơn không hợp lệ").Wrap(err))
+		return
+	}
+
+	var before, response []OrderRoomTypeResponse
+	// Khóa chỗ ở giống CreateOrder để kiểm tra lịch trống và giữ phòng không chạy xen với đơn mới
+	err = o.Tx.Transaction(c.Request.Context(), func(tx repositories.Repositories) error {
+		ctx := c.Request.Context()
+		if err := tx.Orders.LockAccommodation(ctx, order.AccommodationID); err != nil {
+			return apperrors.Internal("Lỗi kiểm tra trạng thái chỗ ở").Wrap(err)
+		}
+		// Đọc lại đơn sau khi khóa, đơn có thể vừa bị hủy
+		order, err := tx.Orders.FindByID(ctx, order.ID)
+		if err != nil {
+			return apperrors.NotFound("Đơn hàng không tồn tại")
+		}
+		if order.Status == 2 {
+			return apperrors.BadRequest("Đơn hàng đã bị hủy")
+		}
+
+		reservations, err := tx.RoomTypes.ListReservations(ctx, order.ID)
+		if err != nil {
+			return apperrors.Internal("Không thể lấy số phòng đặt theo hạng").Wrap(err)
+		}
+		before = toOrderRoomTypeResponses(reservations)
+
+		rooms, err := tx.Rooms.FindByIDs(ctx, request.RoomIDs)
+		if err != nil || len(rooms) != len(request.RoomIDs) {
+			return apperrors.NotFound("Không thể tìm thấy phòng").Wrap(err)
+		}
 
-func GetOrderDetail(c *gin.Context) {
-	orderId := c.Param("id")
+		changed := map[int]bool{}
+		for _, room := range rooms {
+			index := -1
+			for i, reservation := range reservations {
+				if room.RoomTypeID != nil && reservation.RoomTypeID == *room.RoomTypeID && reservation.Assigned < reservation.Quantity {
+					index = i
+					break
+				}
+			}
+			if index < 0 {
+				return apperrors.BadRequest(fmt.Sprintf("Phòng %s không thuộc hạng phòng còn chờ gán của đơn", room.RoomName))
+			}
 
-	var order models.Order
-	if err := config.DB.Preload("User").
-		Preload("Accommodation").
-		Preload("Room").
-		Where("id = ?", orderId).
-		First(&order).Error; err != nil {
+			booked, err := tx.Orders.IsRoomBooked(ctx, room.RoomId, checkInDate, checkOutDate)
+			if err != nil {
+				return apperrors.Internal("Lỗi kiểm tra trạng thái phòng").Wrap(err)
+			}
+			if booked {
+				return apperrors.ErrDatesUnavailable.WithMessage(fmt.Sprintf("Phòng %s đã được đặt trong khoảng thời gian này", room.RoomName))
+			}
+			reservations[index].Assigned++
+			changed[index] = true
+		}
 
-		c.JSON(http.StatusNotFound, gin.H{"code": 0, "error": "Không tìm thấy Order"})
+		if err := tx.Orders.AttachRooms(ctx, &order, request.RoomIDs); err != nil {
+			return apperrors.Internal("Không thể liên kết phòng với đơn hàng").Wrap(err)
+		}
+		for _, roomID := range request.RoomIDs {
+			if err := tx.Orders.BlockRoom(ctx, roomID, checkInDate, checkOutDate); err != nil {
+				return apperrors.Internal("Không thể cập nhật trạng thái phòng").Wrap(err)
+			}
+		}
+		for index := range changed {
+			if err := tx.RoomTypes.SaveReservation(ctx, &reservations[index]); err != nil {
+				return apperrors.Internal("Không thể cập nhật số phòng đã gán").Wrap(err)
+			}
+		}
+		response = toOrderRoomTypeResponses(reservations)
+		return nil
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	recordAudit(c, "order.assign_rooms", "order", order.ID, before, response)
+	invalidateCache(c.Request.Context(), o.Cache, services.ListTag("order"))
+
+	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Gán phòng thành công", "data": response})
+}
+
+func (o OrderController) GetOrderDetail(c *gin.Context) {
+	order, err := o.Orders.FindDetail(c.Request.Context(), paramID(c, "id"))
+	if err != nil {
+
+		c.Error(apperrors.NotFound("Không tìm thấy Order"))
 		return
 	}
 	var user Actor
@@ -711,15 +1038,19 @@ func GetOrderDetail(c *gin.Context) {
 		CheckInRushPrice: order.CheckInRushPrice,
 		SoldOutPrice:     order.SoldOutPrice,
 		DiscountPrice:    order.DiscountPrice,
+		ExtraGuestPrice:  order.ExtraGuestPrice,
+		Adults:           order.Adults,
+		Children:         order.Children,
 		TotalPrice:       order.TotalPrice,
+		RoomTypes:        toOrderRoomTypeResponses(order.RoomTypes),
 	}
 	c.JSON(http.StatusOK, gin.H{"code": 1, "data": orderResponse})
 }
 
-func GetOrdersByUserId(c *gin.Context) {
+func (o OrderController) GetOrdersByUserId(c *gin.Context) {
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Authorization header is missing"})
+		c.Error(apperrors.Unauthorized("Authorization header is missing"))
 		return
 	}
 
@@ -727,7 +1058,7 @@ func GetOrdersByUserId(c *gin.Context) {
 	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 	currentUserID, _, err := GetUserIDFromToken(tokenString)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Invalid token"})
+		c.Error(apperrors.Unauthorized("Invalid token"))
 		return
 	}
 	pageStr := c.Query("page")
@@ -747,24 +1078,9 @@ func GetOrdersByUserId(c *gin.Context) {
 		}
 	}
 
-	var totalOrders int64
-	if err := config.DB.Model(&models.Order{}).Where("user_id = ?", currentUserID).Count(&totalOrders).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Lỗi khi đếm đơn đặt"})
-		return
-	}
-
-	var orders []models.Order
-	result := config.DB.Preload("User").
-		Preload("Accommodation").
-		Preload("Room").
-		Where("user_id = ?", currentUserID).
-		Order("created_at DESC").
-		Offset(page * limit).
-		Limit(limit).
-		Find(&orders)
-
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Lỗi khi lấy thông tin đơn đặt!"})
+	orders, totalOrders, err := o.Orders.ListByUser(c.Request.Context(), currentUserID, page, limit)
+	if err != nil {
+		c.Error(apperrors.Internal("Lỗi khi lấy thông tin đơn đặt!").Wrap(err))
 		return
 	}
 	if len(orders) == 0 {
@@ -790,8 +1106,7 @@ func GetOrdersByUserId(c *gin.Context) {
 
 		var invoiceCode string
 		if order.Status == 1 {
-			var invoice models.Invoice
-			if err := config.DB.Where("order_id = ?", order.ID).First(&invoice).Error; err == nil {
+			if invoice, err := o.Invoices.FindByOrderID(c.Request.Context(), order.ID); err == nil {
 				invoiceCode = invoice.InvoiceCode
 			}
 		}
@@ -811,6 +1126,9 @@ func GetOrdersByUserId(c *gin.Context) {
 			CheckInRushPrice: order.CheckInRushPrice,
 			SoldOutPrice:     order.SoldOutPrice,
 			DiscountPrice:    order.DiscountPrice,
+			ExtraGuestPrice:  order.ExtraGuestPrice,
+			Adults:           order.Adults,
+			Children:         order.Children,
 			TotalPrice:       order.TotalPrice,
 			InvoiceCode:      invoiceCode,
 		}
@@ -0,0 +1,334 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"new/apperrors"
+	"new/models"
+	"new/repositories"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Các repository giả chỉ cài những phương thức quoteOrder dùng, phương thức khác panic vì interface nhúng là nil
+
+type fakeOrders struct {
+	repositories.OrderRepository
+	bookedRooms         map[uint]bool
+	accommodationBooked bool
+}
+
+func (f fakeOrders) IsRoomBooked(_ context.Context, roomID uint, _, _ time.Time) (bool, error) {
+	return f.bookedRooms[roomID], nil
+}
+
+func (f fakeOrders) IsAccommodationBooked(context.Context, uint, time.Time, time.Time) (bool, error) {
+	return f.accommodationBooked, nil
+}
+
+func (f fakeOrders) Holidays(context.Context) ([]models.Holiday, error) {
+	return nil, nil
+}
+
+type fakeAccommodations struct {
+	repositories.AccommodationRepository
+	items map[uint]models.Accommodation
+}
+
+func (f fakeAccommodations) FindByID(_ context.Context, id uint) (models.Accommodation, error) {
+	if accommodation, ok := f.items[id]; ok {
+		return accommodation, nil
+	}
+	return models.Accommodation{}, gorm.ErrRecordNotFound
+}
+
+type fakeRooms struct {
+	repositories.RoomRepository
+	items map[uint]models.Room
+}
+
+func (f fakeRooms) FindByIDs(_ context.Context, ids []uint) ([]models.Room, error) {
+	var rooms []models.Room
+	for _, id := range ids {
+		if room, ok := f.items[id]; ok {
+			rooms = append(rooms, room)
+		}
+	}
+	return rooms, nil
+}
+
+type fakeUsers struct {
+	repositories.UserRepository
+}
+
+func (fakeUsers) FindByPhone(context.Context, string) (models.User, error) {
+	return models.User{}, gorm.ErrRecordNotFound
+}
+
+func (fakeUsers) FindByID(_ context.Context, id uint) (models.User, error) {
+	return models.User{ID: id}, nil
+}
+
+// fakeDiscounts giữ lượt dùng mã theo người dùng, RecordUsage ghi vào usage để kiểm tra báo giá không làm mất lượt
+type fakeDiscounts struct {
+	repositories.DiscountRepository
+	active []models.Discount
+	usage  map[uint]map[uint]int
+}
+
+func (f fakeDiscounts) Active(context.Context) ([]models.Discount, error) {
+	return f.active, nil
+}
+
+func (f fakeDiscounts) UsageByUser(_ context.Context, userID uint) (map[uint]int, error) {
+	return f.usage[userID], nil
+}
+
+func (f fakeDiscounts) RecordUsage(_ context.Context, userID, discountID uint) error {
+	if f.usage[userID] == nil {
+		f.usage[userID] = map[uint]int{}
+	}
+	f.usage[userID][discountID]++
+	return nil
+}
+
+type fakeRatePlans struct {
+	repositories.RatePlanRepository
+	accommodations map[uint]*models.RatePlan
+}
+
+func (f fakeRatePlans) FindByRooms(context.Context, []uint) (map[uint]*models.RatePlan, error) {
+	return map[uint]*models.RatePlan{}, nil
+}
+
+func (f fakeRatePlans) FindByRoomType(context.Context, uint) (*models.RatePlan, error) {
+	return nil, nil
+}
+
+func (f fakeRatePlans) FindByAccommodation(_ context.Context, id uint) (*models.RatePlan, error) {
+	return f.accommodations[id], nil
+}
+
+type fakeRoomTypes struct {
+	repositories.RoomTypeRepository
+	items   map[uint]models.RoomType
+	free    map[uint][]uint
+	pending map[uint]int
+}
+
+func (f fakeRoomTypes) FindByID(_ context.Context, id uint) (models.RoomType, error) {
+	if roomType, ok := f.items[id]; ok {
+		return roomType, nil
+	}
+	return models.RoomType{}, gorm.ErrRecordNotFound
+}
+
+func (f fakeRoomTypes) FreeRooms(_ context.Context, id uint, _, _ time.Time) ([]uint, error) {
+	return f.free[id], nil
+}
+
+func (f fakeRoomTypes) PendingReservations(_ context.Context, id uint, _, _ time.Time) (int, error) {
+	return f.pending[id], nil
+}
+
+type fakeRestrictions struct {
+	repositories.StayRestrictionRepository
+	items []models.StayRestriction
+}
+
+func (f fakeRestrictions) ForStay(context.Context, uint, []uint) ([]models.StayRestriction, error) {
+	return f.items, nil
+}
+
+func TestQuoteOrder(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Đặt trước 30 ngày để không tính phụ phí nhận phòng gấp
+	checkIn := time.Now().AddDate(0, 0, 30)
+	day := func(offset int) string { return checkIn.AddDate(0, 0, offset).Format("02/01/2006") }
+	roomTypeID := uint(7)
+
+	accommodations := map[uint]models.Accommodation{
+		// Homestay cho thuê nguyên căn
+		1: {ID: 1, Type: 1, Status: models.ListingPublished, Price: 500, People: 4},
+		// Khách sạn nhận thêm 1 khách mỗi phòng, phụ thu 100/người lớn/đêm
+		2: {ID: 2, Type: 0, Status: models.ListingPublished, People: 2,
+			ExtraGuestPolicy: models.ExtraGuestPolicy{MaxExtraGuests: 1, ExtraAdultPrice: 100, ExtraChildPrice: 50}},
+		3: {ID: 3, Type: 1, Status: models.ListingApproved, Price: 500},
+		// Homestay có bảng giá riêng
+		4: {ID: 4, Type: 1, Status: models.ListingPublished, Price: 500, People: 4},
+	}
+	rooms := map[uint]models.Room{
+		21: {RoomId: 21, AccommodationID: 2, Price: 300, People: 2},
+		22: {RoomId: 22, AccommodationID: 2, Price: 300, People: 2},
+		31: {RoomId: 31, AccommodationID: 3, Price: 300, People: 2},
+	}
+	plans := map[uint]*models.RatePlan{
+		4: {Overrides: []models.RateOverride{{Date: checkIn, Price: 900}}},
+	}
+	roomTypes := fakeRoomTypes{
+		items:   map[uint]models.RoomType{roomTypeID: {ID: roomTypeID, AccommodationID: 2, Name: "Deluxe", Price: 400, People: 2}},
+		free:    map[uint][]uint{roomTypeID: {71, 72, 73}},
+		pending: map[uint]int{roomTypeID: 1},
+	}
+
+	tests := []struct {
+		name         string
+		request      CreateOrderRequest
+		restrictions []models.StayRestriction
+		wantStatus   int
+		wantCode     string
+		want         OrderQuoteResponse // Chỉ so sánh khi wantStatus là 200
+	}{
+		{
+			name:       "nguyên căn theo giá chỗ ở",
+			request:    CreateOrderRequest{AccommodationID: 1, CheckInDate: day(0), CheckOutDate: day(2), Adults: 2},
+			wantStatus: http.StatusOK,
+			want: OrderQuoteResponse{AccommodationID: 1, RoomID: []uint{}, CheckInDate: day(0), CheckOutDate: day(2),
+				Nights: 2, Price: 500, Adults: 2, TotalPrice: 500},
+		},
+		{
+			name:       "người dùng được giảm theo mã cao nhất còn lượt",
+			request:    CreateOrderRequest{UserID: 5, AccommodationID: 1, CheckInDate: day(0), CheckOutDate: day(2), Adults: 2},
+			wantStatus: http.StatusOK,
+			want: OrderQuoteResponse{AccommodationID: 1, RoomID: []uint{}, CheckInDate: day(0), CheckOutDate: day(2),
+				Nights: 2, Price: 500, DiscountPrice: 50, Adults: 2, TotalPrice: 450},
+		},
+		{
+			name:       "nguyên căn theo bảng giá",
+			request:    CreateOrderRequest{AccommodationID: 4, CheckInDate: day(0), CheckOutDate: day(2)},
+			wantStatus: http.StatusOK,
+			want: OrderQuoteResponse{AccommodationID: 4, RoomID: []uint{}, CheckInDate: day(0), CheckOutDate: day(2),
+				Nights: 2, Price: 1400, Adults: 1, TotalPrice: 1400},
+		},
+		{
+			name:       "phòng khách sạn có phụ thu khách thêm",
+			request:    CreateOrderRequest{AccommodationID: 2, RoomID: []uint{21}, CheckInDate: day(0), CheckOutDate: day(2), Adults: 3},
+			wantStatus: http.StatusOK,
+			want: OrderQuoteResponse{AccommodationID: 2, RoomID: []uint{21}, CheckInDate: day(0), CheckOutDate: day(2),
+				Nights: 2, Price: 600, ExtraGuestPrice: 200, Adults: 3, ExtraAdults: 1, TotalPrice: 800},
+		},
+		{
+			name: "đặt theo hạng phòng gán phòng trống",
+			request: CreateOrderRequest{AccommodationID: 2, CheckInDate: day(0), CheckOutDate: day(1), Adults: 4,
+				RoomTypes: []RoomTypeQuantity{{RoomTypeID: roomTypeID, Quantity: 2}}},
+			wantStatus: http.StatusOK,
+			want: OrderQuoteResponse{AccommodationID: 2, RoomID: []uint{71, 72}, CheckInDate: day(0), CheckOutDate: day(1),
+				Nights: 1, Price: 800, Adults: 4, TotalPrice: 800,
+				RoomTypes: []OrderRoomTypeResponse{{RoomTypeID: roomTypeID, Name: "Deluxe", Quantity: 2, Assigned: 2}}},
+		},
+		{
+			name: "hạng phòng không đủ phòng trống sau khi trừ đơn chưa gán",
+			request: CreateOrderRequest{AccommodationID: 2, CheckInDate: day(0), CheckOutDate: day(1),
+				RoomTypes: []RoomTypeQuantity{{RoomTypeID: roomTypeID, Quantity: 3}}},
+			wantStatus: http.StatusConflict,
+			wantCode:   apperrors.CodeUnavailableDates,
+		},
+		{
+			name:       "phòng đã được đặt",
+			request:    CreateOrderRequest{AccommodationID: 2, RoomID: []uint{22}, CheckInDate: day(0), CheckOutDate: day(1)},
+			wantStatus: http.StatusConflict,
+			wantCode:   apperrors.CodeUnavailableDates,
+		},
+		{
+			name:       "vượt sức chứa kể cả khách thêm",
+			request:    CreateOrderRequest{AccommodationID: 2, RoomID: []uint{21}, CheckInDate: day(0), CheckOutDate: day(1), Adults: 3, Children: 1},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   apperrors.CodeOverCapacity,
+		},
+		{
+			name:         "vi phạm số đêm tối thiểu",
+			request:      CreateOrderRequest{AccommodationID: 1, CheckInDate: day(0), CheckOutDate: day(1)},
+			restrictions: []models.StayRestriction{{MinNights: 2}},
+			wantStatus:   http.StatusUnprocessableEntity,
+			wantCode:     apperrors.CodeStayRestricted,
+		},
+		{
+			name:       "chỗ ở chưa đăng",
+			request:    CreateOrderRequest{AccommodationID: 3, CheckInDate: day(0), CheckOutDate: day(1)},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "phòng thuộc chỗ ở khác",
+			request:    CreateOrderRequest{AccommodationID: 2, RoomID: []uint{31}, CheckInDate: day(0), CheckOutDate: day(1)},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "ngày trả phòng trước ngày nhận phòng",
+			request:    CreateOrderRequest{AccommodationID: 1, CheckInDate: day(2), CheckOutDate: day(1)},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "ngày nhận phòng đã qua",
+			request:    CreateOrderRequest{AccommodationID: 1, CheckInDate: day(-40), CheckOutDate: day(1)},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Người dùng 5 đã dùng hết lượt mã 20%, còn 1 lượt mã 10%
+			discounts := fakeDiscounts{
+				active: []models.Discount{{ID: 1, Discount: 20, Quantity: 1}, {ID: 2, Discount: 10, Quantity: 2}},
+				usage:  map[uint]map[uint]int{5: {1: 1, 2: 1}},
+			}
+			controller := NewOrderController(
+				fakeOrders{bookedRooms: map[uint]bool{22: true}},
+				fakeAccommodations{items: accommodations},
+				fakeRooms{items: rooms},
+				nil,
+				fakeUsers{},
+				fakeRatePlans{accommodations: plans},
+				roomTypes,
+				fakeRestrictions{items: tt.restrictions},
+				discounts,
+				nil, nil,
+			)
+
+			body, _ := json.Marshal(tt.request)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/orderQuote", bytes.NewReader(body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			controller.QuoteOrder(c)
+
+			if tt.wantStatus != http.StatusOK {
+				if len(c.Errors) == 0 {
+					t.Fatalf("QuoteOrder succeeded with %s, want status %d", w.Body, tt.wantStatus)
+				}
+				appErr := apperrors.From(c.Errors.Last().Err)
+				if appErr.Status != tt.wantStatus || (tt.wantCode != "" && appErr.Code != tt.wantCode) {
+					t.Errorf("error = %d %s (%s), want %d %s", appErr.Status, appErr.Code, appErr.Message, tt.wantStatus, tt.wantCode)
+				}
+				return
+			}
+
+			if len(c.Errors) > 0 {
+				t.Fatalf("QuoteOrder error: %v", c.Errors.Last().Err)
+			}
+			var response struct {
+				Code int                `json:"code"`
+				Data OrderQuoteResponse `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != http.StatusOK || response.Code != 1 {
+				t.Errorf("status = %d, code = %d", w.Code, response.Code)
+			}
+			if !reflect.DeepEqual(response.Data, tt.want) {
+				t.Errorf("quote = %+v\nwant    %+v", response.Data, tt.want)
+			}
+			// Báo giá không được ghi lượt dùng mã giảm giá
+			if want := (map[uint]map[uint]int{5: {1: 1, 2: 1}}); !reflect.DeepEqual(discounts.usage, want) {
+				t.Errorf("discount usage after quote = %v, want %v", discounts.usage, want)
+			}
+		})
+	}
+}
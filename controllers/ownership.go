@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"context"
+	"new/apperrors"
+	"new/models"
+	"new/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+// canManageAccommodation kiểm tra người dùng được quản lý chỗ ở: quản trị viên cao nhất quản lý mọi chỗ ở,
+// admin quản lý chỗ ở của mình, lễ tân quản lý chỗ ở của admin quản lý mình
+func canManageAccommodation(ctx context.Context, users repositories.UserRepository, accommodation models.Accommodation, userID uint, role int) (bool, error) {
+	switch role {
+	case 1:
+		return true, nil
+	case 2:
+		return accommodation.UserID == userID, nil
+	case 3:
+		adminID, err := users.AdminID(ctx, userID)
+		if err != nil {
+			return false, err
+		}
+		return adminID != 0 && accommodation.UserID == adminID, nil
+	}
+	return false, nil
+}
+
+// requireAccommodationManager trả 403 nếu người gọi (đã qua AuthMiddleware) không quản lý chỗ ở.
+// Trả về false nếu đã phản hồi lỗi.
+func requireAccommodationManager(c *gin.Context, users repositories.UserRepository, accommodation models.Accommodation) bool {
+	userID, role := auditActor(c)
+	allowed, err := canManageAccommodation(c.Request.Context(), users, accommodation, userID, role)
+	if err != nil {
+		c.Error(apperrors.Internal("Lỗi khi kiểm tra quyền").Wrap(err))
+		return false
+	}
+	if !allowed {
+		c.Error(apperrors.Forbidden("Bạn không có quyền quản lý chỗ ở này"))
+		return false
+	}
+	return true
+}
+
+// requireRoomManager giống requireAccommodationManager cho chỗ ở chứa phòng
+func requireRoomManager(c *gin.Context, users repositories.UserRepository, accommodations repositories.AccommodationRepository, room models.Room) bool {
+	accommodation, err := accommodations.FindByID(c.Request.Context(), room.AccommodationID)
+	if err != nil {
+		c.Error(apperrors.NotFound("Chỗ ở không tồn tại"))
+		return false
+	}
+	return requireAccommodationManager(c, users, accommodation)
+}
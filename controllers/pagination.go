@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"new/apperrors"
+	"new/repositories"
+	"new/services"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Đọc page/limit/cursor từ query, cursor có thì ưu tiên phân trang keyset
+func parsePageRequest(c *gin.Context) (services.PageRequest, error) {
+	p := services.PageRequest{Page: 0, Limit: services.DefaultPageLimit}
+	if parsedPage, err := strconv.Atoi(c.Query("page")); err == nil && parsedPage >= 0 {
+		p.Page = parsedPage
+	}
+	if parsedLimit, err := strconv.Atoi(c.Query("limit")); err == nil && parsedLimit > 0 {
+		p.Limit = parsedLimit
+	}
+	if p.Limit > services.MaxPageLimit {
+		p.Limit = services.MaxPageLimit
+	}
+
+	if cursorStr := c.Query("cursor"); cursorStr != "" {
+		cursor, err := services.DecodeCursor(cursorStr)
+		if err != nil {
+			return p, err
+		}
+		p.Cursor = cursor
+	}
+	return p, nil
+}
+
+// cachedPage là một trang response được lưu trong cache
+type cachedPage[T any] struct {
+	Data       []T               `json:"data"`
+	Pagination services.PageInfo `json:"pagination"`
+}
+
+// Đọc tham số số nguyên tùy chọn, rỗng hoặc sai định dạng thì bỏ qua bộ lọc
+func queryInt(c *gin.Context, name string) *int {
+	value, err := strconv.Atoi(c.Query(name))
+	if err != nil {
+		return nil
+	}
+	return &value
+}
+
+func queryFloat(c *gin.Context, name string) *float64 {
+	value, err := strconv.ParseFloat(c.Query(name), 64)
+	if err != nil {
+		return nil
+	}
+	return &value
+}
+
+// Đọc id trên path, id sai định dạng được coi như không tồn tại (0)
+func paramID(c *gin.Context, name string) uint {
+	id, err := strconv.ParseUint(c.Param(name), 10, 0)
+	if err != nil {
+		return 0
+	}
+	return uint(id)
+}
+
+// Chủ sở hữu có dữ liệu mà người dùng được xem trong các danh sách quản lý: admin xem của mình,
+// lễ tân xem của admin quản lý, vai trò khác trả về 0 là xem tất cả. Trả về false nếu đã phản hồi lỗi.
+func listOwner(c *gin.Context, users repositories.UserRepository, currentUserID uint, currentUserRole int) (uint, bool) {
+	switch currentUserRole {
+	case 2:
+		return currentUserID, true
+	case 3:
+		adminID, err := users.AdminID(c.Request.Context(), currentUserID)
+		if err != nil || adminID == 0 {
+			c.Error(apperrors.Forbidden("Không có quyền truy cập"))
+			return 0, false
+		}
+		return adminID, true
+	}
+	return 0, true
+}
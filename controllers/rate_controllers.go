@@ -1,10 +1,9 @@
 package controllers
 
 import (
-	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
+	"new/apperrors"
 	"new/config"
 	"new/models"
 	"new/services"
@@ -14,6 +13,14 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+type RateController struct {
+	Cache services.Cache
+}
+
+func NewRateController(cache services.Cache) RateController {
+	return RateController{Cache: cache}
+}
+
 type RateResponse struct {
 	ID              uint      `json:"id"`
 	AccommodationID uint      `json:"accommodationId"`
@@ -39,7 +46,7 @@ type UserInfo struct {
 	Avatar string `json:"avatar"`
 }
 
-func GetAllRates(c *gin.Context) {
+func (r RateController) GetAllRates(c *gin.Context) {
 	accommodationIdFilter := c.DefaultQuery("accommodationId", "")
 
 	cacheKey := "rates:all"
@@ -47,40 +54,15 @@ func GetAllRates(c *gin.Context) {
 		cacheKey = fmt.Sprintf("rates:accommodation:%s", accommodationIdFilter)
 	}
 
-	// Kết nối Redis
-	rdb, err := config.ConnectRedis()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể kết nối Redis", "error": err.Error()})
-		return
-	}
-
-	var rates []models.Rate
-
-	// Lấy dữ liệu từ Redis
-	err = services.GetFromRedis(config.Ctx, rdb, cacheKey, &rates)
-	if err == nil && len(rates) > 0 {
-		var rateResponses []RateResponse
-		for _, rate := range rates {
-			rateResponse := RateResponse{
-				ID:              rate.ID,
-				AccommodationID: rate.AccommodationID,
-				Comment:         rate.Comment,
-				Star:            rate.Star,
-				CreateAt:        rate.CreateAt,
-				UpdateAt:        rate.UpdateAt,
-				User: UserInfo{
-					ID:     rate.User.ID,
-					Name:   rate.User.Name,
-					Avatar: rate.User.Avatar,
-				},
-			}
-			rateResponses = append(rateResponses, rateResponse)
-		}
+	// Lấy dữ liệu từ cache
+	var rateResponses []RateResponse
+	if cacheGet(c.Request.Context(), r.Cache, cacheKey, &rateResponses) {
 		c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Lấy danh sách đánh giá thành công từ cache", "data": rateResponses})
 		return
 	}
 
 	// Lấy dữ liệu từ database
+	var rates []models.Rate
 	tx := config.DB.Preload("User")
 	if accommodationIdFilter != "" {
 		if parsedAccommodationId, err := strconv.Atoi(accommodationIdFilter); err == nil {
@@ -89,11 +71,11 @@ func GetAllRates(c *gin.Context) {
 	}
 
 	if err := tx.Limit(20).Find(&rates).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Lỗi khi lấy danh sách đánh giá", "error": err.Error()})
+		c.Error(apperrors.Internal("Lỗi khi lấy danh sách đánh giá").Wrap(err))
 		return
 	}
 
-	var rateResponses []RateResponse
+	tags := []string{services.ListTag("rate")}
 	for _, rate := range rates {
 		rateResponse := RateResponse{
 			ID:              rate.ID,
@@ -109,61 +91,49 @@ func GetAllRates(c *gin.Context) {
 			},
 		}
 		rateResponses = append(rateResponses, rateResponse)
+		tags = append(tags, services.EntityTag("user", rate.UserID))
 	}
 
-	rateResponsesJSON, err := json.Marshal(rateResponses)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Lỗi khi serialize dữ liệu", "error": err.Error()})
-		return
-	}
-
-	if err := services.SetToRedis(config.Ctx, rdb, cacheKey, rateResponsesJSON, time.Hour); err != nil {
-		log.Printf("Lỗi khi lưu danh sách đánh giá vào Redis: %v", err)
-	}
+	cacheSet(c.Request.Context(), r.Cache, cacheKey, rateResponses, tags...)
 
 	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Lấy danh sách đánh giá thành công", "data": rateResponses})
 }
 
-func CreateRate(c *gin.Context) {
+func (r RateController) CreateRate(c *gin.Context) {
 	var rate models.Rate
 	if err := c.ShouldBindJSON(&rate); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Dữ liệu không hợp lệ", "error": err.Error()})
+		c.Error(apperrors.Validation(err))
 		return
 	}
 
 	var existingRate models.Rate
 	if err := config.DB.Where("user_id = ? AND accommodation_id = ?", rate.UserID, rate.AccommodationID).First(&existingRate).Error; err == nil {
-		c.JSON(http.StatusOK, gin.H{"code": 0, "mess": "Bạn đã đánh giá lưu trú này trước đó"})
+		c.Error(apperrors.Conflict("Bạn đã đánh giá lưu trú này trước đó"))
 		return
 	}
 
 	if err := config.DB.Create(&rate).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Lỗi khi tạo đánh giá", "error": err.Error()})
+		c.Error(apperrors.Internal("Lỗi khi tạo đánh giá").Wrap(err))
 		return
 	}
+	recordAudit(c, "rate.create", "rate", rate.ID, nil, rate)
 
 	if err := services.UpdateAccommodationRating(rate.AccommodationID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update accommodation rating"})
+		c.Error(apperrors.Internal("Failed to update accommodation rating").Wrap(err))
 		return
 	}
 
-	//Xóa redis
-	rdb, redisErr := config.ConnectRedis()
-	if redisErr == nil {
-		cacheKey := "rates:all"
-		_ = services.DeleteFromRedis(config.Ctx, rdb, cacheKey)
-		cacheKey2 := "accommodations:all"
-		_ = services.DeleteFromRedis(config.Ctx, rdb, cacheKey2)
-	}
+	// Xóa cache đánh giá và các danh sách chứa chỗ ở (điểm đánh giá thay đổi)
+	invalidateCache(c.Request.Context(), r.Cache, services.ListTag("rate"), services.EntityTag("accommodation", rate.AccommodationID))
 
 	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Tạo đánh giá thành công", "data": rate})
 }
 
-func GetRateDetail(c *gin.Context) {
+func (r RateController) GetRateDetail(c *gin.Context) {
 	id := c.Param("id")
 	var rate models.Rate
 	if err := config.DB.Preload("User").First(&rate, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"code": 0, "mess": "Đánh giá không tồn tại", "error": err.Error()})
+		c.Error(apperrors.NotFound("Đánh giá không tồn tại").WithDetails(err.Error()))
 		return
 	}
 
@@ -184,7 +154,7 @@ func GetRateDetail(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Lấy thông tin đánh giá thành công", "data": rateResponse})
 }
 
-func UpdateRate(c *gin.Context) {
+func (r RateController) UpdateRate(c *gin.Context) {
 	var rateInput struct {
 		ID      uint   `json:"id"`
 		Comment string `json:"comment"`
@@ -192,26 +162,28 @@ func UpdateRate(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&rateInput); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Dữ liệu không hợp lệ", "error": err.Error()})
+		c.Error(apperrors.Validation(err))
 		return
 	}
 
 	var rate models.Rate
 	if err := config.DB.First(&rate, rateInput.ID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"code": 0, "mess": "Đánh giá không tồn tại", "error": err.Error()})
+		c.Error(apperrors.NotFound("Đánh giá không tồn tại").WithDetails(err.Error()))
 		return
 	}
 
+	before := rate
 	rate.Comment = rateInput.Comment
 	rate.Star = rateInput.Star
 
 	if err := config.DB.Save(&rate).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Lỗi khi cập nhật đánh giá", "error": err.Error()})
+		c.Error(apperrors.Internal("Lỗi khi cập nhật đánh giá").Wrap(err))
 		return
 	}
+	recordAudit(c, "rate.update", "rate", rate.ID, before, rate)
 
 	if err := services.UpdateAccommodationRating(rate.AccommodationID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update accommodation rating"})
+		c.Error(apperrors.Internal("Failed to update accommodation rating").Wrap(err))
 		return
 	}
 
@@ -224,14 +196,8 @@ func UpdateRate(c *gin.Context) {
 		UpdateAt:        rate.UpdateAt,
 	}
 
-	//Xóa redis
-	rdb, redisErr := config.ConnectRedis()
-	if redisErr == nil {
-		cacheKey := "rates:all"
-		_ = services.DeleteFromRedis(config.Ctx, rdb, cacheKey)
-		cacheKey2 := "accommodations:all"
-		_ = services.DeleteFromRedis(config.Ctx, rdb, cacheKey2)
-	}
+	// Xóa cache đánh giá và các danh sách chứa chỗ ở (điểm đánh giá thay đổi)
+	invalidateCache(c.Request.Context(), r.Cache, services.ListTag("rate"), services.EntityTag("accommodation", rate.AccommodationID))
 
 	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Cập nhật đánh giá thành công", "data": rateResponse})
 }
@@ -0,0 +1,215 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"new/apperrors"
+	"new/models"
+	"new/services"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RatePlanRequest là phần bảng giá trong request tạo/cập nhật phòng và chỗ ở nguyên căn.
+// Trường không gửi (null) thì giữ nguyên bảng giá cũ, gửi mảng rỗng để xóa.
+type RatePlanRequest struct {
+	// Day nhận: 0-6 (0 là Chủ nhật), T2-T7/CN, mon/monday..., "weekday" (T2-T6), "weekend" (T7, CN).
+	// Mục sau ghi đè mục trước, vd: weekend rồi CN
+	DaysPrice []DayPrice `json:"daysPrice"`
+	// Giá riêng theo ngày, Day theo định dạng 02/01/2006
+	HolidayPrice []DayPrice    `json:"holidayPrice"`
+	Seasons      []SeasonPrice `json:"seasons"`
+}
+
+type SeasonPrice struct {
+	Name     string `json:"name"`
+	FromDate string `json:"fromDate"` // 02/01/2006
+	ToDate   string `json:"toDate"`   // 02/01/2006, gồm cả đêm của ngày này
+	Price    int    `json:"price"`
+}
+
+// RatePlanResponse trả về bảng giá cùng định dạng với RatePlanRequest
+type RatePlanResponse struct {
+	DaysPrice    []DayPrice    `json:"daysPrice"`
+	HolidayPrice []DayPrice    `json:"holidayPrice"`
+	Seasons      []SeasonPrice `json:"seasons"`
+}
+
+var weekdayNames = []string{"CN", "T2", "T3", "T4", "T5", "T6", "T7"}
+
+var weekdayAliases = func() map[string][]time.Weekday {
+	aliases := map[string][]time.Weekday{
+		"weekday": {time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+		"weekend": {time.Saturday, time.Sunday},
+	}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		name := strings.ToLower(day.String())
+		for _, alias := range []string{fmt.Sprint(int(day)), strings.ToLower(weekdayNames[day]), name, name[:3]} {
+			aliases[alias] = []time.Weekday{day}
+		}
+	}
+	return aliases
+}()
+
+// IsSet cho biết request có gửi thông tin bảng giá không
+func (r RatePlanRequest) IsSet() bool {
+	return r.DaysPrice != nil || r.HolidayPrice != nil || r.Seasons != nil
+}
+
+// Apply ghi các trường được gửi vào bảng giá, trường không gửi giữ nguyên
+func (r RatePlanRequest) Apply(plan *models.RatePlan) error {
+	if r.DaysPrice != nil {
+		byDay := map[time.Weekday]int{}
+		for _, entry := range r.DaysPrice {
+			days, ok := weekdayAliases[strings.ToLower(strings.TrimSpace(entry.Day))]
+			if !ok {
+				return fmt.Errorf("thứ không hợp lệ: %q", entry.Day)
+			}
+			for _, day := range days {
+				byDay[day] = entry.Price
+			}
+		}
+		plan.DayPrices = []models.RateDayPrice{}
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			if price, ok := byDay[day]; ok {
+				plan.DayPrices = append(plan.DayPrices, models.RateDayPrice{Weekday: int(day), Price: price})
+			}
+		}
+	}
+
+	if r.HolidayPrice != nil {
+		byDate := map[time.Time]int{}
+		for _, entry := range r.HolidayPrice {
+			date, err := time.Parse("02/01/2006", entry.Day)
+			if err != nil {
+				return fmt.Errorf("ngày không hợp lệ: %q", entry.Day)
+			}
+			byDate[date] = entry.Price
+		}
+		plan.Overrides = []models.RateOverride{}
+		for date, price := range byDate {
+			plan.Overrides = append(plan.Overrides, models.RateOverride{Date: date, Price: price})
+		}
+		sort.Slice(plan.Overrides, func(i, j int) bool { return plan.Overrides[i].Date.Before(plan.Overrides[j].Date) })
+	}
+
+	if r.Seasons != nil {
+		plan.Seasons = []models.RateSeason{}
+		for _, entry := range r.Seasons {
+			fromDate, err := time.Parse("02/01/2006", entry.FromDate)
+			if err != nil {
+				return fmt.Errorf("ngày bắt đầu mùa %q không hợp lệ", entry.Name)
+			}
+			toDate, err := time.Parse("02/01/2006", entry.ToDate)
+			if err != nil {
+				return fmt.Errorf("ngày kết thúc mùa %q không hợp lệ", entry.Name)
+			}
+			plan.Seasons = append(plan.Seasons, models.RateSeason{Name: entry.Name, FromDate: fromDate, ToDate: toDate, Price: entry.Price})
+		}
+	}
+
+	return plan.Validate()
+}
+
+func toRatePlanResponse(plan *models.RatePlan) RatePlanResponse {
+	response := RatePlanResponse{DaysPrice: []DayPrice{}, HolidayPrice: []DayPrice{}, Seasons: []SeasonPrice{}}
+	if plan == nil {
+		return response
+	}
+	for _, day := range plan.DayPrices {
+		response.DaysPrice = append(response.DaysPrice, DayPrice{Day: weekdayNames[day.Weekday], Price: day.Price})
+	}
+	for _, override := range plan.Overrides {
+		response.HolidayPrice = append(response.HolidayPrice, DayPrice{Day: override.Date.Format("02/01/2006"), Price: override.Price})
+	}
+	for _, season := range plan.Seasons {
+		response.Seasons = append(response.Seasons, SeasonPrice{
+			Name:     season.Name,
+			FromDate: season.FromDate.Format("02/01/2006"),
+			ToDate:   season.ToDate.Format("02/01/2006"),
+			Price:    season.Price,
+		})
+	}
+	return response
+}
+
+// GetRatePlan godoc
+// @Summary Bảng giá của chỗ ở thuê nguyên căn
+// @Tags accommodation
+// @Produce json
+// @Param id path int true "ID chỗ ở"
+// @Success 200 {object} gin.H {"code": 1, "mess": "...", "data": RatePlanResponse}
+// @Router /accommodation/{id}/ratePlan [get]
+func (a AccommodationController) GetRatePlan(c *gin.Context) {
+	accommodation, err := a.Accommodations.FindByID(c.Request.Context(), paramID(c, "id"))
+	if err != nil {
+		c.Error(apperrors.NotFound("Chỗ ở không tồn tại"))
+		return
+	}
+
+	plan, err := a.RatePlans.FindByAccommodation(c.Request.Context(), accommodation.ID)
+	if err != nil {
+		c.Error(apperrors.Internal("Không thể lấy bảng giá").Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Lấy bảng giá thành công", "data": toRatePlanResponse(plan)})
+}
+
+// UpdateRatePlan godoc
+// @Summary Cập nhật bảng giá của chỗ ở thuê nguyên căn
+// @Description Chỉ áp dụng cho chỗ ở không chia phòng, chỗ ở có phòng đặt bảng giá theo từng phòng
+// @Tags accommodation
+// @Accept json
+// @Produce json
+// @Param id path int true "ID chỗ ở"
+// @Param request body RatePlanRequest true "Bảng giá"
+// @Success 200 {object} gin.H {"code": 1, "mess": "...", "data": RatePlanResponse}
+// @Router /accommodation/{id}/ratePlan [put]
+func (a AccommodationController) UpdateRatePlan(c *gin.Context) {
+	var request RatePlanRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+
+	accommodation, err := a.Accommodations.FindByID(c.Request.Context(), paramID(c, "id"))
+	if err != nil {
+		c.Error(apperrors.NotFound("Chỗ ở không tồn tại"))
+		return
+	}
+	if !requireAccommodationManager(c, a.Users, accommodation) {
+		return
+	}
+	if accommodation.Type == 0 {
+		c.Error(apperrors.BadRequest("Chỗ ở có nhiều phòng, hãy đặt bảng giá cho từng phòng"))
+		return
+	}
+
+	plan, err := a.RatePlans.FindByAccommodation(c.Request.Context(), accommodation.ID)
+	if err != nil {
+		c.Error(apperrors.Internal("Không thể lấy bảng giá").Wrap(err))
+		return
+	}
+	before := toRatePlanResponse(plan)
+	if plan == nil {
+		plan = &models.RatePlan{AccommodationID: &accommodation.ID}
+	}
+	if err := request.Apply(plan); err != nil {
+		c.Error(apperrors.BadRequest(err.Error()))
+		return
+	}
+	if err := a.RatePlans.Replace(c.Request.Context(), plan); err != nil {
+		c.Error(apperrors.Internal("Không thể cập nhật bảng giá").Wrap(err))
+		return
+	}
+	response := toRatePlanResponse(plan)
+	recordAudit(c, "accommodation.rate_plan.update", "accommodation", accommodation.ID, before, response)
+
+	invalidateCache(c.Request.Context(), a.Cache, services.EntityTag("accommodation", accommodation.ID))
+
+	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Cập nhật bảng giá thành công", "data": response})
+}
@@ -1,16 +1,13 @@
 package controllers
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"net/http"
-	"net/url"
-	"new/config"
+	"new/apperrors"
 	"new/models"
+	"new/repositories"
 	"new/services"
-	"strconv"
 	"strings"
 	"time"
 
@@ -19,26 +16,49 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+type RoomController struct {
+	Rooms          repositories.RoomRepository
+	Accommodations repositories.AccommodationRepository
+	Users          repositories.UserRepository
+	RatePlans      repositories.RatePlanRepository
+	Benefits       repositories.BenefitRepository
+	Galleries      repositories.GalleryRepository
+	Cache          services.Cache
+	Storage        services.Storage
+}
+
+func NewRoomController(rooms repositories.RoomRepository, accommodations repositories.AccommodationRepository, users repositories.UserRepository, ratePlans repositories.RatePlanRepository, benefits repositories.BenefitRepository, galleries repositories.GalleryRepository, cache services.Cache, storage services.Storage) RoomController {
+	return RoomController{Rooms: rooms, Accommodations: accommodations, Users: users, RatePlans: ratePlans, Benefits: benefits, Galleries: galleries, Cache: cache, Storage: storage}
+}
+
 type Request struct {
-	RoomId           uint            `json:"id"`
-	RoomName         string          `json:"roomName"`
-	Type             uint            `json:"type"`
-	NumBed           int             `json:"numBed"`
-	NumTolet         int             `json:"numTolet"`
-	Acreage          int             `json:"acreage"`
-	Price            int             `json:"price"`
-	DaysPrice        json.RawMessage `json:"daysPrice"`
-	HolidayPrice     json.RawMessage `json:"holidayPrice"`
-	Description      string          `json:"description"`
-	ShortDescription string          `json:"shortDescription"`
-	TimeCheckOut     string          `json:"timeCheckOut"`
-	TimeCheckIn      string          `json:"timeCheckIn"`
-	Status           int             `json:"status"`
-	Avatar           string          `json:"avatar"`
-	Img              json.RawMessage `json:"img"`
-	Num              int             `json:"num"`
-	Furniture        json.RawMessage `json:"furniture" gorm:"type:json"`
-	People           int             `json:"people"`
+	RoomId           uint   `json:"id"`
+	RoomName         string `json:"roomName"`
+	Type             uint   `json:"type"`
+	NumBed           int    `json:"numBed"`
+	NumTolet         int    `json:"numTolet"`
+	Acreage          int    `json:"acreage"`
+	Price            int    `json:"price"`
+	Description      string `json:"description"`
+	ShortDescription string `json:"shortDescription"`
+	TimeCheckOut     string `json:"timeCheckOut"`
+	TimeCheckIn      string `json:"timeCheckIn"`
+	Status           int    `json:"status"`
+	Avatar           string `json:"avatar"`
+	Num              int    `json:"num"`
+	People           int    `json:"people"`
+	// Thư viện ảnh và nội thất, gửi mảng để thay toàn bộ; nhận cả dạng cũ là mảng URL/tên
+	Img       []models.Media         `json:"img"`
+	Furniture []models.FurnitureItem `json:"furniture"`
+	// Gửi mảng (kể cả rỗng) để thay toàn bộ tiện ích trong phòng, bỏ trống để giữ nguyên
+	Benefits []models.Benefit `json:"benefits"`
+	RatePlanRequest
+}
+
+// CreateRoomRequest là thông tin phòng kèm bảng giá
+type CreateRoomRequest struct {
+	models.Room
+	RatePlanRequest
 }
 
 type DayPrice struct {
@@ -69,528 +89,305 @@ type Parents struct {
 }
 
 type RoomDetail struct {
-	RoomId           uint            `json:"id" gorm:"primaryKey"`
-	RoomName         string          `json:"roomName"`
-	Type             uint            `json:"type"`
-	NumBed           int             `json:"numBed"`
-	NumTolet         int             `json:"numTolet"`
-	Acreage          int             `json:"acreage"`
-	Price            int             `json:"price"`
-	DaysPrice        json.RawMessage `json:"daysPrice" gorm:"type:json"`
-	HolidayPrice     json.RawMessage `json:"holidayPrice" gorm:"type:json"`
-	Description      string          `json:"description"`
-	ShortDescription string          `json:"shortDescription"`
-	CreatedAt        time.Time       `json:"createdAt"`
-	UpdatedAt        time.Time       `json:"updatedAt"`
-	TimeCheckOut     string          `json:"timeCheckOut"`
-	TimeCheckIn      string          `json:"timeCheckIn"`
-	Status           int             `json:"status"`
-	Avatar           string          `json:"avatar"`
-	Img              json.RawMessage `json:"img" gorm:"type:json"`
-	Num              int             `json:"num"`
-	Furniture        json.RawMessage `json:"furniture" gorm:"type:json"`
-	People           int             `json:"people"`
-	Parent           Parents         `json:"parent"`
+	RoomId           uint      `json:"id" gorm:"primaryKey"`
+	RoomName         string    `json:"roomName"`
+	Type             uint      `json:"type"`
+	NumBed           int       `json:"numBed"`
+	NumTolet         int       `json:"numTolet"`
+	Acreage          int       `json:"acreage"`
+	Price            int       `json:"price"`
+	Description      string    `json:"description"`
+	ShortDescription string    `json:"shortDescription"`
+	CreatedAt        time.Time `json:"createdAt"`
+	UpdatedAt        time.Time `json:"updatedAt"`
+	TimeCheckOut     string    `json:"timeCheckOut"`
+	TimeCheckIn      string    `json:"timeCheckIn"`
+	Status           int       `json:"status"`
+	Avatar           string    `json:"avatar"`
+	Num              int       `json:"num"`
+	People           int       `json:"people"`
+	Parent           Parents   `json:"parent"`
+	RoomTypeID       *uint     `json:"roomTypeId"`
+	// Tiện ích trong phòng
+	Benefits []models.Benefit `json:"benefits"`
+	// Ảnh và nội thất của phòng, phòng thuộc hạng phòng dùng của hạng
+	Img       []models.Media         `json:"img"`
+	Furniture []models.FurnitureItem `json:"furniture"`
+	RatePlanResponse
 }
 
-var CacheKey2 = "accommodations:all"
-
-func GetAllRooms(c *gin.Context) {
+func (r RoomController) GetAllRooms(c *gin.Context) {
 	// Xác thực token
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Authorization header is missing"})
+		c.Error(apperrors.Unauthorized("Authorization header is missing"))
 		return
 	}
 
 	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 	currentUserID, currentUserRole, err := GetUserIDFromToken(tokenString)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Invalid token"})
+		c.Error(apperrors.Unauthorized("Invalid token"))
 		return
 	}
 
-	// Lấy các tham số filter
-	page := 0
-	limit := 10
-	if pageStr := c.Query("page"); pageStr != "" {
-		if parsedPage, err := strconv.Atoi(pageStr); err == nil && parsedPage >= 0 {
-			page = parsedPage
-		}
-	}
-	if limitStr := c.Query("limit"); limitStr != "" {
-		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
-			limit = parsedLimit
-		}
-	}
-
-	typeFilter := c.Query("type")
-	statusFilter := c.Query("status")
-	nameFilter := c.Query("name")
-	accommodationFilter := c.Query("accommodation")
-	numBedFilter := c.Query("numBed")
-	numToletFilter := c.Query("numTolet")
-	peopleFilter := c.Query("people")
-
-	// Tạo cache key động
-	var cacheKey string
-	if currentUserRole == 2 {
-		cacheKey = fmt.Sprintf("rooms:admin:%d", currentUserID)
-	} else if currentUserRole == 3 {
-		cacheKey = fmt.Sprintf("rooms:receptionist:%d", currentUserID)
-	} else {
-		cacheKey = "rooms:all"
-	}
-
-	// Kết nối Redis
-	rdb, err := config.ConnectRedis()
+	pageReq, err := parsePageRequest(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể kết nối Redis"})
+		c.Error(apperrors.BadRequest(err.Error()))
 		return
 	}
 
-	var allRooms []models.Room
-
-	// Lấy dữ liệu từ Redis
-	if err := services.GetFromRedis(config.Ctx, rdb, cacheKey, &allRooms); err != nil || len(allRooms) == 0 {
-		tx := config.DB.Model(&models.Room{}).Preload("Parent")
-
-		if currentUserRole == 2 {
-			// Lấy phòng theo admin
-			tx = tx.Joins("JOIN accommodations ON accommodations.id = rooms.accommodation_id").
-				Where("accommodations.user_id = ?", currentUserID)
-		} else if currentUserRole == 3 {
-			// Lấy phòng theo admin (vị trí receptionist)
-			var adminID int
-			if err := config.DB.Model(&models.User{}).Select("admin_id").Where("id = ?", currentUserID).Scan(&adminID).Error; err != nil || adminID == 0 {
-				c.JSON(http.StatusForbidden, gin.H{"code": 0, "mess": "Không có quyền truy cập"})
-				return
-			}
-			tx = tx.Joins("JOIN accommodations ON accommodations.id = rooms.accommodation_id").
-				Where("accommodations.user_id = ?", adminID)
-		}
-
-		if err := tx.Find(&allRooms).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể lấy danh sách phòng"})
-			return
-		}
-
-		// Lưu dữ liệu vào Redis
-		if err := services.SetToRedis(config.Ctx, rdb, cacheKey, allRooms, time.Hour); err != nil {
-			log.Printf("Lỗi khi lưu danh sách phòng vào Redis: %v", err)
-		}
-	}
-
-	// Áp dụng filter trên dữ liệu từ Redis
-	filteredRooms := make([]models.Room, 0)
-	for _, room := range allRooms {
-		if typeFilter != "" {
-			parsedTypeFilter, err := strconv.Atoi(typeFilter)
-			if err == nil && room.Type != uint(parsedTypeFilter) {
-				continue
-			}
-		}
-		if statusFilter != "" {
-			parsedStatus, _ := strconv.Atoi(statusFilter)
-			if room.Status != parsedStatus {
-				continue
-			}
-		}
-		if nameFilter != "" {
-			decodedNameFilter, _ := url.QueryUnescape(nameFilter)
-			if !strings.Contains(strings.ToLower(room.RoomName), strings.ToLower(decodedNameFilter)) {
-				continue
-			}
-		}
-		if accommodationFilter != "" {
-			decodedAccommodationFilter, _ := url.QueryUnescape(accommodationFilter)
-			if !strings.Contains(strings.ToLower(room.Parent.Name), strings.ToLower(decodedAccommodationFilter)) {
-				continue
-			}
-		}
-		if numBedFilter != "" {
-			numBed, _ := strconv.Atoi(numBedFilter)
-			if room.NumBed != numBed {
-				continue
-			}
-		}
-		if numToletFilter != "" {
-			numTolet, _ := strconv.Atoi(numToletFilter)
-			if room.NumTolet != numTolet {
-				continue
-			}
-		}
-		if peopleFilter != "" {
-			people, _ := strconv.Atoi(peopleFilter)
-			if room.People != people {
-				continue
-			}
-		}
-		filteredRooms = append(filteredRooms, room)
+	// Phạm vi dữ liệu theo vai trò: admin xem phòng của mình, lễ tân xem phòng của admin quản lý
+	ownerID, ok := listOwner(c, r.Users, currentUserID, currentUserRole)
+	if !ok {
+		return
 	}
-
-	// Pagination
-	start := page * limit
-	end := start + limit
-	if start >= len(filteredRooms) {
-		filteredRooms = []models.Room{}
-	} else if end > len(filteredRooms) {
-		filteredRooms = filteredRooms[start:]
+	var cachePrefix string
+	if currentUserRole == 2 {
+		cachePrefix = fmt.Sprintf("rooms:admin:%d", currentUserID)
+	} else if currentUserRole == 3 {
+		cachePrefix = fmt.Sprintf("rooms:receptionist:%d", currentUserID)
 	} else {
-		filteredRooms = filteredRooms[start:end]
-	}
-
-	// Chuẩn bị response
-	roomResponses := make([]RoomResponse, 0)
-	for _, room := range filteredRooms {
-		roomResponses = append(roomResponses, RoomResponse{
-			RoomId:           room.RoomId,
-			RoomName:         room.RoomName,
-			Type:             room.Type,
-			NumBed:           room.NumBed,
-			NumTolet:         room.NumTolet,
-			Acreage:          room.Acreage,
-			Price:            room.Price,
-			ShortDescription: room.ShortDescription,
-			CreatedAt:        room.CreatedAt,
-			UpdatedAt:        room.UpdatedAt,
-			Status:           room.Status,
-			Avatar:           room.Avatar,
-			People:           room.People,
-			Parents: Parents{
-				Id:   room.Parent.ID,
-				Name: room.Parent.Name,
-			},
-		})
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"code": 1,
-		"mess": "Lấy danh sách phòng thành công",
-		"data": roomResponses,
-		"pagination": gin.H{
-			"page":  page,
-			"limit": limit,
-			"total": len(filteredRooms),
-		},
-	})
-}
-
-func GetAllRoomsUser(c *gin.Context) {
-	var rooms []models.Room
-	var totalRooms int64
-
-	pageStr := c.Query("page")
-	limitStr := c.Query("limit")
-	typeFilter := c.Query("type")
-	provinceFilter := c.Query("province")
-	statusFilter := c.Query("status")
-	nameFilter := c.Query("name")
-	accommodationFilter := c.Query("accommodation")
-	accommodationIdFilter := c.Query("accommodationId")
-	numBedFilter := c.Query("numBed")
-	numToletFilter := c.Query("numTolet")
-	peopleFilter := c.Query("people")
-
-	limit := 10
-	page := 0
-
-	if limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
-			limit = l
-		}
-	}
-
-	if pageStr != "" {
-		if p, err := strconv.Atoi(pageStr); err == nil && p >= 0 {
-			page = p
-		}
-	}
-
-	offset := page * limit
-
-	tx := config.DB.Model(&models.Room{}).Preload("Parent")
-
-	if accommodationFilter != "" {
-		decodedAccommodationFilter, err := url.QueryUnescape(accommodationFilter)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể giải mã tham số accommodation"})
-			return
-		}
-		tx = tx.Joins("JOIN accommodations ON accommodations.id = rooms.accommodation_id").
-			Where("accommodations.name ILIKE ?", "%"+decodedAccommodationFilter+"%")
+		cachePrefix = "rooms:all"
 	}
 
-	if accommodationIdFilter != "" {
-		if parsedAccommodationId, err := strconv.Atoi(accommodationIdFilter); err == nil {
-			tx = tx.Joins("JOIN accommodations ON accommodations.id = rooms.accommodation_id").
-				Where("accommodations.id = ?", parsedAccommodationId)
-		}
-	}
-
-	if typeFilter != "" {
-		tx = tx.Where("type = ?", typeFilter)
+	filter := repositories.RoomFilter{
+		OwnerID:           ownerID,
+		Type:              queryInt(c, "type"),
+		Status:            queryInt(c, "status"),
+		Name:              c.Query("name"),
+		AccommodationName: c.Query("accommodation"),
+		NumBed:            queryInt(c, "numBed"),
+		NumTolet:          queryInt(c, "numTolet"),
+		People:            queryInt(c, "people"),
 	}
+	r.listRooms(c, cachePrefix, filter, pageReq)
+}
 
-	if provinceFilter != "" {
-		tx = tx.Where("province_id = ?", provinceFilter)
+func (r RoomController) GetAllRoomsUser(c *gin.Context) {
+	pageReq, err := parsePageRequest(c)
+	if err != nil {
+		c.Error(apperrors.BadRequest(err.Error()))
+		return
 	}
 
-	if statusFilter != "" {
-		tx = tx.Where("status = ?", statusFilter)
-	}
+	published := models.ListingPublished
+	filter := repositories.RoomFilter{
+		Type:              queryInt(c, "type"),
+		Province:          c.Query("province"),
+		Status:            queryInt(c, "status"),
+		Name:              c.Query("name"),
+		AccommodationName: c.Query("accommodation"),
+		AccommodationID:   queryInt(c, "accommodationId"),
+		NumBed:            queryInt(c, "numBed"),
+		NumTolet:          queryInt(c, "numTolet"),
+		People:            queryInt(c, "people"),
+		// Chỉ phòng của chỗ ở đang đăng
+		AccommodationStatus: &published,
+	}
+	r.listRooms(c, "rooms:public", filter, pageReq)
+}
 
-	if nameFilter != "" {
-		decodedNameFilter, err := url.QueryUnescape(nameFilter)
+// Lấy một trang phòng theo bộ lọc, cache theo từng trang
+func (r RoomController) listRooms(c *gin.Context, cachePrefix string, filter repositories.RoomFilter, pageReq services.PageRequest) {
+	cacheKey := services.PageCacheKey(cachePrefix, filter, pageReq)
+	var page cachedPage[RoomResponse]
+	if !cacheGet(c.Request.Context(), r.Cache, cacheKey, &page) {
+		rooms, info, err := r.Rooms.List(c.Request.Context(), filter, pageReq)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể giải mã tham số name"})
+			c.Error(apperrors.Internal("Không thể lấy danh sách phòng").Wrap(err))
 			return
 		}
-		tx = tx.Where("room_name ILIKE ?", "%"+decodedNameFilter+"%")
-	}
-
-	if numBedFilter != "" {
-		if parsedNumBed, err := strconv.Atoi(numBedFilter); err == nil {
-			tx = tx.Where("num_bed = ?", parsedNumBed)
-		}
-	}
 
-	if numToletFilter != "" {
-		if parsedNumTolet, err := strconv.Atoi(numToletFilter); err == nil {
-			tx = tx.Where("num_tolet = ?", parsedNumTolet)
+		page = cachedPage[RoomResponse]{Data: make([]RoomResponse, 0, len(rooms)), Pagination: info}
+		for _, room := range rooms {
+			page.Data = append(page.Data, RoomResponse{
+				RoomId:           room.RoomId,
+				RoomName:         room.RoomName,
+				Type:             room.Type,
+				NumBed:           room.NumBed,
+				NumTolet:         room.NumTolet,
+				Acreage:          room.Acreage,
+				Price:            room.Price,
+				ShortDescription: room.ShortDescription,
+				CreatedAt:        room.CreatedAt,
+				UpdatedAt:        room.UpdatedAt,
+				Status:           room.Status,
+				Avatar:           room.Avatar,
+				People:           room.People,
+				Parents: Parents{
+					Id:   room.Parent.ID,
+					Name: room.Parent.Name,
+				},
+			})
 		}
-	}
-
-	if peopleFilter != "" {
-		if parsedPeople, err := strconv.Atoi(peopleFilter); err == nil {
-			tx = tx.Where("people = ?", parsedPeople)
-		}
-	}
-
-	tx.Count(&totalRooms)
-
-	tx = tx.Order("updated_at DESC")
-
-	if err := tx.Offset(offset).Limit(limit).Find(&rooms).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    0,
-			"mess":    "Không thể lấy danh sách phòng",
-			"details": err.Error(),
-		})
-		return
-	}
-
-	var roomResponses []RoomResponse
-	for _, room := range rooms {
-		roomResponse := RoomResponse{
-			RoomId:           room.RoomId,
-			RoomName:         room.RoomName,
-			Type:             room.Type,
-			NumBed:           room.NumBed,
-			NumTolet:         room.NumTolet,
-			Acreage:          room.Acreage,
-			Price:            room.Price,
-			ShortDescription: room.ShortDescription,
-			CreatedAt:        room.CreatedAt,
-			UpdatedAt:        room.UpdatedAt,
-			Status:           room.Status,
-			Avatar:           room.Avatar,
-			People:           room.People,
-			Parents: Parents{
-				Id:   room.Parent.ID,
-				Name: room.Parent.Name,
-			},
-		}
-		roomResponses = append(roomResponses, roomResponse)
+		cacheSetWithTTL(c.Request.Context(), r.Cache, cacheKey, page, pageCacheTTL, roomCacheTags(rooms)...)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"code": 1,
-		"mess": "Lấy danh sách phòng thành công",
-		"data": roomResponses,
-		"pagination": gin.H{
-			"page":  page,
-			"limit": limit,
-			"total": totalRooms,
-		},
+		"code":       1,
+		"mess":       "Lấy danh sách phòng thành công",
+		"data":       page.Data,
+		"pagination": page.Pagination,
 	})
 }
 
-func CreateRoom(c *gin.Context) {
-	var newRoom models.Room
+func (r RoomController) CreateRoom(c *gin.Context) {
+	var request CreateRoomRequest
 	// Xác thực token
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Authorization header is missing"})
+		c.Error(apperrors.Unauthorized("Authorization header is missing"))
 		return
 	}
 
 	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-	currentUserID, currentUserRole, err := GetUserIDFromToken(tokenString)
+	_, _, err := GetUserIDFromToken(tokenString)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Invalid token"})
+		c.Error(apperrors.Unauthorized("Invalid token"))
 		return
 	}
-	if err := c.ShouldBindJSON(&newRoom); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Dữ liệu đầu vào không hợp lệ", "details": err.Error()})
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Error(apperrors.Validation(err))
 		return
 	}
+	newRoom := request.Room
 
 	if err := newRoom.ValidateStatus(); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": err.Error()})
+		c.Error(apperrors.BadRequest(err.Error()))
 		return
 	}
 
-	furnitureJSON, err := json.Marshal(newRoom.Furniture)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể mã hóa holidayPrice", "details": err.Error()})
+	if err := prepareGallery(newRoom.Img, newRoom.Furniture); err != nil {
+		c.Error(err)
 		return
 	}
 
-	imgJSON, err := json.Marshal(newRoom.Img)
+	accommodation, err := r.Accommodations.FindByID(c.Request.Context(), newRoom.AccommodationID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể mã hóa img", "details": err.Error()})
-		return
-	}
-	var accommodation models.Accommodation
-	if err := config.DB.First(&accommodation, newRoom.AccommodationID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusBadRequest, gin.H{"code": 0, "message": "Không tìm thấy cơ sở lưu trú!"})
+			c.Error(apperrors.NotFound("Không tìm thấy cơ sở lưu trú!"))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "message": "Lỗi server", "details": err.Error()})
+		c.Error(apperrors.Internal("Lỗi server").Wrap(err))
 		return
 	}
 	newRoom.Parent = accommodation
-	newRoom.Img = imgJSON
-	newRoom.Furniture = furnitureJSON
 
-	if err := config.DB.Create(&newRoom).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể tạo phòng", "details": err.Error()})
+	benefits, err := resolveBenefits(c.Request.Context(), r.Benefits, newRoom.Benefits, models.BenefitScopeRoom)
+	if err != nil {
+		c.Error(err)
 		return
 	}
+	newRoom.Benefits = benefits
+
+	// Kiểm tra bảng giá trước khi tạo phòng, RoomID được gán sau khi tạo
+	var plan *models.RatePlan
+	if request.IsSet() {
+		plan = &models.RatePlan{RoomID: &newRoom.RoomId}
+		if err := request.Apply(plan); err != nil {
+			c.Error(apperrors.BadRequest(err.Error()))
+			return
+		}
+	}
 
-	//Xóa redis
-	rdb, redisErr := config.ConnectRedis()
-	if redisErr == nil {
-		switch currentUserRole {
-		case 1: // Super Admin
-			_ = services.DeleteFromRedis(config.Ctx, rdb, "rooms:all")
-			_ = services.DeleteFromRedis(config.Ctx, rdb, CacheKey2)
-		case 2: // Admin
-			adminCacheKey := fmt.Sprintf("rooms:admin:%d", currentUserID)
-			_ = services.DeleteFromRedis(config.Ctx, rdb, adminCacheKey)
-			_ = services.DeleteFromRedis(config.Ctx, rdb, CacheKey2)
-			var receptionistIDs []int
-			if err := config.DB.Model(&models.User{}).Where("admin_id = ?", currentUserID).Pluck("id", &receptionistIDs).Error; err == nil {
-				for _, receptionistID := range receptionistIDs {
-					receptionistCacheKey := fmt.Sprintf("rooms:receptionist:%d", receptionistID)
-					_ = services.DeleteFromRedis(config.Ctx, rdb, receptionistCacheKey)
-					_ = services.DeleteFromRedis(config.Ctx, rdb, CacheKey2)
-				}
-			}
-		case 3: // Receptionist
-			var adminID int
-			if err := config.DB.Model(&models.User{}).Select("admin_id").Where("id = ?", currentUserID).Scan(&adminID).Error; err == nil {
-				adminCacheKey := fmt.Sprintf("rooms:admin:%d", adminID)
-				receptionistCacheKey := fmt.Sprintf("rooms:receptionist:%d", currentUserID)
-				_ = services.DeleteFromRedis(config.Ctx, rdb, adminCacheKey)
-				_ = services.DeleteFromRedis(config.Ctx, rdb, receptionistCacheKey)
-				_ = services.DeleteFromRedis(config.Ctx, rdb, CacheKey2)
-			}
+	if err := r.Rooms.Create(c.Request.Context(), &newRoom); err != nil {
+		c.Error(apperrors.Internal("Không thể tạo phòng").Wrap(err))
+		return
+	}
+	if plan != nil {
+		if err := r.RatePlans.Replace(c.Request.Context(), plan); err != nil {
+			c.Error(apperrors.Internal("Không thể lưu bảng giá phòng").Wrap(err))
+			return
 		}
 	}
+	recordAudit(c, "room.create", "room", newRoom.RoomId, nil, newRoom)
+
+	// Xóa cache danh sách phòng và chỗ ở chứa phòng
+	invalidateCache(c.Request.Context(), r.Cache, services.ListTag("room"), services.EntityTag("accommodation", newRoom.AccommodationID))
 
 	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Tạo phòng thành công", "data": newRoom})
 }
 
-func GetRoomDetail(c *gin.Context) {
-	roomId := c.Param("id")
+func (r RoomController) GetRoomDetail(c *gin.Context) {
+	roomId := paramID(c, "id")
 
-	// Kết nối Redis
-	rdb, redisErr := config.ConnectRedis()
-	if redisErr != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể kết nối Redis"})
+	cacheKey := fmt.Sprintf("room:%d:detail", roomId)
+	var response RoomDetail
+	if cacheGet(c.Request.Context(), r.Cache, cacheKey, &response) {
+		c.JSON(http.StatusOK, gin.H{
+			"code": 1,
+			"mess": "Lấy thông tin phòng thành công (từ cache)",
+			"data": response,
+		})
 		return
 	}
 
-	// Key cache cho tất cả rooms
-	cacheKey := "rooms:all"
-
-	// Lấy danh sách rooms từ cache
-	var cachedRooms []models.Room
-	if err := services.GetFromRedis(config.Ctx, rdb, cacheKey, &cachedRooms); err == nil {
-		// Tìm room theo ID trong cache
-		for _, room := range cachedRooms {
-			if fmt.Sprintf("%d", room.RoomId) == roomId {
-				// Tạo response từ cache
-				c.JSON(http.StatusOK, gin.H{
-					"code": 1,
-					"mess": "Lấy thông tin phòng thành công (từ cache)",
-					"data": buildRoomDetailResponse(room),
-				})
-				return
-			}
-		}
+	room, err := r.Rooms.FindDetail(c.Request.Context(), roomId)
+	if err != nil {
+		c.Error(apperrors.NotFound("Phòng không tồn tại"))
+		return
 	}
 
-	// Nếu không tìm thấy trong cache, truy vấn từ database
-	var room models.Room
-	if err := config.DB.Preload("Parent").First(&room, roomId).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"code": 0, "mess": "Phòng không tồn tại"})
+	plan, err := r.RatePlans.FindByRoom(c.Request.Context(), room.RoomId)
+	if err != nil {
+		c.Error(apperrors.Internal("Không thể lấy bảng giá phòng").Wrap(err))
 		return
 	}
 
-	// Trả về kết quả từ database
+	// Phòng thuộc hạng phòng dùng ảnh và nội thất của hạng
+	if room.RoomTypeID != nil && len(room.Img) == 0 {
+		if room.Img, err = r.Galleries.ListMedia(c.Request.Context(), models.RoomTypeGallery(*room.RoomTypeID)); err != nil {
+			c.Error(apperrors.Internal("Không thể lấy hình ảnh").Wrap(err))
+			return
+		}
+	}
+	if room.RoomTypeID != nil && len(room.Furniture) == 0 {
+		if room.Furniture, err = r.Galleries.ListFurniture(c.Request.Context(), models.RoomTypeGallery(*room.RoomTypeID)); err != nil {
+			c.Error(apperrors.Internal("Không thể lấy nội thất").Wrap(err))
+			return
+		}
+	}
+
+	response = buildRoomDetailResponse(room, plan)
+	cacheSet(c.Request.Context(), r.Cache, cacheKey, response,
+		services.EntityTag("room", room.RoomId),
+		services.EntityTag("accommodation", room.AccommodationID),
+		services.ListTag("benefit"))
+
 	c.JSON(http.StatusOK, gin.H{
 		"code": 1,
 		"mess": "Lấy thông tin phòng thành công",
-		"data": buildRoomDetailResponse(room),
+		"data": response,
 	})
 }
 
-func UpdateRoom(c *gin.Context) {
-	authHeader := c.GetHeader("Authorization")
-	if authHeader == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Authorization header is missing"})
-		return
-	}
-
-	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-	currentUserID, currentUserRole, err := GetUserIDFromToken(tokenString)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Invalid token"})
-		return
-	}
-
+func (r RoomController) UpdateRoom(c *gin.Context) {
 	var request Request
 
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Dữ liệu đầu vào không hợp lệ", "details": err.Error()})
+		c.Error(apperrors.Validation(err))
 		return
 	}
 
-	var room models.Room
-
-	if err := config.DB.First(&room, request.RoomId).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"code": 0, "mess": "Phòng không tồn tại"})
+	room, err := r.Rooms.FindByID(c.Request.Context(), request.RoomId)
+	if err != nil {
+		c.Error(apperrors.NotFound("Phòng không tồn tại"))
 		return
 	}
-
-	if err := room.ValidateStatus(); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": err.Error()})
+	// Sửa phòng có thể thay thư viện ảnh và xóa file cũ khỏi nơi lưu trữ, chỉ người quản lý chỗ ở được sửa
+	if !requireRoomManager(c, r.Users, r.Accommodations, room) {
 		return
 	}
+	before := room
 
-	imgJSON, err := json.Marshal(request.Img)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể mã hóa img", "details": err.Error()})
+	if err := room.ValidateStatus(); err != nil {
+		c.Error(apperrors.BadRequest(err.Error()))
 		return
 	}
 
-	furnitureJSON, err := json.Marshal(request.Furniture)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể mã hóa holidayPrice", "details": err.Error()})
+	if err := prepareGallery(request.Img, request.Furniture); err != nil {
+		c.Error(err)
 		return
 	}
 
@@ -634,64 +431,77 @@ func UpdateRoom(c *gin.Context) {
 		room.Avatar = request.Avatar
 	}
 
-	if len(request.Img) > 0 {
-		room.Img = imgJSON
+	var benefits []models.Benefit
+	if request.Benefits != nil {
+		if benefits, err = resolveBenefits(c.Request.Context(), r.Benefits, request.Benefits, models.BenefitScopeRoom); err != nil {
+			c.Error(err)
+			return
+		}
 	}
 
-	if len(request.Furniture) > 0 {
-		room.Furniture = furnitureJSON
+	var plan *models.RatePlan
+	if request.IsSet() {
+		if plan, err = r.RatePlans.FindByRoom(c.Request.Context(), room.RoomId); err != nil {
+			c.Error(apperrors.Internal("Không thể lấy bảng giá phòng").Wrap(err))
+			return
+		}
+		if plan == nil {
+			plan = &models.RatePlan{RoomID: &room.RoomId}
+		}
+		if err := request.Apply(plan); err != nil {
+			c.Error(apperrors.BadRequest(err.Error()))
+			return
+		}
 	}
 
-	if err := config.DB.Save(&room).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể cập nhật phòng", "details": err.Error()})
+	if err := r.Rooms.Save(c.Request.Context(), &room); err != nil {
+		c.Error(apperrors.Internal("Không thể cập nhật phòng").Wrap(err))
 		return
 	}
-
-	//Xóa redis
-	rdb, redisErr := config.ConnectRedis()
-	if redisErr == nil {
-		switch currentUserRole {
-		case 1: // Super Admin
-			_ = services.DeleteFromRedis(config.Ctx, rdb, "rooms:all")
-			_ = services.DeleteFromRedis(config.Ctx, rdb, CacheKey2)
-		case 2: // Admin
-			adminCacheKey := fmt.Sprintf("rooms:admin:%d", currentUserID)
-			_ = services.DeleteFromRedis(config.Ctx, rdb, adminCacheKey)
-			_ = services.DeleteFromRedis(config.Ctx, rdb, CacheKey2)
-			var receptionistIDs []int
-			if err := config.DB.Model(&models.User{}).Where("admin_id = ?", currentUserID).Pluck("id", &receptionistIDs).Error; err == nil {
-				for _, receptionistID := range receptionistIDs {
-					receptionistCacheKey := fmt.Sprintf("rooms:receptionist:%d", receptionistID)
-					_ = services.DeleteFromRedis(config.Ctx, rdb, receptionistCacheKey)
-					_ = services.DeleteFromRedis(config.Ctx, rdb, CacheKey2)
-				}
-			}
-		case 3: // Receptionist
-			var adminID int
-			if err := config.DB.Model(&models.User{}).Select("admin_id").Where("id = ?", currentUserID).Scan(&adminID).Error; err == nil {
-				adminCacheKey := fmt.Sprintf("rooms:admin:%d", adminID)
-				receptionistCacheKey := fmt.Sprintf("rooms:receptionist:%d", currentUserID)
-				_ = services.DeleteFromRedis(config.Ctx, rdb, adminCacheKey)
-				_ = services.DeleteFromRedis(config.Ctx, rdb, receptionistCacheKey)
-				_ = services.DeleteFromRedis(config.Ctx, rdb, CacheKey2)
-			}
+	if request.Benefits != nil {
+		if err := r.Rooms.ReplaceBenefits(c.Request.Context(), &room, benefits); err != nil {
+			c.Error(apperrors.Internal("Không thể cập nhật tiện ích phòng").Wrap(err))
+			return
+		}
+	}
+	owner := models.RoomGallery(room.RoomId)
+	if err := replaceGallery(c.Request.Context(), r.Galleries, r.Storage, owner, request.Img, request.Furniture); err != nil {
+		c.Error(err)
+		return
+	}
+	if room.Img, err = r.Galleries.ListMedia(c.Request.Context(), owner); err != nil {
+		c.Error(apperrors.Internal("Không thể lấy hình ảnh").Wrap(err))
+		return
+	}
+	if room.Furniture, err = r.Galleries.ListFurniture(c.Request.Context(), owner); err != nil {
+		c.Error(apperrors.Internal("Không thể lấy nội thất").Wrap(err))
+		return
+	}
+	if plan != nil {
+		if err := r.RatePlans.Replace(c.Request.Context(), plan); err != nil {
+			c.Error(apperrors.Internal("Không thể cập nhật bảng giá phòng").Wrap(err))
+			return
 		}
 	}
+	recordAudit(c, "room.update", "room", room.RoomId, before, room)
+
+	// Xóa cache các danh sách chứa phòng này
+	invalidateCache(c.Request.Context(), r.Cache, services.EntityTag("room", room.RoomId), services.EntityTag("accommodation", room.AccommodationID))
 
 	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Cập nhật phòng thành công", "data": room})
 }
 
-func ChangeRoomStatus(c *gin.Context) {
+func (r RoomController) ChangeRoomStatus(c *gin.Context) {
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Authorization header is missing"})
+		c.Error(apperrors.Unauthorized("Authorization header is missing"))
 		return
 	}
 
 	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-	currentUserID, currentUserRole, err := GetUserIDFromToken(tokenString)
+	_, _, err := GetUserIDFromToken(tokenString)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Invalid token"})
+		c.Error(apperrors.Unauthorized("Invalid token"))
 		return
 	}
 
@@ -701,58 +511,32 @@ func ChangeRoomStatus(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Dữ liệu đầu vào không hợp lệ"})
+		c.Error(apperrors.BadRequest("Dữ liệu đầu vào không hợp lệ"))
 		return
 	}
 
-	var room models.Room
-
-	if err := config.DB.First(&room, input.RoomId).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"code": 0, "mess": "Phòng không tồn tại"})
+	room, err := r.Rooms.FindByID(c.Request.Context(), input.RoomId)
+	if err != nil {
+		c.Error(apperrors.NotFound("Phòng không tồn tại"))
 		return
 	}
 
+	before := room
 	room.Status = input.Status
-	if err := config.DB.Save(&room).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể thay đổi trạng thái phòng"})
+	if err := r.Rooms.Save(c.Request.Context(), &room); err != nil {
+		c.Error(apperrors.Internal("Không thể thay đổi trạng thái phòng").Wrap(err))
 		return
 	}
+	recordAudit(c, "room.status", "room", room.RoomId, before, room)
 
-	//Xóa redis
-	rdb, redisErr := config.ConnectRedis()
-	if redisErr == nil {
-		switch currentUserRole {
-		case 1: // Super Admin
-			_ = services.DeleteFromRedis(config.Ctx, rdb, "rooms:all")
-		case 2: // Admin
-			adminCacheKey := fmt.Sprintf("rooms:admin:%d", currentUserID)
-			_ = services.DeleteFromRedis(config.Ctx, rdb, adminCacheKey)
-			_ = services.DeleteFromRedis(config.Ctx, rdb, CacheKey2)
-			var receptionistIDs []int
-			if err := config.DB.Model(&models.User{}).Where("admin_id = ?", currentUserID).Pluck("id", &receptionistIDs).Error; err == nil {
-				for _, receptionistID := range receptionistIDs {
-					receptionistCacheKey := fmt.Sprintf("rooms:receptionist:%d", receptionistID)
-					_ = services.DeleteFromRedis(config.Ctx, rdb, receptionistCacheKey)
-					_ = services.DeleteFromRedis(config.Ctx, rdb, CacheKey2)
-				}
-			}
-		case 3: // Receptionist
-			var adminID int
-			if err := config.DB.Model(&models.User{}).Select("admin_id").Where("id = ?", currentUserID).Scan(&adminID).Error; err == nil {
-				adminCacheKey := fmt.Sprintf("rooms:admin:%d", adminID)
-				receptionistCacheKey := fmt.Sprintf("rooms:receptionist:%d", currentUserID)
-				_ = services.DeleteFromRedis(config.Ctx, rdb, adminCacheKey)
-				_ = services.DeleteFromRedis(config.Ctx, rdb, receptionistCacheKey)
-				_ = services.DeleteFromRedis(config.Ctx, rdb, CacheKey2)
-			}
-		}
-	}
+	// Xóa cache các danh sách chứa phòng này
+	invalidateCache(c.Request.Context(), r.Cache, services.EntityTag("room", room.RoomId), services.EntityTag("accommodation", room.AccommodationID))
 
 	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Thay đổi trạng thái phòng thành công", "data": room})
 }
 
 // Hàm set response cho details
-func buildRoomDetailResponse(room models.Room) RoomDetail {
+func buildRoomDetailResponse(room models.Room, plan *models.RatePlan) RoomDetail {
 	return RoomDetail{
 		RoomId:           room.RoomId,
 		RoomName:         room.RoomName,
@@ -775,5 +559,17 @@ func buildRoomDetailResponse(room models.Room) RoomDetail {
 			Id:   room.Parent.ID,
 			Name: room.Parent.Name,
 		},
+		RoomTypeID:       room.RoomTypeID,
+		Benefits:         room.Benefits,
+		RatePlanResponse: toRatePlanResponse(plan),
+	}
+}
+
+// Tag cache cho danh sách phòng: tag từng phòng và chỗ ở cha đã preload
+func roomCacheTags(rooms []models.Room) []string {
+	tags := []string{services.ListTag("room")}
+	for _, room := range rooms {
+		tags = append(tags, services.EntityTag("room", room.RoomId), services.EntityTag("accommodation", room.AccommodationID))
 	}
+	return tags
 }
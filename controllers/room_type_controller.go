@@ -0,0 +1,452 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"new/apperrors"
+	"new/models"
+	"new/repositories"
+	"new/services"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RoomTypeController struct {
+	RoomTypes      repositories.RoomTypeRepository
+	Accommodations repositories.AccommodationRepository
+	RatePlans      repositories.RatePlanRepository
+	Galleries      repositories.GalleryRepository
+	Users          repositories.UserRepository
+	Cache          services.Cache
+	Storage        services.Storage
+}
+
+func NewRoomTypeController(roomTypes repositories.RoomTypeRepository, accommodations repositories.AccommodationRepository, ratePlans repositories.RatePlanRepository, galleries repositories.GalleryRepository, users repositories.UserRepository, cache services.Cache, storage services.Storage) RoomTypeController {
+	return RoomTypeController{RoomTypes: roomTypes, Accommodations: accommodations, RatePlans: ratePlans, Galleries: galleries, Users: users, Cache: cache, Storage: storage}
+}
+
+type RoomTypeRequest struct {
+	ID               uint   `json:"id"`
+	AccommodationID  uint   `json:"accommodationId"`
+	Name             string `json:"name"`
+	Type             uint   `json:"type"`
+	NumBed           int    `json:"numBed"`
+	NumTolet         int    `json:"numTolet"`
+	Acreage          int    `json:"acreage"`
+	Price            int    `json:"price"`
+	Description      string `json:"description"`
+	ShortDescription string `json:"shortDescription"`
+	Avatar           string `json:"avatar"`
+	People           int    `json:"people"`
+	Status           *int   `json:"status"`
+	AssignAtCheckIn  *bool  `json:"assignAtCheckIn"`
+	// Thư viện ảnh và nội thất dùng chung cho các phòng của hạng, gửi mảng để thay toàn bộ
+	Img       []models.Media         `json:"img"`
+	Furniture []models.FurnitureItem `json:"furniture"`
+	// Quantity là tổng số phòng thật của hạng, khi cập nhật chỉ được tăng
+	Quantity int `json:"quantity"`
+	// RoomNames là tên (số phòng) của các phòng tạo thêm, thiếu thì đặt "<tên hạng> <số thứ tự>"
+	RoomNames []string `json:"roomNames"`
+	RatePlanRequest
+}
+
+type RoomTypeRoom struct {
+	ID       uint   `json:"id"`
+	RoomName string `json:"roomName"`
+	Status   int    `json:"status"`
+}
+
+type RoomTypeResponse struct {
+	ID               uint   `json:"id"`
+	AccommodationID  uint   `json:"accommodationId"`
+	Name             string `json:"name"`
+	Type             uint   `json:"type"`
+	NumBed           int    `json:"numBed"`
+	NumTolet         int    `json:"numTolet"`
+	Acreage          int    `json:"acreage"`
+	Price            int    `json:"price"`
+	Description      string `json:"description"`
+	ShortDescription string `json:"shortDescription"`
+	Avatar           string `json:"avatar"`
+	People           int    `json:"people"`
+	Status           int    `json:"status"`
+	AssignAtCheckIn  bool   `json:"assignAtCheckIn"`
+	Quantity         int    `json:"quantity"`
+	// Thư viện ảnh theo thứ tự hiển thị và nội thất
+	Img       []models.Media         `json:"img"`
+	Furniture []models.FurnitureItem `json:"furniture"`
+	// Available là số phòng còn đặt được, chỉ có khi truyền checkInDate và checkOutDate
+	Available *int           `json:"available,omitempty"`
+	Rooms     []RoomTypeRoom `json:"rooms"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	RatePlanResponse
+}
+
+func toRoomTypeResponse(roomType models.RoomType, plan *models.RatePlan) RoomTypeResponse {
+	rooms := make([]RoomTypeRoom, 0, len(roomType.Rooms))
+	for _, room := range roomType.Rooms {
+		rooms = append(rooms, RoomTypeRoom{ID: room.RoomId, RoomName: room.RoomName, Status: room.Status})
+	}
+	return RoomTypeResponse{
+		ID:               roomType.ID,
+		AccommodationID:  roomType.AccommodationID,
+		Name:             roomType.Name,
+		Type:             roomType.Type,
+		NumBed:           roomType.NumBed,
+		NumTolet:         roomType.NumTolet,
+		Acreage:          roomType.Acreage,
+		Price:            roomType.Price,
+		Description:      roomType.Description,
+		ShortDescription: roomType.ShortDescription,
+		Avatar:           roomType.Avatar,
+		Img:              roomType.Img,
+		Furniture:        roomType.Furniture,
+		People:           roomType.People,
+		Status:           roomType.Status,
+		AssignAtCheckIn:  roomType.AssignAtCheckIn,
+		Quantity:         len(roomType.Rooms),
+		Rooms:            rooms,
+		CreatedAt:        roomType.CreatedAt,
+		UpdatedAt:        roomType.UpdatedAt,
+		RatePlanResponse: toRatePlanResponse(plan),
+	}
+}
+
+// roomTypeAvailability trả về các phòng thật còn trống của hạng trong khoảng [from, to) và số phòng đã đặt
+// theo hạng nhưng chưa gán. Phòng chưa gán vẫn giữ chỗ, số phòng còn đặt được là len(free) - pending.
+func roomTypeAvailability(ctx context.Context, roomTypes repositories.RoomTypeRepository, roomTypeID uint, from, to time.Time) ([]uint, int, error) {
+	free, err := roomTypes.FreeRooms(ctx, roomTypeID, from, to)
+	if err != nil {
+		return nil, 0, err
+	}
+	pending, err := roomTypes.PendingReservations(ctx, roomTypeID, from, to)
+	if err != nil {
+		return nil, 0, err
+	}
+	return free, pending, nil
+}
+
+// Tên các phòng thật tạo thêm cho hạng, bắt đầu từ số thứ tự start
+func newTypeRooms(name string, roomNames []string, start, count int) []models.Room {
+	rooms := make([]models.Room, 0, count)
+	for i := 0; i < count; i++ {
+		roomName := fmt.Sprintf("%s %d", name, start+i+1)
+		if i < len(roomNames) && roomNames[i] != "" {
+			roomName = roomNames[i]
+		}
+		rooms = append(rooms, models.Room{RoomName: roomName})
+	}
+	return rooms
+}
+
+// GetRoomTypes godoc
+// @Summary Danh sách hạng phòng của khách sạn
+// @Description Truyền checkInDate, checkOutDate (dd/mm/yyyy) để lấy số phòng còn đặt được của từng hạng
+// @Tags roomType
+// @Produce json
+// @Param accommodationId query int true "ID khách sạn"
+// @Success 200 {object} gin.H {"code": 1, "mess": "...", "data": []RoomTypeResponse}
+// @Router /roomType [get]
+func (r RoomTypeController) GetRoomTypes(c *gin.Context) {
+	accommodationID, err := strconv.ParseUint(c.Query("accommodationId"), 10, 0)
+	if err != nil {
+		c.Error(apperrors.BadRequest("Thiếu accommodationId"))
+		return
+	}
+
+	var checkIn, checkOut time.Time
+	withAvailability := c.Query("checkInDate") != "" || c.Query("checkOutDate") != ""
+	if withAvailability {
+		if checkIn, err = time.Parse("02/01/2006", c.Query("checkInDate")); err != nil {
+			c.Error(apperrors.BadRequest("Ngày nhận phòng không hợp lệ"))
+			return
+		}
+		if checkOut, err = time.Parse("02/01/2006", c.Query("checkOutDate")); err != nil || !checkOut.After(checkIn) {
+			c.Error(apperrors.BadRequest("Ngày trả phòng phải sau ngày nhận phòng"))
+			return
+		}
+	}
+
+	roomTypes, err := r.RoomTypes.ListByAccommodation(c.Request.Context(), uint(accommodationID))
+	if err != nil {
+		c.Error(apperrors.Internal("Không thể lấy danh sách hạng phòng").Wrap(err))
+		return
+	}
+
+	response := make([]RoomTypeResponse, 0, len(roomTypes))
+	for _, roomType := range roomTypes {
+		plan, err := r.RatePlans.FindByRoomType(c.Request.Context(), roomType.ID)
+		if err != nil {
+			c.Error(apperrors.Internal("Không thể lấy bảng giá hạng phòng").Wrap(err))
+			return
+		}
+		item := toRoomTypeResponse(roomType, plan)
+		if withAvailability {
+			free, pending, err := roomTypeAvailability(c.Request.Context(), r.RoomTypes, roomType.ID, checkIn, checkOut)
+			if err != nil {
+				c.Error(apperrors.Internal("Không thể kiểm tra phòng trống").Wrap(err))
+				return
+			}
+			available := max(len(free)-pending, 0)
+			item.Available = &available
+		}
+		response = append(response, item)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Lấy danh sách hạng phòng thành công", "data": response})
+}
+
+// GetRoomTypeDetail godoc
+// @Summary Chi tiết hạng phòng
+// @Tags roomType
+// @Produce json
+// @Param id path int true "ID hạng phòng"
+// @Success 200 {object} gin.H {"code": 1, "mess": "...", "data": RoomTypeResponse}
+// @Router /roomType/{id} [get]
+func (r RoomTypeController) GetRoomTypeDetail(c *gin.Context) {
+	roomType, err := r.RoomTypes.FindDetail(c.Request.Context(), paramID(c, "id"))
+	if err != nil {
+		c.Error(apperrors.NotFound("Hạng phòng không tồn tại"))
+		return
+	}
+
+	plan, err := r.RatePlans.FindByRoomType(c.Request.Context(), roomType.ID)
+	if err != nil {
+		c.Error(apperrors.Internal("Không thể lấy bảng giá hạng phòng").Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Lấy thông tin hạng phòng thành công", "data": toRoomTypeResponse(roomType, plan)})
+}
+
+// CreateRoomType godoc
+// @Summary Tạo hạng phòng cùng quantity phòng thật
+// @Tags roomType
+// @Accept json
+// @Produce json
+// @Param request body RoomTypeRequest true "Hạng phòng"
+// @Success 201 {object} gin.H {"code": 1, "mess": "...", "data": RoomTypeResponse}
+// @Router /roomType [post]
+func (r RoomTypeController) CreateRoomType(c *gin.Context) {
+	var request RoomTypeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+	if request.Name == "" {
+		c.Error(apperrors.BadRequest("Tên hạng phòng không được để trống"))
+		return
+	}
+	if request.Quantity <= 0 {
+		c.Error(apperrors.BadRequest("Số lượng phòng phải lớn hơn 0"))
+		return
+	}
+
+	accommodation, err := r.Accommodations.FindByID(c.Request.Context(), request.AccommodationID)
+	if err != nil {
+		c.Error(apperrors.NotFound("Không tìm thấy cơ sở lưu trú!"))
+		return
+	}
+	if !requireAccommodationManager(c, r.Users, accommodation) {
+		return
+	}
+	if accommodation.Type != 0 {
+		c.Error(apperrors.BadRequest("Chỉ khách sạn mới có hạng phòng"))
+		return
+	}
+
+	roomType := models.RoomType{
+		AccommodationID:  accommodation.ID,
+		Name:             request.Name,
+		Type:             request.Type,
+		NumBed:           request.NumBed,
+		NumTolet:         request.NumTolet,
+		Acreage:          request.Acreage,
+		Price:            request.Price,
+		Description:      request.Description,
+		ShortDescription: request.ShortDescription,
+		Avatar:           request.Avatar,
+		Img:              request.Img,
+		Furniture:        request.Furniture,
+		People:           request.People,
+	}
+	if request.Status != nil {
+		roomType.Status = *request.Status
+	}
+	if request.AssignAtCheckIn != nil {
+		roomType.AssignAtCheckIn = *request.AssignAtCheckIn
+	}
+	if err := roomType.ValidateStatus(); err != nil {
+		c.Error(apperrors.BadRequest(err.Error()))
+		return
+	}
+	if err := prepareGallery(roomType.Img, roomType.Furniture); err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Kiểm tra bảng giá trước khi tạo, RoomTypeID được gán sau khi tạo
+	var plan *models.RatePlan
+	if request.IsSet() {
+		plan = &models.RatePlan{RoomTypeID: &roomType.ID}
+		if err := request.Apply(plan); err != nil {
+			c.Error(apperrors.BadRequest(err.Error()))
+			return
+		}
+	}
+
+	rooms := newTypeRooms(roomType.Name, request.RoomNames, 0, request.Quantity)
+	if err := r.RoomTypes.Create(c.Request.Context(), &roomType, rooms); err != nil {
+		c.Error(apperrors.Internal("Không thể tạo hạng phòng").Wrap(err))
+		return
+	}
+	if plan != nil {
+		if err := r.RatePlans.Replace(c.Request.Context(), plan); err != nil {
+			c.Error(apperrors.Internal("Không thể lưu bảng giá hạng phòng").Wrap(err))
+			return
+		}
+	}
+	recordAudit(c, "room_type.create", "room_type", roomType.ID, nil, roomType)
+
+	// Phòng thật mới xuất hiện trong danh sách phòng của khách sạn
+	invalidateCache(c.Request.Context(), r.Cache, services.ListTag("room"), services.EntityTag("accommodation", roomType.AccommodationID))
+
+	c.JSON(http.StatusCreated, gin.H{"code": 1, "mess": "Tạo hạng phòng thành công", "data": toRoomTypeResponse(roomType, plan)})
+}
+
+// UpdateRoomType godoc
+// @Summary Cập nhật hạng phòng
+// @Description Thông tin dùng chung được chép sang mọi phòng thật của hạng; quantity lớn hơn hiện tại thì tạo thêm phòng
+// @Tags roomType
+// @Accept json
+// @Produce json
+// @Param request body RoomTypeRequest true "Hạng phòng"
+// @Success 200 {object} gin.H {"code": 1, "mess": "...", "data": RoomTypeResponse}
+// @Router /roomTypeUpdate [put]
+func (r RoomTypeController) UpdateRoomType(c *gin.Context) {
+	var request RoomTypeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+
+	roomType, err := r.RoomTypes.FindDetail(c.Request.Context(), request.ID)
+	if err != nil {
+		c.Error(apperrors.NotFound("Hạng phòng không tồn tại"))
+		return
+	}
+	accommodation, err := r.Accommodations.FindByID(c.Request.Context(), roomType.AccommodationID)
+	if err != nil {
+		c.Error(apperrors.NotFound("Không tìm thấy cơ sở lưu trú!"))
+		return
+	}
+	if !requireAccommodationManager(c, r.Users, accommodation) {
+		return
+	}
+	before := roomType
+
+	if request.Name != "" {
+		roomType.Name = request.Name
+	}
+	if request.Type > 0 {
+		roomType.Type = request.Type
+	}
+	if request.NumBed != 0 {
+		roomType.NumBed = request.NumBed
+	}
+	if request.NumTolet != 0 {
+		roomType.NumTolet = request.NumTolet
+	}
+	if request.Acreage != 0 {
+		roomType.Acreage = request.Acreage
+	}
+	if request.Price != 0 {
+		roomType.Price = request.Price
+	}
+	if request.Description != "" {
+		roomType.Description = request.Description
+	}
+	if request.ShortDescription != "" {
+		roomType.ShortDescription = request.ShortDescription
+	}
+	if request.Avatar != "" {
+		roomType.Avatar = request.Avatar
+	}
+	if request.People != 0 {
+		roomType.People = request.People
+	}
+	if request.Status != nil {
+		roomType.Status = *request.Status
+	}
+	if request.AssignAtCheckIn != nil {
+		roomType.AssignAtCheckIn = *request.AssignAtCheckIn
+	}
+	if err := roomType.ValidateStatus(); err != nil {
+		c.Error(apperrors.BadRequest(err.Error()))
+		return
+	}
+	if err := prepareGallery(request.Img, request.Furniture); err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Phòng thật đã có lịch sử đặt nên không xóa, muốn giảm tồn kho thì chuyển phòng sang bảo trì
+	current := len(roomType.Rooms)
+	if request.Quantity != 0 && request.Quantity < current {
+		c.Error(apperrors.BadRequest("Không thể giảm số lượng phòng, hãy chuyển phòng sang trạng thái bảo trì"))
+		return
+	}
+	var newRooms []models.Room
+	if request.Quantity > current {
+		newRooms = newTypeRooms(roomType.Name, request.RoomNames, current, request.Quantity-current)
+	}
+
+	var plan *models.RatePlan
+	if plan, err = r.RatePlans.FindByRoomType(c.Request.Context(), roomType.ID); err != nil {
+		c.Error(apperrors.Internal("Không thể lấy bảng giá hạng phòng").Wrap(err))
+		return
+	}
+	if request.IsSet() {
+		if plan == nil {
+			plan = &models.RatePlan{RoomTypeID: &roomType.ID}
+		}
+		if err := request.Apply(plan); err != nil {
+			c.Error(apperrors.BadRequest(err.Error()))
+			return
+		}
+	}
+
+	if err := r.RoomTypes.Save(c.Request.Context(), &roomType, newRooms); err != nil {
+		c.Error(apperrors.Internal("Không thể cập nhật hạng phòng").Wrap(err))
+		return
+	}
+	if err := replaceGallery(c.Request.Context(), r.Galleries, r.Storage, models.RoomTypeGallery(roomType.ID), request.Img, request.Furniture); err != nil {
+		c.Error(err)
+		return
+	}
+	if len(request.Img) > 0 {
+		roomType.Img = request.Img
+	}
+	if len(request.Furniture) > 0 {
+		roomType.Furniture = request.Furniture
+	}
+	if request.IsSet() {
+		if err := r.RatePlans.Replace(c.Request.Context(), plan); err != nil {
+			c.Error(apperrors.Internal("Không thể cập nhật bảng giá hạng phòng").Wrap(err))
+			return
+		}
+	}
+	recordAudit(c, "room_type.update", "room_type", roomType.ID, before, roomType)
+
+	tags := []string{services.ListTag("room"), services.EntityTag("accommodation", roomType.AccommodationID)}
+	for _, room := range roomType.Rooms {
+		tags = append(tags, services.EntityTag("room", room.RoomId))
+	}
+	invalidateCache(c.Request.Context(), r.Cache, tags...)
+
+	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Cập nhật hạng phòng thành công", "data": toRoomTypeResponse(roomType, plan)})
+}
@@ -0,0 +1,278 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"new/apperrors"
+	"new/models"
+	"new/repositories"
+	"new/services"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type StayRestrictionController struct {
+	Restrictions   repositories.StayRestrictionRepository
+	Rooms          repositories.RoomRepository
+	Accommodations repositories.AccommodationRepository
+	Users          repositories.UserRepository
+	Cache          services.Cache
+}
+
+func NewStayRestrictionController(restrictions repositories.StayRestrictionRepository, rooms repositories.RoomRepository, accommodations repositories.AccommodationRepository, users repositories.UserRepository, cache services.Cache) StayRestrictionController {
+	return StayRestrictionController{Restrictions: restrictions, Rooms: rooms, Accommodations: accommodations, Users: users, Cache: cache}
+}
+
+// StayRestrictionRequest là một quy định lưu trú. Các trường thứ nhận cùng định dạng với daysPrice của bảng giá
+// (0-6, T2-T7/CN, mon/monday..., weekday, weekend).
+type StayRestrictionRequest struct {
+	FromDate       string   `json:"fromDate"`       // 02/01/2006, bỏ trống: không giới hạn
+	ToDate         string   `json:"toDate"`         // 02/01/2006, gồm cả ngày này
+	ArrivalDays    []string `json:"arrivalDays"`    // Chỉ áp dụng khi ngày nhận phòng rơi vào các thứ này, rỗng: mọi thứ
+	MinNights      int      `json:"minNights"`      // 0: không giới hạn
+	MaxNights      int      `json:"maxNights"`      // 0: không giới hạn
+	NoCheckInDays  []string `json:"noCheckInDays"`  // Thứ không nhận phòng
+	NoCheckOutDays []string `json:"noCheckOutDays"` // Thứ không trả phòng
+	MinAdvanceDays int      `json:"minAdvanceDays"` // Phải đặt trước ít nhất số ngày này
+}
+
+// StayRestrictionsRequest ghi đè toàn bộ quy định, gửi mảng rỗng để xóa
+type StayRestrictionsRequest struct {
+	Restrictions []StayRestrictionRequest `json:"restrictions"`
+}
+
+func parseWeekdayMask(values []string) (int, error) {
+	var days []time.Weekday
+	for _, value := range values {
+		aliases, ok := weekdayAliases[strings.ToLower(strings.TrimSpace(value))]
+		if !ok {
+			return 0, fmt.Errorf("thứ không hợp lệ: %q", value)
+		}
+		days = append(days, aliases...)
+	}
+	return models.WeekdayMask(days...), nil
+}
+
+func weekdayMaskNames(mask int) []string {
+	names := []string{}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if models.HasWeekday(mask, day) {
+			names = append(names, weekdayNames[day])
+		}
+	}
+	return names
+}
+
+func parseOptionalDate(value, field string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	date, err := time.Parse("02/01/2006", value)
+	if err != nil {
+		return nil, fmt.Errorf("sai định dạng %s", field)
+	}
+	return &date, nil
+}
+
+// toModels chuyển request thành quy định của chỗ ở (roomID nil) hoặc của phòng
+func (r StayRestrictionsRequest) toModels(accommodationID, roomID *uint) ([]models.StayRestriction, error) {
+	restrictions := make([]models.StayRestriction, 0, len(r.Restrictions))
+	for _, entry := range r.Restrictions {
+		restriction := models.StayRestriction{
+			AccommodationID: accommodationID,
+			RoomID:          roomID,
+			MinNights:       entry.MinNights,
+			MaxNights:       entry.MaxNights,
+			MinAdvanceDays:  entry.MinAdvanceDays,
+		}
+		var err error
+		if restriction.FromDate, err = parseOptionalDate(entry.FromDate, "fromDate"); err != nil {
+			return nil, err
+		}
+		if restriction.ToDate, err = parseOptionalDate(entry.ToDate, "toDate"); err != nil {
+			return nil, err
+		}
+		if restriction.ArrivalDays, err = parseWeekdayMask(entry.ArrivalDays); err != nil {
+			return nil, err
+		}
+		if restriction.NoCheckInDays, err = parseWeekdayMask(entry.NoCheckInDays); err != nil {
+			return nil, err
+		}
+		if restriction.NoCheckOutDays, err = parseWeekdayMask(entry.NoCheckOutDays); err != nil {
+			return nil, err
+		}
+		if err := restriction.Validate(); err != nil {
+			return nil, err
+		}
+		restrictions = append(restrictions, restriction)
+	}
+	return restrictions, nil
+}
+
+func toStayRestrictionsResponse(restrictions []models.StayRestriction) []StayRestrictionRequest {
+	response := make([]StayRestrictionRequest, 0, len(restrictions))
+	for _, restriction := range restrictions {
+		entry := StayRestrictionRequest{
+			ArrivalDays:    weekdayMaskNames(restriction.ArrivalDays),
+			MinNights:      restriction.MinNights,
+			MaxNights:      restriction.MaxNights,
+			NoCheckInDays:  weekdayMaskNames(restriction.NoCheckInDays),
+			NoCheckOutDays: weekdayMaskNames(restriction.NoCheckOutDays),
+			MinAdvanceDays: restriction.MinAdvanceDays,
+		}
+		if restriction.FromDate != nil {
+			entry.FromDate = restriction.FromDate.Format("02/01/2006")
+		}
+		if restriction.ToDate != nil {
+			entry.ToDate = restriction.ToDate.Format("02/01/2006")
+		}
+		response = append(response, entry)
+	}
+	return response
+}
+
+// GetAccommodationRestrictions godoc
+// @Summary Quy định lưu trú của chỗ ở
+// @Tags restriction
+// @Produce json
+// @Param id path int true "ID chỗ ở"
+// @Success 200 {object} gin.H {"code": 1, "mess": "...", "data": []StayRestrictionRequest}
+// @Router /accommodation/{id}/restrictions [get]
+func (s StayRestrictionController) GetAccommodationRestrictions(c *gin.Context) {
+	accommodation, err := s.Accommodations.FindByID(c.Request.Context(), paramID(c, "id"))
+	if err != nil {
+		c.Error(apperrors.NotFound("Chỗ ở không tồn tại"))
+		return
+	}
+
+	restrictions, err := s.Restrictions.ListByAccommodation(c.Request.Context(), accommodation.ID)
+	if err != nil {
+		c.Error(apperrors.Internal("Không thể lấy quy định lưu trú").Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Lấy quy định lưu trú thành công", "data": toStayRestrictionsResponse(restrictions)})
+}
+
+// UpdateAccommodationRestrictions godoc
+// @Summary Ghi đè quy định lưu trú của chỗ ở
+// @Description Quy định của chỗ ở áp dụng cho mọi đơn đặt, kể cả đặt theo phòng và hạng phòng
+// @Tags restriction
+// @Accept json
+// @Produce json
+// @Param id path int true "ID chỗ ở"
+// @Param request body StayRestrictionsRequest true "Quy định lưu trú"
+// @Success 200 {object} gin.H {"code": 1, "mess": "...", "data": []StayRestrictionRequest}
+// @Router /accommodation/{id}/restrictions [put]
+func (s StayRestrictionController) UpdateAccommodationRestrictions(c *gin.Context) {
+	var request StayRestrictionsRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+
+	accommodation, err := s.Accommodations.FindByID(c.Request.Context(), paramID(c, "id"))
+	if err != nil {
+		c.Error(apperrors.NotFound("Chỗ ở không tồn tại"))
+		return
+	}
+	if !requireAccommodationManager(c, s.Users, accommodation) {
+		return
+	}
+
+	restrictions, err := request.toModels(&accommodation.ID, nil)
+	if err != nil {
+		c.Error(apperrors.BadRequest(err.Error()))
+		return
+	}
+
+	existing, err := s.Restrictions.ListByAccommodation(c.Request.Context(), accommodation.ID)
+	if err != nil {
+		c.Error(apperrors.Internal("Không thể lấy quy định lưu trú").Wrap(err))
+		return
+	}
+	if err := s.Restrictions.ReplaceForAccommodation(c.Request.Context(), accommodation.ID, restrictions); err != nil {
+		c.Error(apperrors.Internal("Không thể cập nhật quy định lưu trú").Wrap(err))
+		return
+	}
+	response := toStayRestrictionsResponse(restrictions)
+	recordAudit(c, "accommodation.restrictions.update", "accommodation", accommodation.ID, toStayRestrictionsResponse(existing), response)
+
+	invalidateCache(c.Request.Context(), s.Cache, services.EntityTag("accommodation", accommodation.ID))
+
+	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Cập nhật quy định lưu trú thành công", "data": response})
+}
+
+// GetRoomRestrictions godoc
+// @Summary Quy định lưu trú riêng của phòng
+// @Tags restriction
+// @Produce json
+// @Param id path int true "ID phòng"
+// @Success 200 {object} gin.H {"code": 1, "mess": "...", "data": []StayRestrictionRequest}
+// @Router /room/{id}/restrictions [get]
+func (s StayRestrictionController) GetRoomRestrictions(c *gin.Context) {
+	room, err := s.Rooms.FindByID(c.Request.Context(), paramID(c, "id"))
+	if err != nil {
+		c.Error(apperrors.NotFound("Phòng không tồn tại"))
+		return
+	}
+
+	restrictions, err := s.Restrictions.ListByRoom(c.Request.Context(), room.RoomId)
+	if err != nil {
+		c.Error(apperrors.Internal("Không thể lấy quy định lưu trú").Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Lấy quy định lưu trú thành công", "data": toStayRestrictionsResponse(restrictions)})
+}
+
+// UpdateRoomRestrictions godoc
+// @Summary Ghi đè quy định lưu trú riêng của phòng
+// @Description Áp dụng thêm vào quy định của chỗ ở khi đặt phòng này
+// @Tags restriction
+// @Accept json
+// @Produce json
+// @Param id path int true "ID phòng"
+// @Param request body StayRestrictionsRequest true "Quy định lưu trú"
+// @Success 200 {object} gin.H {"code": 1, "mess": "...", "data": []StayRestrictionRequest}
+// @Router /room/{id}/restrictions [put]
+func (s StayRestrictionController) UpdateRoomRestrictions(c *gin.Context) {
+	var request StayRestrictionsRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Error(apperrors.Validation(err))
+		return
+	}
+
+	room, err := s.Rooms.FindByID(c.Request.Context(), paramID(c, "id"))
+	if err != nil {
+		c.Error(apperrors.NotFound("Phòng không tồn tại"))
+		return
+	}
+	if !requireRoomManager(c, s.Users, s.Accommodations, room) {
+		return
+	}
+
+	restrictions, err := request.toModels(nil, &room.RoomId)
+	if err != nil {
+		c.Error(apperrors.BadRequest(err.Error()))
+		return
+	}
+
+	existing, err := s.Restrictions.ListByRoom(c.Request.Context(), room.RoomId)
+	if err != nil {
+		c.Error(apperrors.Internal("Không thể lấy quy định lưu trú").Wrap(err))
+		return
+	}
+	if err := s.Restrictions.ReplaceForRoom(c.Request.Context(), room.RoomId, restrictions); err != nil {
+		c.Error(apperrors.Internal("Không thể cập nhật quy định lưu trú").Wrap(err))
+		return
+	}
+	response := toStayRestrictionsResponse(restrictions)
+	recordAudit(c, "room.restrictions.update", "room", room.RoomId, toStayRestrictionsResponse(existing), response)
+
+	invalidateCache(c.Request.Context(), s.Cache, services.EntityTag("room", room.RoomId))
+
+	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Cập nhật quy định lưu trú thành công", "data": response})
+}
@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"new/apperrors"
+	"new/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Thư mục lưu ảnh theo mục đích, ảnh trong galleryFolder được xóa khi bị bỏ khỏi thư viện ảnh
+const (
+	galleryFolder = "uploads"
+	avatarFolder  = "avatars"
+)
+
+type UploadController struct {
+	Storage  services.Storage
+	Limits   services.ImageLimits
+	MaxFiles int
+}
+
+func NewUploadController(storage services.Storage, limits services.ImageLimits, maxFiles int) UploadController {
+	return UploadController{Storage: storage, Limits: limits, MaxFiles: maxFiles}
+}
+
+// UploadedImage là ảnh đã lưu, client gửi lại url/publicId/thumbnailUrl/width/height trong img của chỗ ở/phòng
+type UploadedImage struct {
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnailUrl"`
+	PublicID     string `json:"publicId"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	ContentType  string `json:"contentType"`
+}
+
+// limitBody giới hạn dung lượng cả request trước khi gin đọc multipart form
+func (u UploadController) limitBody(c *gin.Context, files int) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, int64(files)*u.Limits.MaxBytes+1<<20)
+}
+
+// formError phân biệt request vượt dung lượng với form không hợp lệ
+func formError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return apperrors.PayloadTooLarge("Dung lượng upload vượt quá giới hạn")
+	}
+	return apperrors.BadRequest("Không có file")
+}
+
+// saveImage kiểm tra, xử lý rồi lưu ảnh và ảnh thu nhỏ vào folder
+func (u UploadController) saveImage(ctx context.Context, file *multipart.FileHeader, folder string) (UploadedImage, error) {
+	if file.Size > u.Limits.MaxBytes {
+		return UploadedImage{}, apperrors.PayloadTooLarge("File " + file.Filename + " vượt quá dung lượng cho phép")
+	}
+	src, err := file.Open()
+	if err != nil {
+		return UploadedImage{}, apperrors.BadRequest("Lỗi khi mở file")
+	}
+	defer src.Close()
+
+	original, thumbnail, err := services.ProcessImage(src, u.Limits)
+	switch {
+	case errors.Is(err, services.ErrFileTooLarge):
+		return UploadedImage{}, apperrors.PayloadTooLarge("File " + file.Filename + " vượt quá dung lượng cho phép")
+	case errors.Is(err, services.ErrUnsupportedImage):
+		return UploadedImage{}, apperrors.UnsupportedMediaType("File " + file.Filename + " không phải ảnh JPEG hoặc PNG")
+	case errors.Is(err, services.ErrImageTooLarge):
+		return UploadedImage{}, apperrors.BadRequest("Ảnh " + file.Filename + " có kích thước quá lớn")
+	case err != nil:
+		return UploadedImage{}, apperrors.Internal("Không thể xử lý ảnh").Wrap(err)
+	}
+
+	key := services.NewObjectKey(folder, original.Ext)
+	url, err := u.Storage.Put(ctx, key, original.ContentType, original.Data)
+	if err != nil {
+		return UploadedImage{}, apperrors.Internal("Upload thất bại").Wrap(err)
+	}
+	thumbnailURL, err := u.Storage.Put(ctx, services.ThumbnailKey(key), thumbnail.ContentType, thumbnail.Data)
+	if err != nil {
+		u.discard(ctx, key)
+		return UploadedImage{}, apperrors.Internal("Upload thất bại").Wrap(err)
+	}
+	return UploadedImage{
+		URL:          url,
+		ThumbnailURL: thumbnailURL,
+		PublicID:     key,
+		Width:        original.Width,
+		Height:       original.Height,
+		ContentType:  original.ContentType,
+	}, nil
+}
+
+// discard xóa các ảnh đã lưu khi request upload thất bại giữa chừng
+func (u UploadController) discard(ctx context.Context, keys ...string) {
+	for _, key := range keys {
+		if err := u.Storage.Delete(ctx, key); err != nil {
+			services.Logger(ctx).Warn("Không thể xóa ảnh upload dở", "key", key, "error", err)
+		}
+	}
+}
+
+// MultiUpload tải nhiều ảnh cho thư viện ảnh (field "files")
+func (u UploadController) MultiUpload(c *gin.Context) {
+	u.limitBody(c, u.MaxFiles)
+	form, err := c.MultipartForm()
+	if err != nil {
+		c.Error(formError(err))
+		return
+	}
+	files := form.File["files"]
+	if len(files) == 0 {
+		c.Error(apperrors.BadRequest("Không có file"))
+		return
+	}
+	if len(files) > u.MaxFiles {
+		c.Error(apperrors.BadRequest(fmt.Sprintf("Chỉ được tải lên tối đa %d ảnh mỗi lần", u.MaxFiles)))
+		return
+	}
+
+	images := make([]UploadedImage, 0, len(files))
+	for _, file := range files {
+		image, err := u.saveImage(c.Request.Context(), file, galleryFolder)
+		if err != nil {
+			for _, saved := range images {
+				u.discard(c.Request.Context(), saved.PublicID, services.ThumbnailKey(saved.PublicID))
+			}
+			c.Error(err)
+			return
+		}
+		images = append(images, image)
+	}
+
+	urls := make([]string, len(images))
+	for i, image := range images {
+		urls[i] = image.URL
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    1,
+		"message": "Upload thành công",
+		"urls":    urls,
+		"data":    images,
+	})
+}
+
+// Upload tải một ảnh đại diện (field "file")
+func (u UploadController) Upload(c *gin.Context) {
+	u.limitBody(c, 1)
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.Error(formError(err))
+		return
+	}
+
+	image, err := u.saveImage(c.Request.Context(), file, avatarFolder)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    1,
+		"message": "Upload avatar thành công",
+		"url":     image.URL,
+		"data":    image,
+	})
+}
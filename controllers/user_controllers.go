@@ -2,31 +2,29 @@ package controllers
 
 import (
 	"fmt"
-	"log"
 	"net/http"
-	"new/config"
+	"new/apperrors"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"new/models"
+	"new/repositories"
 	"new/services"
 
 	"github.com/gin-gonic/gin"
-	"github.com/redis/go-redis/v9"
-	"gorm.io/gorm"
 )
 
 type UserController struct {
-	DB    *gorm.DB
-	Redis *redis.Client
+	Users repositories.UserRepository
+	Cache services.Cache
 }
 
-func NewUserController(mySQL *gorm.DB, redisCli *redis.Client) UserController {
+func NewUserController(users repositories.UserRepository, cache services.Cache) UserController {
 	return UserController{
-		DB:    mySQL,
-		Redis: redisCli,
+		Users: users,
+		Cache: cache,
 	}
 }
 
@@ -67,14 +65,14 @@ type StausUser struct {
 func (u UserController) GetUsers(c *gin.Context) {
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Authorization header is missing"})
+		c.Error(apperrors.Unauthorized("Authorization header is missing"))
 		return
 	}
 
 	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 	currentUserID, currentUserRole, err := GetUserIDFromToken(tokenString)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Invalid token"})
+		c.Error(apperrors.Unauthorized("Invalid token"))
 		return
 	}
 
@@ -101,59 +99,45 @@ func (u UserController) GetUsers(c *gin.Context) {
 	} else if currentUserRole == 2 {
 		cacheKey = fmt.Sprintf("users:role_3:admin_%d", currentUserID)
 	} else {
-		c.JSON(http.StatusForbidden, gin.H{"code": 0, "mess": "Bạn không có quyền truy cập danh sách này"})
+		c.Error(apperrors.Forbidden("Bạn không có quyền truy cập danh sách này"))
 		return
 	}
 
-	// Kết nối Redis
-	rdb, err := config.ConnectRedis()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể kết nối Redis"})
-		return
-	}
+	// Bộ lọc được truy vấn trong DB nên phải nằm trong key
+	cacheKey = fmt.Sprintf("%s:name=%s:role=%s:status=%s", cacheKey, name, roleStr, statusStr)
 
 	var allUsers []models.User
 
 	// Kiểm tra cache
-	if err := services.GetFromRedis(config.Ctx, rdb, cacheKey, &allUsers); err != nil || len(allUsers) == 0 {
+	found, err := u.Cache.Get(c.Request.Context(), cacheKey, &allUsers)
+	if err != nil {
+		services.Logger(c.Request.Context()).Warn("Lỗi khi đọc cache", "key", cacheKey, "error", err)
+	}
+	if !found {
 		// Nếu không có dữ liệu trong cache, truy vấn từ DB
-		query := u.DB.Preload("Banks").Preload("Children").
-			Where("name LIKE ? OR email LIKE ? OR phone_number LIKE ?", "%"+name+"%", "%"+name+"%", "%"+name+"%")
-
-		if roleStr != "" {
-			userRole, err := strconv.Atoi(roleStr)
-			if err == nil {
-				query = query.Where("role = ?", userRole)
-			}
-		}
-
-		if statusStr != "" {
-			status, err := strconv.Atoi(statusStr)
-			if err == nil {
-				query = query.Where("status = ?", status)
-			}
+		filter := repositories.UserFilter{
+			Search: name,
+			Role:   queryInt(c, "role"),
+			Status: queryInt(c, "status"),
 		}
-
-		if currentUserRole == 3 {
-			var adminID int
-			if err := u.DB.Model(&models.User{}).Select("admin_id").Where("id = ?", currentUserID).Scan(&adminID).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể xác định admin_id của người dùng hiện tại"})
-				return
-			}
-
-			query = query.Where("role = 3 AND admin_id = ?", adminID)
-		} else if currentUserRole == 2 {
-			query = query.Where("role = 3 AND admin_id = ?", currentUserID)
+		if currentUserRole == 2 {
+			// Admin chỉ xem lễ tân của mình
+			filter.ManagedBy = currentUserID
 		}
 
-		if err := query.Find(&allUsers).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Lỗi khi lấy danh sách người dùng"})
+		allUsers, err = u.Users.List(c.Request.Context(), filter)
+		if err != nil {
+			c.Error(apperrors.Internal("Lỗi khi lấy danh sách người dùng").Wrap(err))
 			return
 		}
 
-		// Lưu dữ liệu vào Redis
-		if err := services.SetToRedis(config.Ctx, rdb, cacheKey, allUsers, time.Hour); err != nil {
-			log.Printf("Lỗi khi lưu danh sách người dùng vào Redis: %v", err)
+		// Lưu cache, gắn tag từng người dùng để xóa khi họ thay đổi
+		tags := []string{services.ListTag("user")}
+		for _, user := range allUsers {
+			tags = append(tags, services.EntityTag("user", user.ID))
+		}
+		if err := u.Cache.Set(c.Request.Context(), cacheKey, allUsers, time.Hour, tags...); err != nil {
+			services.Logger(c.Request.Context()).Warn("Lỗi khi lưu danh sách người dùng vào cache", "error", err)
 		}
 	}
 
@@ -261,35 +245,39 @@ func (u UserController) GetUsers(c *gin.Context) {
 func (u UserController) CreateUser(c *gin.Context) {
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Authorization header is missing"})
+		c.Error(apperrors.Unauthorized("Authorization header is missing"))
 		return
 	}
 
 	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 	currentUserID, err := GetIDFromToken(tokenString)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Invalid token"})
+		c.Error(apperrors.Unauthorized("Invalid token"))
 		return
 	}
 
 	var req CreateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": err.Error()})
+		c.Error(apperrors.Validation(err))
 		return
 	}
 
 	var user models.User
 
 	if req.Role == 1 || req.Role == 2 {
-		var bankFake models.BankFake
-		if err := u.DB.Where("id = ? AND account_numbers::jsonb @> ?", req.BankId, fmt.Sprintf(`["%s"]`, req.AccountNumber)).First(&bankFake).Error; err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Không có số tài khoản phù hợp"})
+		bankFake, err := u.Users.FindBankFake(c.Request.Context(), req.BankId, req.AccountNumber)
+		if err != nil {
+			c.Error(apperrors.BadRequest("Không có số tài khoản phù hợp"))
 			return
 		}
 
-		var existingBank models.Bank
-		if err := u.DB.Where("account_number = ?", req.AccountNumber).First(&existingBank).Error; err == nil {
-			c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Số tài khoản đã có người sử dụng"})
+		exists, err := u.Users.AccountNumberExists(c.Request.Context(), req.AccountNumber)
+		if err != nil {
+			c.Error(apperrors.Internal("Không thể kiểm tra số tài khoản").Wrap(err))
+			return
+		}
+		if exists {
+			c.Error(apperrors.BadRequest("Số tài khoản đã có người sử dụng"))
 			return
 		}
 
@@ -300,9 +288,9 @@ func (u UserController) CreateUser(c *gin.Context) {
 			Role:        req.Role,
 		}
 
-		user, err = services.CreateUser(userValues)
+		user, err = services.CreateUser(c.Request.Context(), userValues)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": err.Error()})
+			c.Error(apperrors.BadRequest(err.Error()))
 			return
 		}
 
@@ -313,21 +301,21 @@ func (u UserController) CreateUser(c *gin.Context) {
 			BankShortName: bankFake.BankShortName,
 		}
 
-		if err := u.DB.Create(&bank).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể tạo ngân hàng: " + err.Error()})
+		if err := u.Users.CreateBank(c.Request.Context(), &bank); err != nil {
+			c.Error(apperrors.Internal("Không thể tạo ngân hàng").Wrap(err))
 			return
 		}
 
 		user.Banks = append(user.Banks, bank)
 
-		if err := u.DB.Save(&user).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể cập nhật thông tin người dùng: " + err.Error()})
+		if err := u.Users.Save(c.Request.Context(), &user); err != nil {
+			c.Error(apperrors.Internal("Không thể cập nhật thông tin người dùng").Wrap(err))
 			return
 		}
 	} else if req.Role == 3 {
-		var admin models.User
-		if err := u.DB.Where("id = ?", currentUserID).First(&admin).Error; err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Không tìm thấy admin với ID: " + fmt.Sprint(currentUserID)})
+		admin, err := u.Users.FindByID(c.Request.Context(), currentUserID)
+		if err != nil {
+			c.Error(apperrors.NotFound("Không tìm thấy admin với ID: " + fmt.Sprint(currentUserID)))
 			return
 		}
 
@@ -338,34 +326,27 @@ func (u UserController) CreateUser(c *gin.Context) {
 			Role:        req.Role,
 		}
 
-		user, err = services.CreateUser(userValues)
+		user, err = services.CreateUser(c.Request.Context(), userValues)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": err.Error()})
+			c.Error(apperrors.BadRequest(err.Error()))
 			return
 		}
 
 		admin.Children = append(admin.Children, user)
 
-		if err := u.DB.Save(&admin).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Không thể cập nhật thông tin admin: " + err.Error()})
+		if err := u.Users.Save(c.Request.Context(), &admin); err != nil {
+			c.Error(apperrors.Internal("Không thể cập nhật thông tin admin").Wrap(err))
 			return
 		}
 	} else {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": "Vai trò không hợp lệ"})
+		c.Error(apperrors.BadRequest("Vai trò không hợp lệ"))
 		return
 	}
 
-	//Xóa redis
-	rdb, redisErr := config.ConnectRedis()
-	if redisErr == nil {
-		switch req.Role {
-		case 1, 2:
-			_ = services.DeleteFromRedis(config.Ctx, rdb, "user:all")
-		case 3:
-			adminCacheKey := fmt.Sprintf("users:role_3:admin_%d", currentUserID)
-			_ = services.DeleteFromRedis(config.Ctx, rdb, adminCacheKey)
-		}
-	}
+	// Xóa cache danh sách người dùng
+	u.invalidateUserCache(c, user.ID)
+
+	recordAudit(c, "user.create", "user", user.ID, nil, user)
 
 	c.JSON(http.StatusCreated, gin.H{"code": 1, "mess": "Tạo người dùng thành công", "data": user})
 }
@@ -377,11 +358,9 @@ func (u UserController) CreateUser(c *gin.Context) {
 // @Success 200 {object} models.User
 // @Router /users/{id} [get]
 func (u UserController) GetUserByID(c *gin.Context) {
-	var user models.User
-	id := c.Param("id")
-
-	if err := u.DB.First(&user, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"code": 0, "mess": "Người dùng không tồn tại"})
+	user, err := u.Users.FindByID(c.Request.Context(), paramID(c, "id"))
+	if err != nil {
+		c.Error(apperrors.NotFound("Người dùng không tồn tại"))
 		return
 	}
 
@@ -423,28 +402,29 @@ func (u UserController) GetUserByID(c *gin.Context) {
 func (u UserController) UpdateUser(c *gin.Context) {
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Authorization header is missing"})
+		c.Error(apperrors.Unauthorized("Authorization header is missing"))
 		return
 	}
 
 	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-	currentUserID, currentUserRole, err := GetUserIDFromToken(tokenString)
+	currentUserID, _, err := GetUserIDFromToken(tokenString)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Invalid token"})
+		c.Error(apperrors.Unauthorized("Invalid token"))
 		return
 	}
 
 	var updateUser UpdateUser
 	if err := c.ShouldBindJSON(&updateUser); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": err.Error()})
+		c.Error(apperrors.Validation(err))
 		return
 	}
 
-	var user models.User
-	if err := u.DB.Preload("Banks").First(&user, currentUserID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"code": 0, "mess": "Người dùng không tồn tại"})
+	user, err := u.Users.FindWithBanks(c.Request.Context(), currentUserID)
+	if err != nil {
+		c.Error(apperrors.NotFound("Người dùng không tồn tại"))
 		return
 	}
+	before := user
 
 	if updateUser.Name != "" && updateUser.Name != " " {
 		user.Name = updateUser.Name
@@ -462,8 +442,8 @@ func (u UserController) UpdateUser(c *gin.Context) {
 		user.DateOfBirth = updateUser.DateOfBirth
 	}
 
-	if err := u.DB.Save(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": err.Error()})
+	if err := u.Users.Save(c.Request.Context(), &user); err != nil {
+		c.Error(err)
 		return
 	}
 
@@ -493,17 +473,10 @@ func (u UserController) UpdateUser(c *gin.Context) {
 		DateOfBirth:  user.DateOfBirth,
 	}
 
-	//Xóa redis
-	rdb, redisErr := config.ConnectRedis()
-	if redisErr == nil {
-		switch currentUserRole {
-		case 1, 2:
-			_ = services.DeleteFromRedis(config.Ctx, rdb, "user:all")
-		case 3:
-			adminCacheKey := fmt.Sprintf("users:role_3:admin_%d", currentUserID)
-			_ = services.DeleteFromRedis(config.Ctx, rdb, adminCacheKey)
-		}
-	}
+	// Xóa cache danh sách người dùng
+	u.invalidateUserCache(c, user.ID)
+
+	recordAudit(c, "user.update", "user", user.ID, before, user)
 
 	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Cập nhật người dùng thành công", "data": userResponse})
 }
@@ -524,20 +497,20 @@ func (u UserController) ChangeUserStatus(c *gin.Context) {
 
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Authorization header is missing"})
+		c.Error(apperrors.Unauthorized("Authorization header is missing"))
 		return
 	}
 
 	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 	currentUserID, currentUserRole, err := GetUserIDFromToken(tokenString)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Invalid token"})
+		c.Error(apperrors.Unauthorized("Invalid token"))
 		return
 	}
 
 	var statusRequest StausUser
 	if err := c.ShouldBindJSON(&statusRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 0, "mess": err.Error()})
+		c.Error(apperrors.Validation(err))
 		return
 	}
 
@@ -545,55 +518,57 @@ func (u UserController) ChangeUserStatus(c *gin.Context) {
 
 	if currentUserRole == 2 {
 
-		if err := u.DB.Where("id = ? AND admin_id = ?", statusRequest.Id, currentUserID).First(&user).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"code": 0, "mess": "Người dùng không tồn tại hoặc không thuộc quyền quản lý của admin"})
+		if user, err = u.Users.FindManaged(c.Request.Context(), statusRequest.Id, currentUserID); err != nil {
+			c.Error(apperrors.NotFound("Người dùng không tồn tại hoặc không thuộc quyền quản lý của admin"))
 			return
 		}
 	} else if currentUserRole == 1 {
 
-		if err := u.DB.First(&user, statusRequest.Id).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"code": 0, "mess": "Người dùng không tồn tại"})
+		if user, err = u.Users.FindByID(c.Request.Context(), statusRequest.Id); err != nil {
+			c.Error(apperrors.NotFound("Người dùng không tồn tại"))
 			return
 		}
 
 		if user.Role == 2 {
-			var childUsers []models.User
-			if err := u.DB.Where("admin_id = ?", user.ID).Find(&childUsers).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Lỗi khi tìm tài khoản con"})
+			childUsers, err := u.Users.ListChildren(c.Request.Context(), user.ID)
+			if err != nil {
+				c.Error(apperrors.Internal("Lỗi khi tìm tài khoản con").Wrap(err))
 				return
 			}
 
 			for _, child := range childUsers {
+				childBefore := child
 				child.Status = statusRequest.Status
-				if err := u.DB.Save(&child).Error; err != nil {
-					c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": "Lỗi khi cập nhật trạng thái của tài khoản con"})
+				if err := u.Users.Save(c.Request.Context(), &child); err != nil {
+					c.Error(apperrors.Internal("Lỗi khi cập nhật trạng thái của tài khoản con").Wrap(err))
 					return
 				}
+				recordAudit(c, "user.status", "user", child.ID, childBefore, child)
 			}
 		}
 	} else {
 
-		c.JSON(http.StatusForbidden, gin.H{"code": 0, "mess": "Tài khoản này không có quyền cập nhật trạng thái"})
+		c.Error(apperrors.Forbidden("Tài khoản này không có quyền cập nhật trạng thái"))
 		return
 	}
 
+	before := user
 	user.Status = statusRequest.Status
-	if err := u.DB.Save(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "mess": err.Error()})
+	if err := u.Users.Save(c.Request.Context(), &user); err != nil {
+		c.Error(err)
 		return
 	}
+	recordAudit(c, "user.status", "user", user.ID, before, user)
 
-	//Xóa redis
-	rdb, redisErr := config.ConnectRedis()
-	if redisErr == nil {
-		switch currentUserRole {
-		case 1:
-			_ = services.DeleteFromRedis(config.Ctx, rdb, "user:all")
-		case 3:
-			adminCacheKey := fmt.Sprintf("users:role_3:admin_%d", currentUserID)
-			_ = services.DeleteFromRedis(config.Ctx, rdb, adminCacheKey)
-		}
-	}
+	// Xóa cache danh sách người dùng
+	u.invalidateUserCache(c, user.ID)
 
 	c.JSON(http.StatusOK, gin.H{"code": 1, "mess": "Thay đổi trạng thái người dùng thành công", "data": user})
 }
+
+// Xóa cache danh sách người dùng và các danh sách có preload người dùng này
+func (u UserController) invalidateUserCache(c *gin.Context, userID uint) {
+	if err := u.Cache.InvalidateTags(c.Request.Context(), services.ListTag("user"), services.EntityTag("user", userID)); err != nil {
+		services.Logger(c.Request.Context()).Warn("Lỗi khi xóa cache người dùng", "user", userID, "error", err)
+	}
+}
@@ -1,71 +1,111 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
 	"new/config"
 	_ "new/docs"
+	middlewares "new/middleware"
+	"new/migrations"
 	"new/routes"
+	"new/services"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-contrib/cors"
 
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
-func recreateUserTable() {
-
-	// if err := config.DB.Migrator().DropTable(&models.Rate{}); err != nil {
-	// 	panic("Failed to drop Room table: " + err.Error())
-	// }
-
-	//if err := config.DB.AutoMigrate(&models.Room{}, &models.Benefit{}, &models.User{}, models.Rate{}, models.Order{}, models.Invoice{}, models.Bank{}, models.Accommodation{}, models.AccommodationStatus{}, models.BankFake{}, models.UserDiscount{}, models.Discount{}, models.Holiday{}, models.RoomStatus{}); err != nil {
-	//	panic("Failed to migrate tables: " + err.Error())
-	//}
-
-	//if err := config.DB.AutoMigrate(&models.User{}); err != nil {
-	//	panic("Failed to migrate tables: " + err.Error())
-	//}
-
-	// Thêm cột PaymentType vào bảng Invoice
-	// if err := config.DB.Migrator().AddColumn(&models.Invoice{}, "PaymentType"); err != nil {
-	// 	log.Fatalf("Failed to add column: %v", err)
-	// }
-
-	// Thêm cột PaymentType vào bảng Invoice
-	// if err := config.DB.Migrator().AlterColumn(&models.Accommodation{}, "Longitude"); err != nil {
-	// 	log.Fatalf("Failed to add column: %v", err)
-	// }
-
-	// if err := config.DB.Migrator().AlterColumn(&models.Accommodation{}, "Latitude"); err != nil {
-	// 	log.Fatalf("Failed to add column: %v", err)
-	// }
+// runMigrations chạy subcommand `migrate` rồi thoát
+func runMigrations(ctx context.Context, args []string) {
+	sqlDB, err := config.DB.DB()
+	if err != nil {
+		fatal("Không thể lấy kết nối DB", err)
+	}
+	if err := migrations.Run(ctx, sqlDB, args, os.Stdout); err != nil {
+		fatal("Migration thất bại", err)
+	}
+}
 
-	println("User and Bank tables have been recreated successfully.")
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
 
 func main() {
-	router := gin.Default()
+	slog.SetDefault(services.NewLogger(os.Stdout, slog.LevelInfo))
 
-	err := config.LoadEnv()
+	cfg, err := config.Load()
 	if err != nil {
-		panic("Failed to load .env file")
+		fatal("Không thể nạp cấu hình", err)
 	}
+	slog.SetDefault(services.NewLogger(os.Stdout, cfg.Log.Level))
+	// Secret được che khi ghi log
+	slog.Info("Cấu hình", "config", cfg)
 
-	config.ConnectDB()
-
-	// Khởi tạo Cloudinary
-	config.ConnectCloudinary()
+	if cfg.Env != "dev" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	// Bật tracing trước khi kết nối DB/Redis để các plugin dùng đúng tracer provider
+	shutdownTracing, err := services.InitTracing(context.Background(), cfg.Tracing)
+	if err != nil {
+		fatal("Không thể khởi tạo tracing", err)
+	}
 
-	recreateUserTable()
+	router := gin.New()
+	router.Use(
+		otelgin.Middleware(cfg.Tracing.ServiceName),
+		middlewares.RequestIDMiddleware(),
+		middlewares.MetricsMiddleware(),
+		middlewares.ErrorMiddleware(),
+		middlewares.RecoveryMiddleware(),
+	)
+
+	config.ConnectDB(cfg.DB)
+
+	// go run . migrate up | down [n] | status | to <version>
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrations(context.Background(), os.Args[2:])
+		return
+	}
+	if cfg.DB.AutoMigrate {
+		runMigrations(context.Background(), []string{"up"})
+	}
 
-	redisCli, err := config.ConnectRedis()
+	// Nơi lưu ảnh upload: Cloudinary, thư mục local hoặc S3
+	if cfg.Storage.Driver == "cloudinary" {
+		config.ConnectCloudinary(cfg.Cloudinary)
+	}
+	storage, err := services.NewStorage(cfg.Storage, config.Cloudinary)
 	if err != nil {
-		panic("Failed to connect to Redis!")
+		fatal("Không thể khởi tạo nơi lưu ảnh", err)
 	}
 
+	// Đăng ký các nhà cung cấp đăng nhập mạng xã hội
+	services.InitIdentityProviders(cfg)
+
+	// Các worker nền dừng khi nhận SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Dọn nhật ký kiểm toán quá hạn lưu trữ mỗi ngày
+	services.StartAuditRetention(ctx, cfg.Audit.RetentionDays, 24*time.Hour)
+
+	// Redis chỉ dùng làm cache và rate limit, thiếu Redis ứng dụng vẫn chạy
+	redisCli := config.NewRedisClient(cfg.Redis)
+
 	configCors := cors.DefaultConfig()
-	configCors.AddAllowHeaders("Authorization")
+	configCors.AddAllowHeaders("Authorization", middlewares.RequestIDHeader)
+	configCors.AddExposeHeaders(middlewares.RequestIDHeader)
 	configCors.AllowCredentials = true
 	configCors.AllowAllOrigins = false
 	configCors.AllowOriginFunc = func(origin string) bool {
@@ -74,16 +114,64 @@ func main() {
 
 	router.Use(cors.New(configCors))
 
-	routes.SetupRoutes(router, config.DB, redisCli, config.Cloudinary)
+	routes.SetupRoutes(ctx, router, config.DB, redisCli, storage)
 
-	router.Use(func(c *gin.Context) {
-		c.Next()
-		for key, value := range c.Writer.Header() {
-			fmt.Println(key, value)
+	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+
+	srv := &http.Server{
+		Addr:              cfg.Server.Addr,
+		Handler:           router,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("Server đang chạy", "addr", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
 		}
-	})
+	}()
 
-	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	select {
+	case err := <-serverErr:
+		fatal("Không thể chạy server", err)
+	case <-ctx.Done():
+	}
+	stop()
+	shutdown(srv, redisCli, shutdownTracing, cfg.Server.ShutdownTimeout)
+}
 
-	router.Run(":8083")
+// shutdown ngừng nhận request mới, chờ các request đang xử lý và worker nền dừng,
+// sau đó mới đóng connection pool của DB và Redis.
+func shutdown(srv *http.Server, redisCli *redis.Client, shutdownTracing func(context.Context) error, timeout time.Duration) {
+	slog.Info("Đang tắt server", "timeout", timeout.String())
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Error("Không thể chờ hết request đang xử lý", "error", err)
+	}
+	if err := services.BackgroundWorkers.Wait(ctx); err != nil {
+		slog.Error("Worker nền chưa dừng kịp", "error", err)
+	}
+
+	if sqlDB, err := config.DB.DB(); err == nil {
+		if err := sqlDB.Close(); err != nil {
+			slog.Error("Lỗi khi đóng kết nối DB", "error", err)
+		}
+	}
+	if redisCli != nil {
+		if err := redisCli.Close(); err != nil {
+			slog.Error("Lỗi khi đóng kết nối Redis", "error", err)
+		}
+	}
+	// Gửi nốt các span còn trong bộ đệm
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("Lỗi khi tắt tracing", "error", err)
+	}
+	slog.Info("Server đã tắt")
 }
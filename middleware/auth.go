@@ -1,17 +1,19 @@
 package middlewares
 
 import (
-	"github.com/gin-gonic/gin"
-	"net/http"
+	"new/apperrors"
 	"new/controllers"
+	"new/services"
 	"strings"
+
+	"github.com/gin-gonic/gin"
 )
 
 func AuthMiddleware(requiredRoles ...int) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Authorization header is missing"})
+			c.Error(apperrors.Unauthorized("Authorization header is missing"))
 			c.Abort()
 			return
 		}
@@ -20,11 +22,16 @@ func AuthMiddleware(requiredRoles ...int) gin.HandlerFunc {
 
 		currentUserID, currentUserRole, err := controllers.GetUserIDFromToken(tokenString)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"code": 0, "mess": "Invalid token"})
+			c.Error(apperrors.Unauthorized("Invalid token"))
 			c.Abort()
 			return
 		}
 
+		// Gắn người dùng vào logger của request, kể cả khi bị từ chối quyền
+		ctx := c.Request.Context()
+		logger := services.Logger(ctx).With("user_id", currentUserID, "role", currentUserRole)
+		c.Request = c.Request.WithContext(services.WithLogger(ctx, logger))
+
 		hasRole := false
 		for _, role := range requiredRoles {
 			if currentUserRole == role {
@@ -34,7 +41,7 @@ func AuthMiddleware(requiredRoles ...int) gin.HandlerFunc {
 		}
 
 		if !hasRole {
-			c.JSON(http.StatusForbidden, gin.H{"message": "Bạn không có quyền truy cập"})
+			c.Error(apperrors.Forbidden("Bạn không có quyền truy cập"))
 			c.Abort()
 			return
 		}
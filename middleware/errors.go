@@ -0,0 +1,42 @@
+package middlewares
+
+import (
+	"new/apperrors"
+	"new/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorMiddleware trả về response lỗi chung cho lỗi cuối cùng mà handler ghi bằng c.Error:
+// {"code": 0, "mess": "...", "error": {"code": "NOT_FOUND", "key": "errors.not_found", "details": ...}}
+func ErrorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		appErr := apperrors.From(c.Errors.Last().Err)
+		if appErr.Status >= 500 {
+			services.Logger(c.Request.Context()).Error(appErr.Message, "error", appErr.Err, "error_code", appErr.Code)
+		}
+
+		body := gin.H{"code": appErr.Code, "key": appErr.Key}
+		if appErr.Details != nil {
+			body["details"] = appErr.Details
+		}
+		c.JSON(appErr.Status, gin.H{
+			"code":  appErr.EnvelopeCode(),
+			"mess":  appErr.Message,
+			"error": body,
+		})
+	}
+}
+
+// responseStatus là status sẽ trả về cho client, kể cả khi response lỗi chưa được ErrorMiddleware ghi
+func responseStatus(c *gin.Context) int {
+	if len(c.Errors) > 0 && !c.Writer.Written() {
+		return apperrors.From(c.Errors.Last().Err).Status
+	}
+	return c.Writer.Status()
+}
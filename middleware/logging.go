@@ -0,0 +1,91 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"new/services"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDMiddleware dùng X-Request-ID của client (hoặc tạo mới), trả lại trong response
+// và gắn logger có request_id, method, route vào context của request. Sau khi xử lý xong
+// ghi một dòng log truy cập kèm user_id, role nếu đã đăng nhập.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		c.Set("requestID", requestID)
+		c.Header(RequestIDHeader, requestID)
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		logger := slog.Default().With(
+			"request_id", requestID,
+			"method", c.Request.Method,
+			"route", route,
+		)
+		// Gắn trace_id để tìm trace tương ứng từ log
+		if spanCtx := trace.SpanContextFromContext(c.Request.Context()); spanCtx.IsValid() {
+			logger = logger.With("trace_id", spanCtx.TraceID().String())
+		}
+		c.Request = c.Request.WithContext(services.WithLogger(c.Request.Context(), logger))
+
+		start := time.Now()
+		c.Next()
+
+		// AuthMiddleware có thể đã bổ sung user_id, role vào logger
+		logger = services.Logger(c.Request.Context())
+		attrs := []any{
+			"status", c.Writer.Status(),
+			"latency_ms", time.Since(start).Milliseconds(),
+			"path", c.Request.URL.Path,
+			"ip", c.ClientIP(),
+		}
+		if query := services.RedactQuery(c.Request.URL.RawQuery); query != "" {
+			attrs = append(attrs, "query", query)
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, "errors", c.Errors.String())
+		}
+
+		switch status := c.Writer.Status(); {
+		case status >= 500:
+			logger.Error("request", attrs...)
+		case status >= 400:
+			logger.Warn("request", attrs...)
+		default:
+			logger.Info("request", attrs...)
+		}
+	}
+}
+
+// Chỉ nhận request id ngắn gồm ký tự in được, tránh chèn dữ liệu lạ vào log
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		if r < 0x21 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,26 @@
+package middlewares
+
+import (
+	"new/services"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MetricsMiddleware ghi thời gian xử lý theo route đã khai báo (vd: /api/v1/rooms/:id)
+// để số lượng nhãn không tăng theo từng id trong URL.
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		services.HTTPRequestDuration.
+			WithLabelValues(c.Request.Method, route, strconv.Itoa(responseStatus(c))).
+			Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,121 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"new/apperrors"
+	"new/services"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitMiddleware giới hạn request theo IP và theo định danh (email/số điện thoại) trong body.
+// Các response 4xx được tính là thất bại, vượt MaxFailures sẽ bị khóa tăng dần.
+func RateLimitMiddleware(limiter services.RateLimiter, policy services.RateLimitPolicy, identifierFields ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		identifier, err := readIdentifier(c, identifierFields)
+		if err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		keys := []string{services.RateLimitKey(policy, "ip", c.ClientIP())}
+		if identifier != "" {
+			keys = append(keys, services.RateLimitKey(policy, "id", identifier))
+		}
+
+		for _, key := range keys {
+			lockedFor, err := limiter.LockedFor(ctx, key)
+			if err != nil {
+				services.Logger(ctx).Warn("Lỗi khi kiểm tra khóa rate limit", "key", key, "error", err)
+				continue
+			}
+			if lockedFor > 0 {
+				abortTooManyRequests(c, lockedFor, "Bạn đã thử quá nhiều lần, tài khoản tạm thời bị khóa")
+				return
+			}
+		}
+
+		for _, key := range keys {
+			allowed, retryAfter, err := limiter.Allow(ctx, key, policy.Limit, policy.Window)
+			if err != nil {
+				services.Logger(ctx).Warn("Lỗi khi kiểm tra rate limit", "key", key, "error", err)
+				continue
+			}
+			if !allowed {
+				abortTooManyRequests(c, retryAfter, "Bạn đã gửi quá nhiều yêu cầu, vui lòng thử lại sau")
+				return
+			}
+		}
+
+		c.Next()
+
+		status := responseStatus(c)
+		switch {
+		case status >= http.StatusBadRequest && status < http.StatusInternalServerError && status != http.StatusTooManyRequests:
+			for _, key := range keys {
+				if _, err := limiter.RegisterFailure(ctx, key, policy); err != nil {
+					services.Logger(ctx).Warn("Lỗi khi ghi nhận thất bại rate limit", "key", key, "error", err)
+				}
+			}
+		case status < http.StatusBadRequest && policy.ResetOnSuccess:
+			for _, key := range keys[1:] {
+				if err := limiter.Reset(ctx, key); err != nil {
+					services.Logger(ctx).Warn("Lỗi khi xóa rate limit", "key", key, "error", err)
+				}
+			}
+		}
+	}
+}
+
+// Dung lượng body tối đa của các endpoint có rate limit theo định danh (đăng nhập, OTP...)
+const maxIdentifierBody = 8 << 10
+
+// Đọc định danh từ JSON body mà không làm mất body cho handler phía sau.
+// Body vượt maxIdentifierBody bị từ chối để không phải đọc cả request lớn vào bộ nhớ.
+func readIdentifier(c *gin.Context, fields []string) (string, error) {
+	if len(fields) == 0 || c.Request.Body == nil {
+		return "", nil
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxIdentifierBody))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return "", apperrors.PayloadTooLarge("Dữ liệu gửi lên quá lớn")
+		}
+		return "", nil
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return "", nil
+	}
+
+	for _, field := range fields {
+		if value, ok := payload[field].(string); ok && strings.TrimSpace(value) != "" {
+			return strings.ToLower(strings.TrimSpace(value)), nil
+		}
+	}
+	return "", nil
+}
+
+func abortTooManyRequests(c *gin.Context, retryAfter time.Duration, mess string) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.Error(apperrors.TooManyRequests(mess).WithDetails(gin.H{"retryAfter": seconds}))
+	c.Abort()
+}
@@ -0,0 +1,28 @@
+package middlewares
+
+import (
+	"fmt"
+	"new/apperrors"
+	"new/services"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RecoveryMiddleware bắt panic trong handler, ghi log kèm stack trace và chuyển thành lỗi 500
+// để ErrorMiddleware trả về response chung, vì vậy phải đăng ký sau ErrorMiddleware.
+func RecoveryMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if r := recover(); r != nil {
+				services.Logger(c.Request.Context()).Error("panic",
+					"error", r,
+					"stack", string(debug.Stack()),
+				)
+				c.Error(apperrors.Internal("Lỗi hệ thống, vui lòng thử lại sau").Wrap(fmt.Errorf("panic: %v", r)))
+				c.Abort()
+			}
+		}()
+		c.Next()
+	}
+}
@@ -0,0 +1,65 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+var ErrUsage = errors.New("cách dùng: migrate up | down [n] | status | to <version>")
+
+// Run xử lý subcommand `migrate`, kết quả status được in ra out
+func Run(ctx context.Context, db *sql.DB, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return ErrUsage
+	}
+
+	m, err := New(db)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		return m.Up(ctx)
+	case "down":
+		n := 1
+		if len(args) > 1 {
+			if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+				return ErrUsage
+			}
+		}
+		return m.Down(ctx, n)
+	case "to":
+		if len(args) < 2 {
+			return ErrUsage
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || version < 0 {
+			return ErrUsage
+		}
+		return m.To(ctx, version)
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			switch {
+			case s.Missing:
+				state = "applied (không có file)"
+			case s.Modified:
+				state = "applied (checksum khác)"
+			case s.Applied:
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(out, "%04d  %-30s  %s\n", s.Version, s.Name, state)
+		}
+		return nil
+	}
+	return ErrUsage
+}
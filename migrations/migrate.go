@@ -0,0 +1,318 @@
+// Package migrations quản lý schema DB bằng các file SQL có đánh số phiên bản,
+// được nhúng vào binary và ghi lại trong bảng schema_migrations.
+package migrations
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// Khóa advisory để chỉ một instance chạy migration tại một thời điểm
+const lockKey int64 = 7_402_113_561
+
+var fileRegex = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // SHA-256 của file up
+}
+
+// MigrationStatus là trạng thái của một migration so với DB
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+	Modified  bool // file up đã bị sửa sau khi chạy
+	Missing   bool // đã chạy trên DB nhưng không còn file
+}
+
+type appliedMigration struct {
+	Version   int64
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// Load đọc và kiểm tra các migration được nhúng, sắp xếp theo phiên bản
+func Load() ([]Migration, error) {
+	entries, err := fs.ReadDir(files, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := fileRegex.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("tên file migration không hợp lệ: %s", entry.Name())
+		}
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		content, err := files.ReadFile("sql/" + entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d có hai tên khác nhau: %s, %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+			sum := sha256.Sum256(content)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s phải có cả file up và down", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator chạy migration trên một kết nối riêng giữ khóa advisory
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func New(db *sql.DB) (*Migrator, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Latest là phiên bản mới nhất có trong binary
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up chạy mọi migration chưa chạy
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.To(ctx, m.Latest())
+}
+
+// Down rollback n migration gần nhất
+func (m *Migrator) Down(ctx context.Context, n int) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.verify(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && n > 0; i-- {
+			if _, ok := applied[m.migrations[i].Version]; !ok {
+				continue
+			}
+			if err := m.apply(ctx, conn, m.migrations[i], false); err != nil {
+				return err
+			}
+			n--
+		}
+		return nil
+	})
+}
+
+// To đưa schema về đúng phiên bản target: chạy up các bản <= target, rollback các bản > target
+func (m *Migrator) To(ctx context.Context, target int64) error {
+	if target != 0 && !m.has(target) {
+		return fmt.Errorf("không có migration phiên bản %d", target)
+	}
+
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.verify(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; ok && mig.Version > target {
+				if err := m.apply(ctx, conn, mig, false); err != nil {
+					return err
+				}
+			}
+		}
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; !ok && mig.Version <= target {
+				if err := m.apply(ctx, conn, mig, true); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Status so sánh các migration trong binary với bảng schema_migrations
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var result []MigrationStatus
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			status := MigrationStatus{Version: mig.Version, Name: mig.Name}
+			if a, ok := applied[mig.Version]; ok {
+				appliedAt := a.AppliedAt
+				status.Applied = true
+				status.AppliedAt = &appliedAt
+				status.Modified = a.Checksum != mig.Checksum
+				delete(applied, mig.Version)
+			}
+			result = append(result, status)
+		}
+		for version, a := range applied {
+			appliedAt := a.AppliedAt
+			result = append(result, MigrationStatus{Version: version, Applied: true, AppliedAt: &appliedAt, Missing: true})
+		}
+		sort.Slice(result, func(i, j int) bool { return result[i].Version < result[j].Version })
+		return nil
+	})
+	return result, err
+}
+
+func (m *Migrator) has(version int64) bool {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Khóa gắn với session nên phải lock/unlock trên cùng một kết nối
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+		return fmt.Errorf("không thể lấy khóa migration: %w", err)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockKey); err != nil {
+			slog.Warn("Không thể nhả khóa migration", "error", err)
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version bigint PRIMARY KEY,
+		name text NOT NULL,
+		checksum text NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int64]appliedMigration, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int64]appliedMigration{}
+	for rows.Next() {
+		var a appliedMigration
+		if err := rows.Scan(&a.Version, &a.Checksum, &a.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied[a.Version] = a
+	}
+	return applied, rows.Err()
+}
+
+// verify từ chối chạy tiếp nếu migration đã chạy bị sửa hoặc không còn trong binary
+func (m *Migrator) verify(ctx context.Context, conn *sql.Conn) (map[int64]appliedMigration, error) {
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	known := map[int64]Migration{}
+	for _, mig := range m.migrations {
+		known[mig.Version] = mig
+	}
+	for version, a := range applied {
+		mig, ok := known[version]
+		if !ok {
+			return nil, fmt.Errorf("DB đã chạy migration %d nhưng binary không có, cần bản build mới hơn", version)
+		}
+		if a.Checksum != mig.Checksum {
+			return nil, fmt.Errorf("migration %d_%s đã bị sửa sau khi chạy (checksum khác), hãy tạo migration mới thay vì sửa file cũ", mig.Version, mig.Name)
+		}
+	}
+	return applied, nil
+}
+
+// apply chạy file up hoặc down trong một transaction cùng với việc ghi schema_migrations
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, mig Migration, up bool) (err error) {
+	direction := "down"
+	script := mig.Down
+	if up {
+		direction = "up"
+		script = mig.Up
+	}
+	slog.Info("Migration", "direction", direction, "version", mig.Version, "name", mig.Name)
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("migration %d_%s (%s) lỗi: %w", mig.Version, mig.Name, direction, err)
+	}
+	if up {
+		_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)",
+			mig.Version, mig.Name, mig.Checksum)
+	} else {
+		_, err = tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", mig.Version)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
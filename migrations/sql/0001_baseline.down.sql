@@ -0,0 +1,21 @@
+-- Xóa toàn bộ schema, chỉ dùng cho môi trường dev/test
+DROP TABLE IF EXISTS audit_logs;
+DROP TABLE IF EXISTS user_identities;
+DROP TABLE IF EXISTS user_tokens;
+DROP TABLE IF EXISTS room_statuses;
+DROP TABLE IF EXISTS accommodation_statuses;
+DROP TABLE IF EXISTS provinces;
+DROP TABLE IF EXISTS holidays;
+DROP TABLE IF EXISTS user_discounts;
+DROP TABLE IF EXISTS discounts;
+DROP TABLE IF EXISTS invoices;
+DROP TABLE IF EXISTS order_rooms;
+DROP TABLE IF EXISTS orders;
+DROP TABLE IF EXISTS rates;
+DROP TABLE IF EXISTS rooms;
+DROP TABLE IF EXISTS accommodation_benefits;
+DROP TABLE IF EXISTS accommodations;
+DROP TABLE IF EXISTS benefits;
+DROP TABLE IF EXISTS bank_fakes;
+DROP TABLE IF EXISTS banks;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,292 @@
+-- Schema ban đầu, khớp với package models tại thời điểm chuyển sang migration.
+-- Dùng IF NOT EXISTS để chạy được trên DB đã tạo bằng AutoMigrate trước đây.
+
+CREATE TABLE IF NOT EXISTS users (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    name text DEFAULT 'New User',
+    email text,
+    password text,
+    is_verified boolean DEFAULT false,
+    code text,
+    code_created_at timestamptz,
+    phone_number varchar(11) NOT NULL,
+    avatar text DEFAULT 'https://res.cloudinary.com/dqipg0or3/image/upload/v1728746922/uploads/oigc5k6e91shemck15uz.jpg',
+    role bigint DEFAULT 0,
+    status bigint DEFAULT 0,
+    gender bigint,
+    date_of_birth text DEFAULT '01/01/2000',
+    admin_id bigint,
+    CONSTRAINT fk_users_children FOREIGN KEY (admin_id) REFERENCES users (id)
+);
+
+-- Tài khoản mạng xã hội có thể chưa có email/số điện thoại:
+-- thay ràng buộc unique cũ bằng unique index bỏ qua giá trị rỗng
+ALTER TABLE users DROP CONSTRAINT IF EXISTS uni_users_email;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS uni_users_phone_number;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email) WHERE email <> '';
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_phone_number ON users (phone_number) WHERE phone_number <> '';
+
+CREATE TABLE IF NOT EXISTS banks (
+    bank_id bigserial PRIMARY KEY,
+    user_id bigint,
+    bank_name text NOT NULL,
+    account_number text NOT NULL,
+    bank_short_name text NOT NULL,
+    CONSTRAINT uni_banks_account_number UNIQUE (account_number),
+    CONSTRAINT fk_users_banks FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE TABLE IF NOT EXISTS bank_fakes (
+    id bigserial PRIMARY KEY,
+    bank_name text NOT NULL,
+    bank_short_name text NOT NULL,
+    account_numbers json,
+    icon text NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS benefits (
+    id bigserial PRIMARY KEY,
+    name text,
+    status bigint DEFAULT 0,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS accommodations (
+    id bigserial PRIMARY KEY,
+    type bigint,
+    user_id bigint,
+    name text,
+    address text,
+    create_at timestamptz,
+    update_at timestamptz,
+    avatar text,
+    img json,
+    short_description text,
+    description text,
+    status bigint,
+    num bigint,
+    furniture json,
+    people bigint,
+    price bigint,
+    num_bed bigint,
+    num_tolet bigint,
+    time_check_out text,
+    time_check_in text,
+    province text,
+    district text,
+    ward text,
+    longitude decimal,
+    latitude decimal,
+    CONSTRAINT fk_accommodations_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE TABLE IF NOT EXISTS accommodation_benefits (
+    accommodation_id bigint NOT NULL,
+    benefit_id bigint NOT NULL,
+    PRIMARY KEY (accommodation_id, benefit_id),
+    CONSTRAINT fk_accommodation_benefits_accommodation FOREIGN KEY (accommodation_id) REFERENCES accommodations (id),
+    CONSTRAINT fk_accommodation_benefits_benefit FOREIGN KEY (benefit_id) REFERENCES benefits (id)
+);
+
+CREATE TABLE IF NOT EXISTS rooms (
+    room_id bigserial PRIMARY KEY,
+    accommodation_id bigint,
+    room_name text,
+    type bigint,
+    num_bed bigint,
+    num_tolet bigint,
+    acreage bigint,
+    price bigint,
+    description text,
+    short_description text,
+    created_at timestamptz,
+    updated_at timestamptz,
+    status bigint DEFAULT 0,
+    avatar text,
+    img json,
+    num bigint,
+    furniture json,
+    people bigint,
+    CONSTRAINT fk_accommodations_rooms FOREIGN KEY (accommodation_id) REFERENCES accommodations (id)
+);
+
+CREATE TABLE IF NOT EXISTS rates (
+    id bigserial PRIMARY KEY,
+    user_id bigint,
+    accommodation_id bigint,
+    comment text,
+    star bigint,
+    create_at timestamptz,
+    update_at timestamptz,
+    CONSTRAINT fk_rates_user FOREIGN KEY (user_id) REFERENCES users (id),
+    CONSTRAINT fk_accommodations_rates FOREIGN KEY (accommodation_id) REFERENCES accommodations (id)
+);
+
+CREATE TABLE IF NOT EXISTS orders (
+    id bigserial PRIMARY KEY,
+    user_id bigint,
+    accommodation_id bigint,
+    check_in_date text,
+    check_out_date text,
+    status bigint,
+    created_at timestamptz,
+    updated_at timestamptz,
+    guest_name text,
+    guest_email text,
+    guest_phone text,
+    price bigint,
+    holiday_price decimal,
+    check_in_rush_price decimal,
+    sold_out_price decimal,
+    discount_price decimal,
+    total_price decimal,
+    CONSTRAINT fk_orders_user FOREIGN KEY (user_id) REFERENCES users (id),
+    CONSTRAINT fk_orders_accommodation FOREIGN KEY (accommodation_id) REFERENCES accommodations (id)
+);
+
+CREATE TABLE IF NOT EXISTS order_rooms (
+    order_id bigint NOT NULL,
+    room_room_id bigint NOT NULL,
+    PRIMARY KEY (order_id, room_room_id),
+    CONSTRAINT fk_order_rooms_order FOREIGN KEY (order_id) REFERENCES orders (id),
+    CONSTRAINT fk_order_rooms_room FOREIGN KEY (room_room_id) REFERENCES rooms (room_id)
+);
+
+CREATE TABLE IF NOT EXISTS invoices (
+    id bigserial PRIMARY KEY,
+    invoice_code varchar(20),
+    order_id bigint,
+    total_amount decimal,
+    paid_amount decimal,
+    remaining_amount decimal,
+    status bigint,
+    payment_date timestamptz,
+    payment_type bigint,
+    created_at timestamptz,
+    updated_at timestamptz,
+    CONSTRAINT uni_invoices_invoice_code UNIQUE (invoice_code),
+    CONSTRAINT fk_invoices_order FOREIGN KEY (order_id) REFERENCES orders (id)
+);
+
+CREATE TABLE IF NOT EXISTS discounts (
+    id bigserial PRIMARY KEY,
+    name text,
+    description text,
+    quantity bigint,
+    from_date text,
+    to_date text,
+    discount bigint,
+    status bigint DEFAULT 1,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS user_discounts (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    discount_id bigint NOT NULL,
+    usage_count bigint DEFAULT 0,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS holidays (
+    id bigserial PRIMARY KEY,
+    name text,
+    from_date text,
+    to_date text,
+    price bigint,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS provinces (
+    province_id bigserial PRIMARY KEY,
+    province_name text,
+    province_img text,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS accommodation_statuses (
+    id bigserial PRIMARY KEY,
+    accommodation_id bigint,
+    from_date timestamptz,
+    to_date timestamptz,
+    status bigint,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_accommodation_statuses_accommodation_id ON accommodation_statuses (accommodation_id);
+CREATE INDEX IF NOT EXISTS idx_accommodation_statuses_from_date ON accommodation_statuses (from_date);
+CREATE INDEX IF NOT EXISTS idx_accommodation_statuses_to_date ON accommodation_statuses (to_date);
+
+CREATE TABLE IF NOT EXISTS room_statuses (
+    id bigserial PRIMARY KEY,
+    room_id bigint,
+    from_date timestamptz,
+    to_date timestamptz,
+    status bigint,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_room_statuses_room_id ON room_statuses (room_id);
+CREATE INDEX IF NOT EXISTS idx_room_statuses_from_date ON room_statuses (from_date);
+CREATE INDEX IF NOT EXISTS idx_room_statuses_to_date ON room_statuses (to_date);
+
+CREATE TABLE IF NOT EXISTS user_tokens (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    purpose varchar(32) NOT NULL,
+    token_hash varchar(64) NOT NULL,
+    expires_at timestamptz,
+    used_at timestamptz,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_user_tokens_user_id ON user_tokens (user_id);
+CREATE INDEX IF NOT EXISTS idx_user_tokens_purpose ON user_tokens (purpose);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_tokens_token_hash ON user_tokens (token_hash);
+CREATE INDEX IF NOT EXISTS idx_user_tokens_expires_at ON user_tokens (expires_at);
+
+CREATE TABLE IF NOT EXISTS user_identities (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    provider varchar(32) NOT NULL,
+    provider_user_id varchar(255) NOT NULL,
+    email text,
+    created_at timestamptz,
+    updated_at timestamptz,
+    CONSTRAINT fk_users_identities FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_identities_provider_subject ON user_identities (provider, provider_user_id);
+
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id bigserial PRIMARY KEY,
+    actor_id bigint,
+    actor_role bigint,
+    action varchar(64) NOT NULL,
+    entity_type varchar(64) NOT NULL,
+    entity_id varchar(64),
+    changes jsonb,
+    ip varchar(64),
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_actor_id ON audit_logs (actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_action ON audit_logs (action);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_entity ON audit_logs (entity_type, entity_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs (created_at);
+
+-- Index cho bộ lọc và phân trang keyset của các API danh sách
+CREATE INDEX IF NOT EXISTS idx_accommodations_keyset ON accommodations (update_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_accommodations_user_id ON accommodations (user_id);
+CREATE INDEX IF NOT EXISTS idx_accommodations_status ON accommodations (status);
+CREATE INDEX IF NOT EXISTS idx_accommodation_benefits_benefit_id ON accommodation_benefits (benefit_id);
+CREATE INDEX IF NOT EXISTS idx_orders_keyset ON orders (updated_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_orders_user_id ON orders (user_id);
+CREATE INDEX IF NOT EXISTS idx_orders_accommodation_id ON orders (accommodation_id);
+CREATE INDEX IF NOT EXISTS idx_discounts_keyset ON discounts (updated_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_holidays_keyset ON holidays (updated_at DESC, id DESC);
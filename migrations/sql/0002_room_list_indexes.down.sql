@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_rooms_accommodation_id;
+DROP INDEX IF EXISTS idx_rooms_keyset;
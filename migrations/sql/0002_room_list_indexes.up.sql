@@ -0,0 +1,3 @@
+-- Danh sách phòng phân trang keyset trong SQL
+CREATE INDEX IF NOT EXISTS idx_rooms_keyset ON rooms (updated_at DESC, room_id DESC);
+CREATE INDEX IF NOT EXISTS idx_rooms_accommodation_id ON rooms (accommodation_id);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS rate_overrides;
+DROP TABLE IF EXISTS rate_seasons;
+DROP TABLE IF EXISTS rate_day_prices;
+DROP TABLE IF EXISTS rate_plans;
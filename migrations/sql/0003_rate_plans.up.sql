@@ -0,0 +1,43 @@
+-- Bảng giá theo đêm của phòng hoặc chỗ ở thuê nguyên căn
+CREATE TABLE IF NOT EXISTS rate_plans (
+    id bigserial PRIMARY KEY,
+    room_id bigint,
+    accommodation_id bigint,
+    created_at timestamptz,
+    updated_at timestamptz,
+    CONSTRAINT fk_rooms_rate_plan FOREIGN KEY (room_id) REFERENCES rooms (room_id) ON DELETE CASCADE,
+    CONSTRAINT fk_accommodations_rate_plan FOREIGN KEY (accommodation_id) REFERENCES accommodations (id) ON DELETE CASCADE,
+    CONSTRAINT chk_rate_plans_owner CHECK ((room_id IS NULL) <> (accommodation_id IS NULL))
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_rate_plans_room_id ON rate_plans (room_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_rate_plans_accommodation_id ON rate_plans (accommodation_id);
+
+CREATE TABLE IF NOT EXISTS rate_day_prices (
+    id bigserial PRIMARY KEY,
+    rate_plan_id bigint NOT NULL,
+    weekday bigint NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+    price bigint NOT NULL,
+    CONSTRAINT fk_rate_plans_day_prices FOREIGN KEY (rate_plan_id) REFERENCES rate_plans (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_rate_day_prices_plan_weekday ON rate_day_prices (rate_plan_id, weekday);
+
+CREATE TABLE IF NOT EXISTS rate_seasons (
+    id bigserial PRIMARY KEY,
+    rate_plan_id bigint NOT NULL,
+    name text,
+    from_date date NOT NULL,
+    to_date date NOT NULL,
+    price bigint NOT NULL,
+    CONSTRAINT fk_rate_plans_seasons FOREIGN KEY (rate_plan_id) REFERENCES rate_plans (id) ON DELETE CASCADE,
+    CONSTRAINT chk_rate_seasons_dates CHECK (from_date <= to_date)
+);
+CREATE INDEX IF NOT EXISTS idx_rate_seasons_rate_plan_id ON rate_seasons (rate_plan_id);
+
+CREATE TABLE IF NOT EXISTS rate_overrides (
+    id bigserial PRIMARY KEY,
+    rate_plan_id bigint NOT NULL,
+    date date NOT NULL,
+    price bigint NOT NULL,
+    CONSTRAINT fk_rate_plans_overrides FOREIGN KEY (rate_plan_id) REFERENCES rate_plans (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_rate_overrides_plan_date ON rate_overrides (rate_plan_id, date);
@@ -0,0 +1,12 @@
+DELETE FROM rate_plans WHERE room_type_id IS NOT NULL;
+ALTER TABLE rate_plans DROP CONSTRAINT IF EXISTS chk_rate_plans_owner;
+ALTER TABLE rate_plans ADD CONSTRAINT chk_rate_plans_owner CHECK ((room_id IS NULL) <> (accommodation_id IS NULL));
+DROP INDEX IF EXISTS idx_rate_plans_room_type_id;
+ALTER TABLE rate_plans DROP COLUMN IF EXISTS room_type_id;
+
+DROP TABLE IF EXISTS order_room_types;
+
+DROP INDEX IF EXISTS idx_rooms_room_type_id;
+ALTER TABLE rooms DROP COLUMN IF EXISTS room_type_id;
+
+DROP TABLE IF EXISTS room_types;
@@ -0,0 +1,51 @@
+-- Hạng phòng của khách sạn, các phòng thật thuộc hạng là tồn kho của hạng
+CREATE TABLE IF NOT EXISTS room_types (
+    id bigserial PRIMARY KEY,
+    accommodation_id bigint NOT NULL,
+    name text NOT NULL,
+    type bigint,
+    num_bed bigint,
+    num_tolet bigint,
+    acreage bigint,
+    price bigint,
+    description text,
+    short_description text,
+    avatar text,
+    img json,
+    furniture json,
+    people bigint,
+    status bigint DEFAULT 0,
+    assign_at_check_in boolean DEFAULT false,
+    created_at timestamptz,
+    updated_at timestamptz,
+    CONSTRAINT fk_accommodations_room_types FOREIGN KEY (accommodation_id) REFERENCES accommodations (id)
+);
+CREATE INDEX IF NOT EXISTS idx_room_types_accommodation_id ON room_types (accommodation_id);
+
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS room_type_id bigint;
+ALTER TABLE rooms ADD CONSTRAINT fk_room_types_rooms FOREIGN KEY (room_type_id) REFERENCES room_types (id);
+CREATE INDEX IF NOT EXISTS idx_rooms_room_type_id ON rooms (room_type_id);
+
+-- Số phòng đặt theo hạng trong mỗi đơn, phòng chưa gán (quantity - assigned) vẫn giữ tồn kho của hạng
+CREATE TABLE IF NOT EXISTS order_room_types (
+    id bigserial PRIMARY KEY,
+    order_id bigint NOT NULL,
+    room_type_id bigint NOT NULL,
+    quantity bigint NOT NULL CHECK (quantity > 0),
+    assigned bigint NOT NULL DEFAULT 0,
+    from_date date NOT NULL,
+    to_date date NOT NULL,
+    created_at timestamptz,
+    CONSTRAINT fk_orders_room_types FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE,
+    CONSTRAINT fk_order_room_types_room_type FOREIGN KEY (room_type_id) REFERENCES room_types (id),
+    CONSTRAINT chk_order_room_types_assigned CHECK (assigned BETWEEN 0 AND quantity)
+);
+CREATE INDEX IF NOT EXISTS idx_order_room_types_order_id ON order_room_types (order_id);
+CREATE INDEX IF NOT EXISTS idx_order_room_types_stay ON order_room_types (room_type_id, from_date, to_date);
+
+-- Bảng giá dùng chung cho cả hạng phòng
+ALTER TABLE rate_plans ADD COLUMN IF NOT EXISTS room_type_id bigint;
+ALTER TABLE rate_plans ADD CONSTRAINT fk_room_types_rate_plan FOREIGN KEY (room_type_id) REFERENCES room_types (id) ON DELETE CASCADE;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_rate_plans_room_type_id ON rate_plans (room_type_id);
+ALTER TABLE rate_plans DROP CONSTRAINT IF EXISTS chk_rate_plans_owner;
+ALTER TABLE rate_plans ADD CONSTRAINT chk_rate_plans_owner CHECK (num_nonnulls(room_id, accommodation_id, room_type_id) = 1);
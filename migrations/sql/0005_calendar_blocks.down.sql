@@ -0,0 +1,4 @@
+ALTER TABLE accommodation_statuses DROP COLUMN IF EXISTS created_by;
+ALTER TABLE accommodation_statuses DROP COLUMN IF EXISTS reason;
+ALTER TABLE room_statuses DROP COLUMN IF EXISTS created_by;
+ALTER TABLE room_statuses DROP COLUMN IF EXISTS reason;
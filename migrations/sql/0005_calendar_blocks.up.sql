@@ -0,0 +1,5 @@
+-- Chặn lịch theo khoảng ngày (bảo trì, chủ sử dụng, tạm đóng) kèm lý do
+ALTER TABLE room_statuses ADD COLUMN IF NOT EXISTS reason text;
+ALTER TABLE room_statuses ADD COLUMN IF NOT EXISTS created_by bigint;
+ALTER TABLE accommodation_statuses ADD COLUMN IF NOT EXISTS reason text;
+ALTER TABLE accommodation_statuses ADD COLUMN IF NOT EXISTS created_by bigint;
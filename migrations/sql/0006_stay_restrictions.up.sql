@@ -0,0 +1,22 @@
+-- Quy định lưu trú (số đêm tối thiểu/tối đa, ngày không nhận/trả phòng, đặt trước) của phòng hoặc chỗ ở
+CREATE TABLE IF NOT EXISTS stay_restrictions (
+    id bigserial PRIMARY KEY,
+    accommodation_id bigint,
+    room_id bigint,
+    from_date date,
+    to_date date,
+    arrival_days bigint NOT NULL DEFAULT 0,
+    min_nights bigint NOT NULL DEFAULT 0,
+    max_nights bigint NOT NULL DEFAULT 0,
+    no_check_in_days bigint NOT NULL DEFAULT 0,
+    no_check_out_days bigint NOT NULL DEFAULT 0,
+    min_advance_days bigint NOT NULL DEFAULT 0,
+    created_at timestamptz,
+    updated_at timestamptz,
+    CONSTRAINT fk_accommodations_stay_restrictions FOREIGN KEY (accommodation_id) REFERENCES accommodations (id) ON DELETE CASCADE,
+    CONSTRAINT fk_rooms_stay_restrictions FOREIGN KEY (room_id) REFERENCES rooms (room_id) ON DELETE CASCADE,
+    CONSTRAINT chk_stay_restrictions_owner CHECK ((room_id IS NULL) <> (accommodation_id IS NULL)),
+    CONSTRAINT chk_stay_restrictions_dates CHECK (from_date IS NULL OR to_date IS NULL OR from_date <= to_date)
+);
+CREATE INDEX IF NOT EXISTS idx_stay_restrictions_accommodation_id ON stay_restrictions (accommodation_id);
+CREATE INDEX IF NOT EXISTS idx_stay_restrictions_room_id ON stay_restrictions (room_id);
@@ -0,0 +1,6 @@
+ALTER TABLE accommodations DROP COLUMN IF EXISTS extra_child_price;
+ALTER TABLE accommodations DROP COLUMN IF EXISTS extra_adult_price;
+ALTER TABLE accommodations DROP COLUMN IF EXISTS max_extra_guests;
+ALTER TABLE orders DROP COLUMN IF EXISTS extra_guest_price;
+ALTER TABLE orders DROP COLUMN IF EXISTS children;
+ALTER TABLE orders DROP COLUMN IF EXISTS adults;
@@ -0,0 +1,7 @@
+-- Số khách của đơn, phụ thu khách thêm của đơn và chính sách khách thêm của chỗ ở
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS adults bigint NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS children bigint NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS extra_guest_price decimal NOT NULL DEFAULT 0;
+ALTER TABLE accommodations ADD COLUMN IF NOT EXISTS max_extra_guests bigint NOT NULL DEFAULT 0;
+ALTER TABLE accommodations ADD COLUMN IF NOT EXISTS extra_adult_price bigint NOT NULL DEFAULT 0;
+ALTER TABLE accommodations ADD COLUMN IF NOT EXISTS extra_child_price bigint NOT NULL DEFAULT 0;
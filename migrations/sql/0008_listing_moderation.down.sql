@@ -0,0 +1,5 @@
+-- Không khôi phục được giá trị status cũ
+ALTER TABLE accommodations DROP COLUMN IF EXISTS reviewed_at;
+ALTER TABLE accommodations DROP COLUMN IF EXISTS reviewed_by;
+ALTER TABLE accommodations DROP COLUMN IF EXISTS submitted_at;
+ALTER TABLE accommodations DROP COLUMN IF EXISTS moderation_reason;
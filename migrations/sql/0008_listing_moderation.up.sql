@@ -0,0 +1,10 @@
+-- Quy trình duyệt tin chỗ ở: status là trạng thái duyệt (0 nháp ... 4 đang đăng, 5 tạm ngưng)
+ALTER TABLE accommodations ADD COLUMN IF NOT EXISTS moderation_reason text;
+ALTER TABLE accommodations ADD COLUMN IF NOT EXISTS submitted_at timestamptz;
+ALTER TABLE accommodations ADD COLUMN IF NOT EXISTS reviewed_by bigint;
+ALTER TABLE accommodations ADD COLUMN IF NOT EXISTS reviewed_at timestamptz;
+-- Chuyển status cũ sang trạng thái duyệt: chỗ ở đang hoạt động (0) tiếp tục được đăng,
+-- các giá trị khác (đang ẩn/ngừng hoạt động) bị tạm ngưng để quản trị xem lại trước khi đăng lại
+UPDATE accommodations
+SET status = CASE WHEN status = 0 THEN 4 ELSE 5 END,
+    moderation_reason = CASE WHEN status = 0 THEN NULL ELSE 'Chỗ ở chưa hoạt động trước khi có quy trình duyệt, cần quản trị xem lại' END;
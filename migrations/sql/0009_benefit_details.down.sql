@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS room_benefits;
+ALTER TABLE benefits DROP CONSTRAINT IF EXISTS fk_benefits_merged_into;
+ALTER TABLE benefits DROP COLUMN IF EXISTS merged_into_id;
+ALTER TABLE benefits DROP COLUMN IF EXISTS translations;
+ALTER TABLE benefits DROP COLUMN IF EXISTS scope;
+ALTER TABLE benefits DROP COLUMN IF EXISTS icon;
+ALTER TABLE benefits DROP COLUMN IF EXISTS category;
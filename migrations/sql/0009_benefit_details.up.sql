@@ -0,0 +1,17 @@
+-- Tiện ích có nhóm, icon, bản dịch, phạm vi (chỗ ở/phòng) và có thể được gộp vào tiện ích khác
+ALTER TABLE benefits ADD COLUMN IF NOT EXISTS category text DEFAULT 'general';
+ALTER TABLE benefits ADD COLUMN IF NOT EXISTS icon text;
+ALTER TABLE benefits ADD COLUMN IF NOT EXISTS scope text DEFAULT 'property';
+ALTER TABLE benefits ADD COLUMN IF NOT EXISTS translations json;
+ALTER TABLE benefits ADD COLUMN IF NOT EXISTS merged_into_id bigint;
+ALTER TABLE benefits ADD CONSTRAINT fk_benefits_merged_into FOREIGN KEY (merged_into_id) REFERENCES benefits (id);
+CREATE INDEX IF NOT EXISTS idx_benefits_merged_into_id ON benefits (merged_into_id);
+
+CREATE TABLE IF NOT EXISTS room_benefits (
+    room_room_id bigint NOT NULL,
+    benefit_id bigint NOT NULL,
+    PRIMARY KEY (room_room_id, benefit_id),
+    CONSTRAINT fk_room_benefits_room FOREIGN KEY (room_room_id) REFERENCES rooms (room_id) ON DELETE CASCADE,
+    CONSTRAINT fk_room_benefits_benefit FOREIGN KEY (benefit_id) REFERENCES benefits (id)
+);
+CREATE INDEX IF NOT EXISTS idx_room_benefits_benefit_id ON room_benefits (benefit_id);
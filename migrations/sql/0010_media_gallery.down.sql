@@ -0,0 +1,31 @@
+-- Khôi phục cột JSON dạng [url, ...] và [{"name", "quantity"}, ...], mất caption/kích thước/ghi chú.
+-- Phòng thuộc hạng phòng nhận lại bản sao của hạng như trước.
+
+ALTER TABLE accommodations ADD COLUMN IF NOT EXISTS img json;
+ALTER TABLE accommodations ADD COLUMN IF NOT EXISTS furniture json;
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS img json;
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS furniture json;
+ALTER TABLE room_types ADD COLUMN IF NOT EXISTS img json;
+ALTER TABLE room_types ADD COLUMN IF NOT EXISTS furniture json;
+
+UPDATE accommodations o SET
+    img = (SELECT json_agg(m.url ORDER BY m.sort_order, m.id) FROM media m WHERE m.accommodation_id = o.id),
+    furniture = (SELECT json_agg(json_build_object('name', f.name, 'quantity', f.quantity) ORDER BY f.sort_order, f.id)
+                 FROM furniture_items f WHERE f.accommodation_id = o.id);
+
+UPDATE room_types o SET
+    img = (SELECT json_agg(m.url ORDER BY m.sort_order, m.id) FROM media m WHERE m.room_type_id = o.id),
+    furniture = (SELECT json_agg(json_build_object('name', f.name, 'quantity', f.quantity) ORDER BY f.sort_order, f.id)
+                 FROM furniture_items f WHERE f.room_type_id = o.id);
+
+UPDATE rooms o SET
+    img = (SELECT json_agg(m.url ORDER BY m.sort_order, m.id) FROM media m WHERE m.room_id = o.room_id),
+    furniture = (SELECT json_agg(json_build_object('name', f.name, 'quantity', f.quantity) ORDER BY f.sort_order, f.id)
+                 FROM furniture_items f WHERE f.room_id = o.room_id);
+
+UPDATE rooms r SET img = t.img, furniture = t.furniture
+FROM room_types t
+WHERE r.room_type_id = t.id AND r.img IS NULL AND r.furniture IS NULL;
+
+DROP TABLE IF EXISTS furniture_items;
+DROP TABLE IF EXISTS media;
@@ -0,0 +1,144 @@
+-- Thư viện ảnh và nội thất của chỗ ở, phòng và hạng phòng thay cho các cột JSON img/furniture
+CREATE TABLE IF NOT EXISTS media (
+    id bigserial PRIMARY KEY,
+    url text NOT NULL,
+    public_id text NOT NULL DEFAULT '',
+    caption text NOT NULL DEFAULT '',
+    sort_order bigint NOT NULL DEFAULT 0,
+    is_cover boolean NOT NULL DEFAULT false,
+    width bigint NOT NULL DEFAULT 0,
+    height bigint NOT NULL DEFAULT 0,
+    accommodation_id bigint,
+    room_id bigint,
+    room_type_id bigint,
+    created_at timestamptz,
+    updated_at timestamptz,
+    CONSTRAINT fk_accommodations_img FOREIGN KEY (accommodation_id) REFERENCES accommodations (id) ON DELETE CASCADE,
+    CONSTRAINT fk_rooms_img FOREIGN KEY (room_id) REFERENCES rooms (room_id) ON DELETE CASCADE,
+    CONSTRAINT fk_room_types_img FOREIGN KEY (room_type_id) REFERENCES room_types (id) ON DELETE CASCADE,
+    CONSTRAINT chk_media_owner CHECK (num_nonnulls(accommodation_id, room_id, room_type_id) = 1)
+);
+CREATE INDEX IF NOT EXISTS idx_media_accommodation_id ON media (accommodation_id);
+CREATE INDEX IF NOT EXISTS idx_media_room_id ON media (room_id);
+CREATE INDEX IF NOT EXISTS idx_media_room_type_id ON media (room_type_id);
+
+CREATE TABLE IF NOT EXISTS furniture_items (
+    id bigserial PRIMARY KEY,
+    name text NOT NULL,
+    category text NOT NULL DEFAULT '',
+    quantity bigint NOT NULL DEFAULT 1,
+    note text NOT NULL DEFAULT '',
+    sort_order bigint NOT NULL DEFAULT 0,
+    accommodation_id bigint,
+    room_id bigint,
+    room_type_id bigint,
+    created_at timestamptz,
+    updated_at timestamptz,
+    CONSTRAINT fk_accommodations_furniture FOREIGN KEY (accommodation_id) REFERENCES accommodations (id) ON DELETE CASCADE,
+    CONSTRAINT fk_rooms_furniture FOREIGN KEY (room_id) REFERENCES rooms (room_id) ON DELETE CASCADE,
+    CONSTRAINT fk_room_types_furniture FOREIGN KEY (room_type_id) REFERENCES room_types (id) ON DELETE CASCADE,
+    CONSTRAINT chk_furniture_items_owner CHECK (num_nonnulls(accommodation_id, room_id, room_type_id) = 1)
+);
+CREATE INDEX IF NOT EXISTS idx_furniture_items_accommodation_id ON furniture_items (accommodation_id);
+CREATE INDEX IF NOT EXISTS idx_furniture_items_room_id ON furniture_items (room_id);
+CREATE INDEX IF NOT EXISTS idx_furniture_items_room_type_id ON furniture_items (room_type_id);
+
+-- Chuyển mảng JSON cũ: phần tử là chuỗi (URL/tên) hoặc object có url/name, bỏ qua phần tử không đọc được.
+-- Phòng thuộc hạng phòng chỉ giữ bản sao ảnh/nội thất của hạng nên không chuyển.
+
+WITH items AS (
+    SELECT o.id AS owner_id, e.ordinality,
+           CASE json_typeof(e.value) WHEN 'string' THEN e.value #>> '{}' WHEN 'object' THEN e.value ->> 'url' END AS url,
+           CASE json_typeof(e.value) WHEN 'object' THEN e.value ->> 'caption' END AS caption
+    FROM accommodations o,
+         json_array_elements(CASE WHEN json_typeof(o.img) = 'array' THEN o.img ELSE '[]'::json END) WITH ORDINALITY e
+    WHERE o.img IS NOT NULL
+), ordered AS (
+    SELECT owner_id, url, caption, row_number() OVER (PARTITION BY owner_id ORDER BY ordinality) - 1 AS sort_order
+    FROM items
+    WHERE COALESCE(url, '') <> ''
+)
+INSERT INTO media (url, caption, sort_order, is_cover, accommodation_id, created_at, updated_at)
+SELECT url, COALESCE(caption, ''), sort_order, sort_order = 0, owner_id, now(), now() FROM ordered;
+
+WITH items AS (
+    SELECT o.id AS owner_id, e.ordinality,
+           CASE json_typeof(e.value) WHEN 'string' THEN e.value #>> '{}' WHEN 'object' THEN e.value ->> 'url' END AS url,
+           CASE json_typeof(e.value) WHEN 'object' THEN e.value ->> 'caption' END AS caption
+    FROM room_types o,
+         json_array_elements(CASE WHEN json_typeof(o.img) = 'array' THEN o.img ELSE '[]'::json END) WITH ORDINALITY e
+    WHERE o.img IS NOT NULL
+), ordered AS (
+    SELECT owner_id, url, caption, row_number() OVER (PARTITION BY owner_id ORDER BY ordinality) - 1 AS sort_order
+    FROM items
+    WHERE COALESCE(url, '') <> ''
+)
+INSERT INTO media (url, caption, sort_order, is_cover, room_type_id, created_at, updated_at)
+SELECT url, COALESCE(caption, ''), sort_order, sort_order = 0, owner_id, now(), now() FROM ordered;
+
+WITH items AS (
+    SELECT o.room_id AS owner_id, e.ordinality,
+           CASE json_typeof(e.value) WHEN 'string' THEN e.value #>> '{}' WHEN 'object' THEN e.value ->> 'url' END AS url,
+           CASE json_typeof(e.value) WHEN 'object' THEN e.value ->> 'caption' END AS caption
+    FROM rooms o,
+         json_array_elements(CASE WHEN json_typeof(o.img) = 'array' THEN o.img ELSE '[]'::json END) WITH ORDINALITY e
+    WHERE o.img IS NOT NULL AND o.room_type_id IS NULL
+), ordered AS (
+    SELECT owner_id, url, caption, row_number() OVER (PARTITION BY owner_id ORDER BY ordinality) - 1 AS sort_order
+    FROM items
+    WHERE COALESCE(url, '') <> ''
+)
+INSERT INTO media (url, caption, sort_order, is_cover, room_id, created_at, updated_at)
+SELECT url, COALESCE(caption, ''), sort_order, sort_order = 0, owner_id, now(), now() FROM ordered;
+
+WITH items AS (
+    SELECT o.id AS owner_id, e.ordinality,
+           CASE json_typeof(e.value) WHEN 'string' THEN e.value #>> '{}' WHEN 'object' THEN e.value ->> 'name' END AS name,
+           CASE WHEN json_typeof(e.value) = 'object' AND (e.value ->> 'quantity') ~ '^[0-9]+$' THEN (e.value ->> 'quantity')::bigint END AS quantity
+    FROM accommodations o,
+         json_array_elements(CASE WHEN json_typeof(o.furniture) = 'array' THEN o.furniture ELSE '[]'::json END) WITH ORDINALITY e
+    WHERE o.furniture IS NOT NULL
+), ordered AS (
+    SELECT owner_id, btrim(name) AS name, quantity, row_number() OVER (PARTITION BY owner_id ORDER BY ordinality) - 1 AS sort_order
+    FROM items
+    WHERE btrim(COALESCE(name, '')) <> ''
+)
+INSERT INTO furniture_items (name, quantity, sort_order, accommodation_id, created_at, updated_at)
+SELECT name, GREATEST(COALESCE(quantity, 1), 1), sort_order, owner_id, now(), now() FROM ordered;
+
+WITH items AS (
+    SELECT o.id AS owner_id, e.ordinality,
+           CASE json_typeof(e.value) WHEN 'string' THEN e.value #>> '{}' WHEN 'object' THEN e.value ->> 'name' END AS name,
+           CASE WHEN json_typeof(e.value) = 'object' AND (e.value ->> 'quantity') ~ '^[0-9]+$' THEN (e.value ->> 'quantity')::bigint END AS quantity
+    FROM room_types o,
+         json_array_elements(CASE WHEN json_typeof(o.furniture) = 'array' THEN o.furniture ELSE '[]'::json END) WITH ORDINALITY e
+    WHERE o.furniture IS NOT NULL
+), ordered AS (
+    SELECT owner_id, btrim(name) AS name, quantity, row_number() OVER (PARTITION BY owner_id ORDER BY ordinality) - 1 AS sort_order
+    FROM items
+    WHERE btrim(COALESCE(name, '')) <> ''
+)
+INSERT INTO furniture_items (name, quantity, sort_order, room_type_id, created_at, updated_at)
+SELECT name, GREATEST(COALESCE(quantity, 1), 1), sort_order, owner_id, now(), now() FROM ordered;
+
+WITH items AS (
+    SELECT o.room_id AS owner_id, e.ordinality,
+           CASE json_typeof(e.value) WHEN 'string' THEN e.value #>> '{}' WHEN 'object' THEN e.value ->> 'name' END AS name,
+           CASE WHEN json_typeof(e.value) = 'object' AND (e.value ->> 'quantity') ~ '^[0-9]+$' THEN (e.value ->> 'quantity')::bigint END AS quantity
+    FROM rooms o,
+         json_array_elements(CASE WHEN json_typeof(o.furniture) = 'array' THEN o.furniture ELSE '[]'::json END) WITH ORDINALITY e
+    WHERE o.furniture IS NOT NULL AND o.room_type_id IS NULL
+), ordered AS (
+    SELECT owner_id, btrim(name) AS name, quantity, row_number() OVER (PARTITION BY owner_id ORDER BY ordinality) - 1 AS sort_order
+    FROM items
+    WHERE btrim(COALESCE(name, '')) <> ''
+)
+INSERT INTO furniture_items (name, quantity, sort_order, room_id, created_at, updated_at)
+SELECT name, GREATEST(COALESCE(quantity, 1), 1), sort_order, owner_id, now(), now() FROM ordered;
+
+ALTER TABLE accommodations DROP COLUMN IF EXISTS img;
+ALTER TABLE accommodations DROP COLUMN IF EXISTS furniture;
+ALTER TABLE rooms DROP COLUMN IF EXISTS img;
+ALTER TABLE rooms DROP COLUMN IF EXISTS furniture;
+ALTER TABLE room_types DROP COLUMN IF EXISTS img;
+ALTER TABLE room_types DROP COLUMN IF EXISTS furniture;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_media_public_id;
+ALTER TABLE media DROP COLUMN IF EXISTS thumbnail_url;
@@ -0,0 +1,3 @@
+-- Ảnh thu nhỏ do server tạo khi upload; index public_id để kiểm tra ảnh còn được dùng trước khi xóa khỏi nơi lưu trữ
+ALTER TABLE media ADD COLUMN IF NOT EXISTS thumbnail_url text;
+CREATE INDEX IF NOT EXISTS idx_media_public_id ON media (public_id);
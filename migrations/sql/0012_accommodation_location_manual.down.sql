@@ -0,0 +1 @@
+ALTER TABLE accommodations DROP COLUMN IF EXISTS location_manual;
@@ -0,0 +1,2 @@
+-- Tọa độ do chủ chỗ ở tự ghim, không geocode lại khi đổi địa chỉ
+ALTER TABLE accommodations ADD COLUMN IF NOT EXISTS location_manual boolean NOT NULL DEFAULT false;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_discounts_to_date_ymd;
+DROP INDEX IF EXISTS idx_discounts_from_date_ymd;
+DROP INDEX IF EXISTS idx_holidays_to_date_ymd;
+DROP INDEX IF EXISTS idx_holidays_from_date_ymd;
@@ -0,0 +1,6 @@
+-- Bộ lọc khoảng ngày so sánh from_date/to_date (chuỗi dd/mm/yyyy) đổi sang yyyymmdd, index đúng biểu thức đó để không quét toàn bảng
+-- Biểu thức phải giữ nguyên như services.comparableDate
+CREATE INDEX IF NOT EXISTS idx_holidays_from_date_ymd ON holidays ((SUBSTRING(from_date, 7, 4) || SUBSTRING(from_date, 4, 2) || SUBSTRING(from_date, 1, 2)));
+CREATE INDEX IF NOT EXISTS idx_holidays_to_date_ymd ON holidays ((SUBSTRING(to_date, 7, 4) || SUBSTRING(to_date, 4, 2) || SUBSTRING(to_date, 1, 2)));
+CREATE INDEX IF NOT EXISTS idx_discounts_from_date_ymd ON discounts ((SUBSTRING(from_date, 7, 4) || SUBSTRING(from_date, 4, 2) || SUBSTRING(from_date, 1, 2)));
+CREATE INDEX IF NOT EXISTS idx_discounts_to_date_ymd ON discounts ((SUBSTRING(to_date, 7, 4) || SUBSTRING(to_date, 4, 2) || SUBSTRING(to_date, 1, 2)));
@@ -1,7 +1,6 @@
 package models
 
 import (
-	"encoding/json"
 	"fmt"
 	"time"
 )
@@ -14,16 +13,16 @@ type Accommodation struct {
 	Address          string          `json:"address"`              // Địa chỉ khách sạn
 	CreateAt         time.Time       `gorm:"autoCreateTime" json:"createdAt"`
 	UpdateAt         time.Time       `gorm:"autoUpdateTime" json:"updatedAt"`
-	Avatar           string          `json:"avatar"`               // Avatar khách sạn
-	Img              json.RawMessage `json:"img" gorm:"type:json"` // Hình ảnh khách sạn
-	ShortDescription string          `json:"shortDescription"`     // Mô tả ngắn (shortDescription)
-	Description      string          `json:"description"`          // Mô tả chi tiết
+	Avatar           string          `json:"avatar"`           // Avatar khách sạn
+	Img              []Media         `json:"img"`              // Hình ảnh khách sạn
+	ShortDescription string          `json:"shortDescription"` // Mô tả ngắn (shortDescription)
+	Description      string          `json:"description"`      // Mô tả chi tiết
 	Status           int             `json:"status"`
 	User             User            `json:"user" gorm:"foreignKey:UserID"`           // Người dùng sở hữu
 	Rooms            []Room          `json:"rooms" gorm:"foreignKey:AccommodationID"` // Danh sách các phòng
 	Rates            []Rate          `json:"rates"`                                   // Danh sách các đánh giá
 	Num              int             `json:"num"`
-	Furniture        json.RawMessage `json:"furniture" gorm:"type:json"`
+	Furniture        []FurnitureItem `json:"furniture"`
 	People           int             `json:"people"`
 	Price            int             `json:"price"`
 	Benefits         []Benefit       `json:"benefits" gorm:"many2many:accommodation_benefits;"` // Mối quan hệ nhiều-nhiều
@@ -36,6 +35,19 @@ type Accommodation struct {
 	Ward             string          `json:"ward"`
 	Longitude        float64         `json:"longitude"`
 	Latitude         float64         `json:"latitude"`
+	LocationManual   bool            `json:"locationManual"`   // Tọa độ do chủ tự ghim, không geocode lại khi đổi địa chỉ
+	ModerationReason string          `json:"moderationReason"` // Lý do từ chối/tạm ngưng gần nhất
+	SubmittedAt      *time.Time      `json:"submittedAt"`
+	ReviewedBy       *uint           `json:"reviewedBy"`
+	ReviewedAt       *time.Time      `json:"reviewedAt"`
+	ExtraGuestPolicy `gorm:"embedded"`
+}
+
+// ExtraGuestPolicy cho phép nhận thêm khách ngoài sức chứa tiêu chuẩn (People của phòng/chỗ ở) kèm phụ thu theo đêm
+type ExtraGuestPolicy struct {
+	MaxExtraGuests  int `json:"maxExtraGuests"`  // Số khách thêm tối đa mỗi phòng (hoặc cả căn), 0: không nhận thêm
+	ExtraAdultPrice int `json:"extraAdultPrice"` // Phụ thu mỗi người lớn thêm mỗi đêm
+	ExtraChildPrice int `json:"extraChildPrice"` // Phụ thu mỗi trẻ em thêm mỗi đêm
 }
 
 func (r *Accommodation) ValidateType() error {
@@ -46,8 +58,15 @@ func (r *Accommodation) ValidateType() error {
 }
 
 func (r *Accommodation) ValidateStatus() error {
-	if r.Status < 0 || r.Status > 4 {
-		return fmt.Errorf("invalid Status: %d, must be between 0 and 4", r.Status)
+	if _, ok := ListingStatusNames[r.Status]; !ok {
+		return fmt.Errorf("invalid Status: %d, must be between %d and %d", r.Status, ListingDraft, ListingSuspended)
+	}
+	return nil
+}
+
+func (p *ExtraGuestPolicy) Validate() error {
+	if p.MaxExtraGuests < 0 || p.ExtraAdultPrice < 0 || p.ExtraChildPrice < 0 {
+		return fmt.Errorf("số khách thêm và phụ thu không được âm")
 	}
 	return nil
 }
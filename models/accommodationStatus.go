@@ -7,7 +7,9 @@ type AccommodationStatus struct {
 	AccommodationID uint      `gorm:"index"` // Liên kết với phòng
 	FromDate        time.Time `gorm:"index"` // Ngày bắt đầu trạng thái
 	ToDate          time.Time `gorm:"index"` // Ngày kết thúc trạng thái
-	Status          int       // 0: có sẵn, 1: đã đặt, 2: đang bảo trì, v.v.
+	Status          int       // Như RoomStatus, trạng thái chặn lịch (2-4) chặn cả các phòng của chỗ ở
+	Reason          string    // Lý do chặn lịch
+	CreatedBy       uint      // Người chặn lịch, 0 với trạng thái do đơn hàng tạo
 	CreatedAt       time.Time
 	UpdatedAt       time.Time
 }
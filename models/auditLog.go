@@ -0,0 +1,32 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrAuditLogImmutable = errors.New("nhật ký kiểm toán chỉ được phép ghi thêm")
+
+// AuditLog ghi lại thao tác thay đổi dữ liệu, chỉ ghi thêm không sửa/xóa
+type AuditLog struct {
+	ID         uint            `json:"id" gorm:"primaryKey"`
+	ActorID    uint            `json:"actorId" gorm:"index"` // 0: chưa đăng nhập
+	ActorRole  int             `json:"actorRole"`
+	Action     string          `json:"action" gorm:"size:64;index;not null"`
+	EntityType string          `json:"entityType" gorm:"size:64;not null;index:idx_audit_logs_entity"`
+	EntityID   string          `json:"entityId" gorm:"size:64;index:idx_audit_logs_entity"`
+	Changes    json.RawMessage `json:"changes" gorm:"type:jsonb"` // {"field": {"from": ..., "to": ...}}
+	IP         string          `json:"ip" gorm:"size:64"`
+	CreatedAt  time.Time       `json:"createdAt" gorm:"autoCreateTime;index"`
+}
+
+func (a *AuditLog) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
+
+func (a *AuditLog) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
@@ -1,16 +1,36 @@
 package models
 
 import (
+	"encoding/json"
 	"fmt"
+	"slices"
 	"time"
 )
 
+// Phạm vi áp dụng của tiện ích
+const (
+	BenefitScopeProperty = "property" // Tiện ích chung của chỗ ở (hồ bơi, bãi đỗ xe...)
+	BenefitScopeRoom     = "room"     // Tiện ích trong phòng (điều hòa, minibar...)
+	BenefitScopeAll      = "all"      // Dùng cho cả chỗ ở và phòng
+)
+
+// BenefitCategories là các nhóm tiện ích, tiện ích tạo từ văn bản tự do thuộc nhóm "general"
+var BenefitCategories = []string{
+	"general", "kitchen", "bathroom", "bedroom", "entertainment", "internet",
+	"parking", "outdoor", "services", "safety", "accessibility", "family",
+}
+
 type Benefit struct {
-	Id        int       `json:"id" gorm:"primaryKey"`
-	Name      string    `json:"name"`
-	Status    int       `gorm:"default:0" json:"status"`
-	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+	Id           int             `json:"id" gorm:"primaryKey"`
+	Name         string          `json:"name"`
+	Category     string          `json:"category" gorm:"default:general"`
+	Icon         string          `json:"icon"` // Tên icon hoặc URL ảnh
+	Scope        string          `json:"scope" gorm:"default:property"`
+	Translations json.RawMessage `json:"translations" gorm:"type:json"`       // Tên theo ngôn ngữ, vd: {"en": "Free Wi-Fi"}
+	MergedIntoID *int            `json:"mergedIntoId,omitempty" gorm:"index"` // Đã được gộp vào tiện ích này
+	Status       int             `gorm:"default:0" json:"status"`
+	CreatedAt    time.Time       `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt    time.Time       `gorm:"autoUpdateTime" json:"updatedAt"`
 }
 
 func (b *Benefit) ValidateStatus() error {
@@ -19,3 +39,46 @@ func (b *Benefit) ValidateStatus() error {
 	}
 	return nil
 }
+
+func (b *Benefit) Validate() error {
+	if b.Category != "" && !slices.Contains(BenefitCategories, b.Category) {
+		return fmt.Errorf("nhóm tiện ích không hợp lệ: %q", b.Category)
+	}
+	switch b.Scope {
+	case "", BenefitScopeProperty, BenefitScopeRoom, BenefitScopeAll:
+	default:
+		return fmt.Errorf("phạm vi tiện ích không hợp lệ: %q", b.Scope)
+	}
+	if len(b.Translations) > 0 {
+		if _, err := b.TranslationMap(); err != nil {
+			return fmt.Errorf("translations phải là object {\"mã ngôn ngữ\": \"tên\"}")
+		}
+	}
+	return b.ValidateStatus()
+}
+
+func (b *Benefit) TranslationMap() (map[string]string, error) {
+	translations := map[string]string{}
+	if len(b.Translations) == 0 || string(b.Translations) == "null" {
+		return translations, nil
+	}
+	err := json.Unmarshal(b.Translations, &translations)
+	return translations, err
+}
+
+// LocalizedName trả về tên theo ngôn ngữ lang, không có bản dịch thì dùng Name
+func (b *Benefit) LocalizedName(lang string) string {
+	if lang == "" {
+		return b.Name
+	}
+	translations, _ := b.TranslationMap()
+	if name := translations[lang]; name != "" {
+		return name
+	}
+	return b.Name
+}
+
+// AllowedFor cho biết tiện ích có dùng được cho phạm vi scope (property/room) không
+func (b *Benefit) AllowedFor(scope string) bool {
+	return b.Scope == "" || b.Scope == BenefitScopeAll || b.Scope == scope
+}
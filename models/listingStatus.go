@@ -0,0 +1,59 @@
+package models
+
+// Trạng thái duyệt tin (Accommodation.Status): nháp → gửi duyệt → duyệt/từ chối → đăng → tạm ngưng
+const (
+	ListingDraft     = 0 // Nháp, chủ đang soạn
+	ListingSubmitted = 1 // Đã gửi duyệt
+	ListingApproved  = 2 // Đã duyệt, chủ có thể đăng
+	ListingRejected  = 3 // Bị từ chối, lý do trong ModerationReason
+	ListingPublished = 4 // Đang hiển thị công khai và nhận đặt phòng
+	ListingSuspended = 5 // Bị quản trị tạm ngưng
+)
+
+var ListingStatusNames = map[int]string{
+	ListingDraft:     "Nháp",
+	ListingSubmitted: "Chờ duyệt",
+	ListingApproved:  "Đã duyệt",
+	ListingRejected:  "Bị từ chối",
+	ListingPublished: "Đang đăng",
+	ListingSuspended: "Tạm ngưng",
+}
+
+// ListingTransition là một bước chuyển trạng thái hợp lệ
+type ListingTransition struct {
+	Action      string // submit, approve, reject, publish, unpublish, suspend, reinstate
+	AdminOnly   bool   // Chỉ quản trị viên cao nhất (role 1) được thực hiện
+	NeedsReason bool   // Bắt buộc kèm lý do
+	Notify      bool   // Gửi email báo kết quả cho chủ chỗ ở
+}
+
+// listingTransitions[from][to]
+var listingTransitions = map[int]map[int]ListingTransition{
+	ListingDraft: {
+		ListingSubmitted: {Action: "submit"},
+	},
+	ListingSubmitted: {
+		ListingApproved: {Action: "approve", AdminOnly: true, Notify: true},
+		ListingRejected: {Action: "reject", AdminOnly: true, NeedsReason: true, Notify: true},
+	},
+	ListingRejected: {
+		ListingSubmitted: {Action: "submit"},
+	},
+	ListingApproved: {
+		ListingPublished: {Action: "publish"},
+		ListingSuspended: {Action: "suspend", AdminOnly: true, NeedsReason: true, Notify: true},
+	},
+	ListingPublished: {
+		ListingApproved:  {Action: "unpublish"},
+		ListingSuspended: {Action: "suspend", AdminOnly: true, NeedsReason: true, Notify: true},
+	},
+	ListingSuspended: {
+		ListingPublished: {Action: "reinstate", AdminOnly: true, Notify: true},
+	},
+}
+
+// FindListingTransition trả về bước chuyển từ from sang to, false nếu không được phép
+func FindListingTransition(from, to int) (ListingTransition, bool) {
+	transition, ok := listingTransitions[from][to]
+	return transition, ok
+}
@@ -0,0 +1,37 @@
+package models
+
+import "testing"
+
+func TestFindListingTransition(t *testing.T) {
+	tests := []struct {
+		from, to int
+		ok       bool
+		want     ListingTransition
+	}{
+		{ListingDraft, ListingSubmitted, true, ListingTransition{Action: "submit"}},
+		{ListingSubmitted, ListingApproved, true, ListingTransition{Action: "approve", AdminOnly: true, Notify: true}},
+		{ListingSubmitted, ListingRejected, true, ListingTransition{Action: "reject", AdminOnly: true, NeedsReason: true, Notify: true}},
+		{ListingRejected, ListingSubmitted, true, ListingTransition{Action: "submit"}},
+		{ListingApproved, ListingPublished, true, ListingTransition{Action: "publish"}},
+		{ListingPublished, ListingApproved, true, ListingTransition{Action: "unpublish"}},
+		{ListingPublished, ListingSuspended, true, ListingTransition{Action: "suspend", AdminOnly: true, NeedsReason: true, Notify: true}},
+		{ListingSuspended, ListingPublished, true, ListingTransition{Action: "reinstate", AdminOnly: true, Notify: true}},
+
+		// Không được bỏ qua bước duyệt
+		{ListingDraft, ListingPublished, false, ListingTransition{}},
+		{ListingSubmitted, ListingPublished, false, ListingTransition{}},
+		{ListingRejected, ListingApproved, false, ListingTransition{}},
+		// Chỗ ở bị tạm ngưng không tự đăng lại hay quay về nháp
+		{ListingSuspended, ListingApproved, false, ListingTransition{}},
+		{ListingSuspended, ListingDraft, false, ListingTransition{}},
+		{ListingPublished, ListingPublished, false, ListingTransition{}},
+		{-1, ListingSubmitted, false, ListingTransition{}},
+		{ListingDraft, 9, false, ListingTransition{}},
+	}
+	for _, tt := range tests {
+		got, ok := FindListingTransition(tt.from, tt.to)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("FindListingTransition(%d, %d) = %+v, %v; want %+v, %v", tt.from, tt.to, got, ok, tt.want, tt.ok)
+		}
+	}
+}
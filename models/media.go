@@ -0,0 +1,116 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// GalleryOwner là chủ của ảnh/nội thất: đúng một trong chỗ ở, phòng hoặc hạng phòng.
+// Phòng thuộc hạng phòng dùng ảnh và nội thất của hạng.
+type GalleryOwner struct {
+	AccommodationID *uint `json:"-" gorm:"index"`
+	RoomID          *uint `json:"-" gorm:"index"`
+	RoomTypeID      *uint `json:"-" gorm:"index"`
+}
+
+func AccommodationGallery(id uint) GalleryOwner { return GalleryOwner{AccommodationID: &id} }
+func RoomGallery(id uint) GalleryOwner          { return GalleryOwner{RoomID: &id} }
+func RoomTypeGallery(id uint) GalleryOwner      { return GalleryOwner{RoomTypeID: &id} }
+
+// Media là một ảnh trong thư viện ảnh, xếp theo SortOrder
+type Media struct {
+	ID        uint   `json:"id" gorm:"primaryKey"`
+	URL       string `json:"url" gorm:"not null"`
+	PublicID  string `json:"publicId" gorm:"index"` // Key của ảnh tại nơi lưu trữ, dùng để xóa ảnh
+	Caption   string `json:"caption"`
+	SortOrder int    `json:"sortOrder"`
+	IsCover   bool   `json:"isCover"` // Ảnh bìa, mỗi thư viện có đúng một ảnh bìa
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	// Ảnh thu nhỏ tạo khi upload, ảnh cũ không có
+	ThumbnailURL string `json:"thumbnailUrl"`
+	GalleryOwner
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+// FurnitureItem là một món nội thất/đồ dùng, vd: {"name": "Tủ lạnh", "quantity": 1}
+type FurnitureItem struct {
+	ID        uint   `json:"id" gorm:"primaryKey"`
+	Name      string `json:"name" gorm:"not null"`
+	Category  string `json:"category"` // Khu vực/nhóm tùy ý, vd: bedroom, kitchen
+	Quantity  int    `json:"quantity" gorm:"not null;default:1"`
+	Note      string `json:"note"`
+	SortOrder int    `json:"sortOrder"`
+	GalleryOwner
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+// UnmarshalJSON nhận cả dạng cũ là chuỗi URL
+func (m *Media) UnmarshalJSON(data []byte) error {
+	var url string
+	if json.Unmarshal(data, &url) == nil {
+		*m = Media{URL: url}
+		return nil
+	}
+	type media Media
+	return json.Unmarshal(data, (*media)(m))
+}
+
+// UnmarshalJSON nhận cả dạng cũ là chuỗi tên
+func (f *FurnitureItem) UnmarshalJSON(data []byte) error {
+	var name string
+	if json.Unmarshal(data, &name) == nil {
+		*f = FurnitureItem{Name: name}
+		return nil
+	}
+	type furnitureItem FurnitureItem
+	return json.Unmarshal(data, (*furnitureItem)(f))
+}
+
+// PrepareGallery kiểm tra thư viện ảnh, đánh lại SortOrder theo thứ tự gửi lên
+// và chọn ảnh đầu tiên làm ảnh bìa nếu chưa có
+func PrepareGallery(media []Media) error {
+	cover := -1
+	for i := range media {
+		media[i].URL = strings.TrimSpace(media[i].URL)
+		if media[i].URL == "" {
+			return fmt.Errorf("ảnh thứ %d thiếu url", i+1)
+		}
+		if media[i].Width < 0 || media[i].Height < 0 {
+			return fmt.Errorf("kích thước ảnh thứ %d không hợp lệ", i+1)
+		}
+		if media[i].IsCover {
+			if cover >= 0 {
+				return fmt.Errorf("chỉ được chọn một ảnh bìa")
+			}
+			cover = i
+		}
+		media[i].SortOrder = i
+	}
+	if cover < 0 && len(media) > 0 {
+		media[0].IsCover = true
+	}
+	return nil
+}
+
+// PrepareFurniture kiểm tra danh sách nội thất và đánh lại SortOrder, Quantity bỏ trống tính là 1
+func PrepareFurniture(items []FurnitureItem) error {
+	for i := range items {
+		items[i].Name = strings.TrimSpace(items[i].Name)
+		if items[i].Name == "" {
+			return fmt.Errorf("nội thất thứ %d thiếu tên", i+1)
+		}
+		if items[i].Quantity < 0 {
+			return fmt.Errorf("số lượng nội thất %q không được âm", items[i].Name)
+		}
+		if items[i].Quantity == 0 {
+			items[i].Quantity = 1
+		}
+		items[i].SortOrder = i
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// RatePlan là bảng giá theo đêm của một phòng, một hạng phòng hoặc một chỗ ở thuê nguyên căn, chỉ một trong ba được gán.
+// Giá một đêm lấy theo thứ tự: giá theo ngày cụ thể > giá theo mùa > giá theo thứ > giá của phòng/chỗ ở.
+type RatePlan struct {
+	ID              uint           `json:"id" gorm:"primaryKey"`
+	RoomID          *uint          `json:"roomId" gorm:"uniqueIndex"`
+	AccommodationID *uint          `json:"accommodationId" gorm:"uniqueIndex"`
+	RoomTypeID      *uint          `json:"roomTypeId" gorm:"uniqueIndex"`
+	DayPrices       []RateDayPrice `json:"dayPrices" gorm:"foreignKey:RatePlanID"`
+	Seasons         []RateSeason   `json:"seasons" gorm:"foreignKey:RatePlanID"`
+	Overrides       []RateOverride `json:"overrides" gorm:"foreignKey:RatePlanID"`
+	CreatedAt       time.Time      `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt       time.Time      `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+// RateDayPrice là giá theo thứ trong tuần
+type RateDayPrice struct {
+	ID         uint `json:"id" gorm:"primaryKey"`
+	RatePlanID uint `json:"ratePlanId" gorm:"not null;uniqueIndex:idx_rate_day_prices_plan_weekday"`
+	Weekday    int  `json:"weekday" gorm:"not null;uniqueIndex:idx_rate_day_prices_plan_weekday"` // 0: Chủ nhật ... 6: Thứ bảy
+	Price      int  `json:"price" gorm:"not null"`
+}
+
+// RateSeason là giá theo mùa, áp dụng cho các đêm từ FromDate đến hết ToDate
+type RateSeason struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	RatePlanID uint      `json:"ratePlanId" gorm:"not null;index"`
+	Name       string    `json:"name"`
+	FromDate   time.Time `json:"fromDate" gorm:"type:date;not null"`
+	ToDate     time.Time `json:"toDate" gorm:"type:date;not null"`
+	Price      int       `json:"price" gorm:"not null"`
+}
+
+// RateOverride là giá riêng cho một đêm (ngày lễ, sự kiện...)
+type RateOverride struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	RatePlanID uint      `json:"ratePlanId" gorm:"not null;uniqueIndex:idx_rate_overrides_plan_date"`
+	Date       time.Time `json:"date" gorm:"type:date;not null;uniqueIndex:idx_rate_overrides_plan_date"`
+	Price      int       `json:"price" gorm:"not null"`
+}
+
+func (p *RatePlan) Validate() error {
+	owners := 0
+	for _, id := range []*uint{p.RoomID, p.AccommodationID, p.RoomTypeID} {
+		if id != nil {
+			owners++
+		}
+	}
+	if owners != 1 {
+		return fmt.Errorf("bảng giá phải thuộc về đúng một phòng, hạng phòng hoặc chỗ ở")
+	}
+	for _, day := range p.DayPrices {
+		if day.Weekday < 0 || day.Weekday > 6 {
+			return fmt.Errorf("invalid weekday: %d, must be between 0 and 6", day.Weekday)
+		}
+		if day.Price <= 0 {
+			return fmt.Errorf("giá theo thứ phải lớn hơn 0")
+		}
+	}
+	for i, season := range p.Seasons {
+		if season.Price <= 0 {
+			return fmt.Errorf("giá mùa %q phải lớn hơn 0", season.Name)
+		}
+		if season.ToDate.Before(season.FromDate) {
+			return fmt.Errorf("mùa %q có ngày kết thúc trước ngày bắt đầu", season.Name)
+		}
+		for _, other := range p.Seasons[:i] {
+			if !season.FromDate.After(other.ToDate) && !other.FromDate.After(season.ToDate) {
+				return fmt.Errorf("mùa %q trùng thời gian với mùa %q", season.Name, other.Name)
+			}
+		}
+	}
+	for _, override := range p.Overrides {
+		if override.Price <= 0 {
+			return fmt.Errorf("giá ngày %s phải lớn hơn 0", override.Date.Format("02/01/2006"))
+		}
+	}
+	return nil
+}
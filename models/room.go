@@ -1,7 +1,6 @@
 package models
 
 import (
-	"encoding/json"
 	"fmt"
 	"time"
 )
@@ -9,6 +8,7 @@ import (
 type Room struct {
 	RoomId           uint            `json:"id" gorm:"primaryKey"`
 	AccommodationID  uint            `json:"accommodationId"`
+	RoomTypeID       *uint           `json:"roomTypeId" gorm:"index"` // nil: phòng lẻ không thuộc hạng phòng nào
 	RoomName         string          `json:"roomName"`
 	Type             uint            `json:"type"`
 	NumBed           int             `json:"numBed"`
@@ -21,11 +21,12 @@ type Room struct {
 	UpdatedAt        time.Time       `gorm:"autoUpdateTime" json:"updatedAt"`
 	Status           int             `json:"status" gorm:"default:0"` //0: trống 1:đã dặt 2:checkin 3:checkout 4:Bảo trì
 	Avatar           string          `json:"avatar"`
-	Img              json.RawMessage `json:"img" gorm:"type:json"`
+	Img              []Media         `json:"img" gorm:"foreignKey:RoomID"`
 	Num              int             `json:"num"`
-	Furniture        json.RawMessage `json:"furniture" gorm:"type:json"`
+	Furniture        []FurnitureItem `json:"furniture" gorm:"foreignKey:RoomID"`
 	People           int             `json:"people"`
 	Parent           Accommodation   `json:"Accommodation" gorm:"foreignKey:AccommodationID"`
+	Benefits         []Benefit       `json:"benefits" gorm:"many2many:room_benefits;"` // Tiện ích trong phòng
 }
 
 func (r *Room) ValidateStatus() error {
@@ -2,12 +2,30 @@ package models
 
 import "time"
 
+// Trạng thái trên lịch của phòng/chỗ ở, mọi trạng thái khác CalendarAvailable đều chặn đặt trong khoảng [FromDate, ToDate)
+const (
+	CalendarAvailable   = 0 // Đã trả lại (đơn bị hủy)
+	CalendarBooked      = 1 // Có đơn đặt
+	CalendarMaintenance = 2 // Bảo trì
+	CalendarOwnerUse    = 3 // Chủ sử dụng
+	CalendarClosed      = 4 // Tạm đóng
+)
+
+// CalendarBlockStatuses là các trạng thái chặn lịch do chủ/lễ tân tạo, không gắn với đơn
+var CalendarBlockStatuses = []int{CalendarMaintenance, CalendarOwnerUse, CalendarClosed}
+
+func IsCalendarBlock(status int) bool {
+	return status >= CalendarMaintenance && status <= CalendarClosed
+}
+
 type RoomStatus struct {
 	ID        uint      `gorm:"primaryKey"`
 	RoomID    uint      `gorm:"index"` // Liên kết với phòng
 	FromDate  time.Time `gorm:"index"` // Ngày bắt đầu trạng thái
 	ToDate    time.Time `gorm:"index"` // Ngày kết thúc trạng thái
-	Status    int       // 0: có sẵn, 1: đã đặt, 2: đang bảo trì, v.v.
+	Status    int       // 0: có sẵn, 1: đã đặt, 2: đang bảo trì, 3: chủ sử dụng, 4: tạm đóng
+	Reason    string    // Lý do chặn lịch
+	CreatedBy uint      // Người chặn lịch, 0 với trạng thái do đơn hàng tạo
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// RoomType là hạng phòng của khách sạn (chỗ ở Type 0), vd: "Deluxe Double ×12".
+// Giá, ảnh và nội thất dùng chung cho các phòng thật thuộc hạng, số phòng thật là tồn kho của hạng.
+type RoomType struct {
+	ID               uint            `json:"id" gorm:"primaryKey"`
+	AccommodationID  uint            `json:"accommodationId" gorm:"index;not null"`
+	Name             string          `json:"name" gorm:"not null"`
+	Type             uint            `json:"type"`
+	NumBed           int             `json:"numBed"`
+	NumTolet         int             `json:"numTolet"`
+	Acreage          int             `json:"acreage"`
+	Price            int             `json:"price"`
+	Description      string          `json:"description"`
+	ShortDescription string          `json:"shortDescription"`
+	Avatar           string          `json:"avatar"`
+	Img              []Media         `json:"img"`
+	Furniture        []FurnitureItem `json:"furniture"`
+	People           int             `json:"people"`
+	Status           int             `json:"status" gorm:"default:0"` // 0: đang bán 1: ngừng bán
+	// AssignAtCheckIn: true thì lễ tân gán phòng thật khi khách nhận phòng, false thì gán ngay khi đặt
+	AssignAtCheckIn bool      `json:"assignAtCheckIn" gorm:"default:false"`
+	CreatedAt       time.Time `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt       time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+	Rooms           []Room    `json:"rooms,omitempty" gorm:"foreignKey:RoomTypeID"`
+}
+
+// OrderRoomType là số phòng khách đặt theo hạng trong một đơn, Assigned là số phòng thật đã được gán
+type OrderRoomType struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	OrderID    uint      `json:"orderId" gorm:"index;not null"`
+	RoomTypeID uint      `json:"roomTypeId" gorm:"not null;index:idx_order_room_types_stay"`
+	RoomType   RoomType  `json:"roomType" gorm:"foreignKey:RoomTypeID"`
+	Quantity   int       `json:"quantity" gorm:"not null"`
+	Assigned   int       `json:"assigned" gorm:"not null;default:0"`
+	FromDate   time.Time `json:"fromDate" gorm:"type:date;not null;index:idx_order_room_types_stay"`
+	ToDate     time.Time `json:"toDate" gorm:"type:date;not null;index:idx_order_room_types_stay"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"createdAt"`
+}
+
+func (t *RoomType) ValidateStatus() error {
+	if t.Status < 0 || t.Status > 1 {
+		return fmt.Errorf("invalid status: %d, must be 0 or 1", t.Status)
+	}
+	return nil
+}
+
+// SyncRoom chép các thông tin dùng chung của hạng sang phòng thật, giữ tên và trạng thái của phòng.
+// Ảnh và nội thất không chép, phòng thuộc hạng dùng ảnh và nội thất của hạng.
+func (t *RoomType) SyncRoom(room *Room) {
+	room.AccommodationID = t.AccommodationID
+	room.RoomTypeID = &t.ID
+	room.Type = t.Type
+	room.NumBed = t.NumBed
+	room.NumTolet = t.NumTolet
+	room.Acreage = t.Acreage
+	room.Price = t.Price
+	room.Description = t.Description
+	room.ShortDescription = t.ShortDescription
+	room.Avatar = t.Avatar
+	room.People = t.People
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// StayRestriction là quy định lưu trú của một chỗ ở hoặc một phòng, chỉ một trong hai được gán.
+// Quy định áp dụng cho kỳ lưu trú có ngày nhận phòng nằm trong [FromDate, ToDate] (nil: không giới hạn)
+// và rơi vào ArrivalDays (0: mọi thứ).
+// Các trường thứ trong tuần là bitmask: bit 0 là Chủ nhật ... bit 6 là Thứ bảy.
+type StayRestriction struct {
+	ID              uint       `json:"id" gorm:"primaryKey"`
+	AccommodationID *uint      `json:"accommodationId" gorm:"index"`
+	RoomID          *uint      `json:"roomId" gorm:"index"`
+	FromDate        *time.Time `json:"fromDate" gorm:"type:date"`
+	ToDate          *time.Time `json:"toDate" gorm:"type:date"`
+	ArrivalDays     int        `json:"arrivalDays"`
+	MinNights       int        `json:"minNights"`      // 0: không giới hạn
+	MaxNights       int        `json:"maxNights"`      // 0: không giới hạn
+	NoCheckInDays   int        `json:"noCheckInDays"`  // Thứ không nhận khách
+	NoCheckOutDays  int        `json:"noCheckOutDays"` // Thứ không trả phòng
+	MinAdvanceDays  int        `json:"minAdvanceDays"` // Phải đặt trước ít nhất số ngày này
+	CreatedAt       time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt       time.Time  `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+const allWeekdays = 1<<7 - 1
+
+// WeekdayMask chuyển danh sách thứ thành bitmask dùng trong StayRestriction
+func WeekdayMask(days ...time.Weekday) int {
+	mask := 0
+	for _, day := range days {
+		mask |= 1 << day
+	}
+	return mask
+}
+
+// HasWeekday cho biết bitmask có chứa thứ day không
+func HasWeekday(mask int, day time.Weekday) bool {
+	return mask&(1<<day) != 0
+}
+
+// AppliesTo cho biết quy định có áp dụng cho kỳ lưu trú nhận phòng ngày checkIn không
+func (r *StayRestriction) AppliesTo(checkIn time.Time) bool {
+	day := checkIn.Format("2006-01-02")
+	if r.FromDate != nil && day < r.FromDate.Format("2006-01-02") {
+		return false
+	}
+	if r.ToDate != nil && day > r.ToDate.Format("2006-01-02") {
+		return false
+	}
+	return r.ArrivalDays == 0 || HasWeekday(r.ArrivalDays, checkIn.Weekday())
+}
+
+func (r *StayRestriction) Validate() error {
+	if (r.AccommodationID == nil) == (r.RoomID == nil) {
+		return fmt.Errorf("quy định lưu trú phải thuộc về đúng một phòng hoặc chỗ ở")
+	}
+	if r.FromDate != nil && r.ToDate != nil && r.ToDate.Before(*r.FromDate) {
+		return fmt.Errorf("ngày kết thúc áp dụng trước ngày bắt đầu")
+	}
+	for _, mask := range []int{r.ArrivalDays, r.NoCheckInDays, r.NoCheckOutDays} {
+		if mask < 0 || mask > allWeekdays {
+			return fmt.Errorf("invalid weekday mask: %d", mask)
+		}
+	}
+	if r.MinNights < 0 || r.MaxNights < 0 || r.MinAdvanceDays < 0 {
+		return fmt.Errorf("số đêm và số ngày đặt trước không được âm")
+	}
+	if r.MaxNights > 0 && r.MaxNights < r.MinNights {
+		return fmt.Errorf("số đêm tối đa nhỏ hơn số đêm tối thiểu")
+	}
+	if r.NoCheckInDays == allWeekdays {
+		return fmt.Errorf("không thể chặn nhận phòng tất cả các ngày trong tuần")
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Các nhà cung cấp đăng nhập bên ngoài
+const (
+	ProviderGoogle   = "google"
+	ProviderFacebook = "facebook"
+	ProviderZalo     = "zalo"
+)
+
+// UserIdentity liên kết một phương thức đăng nhập bên ngoài với User
+type UserIdentity struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	UserID         uint      `json:"userId" gorm:"index;not null"`
+	Provider       string    `json:"provider" gorm:"size:32;not null;uniqueIndex:idx_user_identities_provider_subject"`
+	ProviderUserID string    `json:"providerUserId" gorm:"size:255;not null;uniqueIndex:idx_user_identities_provider_subject"` // ID người dùng phía nhà cung cấp
+	Email          string    `json:"email"`
+	CreatedAt      time.Time `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt      time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Mục đích sử dụng của token, mỗi token chỉ dùng được cho đúng mục đích đã tạo
+const (
+	TokenPurposeResetPassword = "reset_password"
+)
+
+type UserToken struct {
+	ID        uint       `gorm:"primaryKey"`
+	UserID    uint       `gorm:"index;not null"`
+	Purpose   string     `gorm:"size:32;index;not null"`
+	TokenHash string     `gorm:"size:64;uniqueIndex;not null"` // SHA-256 của token, không lưu token gốc
+	ExpiresAt time.Time  `gorm:"index"`
+	UsedAt    *time.Time // Đã dùng hoặc bị vô hiệu hóa
+	CreatedAt time.Time
+}
@@ -0,0 +1,109 @@
+package repositories
+
+import (
+	"context"
+	"new/models"
+	"new/services"
+
+	"gorm.io/gorm"
+)
+
+// AccommodationFilter là bộ lọc danh sách chỗ ở, trường nil/rỗng được bỏ qua
+type AccommodationFilter struct {
+	// OwnerID khác 0 thì chỉ lấy chỗ ở của chủ này
+	OwnerID  uint   `json:"ownerId,omitempty"`
+	Type     *int   `json:"type,omitempty"`
+	Status   *int   `json:"status,omitempty"`
+	Province string `json:"province,omitempty"`
+	District string `json:"district,omitempty"`
+	Name     string `json:"name,omitempty"`
+	NumBed   *int   `json:"numBed,omitempty"`
+	NumTolet *int   `json:"numTolet,omitempty"`
+	People   *int   `json:"people,omitempty"`
+	Num      *int   `json:"num,omitempty"`
+	// Có ít nhất một tiện ích trong danh sách
+	BenefitIDs   []int `json:"benefitIds,omitempty"`
+	WithBenefits bool  `json:"withBenefits,omitempty"`
+}
+
+type AccommodationRepository interface {
+	List(ctx context.Context, filter AccommodationFilter, page services.PageRequest) ([]models.Accommodation, services.PageInfo, error)
+	FindByID(ctx context.Context, id uint) (models.Accommodation, error)
+	// FindDetail kèm tiện ích, ảnh, nội thất, chủ sở hữu và ngân hàng của chủ
+	FindDetail(ctx context.Context, id uint) (models.Accommodation, error)
+	// FindWithRelations kèm chủ sở hữu, phòng và đánh giá
+	FindWithRelations(ctx context.Context, id uint) (models.Accommodation, error)
+	Create(ctx context.Context, accommodation *models.Accommodation) error
+	Save(ctx context.Context, accommodation *models.Accommodation) error
+	ReplaceBenefits(ctx context.Context, accommodation *models.Accommodation, benefits []models.Benefit) error
+}
+
+type accommodationRepository struct {
+	db *gorm.DB
+}
+
+func NewAccommodationRepository(db *gorm.DB) AccommodationRepository {
+	return accommodationRepository{db: db}
+}
+
+// Danh sách chỗ ở xếp theo cập nhật mới nhất, cần index (update_at DESC, id DESC)
+var accommodationKeyset = services.Keyset{TimeColumn: "accommodations.update_at", IDColumn: "accommodations.id"}
+
+func accommodationCursor(acc models.Accommodation) services.Cursor {
+	return services.Cursor{Time: acc.UpdateAt, ID: acc.ID}
+}
+
+func (r accommodationRepository) List(ctx context.Context, filter AccommodationFilter, page services.PageRequest) ([]models.Accommodation, services.PageInfo, error) {
+	q := services.NewListQuery(r.db.WithContext(ctx).Model(&models.Accommodation{})).
+		Contains("province", filter.Province).
+		Contains("district", filter.District).
+		Contains("name", filter.Name)
+	if filter.OwnerID != 0 {
+		q.Where("user_id", "user_id = ?", filter.OwnerID)
+	}
+	eq(q, "type", filter.Type)
+	eq(q, "status", filter.Status)
+	eq(q, "num_bed", filter.NumBed)
+	eq(q, "num_tolet", filter.NumTolet)
+	eq(q, "people", filter.People)
+	eq(q, "num", filter.Num)
+	if len(filter.BenefitIDs) > 0 {
+		q.Where("benefit_id", "EXISTS (SELECT 1 FROM accommodation_benefits ab WHERE ab.accommodation_id = accommodations.id AND ab.benefit_id IN ?)", filter.BenefitIDs)
+	}
+	if filter.WithBenefits {
+		q.Preload("Benefits")
+	}
+	return services.Find(q, page, accommodationKeyset, accommodationCursor)
+}
+
+func (r accommodationRepository) FindByID(ctx context.Context, id uint) (models.Accommodation, error) {
+	var accommodation models.Accommodation
+	err := r.db.WithContext(ctx).First(&accommodation, id).Error
+	return accommodation, err
+}
+
+func (r accommodationRepository) FindDetail(ctx context.Context, id uint) (models.Accommodation, error) {
+	var accommodation models.Accommodation
+	err := r.db.WithContext(ctx).Preload("Benefits").
+		Preload("Img", galleryOrder).Preload("Furniture", galleryOrder).
+		Preload("User").Preload("User.Banks").First(&accommodation, id).Error
+	return accommodation, err
+}
+
+func (r accommodationRepository) FindWithRelations(ctx context.Context, id uint) (models.Accommodation, error) {
+	var accommodation models.Accommodation
+	err := r.db.WithContext(ctx).Preload("User").Preload("Rooms").Preload("Rates").First(&accommodation, id).Error
+	return accommodation, err
+}
+
+func (r accommodationRepository) Create(ctx context.Context, accommodation *models.Accommodation) error {
+	return r.db.WithContext(ctx).Create(accommodation).Error
+}
+
+func (r accommodationRepository) Save(ctx context.Context, accommodation *models.Accommodation) error {
+	return r.db.WithContext(ctx).Save(accommodation).Error
+}
+
+func (r accommodationRepository) ReplaceBenefits(ctx context.Context, accommodation *models.Accommodation, benefits []models.Benefit) error {
+	return r.db.WithContext(ctx).Model(accommodation).Association("Benefits").Replace(benefits)
+}
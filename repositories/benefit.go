@@ -0,0 +1,162 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"new/models"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type BenefitRepository interface {
+	// List lấy mọi tiện ích chưa bị gộp
+	List(ctx context.Context) ([]models.Benefit, error)
+	FindByID(ctx context.Context, id uint) (models.Benefit, error)
+	FindByIDs(ctx context.Context, ids []int) ([]models.Benefit, error)
+	// FindCanonical lấy tiện ích theo ID, tiện ích đã gộp trả về tiện ích chuẩn
+	FindCanonical(ctx context.Context, id int) (models.Benefit, error)
+	// FindOrCreateByName tìm tiện ích theo tên không phân biệt hoa thường và khoảng trắng, chưa có thì tạo mới
+	// với phạm vi scope. Tên của tiện ích đã gộp trả về tiện ích chuẩn.
+	FindOrCreateByName(ctx context.Context, name string, scope string) (models.Benefit, error)
+	Create(ctx context.Context, benefits []models.Benefit) error
+	Save(ctx context.Context, benefit *models.Benefit) error
+	UpdateStatus(ctx context.Context, benefit *models.Benefit, status int) error
+	// Merge chuyển liên kết chỗ ở/phòng của các tiện ích trùng sang tiện ích chuẩn và đánh dấu chúng đã gộp
+	// trong một transaction, trả về ID các chỗ ở và phòng bị ảnh hưởng
+	Merge(ctx context.Context, canonicalID int, duplicateIDs []int) (BenefitMergeResult, error)
+}
+
+// BenefitMergedError là lỗi Merge trả về khi tiện ích chuẩn hoặc tiện ích trùng đã được gộp vào tiện ích khác
+type BenefitMergedError struct {
+	ID           int
+	MergedIntoID int
+}
+
+func (e BenefitMergedError) Error() string {
+	return fmt.Sprintf("Tiện ích %d đã được gộp vào tiện ích %d", e.ID, e.MergedIntoID)
+}
+
+type BenefitMergeResult struct {
+	AccommodationIDs []uint `json:"accommodationIds"`
+	RoomIDs          []uint `json:"roomIds"`
+}
+
+type benefitRepository struct {
+	db *gorm.DB
+}
+
+func NewBenefitRepository(db *gorm.DB) BenefitRepository {
+	return benefitRepository{db: db}
+}
+
+func (r benefitRepository) List(ctx context.Context) ([]models.Benefit, error) {
+	var benefits []models.Benefit
+	err := r.db.WithContext(ctx).Where("merged_into_id IS NULL").Order("category, id").Find(&benefits).Error
+	return benefits, err
+}
+
+func (r benefitRepository) FindByID(ctx context.Context, id uint) (models.Benefit, error) {
+	var benefit models.Benefit
+	err := r.db.WithContext(ctx).First(&benefit, id).Error
+	return benefit, err
+}
+
+func (r benefitRepository) FindByIDs(ctx context.Context, ids []int) ([]models.Benefit, error) {
+	var benefits []models.Benefit
+	if len(ids) == 0 {
+		return benefits, nil
+	}
+	err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&benefits).Error
+	return benefits, err
+}
+
+func (r benefitRepository) FindCanonical(ctx context.Context, id int) (models.Benefit, error) {
+	var benefit models.Benefit
+	if err := r.db.WithContext(ctx).First(&benefit, id).Error; err != nil || benefit.MergedIntoID == nil {
+		return benefit, err
+	}
+	// Merge luôn trỏ thẳng về tiện ích chuẩn nên chỉ cần đi một bước
+	var canonical models.Benefit
+	err := r.db.WithContext(ctx).First(&canonical, *benefit.MergedIntoID).Error
+	return canonical, err
+}
+
+func (r benefitRepository) FindOrCreateByName(ctx context.Context, name string, scope string) (models.Benefit, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	benefit := models.Benefit{Name: name, Scope: scope}
+	err := r.db.WithContext(ctx).
+		Where(`regexp_replace(LOWER(TRIM(name)), '\s+', ' ', 'g') = ?`, strings.ToLower(name)).
+		Order("merged_into_id NULLS FIRST").
+		FirstOrCreate(&benefit).Error
+	if err != nil || benefit.MergedIntoID == nil {
+		return benefit, err
+	}
+	return r.FindCanonical(ctx, *benefit.MergedIntoID)
+}
+
+func (r benefitRepository) Create(ctx context.Context, benefits []models.Benefit) error {
+	return r.db.WithContext(ctx).Create(&benefits).Error
+}
+
+func (r benefitRepository) Save(ctx context.Context, benefit *models.Benefit) error {
+	return r.db.WithContext(ctx).Save(benefit).Error
+}
+
+func (r benefitRepository) UpdateStatus(ctx context.Context, benefit *models.Benefit, status int) error {
+	return r.db.WithContext(ctx).Model(benefit).Update("status", status).Error
+}
+
+func (r benefitRepository) Merge(ctx context.Context, canonicalID int, duplicateIDs []int) (BenefitMergeResult, error) {
+	var result BenefitMergeResult
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Khóa các tiện ích liên quan để hai lần gộp chạy song song không chéo nhau
+		var locked []models.Benefit
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ?", append([]int{canonicalID}, duplicateIDs...)).Order("id").Find(&locked).Error; err != nil {
+			return err
+		}
+		// Kiểm tra lại trên các dòng đã khóa, lần gộp khác có thể vừa gộp tiện ích chuẩn hoặc tiện ích trùng
+		if len(locked) != len(duplicateIDs)+1 {
+			return gorm.ErrRecordNotFound
+		}
+		for _, benefit := range locked {
+			if benefit.MergedIntoID != nil {
+				return BenefitMergedError{ID: benefit.Id, MergedIntoID: *benefit.MergedIntoID}
+			}
+		}
+
+		if err := tx.Table("accommodation_benefits").Distinct("accommodation_id").
+			Where("benefit_id IN ?", duplicateIDs).Pluck("accommodation_id", &result.AccommodationIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Table("room_benefits").Distinct("room_room_id").
+			Where("benefit_id IN ?", duplicateIDs).Pluck("room_room_id", &result.RoomIDs).Error; err != nil {
+			return err
+		}
+
+		// Chỗ ở/phòng đã có tiện ích chuẩn thì chỉ xóa liên kết trùng
+		for _, join := range []struct{ table, owner string }{
+			{"accommodation_benefits", "accommodation_id"},
+			{"room_benefits", "room_room_id"},
+		} {
+			if err := tx.Exec("INSERT INTO "+join.table+" ("+join.owner+", benefit_id) "+
+				"SELECT DISTINCT "+join.owner+", ? FROM "+join.table+" WHERE benefit_id IN ? ON CONFLICT DO NOTHING",
+				canonicalID, duplicateIDs).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec("DELETE FROM "+join.table+" WHERE benefit_id IN ?", duplicateIDs).Error; err != nil {
+				return err
+			}
+		}
+
+		// Tiện ích đã gộp được ẩn và trỏ về tiện ích chuẩn, kể cả các tiện ích trước đó đã gộp vào chúng
+		if err := tx.Model(&models.Benefit{}).Where("id IN ? OR merged_into_id IN ?", duplicateIDs, duplicateIDs).
+			Updates(map[string]interface{}{"merged_into_id": canonicalID, "status": 1}).Error; err != nil {
+			return err
+		}
+		return nil
+	})
+	return result, err
+}
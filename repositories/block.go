@@ -0,0 +1,106 @@
+package repositories
+
+import (
+	"context"
+	"new/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// BlockRepository quản lý lịch chặn (bảo trì, chủ sử dụng, tạm đóng) của phòng và chỗ ở,
+// lưu cùng bảng room_statuses/accommodation_statuses với lịch đặt của đơn hàng
+type BlockRepository interface {
+	// ListRoomBlocks lấy lịch chặn của phòng chưa kết thúc trước since
+	ListRoomBlocks(ctx context.Context, roomID uint, since time.Time) ([]models.RoomStatus, error)
+	ListAccommodationBlocks(ctx context.Context, accommodationID uint, since time.Time) ([]models.AccommodationStatus, error)
+	FindRoomBlock(ctx context.Context, roomID, id uint) (models.RoomStatus, error)
+	FindAccommodationBlock(ctx context.Context, accommodationID, id uint) (models.AccommodationStatus, error)
+	CreateRoomBlock(ctx context.Context, block *models.RoomStatus) error
+	CreateAccommodationBlock(ctx context.Context, block *models.AccommodationStatus) error
+	DeleteRoomBlock(ctx context.Context, block *models.RoomStatus) error
+	DeleteAccommodationBlock(ctx context.Context, block *models.AccommodationStatus) error
+
+	// RoomHasBookings kiểm tra phòng có đơn đặt trùng khoảng [from, to)
+	RoomHasBookings(ctx context.Context, roomID uint, from, to time.Time) (bool, error)
+	// AccommodationHasBookings kiểm tra chỗ ở hoặc bất kỳ phòng nào của chỗ ở có đơn đặt trùng khoảng [from, to)
+	AccommodationHasBookings(ctx context.Context, accommodationID uint, from, to time.Time) (bool, error)
+}
+
+type blockRepository struct {
+	db *gorm.DB
+}
+
+func NewBlockRepository(db *gorm.DB) BlockRepository {
+	return blockRepository{db: db}
+}
+
+func (r blockRepository) ListRoomBlocks(ctx context.Context, roomID uint, since time.Time) ([]models.RoomStatus, error) {
+	var blocks []models.RoomStatus
+	err := r.db.WithContext(ctx).
+		Where("room_id = ? AND status IN ? AND to_date > ?", roomID, models.CalendarBlockStatuses, since).
+		Order("from_date").
+		Find(&blocks).Error
+	return blocks, err
+}
+
+func (r blockRepository) ListAccommodationBlocks(ctx context.Context, accommodationID uint, since time.Time) ([]models.AccommodationStatus, error) {
+	var blocks []models.AccommodationStatus
+	err := r.db.WithContext(ctx).
+		Where("accommodation_id = ? AND status IN ? AND to_date > ?", accommodationID, models.CalendarBlockStatuses, since).
+		Order("from_date").
+		Find(&blocks).Error
+	return blocks, err
+}
+
+func (r blockRepository) FindRoomBlock(ctx context.Context, roomID, id uint) (models.RoomStatus, error) {
+	var block models.RoomStatus
+	err := r.db.WithContext(ctx).
+		Where("id = ? AND room_id = ? AND status IN ?", id, roomID, models.CalendarBlockStatuses).
+		Take(&block).Error
+	return block, err
+}
+
+func (r blockRepository) FindAccommodationBlock(ctx context.Context, accommodationID, id uint) (models.AccommodationStatus, error) {
+	var block models.AccommodationStatus
+	err := r.db.WithContext(ctx).
+		Where("id = ? AND accommodation_id = ? AND status IN ?", id, accommodationID, models.CalendarBlockStatuses).
+		Take(&block).Error
+	return block, err
+}
+
+func (r blockRepository) CreateRoomBlock(ctx context.Context, block *models.RoomStatus) error {
+	return r.db.WithContext(ctx).Create(block).Error
+}
+
+func (r blockRepository) CreateAccommodationBlock(ctx context.Context, block *models.AccommodationStatus) error {
+	return r.db.WithContext(ctx).Create(block).Error
+}
+
+func (r blockRepository) DeleteRoomBlock(ctx context.Context, block *models.RoomStatus) error {
+	return r.db.WithContext(ctx).Delete(block).Error
+}
+
+func (r blockRepository) DeleteAccommodationBlock(ctx context.Context, block *models.AccommodationStatus) error {
+	return r.db.WithContext(ctx).Delete(block).Error
+}
+
+func (r blockRepository) RoomHasBookings(ctx context.Context, roomID uint, from, to time.Time) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.RoomStatus{}).
+		Where("room_id = ? AND status = ? AND from_date < ? AND to_date > ?", roomID, models.CalendarBooked, to, from).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r blockRepository) AccommodationHasBookings(ctx context.Context, accommodationID uint, from, to time.Time) (bool, error) {
+	var booked bool
+	accommodationBookings := r.db.Model(&models.AccommodationStatus{}).Select("1").
+		Where("accommodation_id = ? AND status = ? AND from_date < ? AND to_date > ?", accommodationID, models.CalendarBooked, to, from)
+	roomBookings := r.db.Model(&models.RoomStatus{}).Select("1").
+		Where("room_id IN (?) AND status = ? AND from_date < ? AND to_date > ?",
+			r.db.Model(&models.Room{}).Select("room_id").Where("accommodation_id = ?", accommodationID),
+			models.CalendarBooked, to, from)
+	err := r.db.WithContext(ctx).Raw("SELECT EXISTS (?) OR EXISTS (?)", accommodationBookings, roomBookings).Scan(&booked).Error
+	return booked, err
+}
@@ -0,0 +1,119 @@
+package repositories
+
+import (
+	"context"
+	"new/models"
+	"new/services"
+
+	"gorm.io/gorm"
+)
+
+// DiscountFilter là bộ lọc danh sách mã giảm giá, trường nil/rỗng được bỏ qua
+type DiscountFilter struct {
+	Name     string   `json:"name,omitempty"`
+	Status   *int     `json:"status,omitempty"`
+	Discount *float64 `json:"discount,omitempty"`
+	Quantity *float64 `json:"quantity,omitempty"`
+	// Khoảng ngày ở dạng yyyymmdd
+	FromDate string `json:"fromDate,omitempty"`
+	ToDate   string `json:"toDate,omitempty"`
+}
+
+type DiscountRepository interface {
+	List(ctx context.Context, filter DiscountFilter, page services.PageRequest) ([]models.Discount, services.PageInfo, error)
+	FindByID(ctx context.Context, id uint) (models.Discount, error)
+	Create(ctx context.Context, discount *models.Discount) error
+	Save(ctx context.Context, discount *models.Discount) error
+	Delete(ctx context.Context, discount *models.Discount) error
+	UpdateStatus(ctx context.Context, discount *models.Discount, status int) error
+	// ExpireOverdue tắt các mã đã hết hạn (trừ mã mặc định id = 1), trả về số mã bị tắt
+	ExpireOverdue(ctx context.Context) (int64, error)
+	// Active lấy các mã đang bật còn số lượng, mức giảm cao nhất trước
+	Active(ctx context.Context) ([]models.Discount, error)
+	// UsageByUser trả về số lần người dùng đã dùng từng mã, theo ID mã
+	UsageByUser(ctx context.Context, userID uint) (map[uint]int, error)
+	// RecordUsage tăng số lần người dùng đã dùng mã thêm 1
+	RecordUsage(ctx context.Context, userID, discountID uint) error
+}
+
+type discountRepository struct {
+	db *gorm.DB
+}
+
+func NewDiscountRepository(db *gorm.DB) DiscountRepository {
+	return discountRepository{db: db}
+}
+
+// Danh sách mã giảm giá xếp theo cập nhật mới nhất, cần index (updated_at DESC, id DESC)
+var discountKeyset = services.Keyset{TimeColumn: "updated_at", IDColumn: "id"}
+
+func discountCursor(discount models.Discount) services.Cursor {
+	return services.Cursor{Time: discount.UpdatedAt, ID: discount.ID}
+}
+
+func (r discountRepository) List(ctx context.Context, filter DiscountFilter, page services.PageRequest) ([]models.Discount, services.PageInfo, error) {
+	q := services.NewListQuery(r.db.WithContext(ctx).Model(&models.Discount{})).
+		Contains("name", filter.Name).
+		DateRange("from_date", "to_date", filter.FromDate, filter.ToDate)
+	eq(q, "status", filter.Status)
+	eq(q, "discount", filter.Discount)
+	eq(q, "quantity", filter.Quantity)
+	return services.Find(q, page, discountKeyset, discountCursor)
+}
+
+func (r discountRepository) FindByID(ctx context.Context, id uint) (models.Discount, error) {
+	var discount models.Discount
+	err := r.db.WithContext(ctx).First(&discount, id).Error
+	return discount, err
+}
+
+func (r discountRepository) Create(ctx context.Context, discount *models.Discount) error {
+	return r.db.WithContext(ctx).Create(discount).Error
+}
+
+func (r discountRepository) Save(ctx context.Context, discount *models.Discount) error {
+	return r.db.WithContext(ctx).Save(discount).Error
+}
+
+func (r discountRepository) Delete(ctx context.Context, discount *models.Discount) error {
+	return r.db.WithContext(ctx).Delete(discount).Error
+}
+
+func (r discountRepository) UpdateStatus(ctx context.Context, discount *models.Discount, status int) error {
+	return r.db.WithContext(ctx).Model(discount).Update("status", status).Error
+}
+
+func (r discountRepository) ExpireOverdue(ctx context.Context) (int64, error) {
+	result := r.db.WithContext(ctx).Model(&models.Discount{}).
+		Where("id <> 1 AND status = 1 AND to_date ~ '^[0-9]{2}/[0-9]{2}/[0-9]{4}$' AND to_date(to_date, 'DD/MM/YYYY') < CURRENT_TIMESTAMP").
+		Update("status", 0)
+	return result.RowsAffected, result.Error
+}
+
+func (r discountRepository) Active(ctx context.Context) ([]models.Discount, error) {
+	var discounts []models.Discount
+	err := r.db.WithContext(ctx).Where("status = ? AND quantity > 0", 1).Order("discount DESC").Find(&discounts).Error
+	return discounts, err
+}
+
+func (r discountRepository) UsageByUser(ctx context.Context, userID uint) (map[uint]int, error) {
+	var userDiscounts []models.UserDiscount
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Find(&userDiscounts).Error; err != nil {
+		return nil, err
+	}
+	usage := make(map[uint]int, len(userDiscounts))
+	for _, userDiscount := range userDiscounts {
+		usage[userDiscount.DiscountID] = userDiscount.UsageCount
+	}
+	return usage, nil
+}
+
+func (r discountRepository) RecordUsage(ctx context.Context, userID, discountID uint) error {
+	result := r.db.WithContext(ctx).Model(&models.UserDiscount{}).
+		Where("user_id = ? AND discount_id = ?", userID, discountID).
+		Update("usage_count", gorm.Expr("usage_count + 1"))
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.Error
+	}
+	return r.db.WithContext(ctx).Create(&models.UserDiscount{UserID: userID, DiscountID: discountID, UsageCount: 1}).Error
+}
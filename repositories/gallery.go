@@ -0,0 +1,120 @@
+package repositories
+
+import (
+	"context"
+	"new/models"
+	"slices"
+
+	"gorm.io/gorm"
+)
+
+type GalleryRepository interface {
+	ListMedia(ctx context.Context, owner models.GalleryOwner) ([]models.Media, error)
+	ListFurniture(ctx context.Context, owner models.GalleryOwner) ([]models.FurnitureItem, error)
+	// ReplaceMedia ghi đè thư viện ảnh trong một transaction, trả về các ảnh bị bỏ khỏi thư viện
+	// mà không còn thư viện nào dùng (cùng PublicID) để xóa khỏi nơi lưu trữ
+	ReplaceMedia(ctx context.Context, owner models.GalleryOwner, media []models.Media) ([]models.Media, error)
+	// ReplaceFurniture ghi đè danh sách nội thất trong một transaction
+	ReplaceFurniture(ctx context.Context, owner models.GalleryOwner, items []models.FurnitureItem) error
+}
+
+type galleryRepository struct {
+	db *gorm.DB
+}
+
+func NewGalleryRepository(db *gorm.DB) GalleryRepository {
+	return galleryRepository{db: db}
+}
+
+// ownedBy lọc theo chủ của ảnh/nội thất
+func ownedBy(owner models.GalleryOwner) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		switch {
+		case owner.AccommodationID != nil:
+			return db.Where("accommodation_id = ?", *owner.AccommodationID)
+		case owner.RoomID != nil:
+			return db.Where("room_id = ?", *owner.RoomID)
+		case owner.RoomTypeID != nil:
+			return db.Where("room_type_id = ?", *owner.RoomTypeID)
+		}
+		return db.Where("1 = 0")
+	}
+}
+
+// galleryOrder sắp ảnh/nội thất theo thứ tự hiển thị, dùng cho cả Preload
+func galleryOrder(db *gorm.DB) *gorm.DB {
+	return db.Order("sort_order, id")
+}
+
+func (r galleryRepository) ListMedia(ctx context.Context, owner models.GalleryOwner) ([]models.Media, error) {
+	var media []models.Media
+	err := r.db.WithContext(ctx).Scopes(ownedBy(owner), galleryOrder).Find(&media).Error
+	return media, err
+}
+
+func (r galleryRepository) ListFurniture(ctx context.Context, owner models.GalleryOwner) ([]models.FurnitureItem, error) {
+	var items []models.FurnitureItem
+	err := r.db.WithContext(ctx).Scopes(ownedBy(owner), galleryOrder).Find(&items).Error
+	return items, err
+}
+
+func (r galleryRepository) ReplaceMedia(ctx context.Context, owner models.GalleryOwner, media []models.Media) ([]models.Media, error) {
+	var orphaned []models.Media
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var previous []models.Media
+		if err := tx.Scopes(ownedBy(owner)).Find(&previous).Error; err != nil {
+			return err
+		}
+		if err := tx.Scopes(ownedBy(owner)).Delete(&models.Media{}).Error; err != nil {
+			return err
+		}
+		if len(media) > 0 {
+			for i := range media {
+				media[i].ID = 0
+				media[i].GalleryOwner = owner
+			}
+			if err := tx.Create(&media).Error; err != nil {
+				return err
+			}
+		}
+
+		var publicIDs []string
+		for _, m := range previous {
+			if m.PublicID != "" {
+				publicIDs = append(publicIDs, m.PublicID)
+			}
+		}
+		if len(publicIDs) == 0 {
+			return nil
+		}
+		var inUse []string
+		if err := tx.Model(&models.Media{}).Distinct("public_id").
+			Where("public_id IN ?", publicIDs).Pluck("public_id", &inUse).Error; err != nil {
+			return err
+		}
+		for _, m := range previous {
+			if m.PublicID != "" && !slices.Contains(inUse, m.PublicID) {
+				orphaned = append(orphaned, m)
+				inUse = append(inUse, m.PublicID)
+			}
+		}
+		return nil
+	})
+	return orphaned, err
+}
+
+func (r galleryRepository) ReplaceFurniture(ctx context.Context, owner models.GalleryOwner, items []models.FurnitureItem) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Scopes(ownedBy(owner)).Delete(&models.FurnitureItem{}).Error; err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		for i := range items {
+			items[i].ID = 0
+			items[i].GalleryOwner = owner
+		}
+		return tx.Create(&items).Error
+	})
+}
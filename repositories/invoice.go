@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"context"
+	"new/models"
+
+	"gorm.io/gorm"
+)
+
+type InvoiceRepository interface {
+	// List lấy một trang hóa đơn, ownerID khác 0 thì chỉ lấy hóa đơn của các chỗ ở thuộc chủ này
+	List(ctx context.Context, ownerID uint, page, limit int) ([]models.Invoice, int64, error)
+	// ListAll lấy mọi hóa đơn trong phạm vi ownerID, dùng để tính doanh thu
+	ListAll(ctx context.Context, ownerID uint) ([]models.Invoice, error)
+	FindByID(ctx context.Context, id uint) (models.Invoice, error)
+	FindByOrderID(ctx context.Context, orderID uint) (models.Invoice, error)
+	Create(ctx context.Context, invoice *models.Invoice) error
+	Save(ctx context.Context, invoice *models.Invoice) error
+}
+
+type invoiceRepository struct {
+	db *gorm.DB
+}
+
+func NewInvoiceRepository(db *gorm.DB) InvoiceRepository {
+	return invoiceRepository{db: db}
+}
+
+func (r invoiceRepository) scoped(ctx context.Context, ownerID uint) *gorm.DB {
+	tx := r.db.WithContext(ctx).Model(&models.Invoice{})
+	if ownerID != 0 {
+		tx = tx.Where("order_id IN (?)", r.db.Table("orders").
+			Select("orders.id").
+			Joins("JOIN accommodations ON accommodations.id = orders.accommodation_id").
+			Where("accommodations.user_id = ?", ownerID))
+	}
+	return tx
+}
+
+func (r invoiceRepository) List(ctx context.Context, ownerID uint, page, limit int) ([]models.Invoice, int64, error) {
+	var total int64
+	tx := r.scoped(ctx, ownerID)
+	if err := tx.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var invoices []models.Invoice
+	err := tx.Order("updated_at DESC").
+		Offset(page * limit).
+		Limit(limit).
+		Find(&invoices).Error
+	return invoices, total, err
+}
+
+func (r invoiceRepository) ListAll(ctx context.Context, ownerID uint) ([]models.Invoice, error) {
+	var invoices []models.Invoice
+	err := r.scoped(ctx, ownerID).Find(&invoices).Error
+	return invoices, err
+}
+
+func (r invoiceRepository) FindByID(ctx context.Context, id uint) (models.Invoice, error) {
+	var invoice models.Invoice
+	err := r.db.WithContext(ctx).First(&invoice, id).Error
+	return invoice, err
+}
+
+func (r invoiceRepository) FindByOrderID(ctx context.Context, orderID uint) (models.Invoice, error) {
+	var invoice models.Invoice
+	err := r.db.WithContext(ctx).Where("order_id = ?", orderID).First(&invoice).Error
+	return invoice, err
+}
+
+func (r invoiceRepository) Create(ctx context.Context, invoice *models.Invoice) error {
+	return r.db.WithContext(ctx).Create(invoice).Error
+}
+
+func (r invoiceRepository) Save(ctx context.Context, invoice *models.Invoice) error {
+	return r.db.WithContext(ctx).Save(invoice).Error
+}
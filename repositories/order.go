@@ -0,0 +1,199 @@
+package repositories
+
+import (
+	"context"
+	"new/models"
+	"new/services"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OrderFilter là bộ lọc danh sách đơn hàng, trường nil/rỗng được bỏ qua
+type OrderFilter struct {
+	// OwnerID khác 0 thì chỉ lấy đơn của các chỗ ở thuộc chủ này
+	OwnerID           uint       `json:"ownerId,omitempty"`
+	AccommodationName string     `json:"name,omitempty"`
+	PhoneNumber       string     `json:"phoneNumber,omitempty"`
+	MinPrice          *float64   `json:"price,omitempty"`
+	CreatedFrom       *time.Time `json:"fromDate,omitempty"`
+	UpdatedTo         *time.Time `json:"toDate,omitempty"`
+}
+
+type OrderRepository interface {
+	// List lấy một trang đơn hàng kèm chỗ ở, phòng và người đặt
+	List(ctx context.Context, filter OrderFilter, page services.PageRequest) ([]models.Order, services.PageInfo, error)
+	// ListByUser lấy lịch sử đặt của một người dùng, mới nhất trước
+	ListByUser(ctx context.Context, userID uint, page, limit int) ([]models.Order, int64, error)
+	FindByID(ctx context.Context, id uint) (models.Order, error)
+	// FindDetail kèm chỗ ở, phòng, số phòng đặt theo hạng và người đặt
+	FindDetail(ctx context.Context, id uint) (models.Order, error)
+	Create(ctx context.Context, order *models.Order) error
+	Save(ctx context.Context, order *models.Order) error
+	AttachRooms(ctx context.Context, order *models.Order, roomIDs []uint) error
+
+	// LockAccommodation khóa dòng chỗ ở (SELECT ... FOR UPDATE) tới hết transaction, để các đơn cùng chỗ ở
+	// kiểm tra lịch trống và giữ chỗ lần lượt; chỉ có tác dụng khi gọi trong TxRunner.Transaction
+	LockAccommodation(ctx context.Context, accommodationID uint) error
+	// Lịch đặt của phòng/chỗ ở: Booked kiểm tra trùng lịch (cả đơn khác và lịch chặn), Block giữ chỗ, Release trả lại
+	IsRoomBooked(ctx context.Context, roomID uint, from, to time.Time) (bool, error)
+	IsAccommodationBooked(ctx context.Context, accommodationID uint, from, to time.Time) (bool, error)
+	BlockRoom(ctx context.Context, roomID uint, from, to time.Time) error
+	BlockAccommodation(ctx context.Context, accommodationID uint, from, to time.Time) error
+	// Release trả lại đúng lịch đặt của đơn theo phòng/chỗ ở và ngày nhận, trả phòng
+	ReleaseRoom(ctx context.Context, roomID uint, from, to time.Time) error
+	ReleaseAccommodation(ctx context.Context, accommodationID uint, from, to time.Time) error
+
+	// Holidays trả về các ngày lễ dùng để tính phụ phí
+	Holidays(ctx context.Context) ([]models.Holiday, error)
+}
+
+type orderRepository struct {
+	db *gorm.DB
+}
+
+func NewOrderRepository(db *gorm.DB) OrderRepository {
+	return orderRepository{db: db}
+}
+
+// Danh sách đơn hàng xếp theo cập nhật mới nhất, cần index (updated_at DESC, id DESC)
+var orderKeyset = services.Keyset{TimeColumn: "orders.updated_at", IDColumn: "orders.id"}
+
+func orderCursor(order models.Order) services.Cursor {
+	return services.Cursor{Time: order.UpdatedAt, ID: order.ID}
+}
+
+func (r orderRepository) List(ctx context.Context, filter OrderFilter, page services.PageRequest) ([]models.Order, services.PageInfo, error) {
+	q := services.NewListQuery(r.db.WithContext(ctx).Model(&models.Order{})).
+		Preload("Accommodation", "Room", "User")
+
+	accommodations := func(query string, args ...interface{}) *gorm.DB {
+		return r.db.Model(&models.Accommodation{}).Select("id").Where(query, args...)
+	}
+	if filter.OwnerID != 0 {
+		q.Where("owner", "orders.accommodation_id IN (?)", accommodations("user_id = ?", filter.OwnerID))
+	}
+	if filter.AccommodationName != "" {
+		q.Where("name", "orders.accommodation_id IN (?)",
+			accommodations("name ILIKE ?", services.ContainsPattern(filter.AccommodationName)))
+	}
+	if filter.PhoneNumber != "" {
+		pattern := services.ContainsPattern(filter.PhoneNumber)
+		q.Where("phone", "(orders.user_id IN (?) OR (orders.user_id IS NULL AND orders.guest_phone ILIKE ?))",
+			r.db.Model(&models.User{}).Select("id").Where("phone_number ILIKE ?", pattern), pattern)
+	}
+	if filter.MinPrice != nil {
+		q.Where("price", "orders.total_price >= ?", *filter.MinPrice)
+	}
+	if filter.CreatedFrom != nil {
+		q.Where("fromDate", "orders.created_at >= ?", *filter.CreatedFrom)
+	}
+	if filter.UpdatedTo != nil {
+		q.Where("toDate", "orders.updated_at <= ?", *filter.UpdatedTo)
+	}
+	return services.Find(q, page, orderKeyset, orderCursor)
+}
+
+func (r orderRepository) ListByUser(ctx context.Context, userID uint, page, limit int) ([]models.Order, int64, error) {
+	var total int64
+	if err := r.db.WithContext(ctx).Model(&models.Order{}).Where("user_id = ?", userID).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var orders []models.Order
+	err := r.db.WithContext(ctx).Preload("User").
+		Preload("Accommodation").
+		Preload("Room").
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Offset(page * limit).
+		Limit(limit).
+		Find(&orders).Error
+	return orders, total, err
+}
+
+func (r orderRepository) FindByID(ctx context.Context, id uint) (models.Order, error) {
+	var order models.Order
+	err := r.db.WithContext(ctx).First(&order, id).Error
+	return order, err
+}
+
+func (r orderRepository) FindDetail(ctx context.Context, id uint) (models.Order, error) {
+	var order models.Order
+	err := r.db.WithContext(ctx).Preload("User").
+		Preload("Accommodation").
+		Preload("Room").
+		Preload("RoomTypes.RoomType").
+		First(&order, id).Error
+	return order, err
+}
+
+func (r orderRepository) Create(ctx context.Context, order *models.Order) error {
+	return r.db.WithContext(ctx).Create(order).Error
+}
+
+func (r orderRepository) Save(ctx context.Context, order *models.Order) error {
+	return r.db.WithContext(ctx).Save(order).Error
+}
+
+func (r orderRepository) AttachRooms(ctx context.Context, order *models.Order, roomIDs []uint) error {
+	rooms := make([]models.Room, 0, len(roomIDs))
+	for _, roomID := range roomIDs {
+		rooms = append(rooms, models.Room{RoomId: roomID})
+	}
+	return r.db.WithContext(ctx).Model(order).Association("Room").Append(rooms)
+}
+
+func (r orderRepository) LockAccommodation(ctx context.Context, accommodationID uint) error {
+	var ids []uint
+	return r.db.WithContext(ctx).Model(&models.Accommodation{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", accommodationID).Pluck("id", &ids).Error
+}
+
+func (r orderRepository) IsRoomBooked(ctx context.Context, roomID uint, from, to time.Time) (bool, error) {
+	var booked bool
+	roomStatuses := r.db.Model(&models.RoomStatus{}).Select("1").
+		Where("room_id = ? AND status <> ? AND from_date < ? AND to_date > ?", roomID, models.CalendarAvailable, to, from)
+	// Chỗ ở bị chặn lịch thì mọi phòng của chỗ ở cũng bị chặn
+	accommodationBlocks := r.db.Model(&models.AccommodationStatus{}).Select("1").
+		Where("accommodation_id IN (?) AND status IN ? AND from_date < ? AND to_date > ?",
+			r.db.Model(&models.Room{}).Select("accommodation_id").Where("room_id = ?", roomID),
+			models.CalendarBlockStatuses, to, from)
+	err := r.db.WithContext(ctx).Raw("SELECT EXISTS (?) OR EXISTS (?)", roomStatuses, accommodationBlocks).Scan(&booked).Error
+	return booked, err
+}
+
+func (r orderRepository) IsAccommodationBooked(ctx context.Context, accommodationID uint, from, to time.Time) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.AccommodationStatus{}).
+		Where("accommodation_id = ? AND status <> ? AND from_date < ? AND to_date > ?", accommodationID, models.CalendarAvailable, to, from).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r orderRepository) BlockRoom(ctx context.Context, roomID uint, from, to time.Time) error {
+	return r.db.WithContext(ctx).Create(&models.RoomStatus{RoomID: roomID, Status: models.CalendarBooked, FromDate: from, ToDate: to}).Error
+}
+
+func (r orderRepository) BlockAccommodation(ctx context.Context, accommodationID uint, from, to time.Time) error {
+	return r.db.WithContext(ctx).Create(&models.AccommodationStatus{AccommodationID: accommodationID, Status: models.CalendarBooked, FromDate: from, ToDate: to}).Error
+}
+
+func (r orderRepository) ReleaseRoom(ctx context.Context, roomID uint, from, to time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.RoomStatus{}).
+		Where("room_id = ? AND status = ? AND from_date = ? AND to_date = ?", roomID, models.CalendarBooked, from, to).
+		Update("status", models.CalendarAvailable).Error
+}
+
+func (r orderRepository) ReleaseAccommodation(ctx context.Context, accommodationID uint, from, to time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.AccommodationStatus{}).
+		Where("accommodation_id = ? AND status = ? AND from_date = ? AND to_date = ?", accommodationID, models.CalendarBooked, from, to).
+		Update("status", models.CalendarAvailable).Error
+}
+
+func (r orderRepository) Holidays(ctx context.Context) ([]models.Holiday, error) {
+	var holidays []models.Holiday
+	err := r.db.WithContext(ctx).Find(&holidays).Error
+	return holidays, err
+}
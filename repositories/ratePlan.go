@@ -0,0 +1,115 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"new/models"
+
+	"gorm.io/gorm"
+)
+
+type RatePlanRepository interface {
+	// FindByRoom trả về nil khi phòng chưa có bảng giá
+	FindByRoom(ctx context.Context, roomID uint) (*models.RatePlan, error)
+	// FindByRooms trả về bảng giá theo ID phòng, phòng chưa có bảng giá không có trong map
+	FindByRooms(ctx context.Context, roomIDs []uint) (map[uint]*models.RatePlan, error)
+	// FindByAccommodation trả về nil khi chỗ ở chưa có bảng giá
+	FindByAccommodation(ctx context.Context, accommodationID uint) (*models.RatePlan, error)
+	// FindByRoomType trả về nil khi hạng phòng chưa có bảng giá
+	FindByRoomType(ctx context.Context, roomTypeID uint) (*models.RatePlan, error)
+	// Replace ghi đè toàn bộ bảng giá (theo thứ, theo mùa, theo ngày) của phòng/chỗ ở trong một transaction
+	Replace(ctx context.Context, plan *models.RatePlan) error
+}
+
+type ratePlanRepository struct {
+	db *gorm.DB
+}
+
+func NewRatePlanRepository(db *gorm.DB) RatePlanRepository {
+	return ratePlanRepository{db: db}
+}
+
+func preloadRatePlan(db *gorm.DB) *gorm.DB {
+	return db.Preload("DayPrices").
+		Preload("Seasons", func(db *gorm.DB) *gorm.DB { return db.Order("from_date") }).
+		Preload("Overrides", func(db *gorm.DB) *gorm.DB { return db.Order("date") })
+}
+
+func (r ratePlanRepository) findOne(ctx context.Context, query string, id uint) (*models.RatePlan, error) {
+	var plan models.RatePlan
+	err := preloadRatePlan(r.db.WithContext(ctx)).Where(query, id).Take(&plan).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+func (r ratePlanRepository) FindByRoom(ctx context.Context, roomID uint) (*models.RatePlan, error) {
+	return r.findOne(ctx, "room_id = ?", roomID)
+}
+
+func (r ratePlanRepository) FindByAccommodation(ctx context.Context, accommodationID uint) (*models.RatePlan, error) {
+	return r.findOne(ctx, "accommodation_id = ?", accommodationID)
+}
+
+func (r ratePlanRepository) FindByRoomType(ctx context.Context, roomTypeID uint) (*models.RatePlan, error) {
+	return r.findOne(ctx, "room_type_id = ?", roomTypeID)
+}
+
+func (r ratePlanRepository) FindByRooms(ctx context.Context, roomIDs []uint) (map[uint]*models.RatePlan, error) {
+	plans := make(map[uint]*models.RatePlan, len(roomIDs))
+	if len(roomIDs) == 0 {
+		return plans, nil
+	}
+	var found []models.RatePlan
+	if err := preloadRatePlan(r.db.WithContext(ctx)).Where("room_id IN ?", roomIDs).Find(&found).Error; err != nil {
+		return nil, err
+	}
+	for i := range found {
+		plans[*found[i].RoomID] = &found[i]
+	}
+	return plans, nil
+}
+
+func (r ratePlanRepository) Replace(ctx context.Context, plan *models.RatePlan) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing models.RatePlan
+		q := tx.Where("room_id = ?", plan.RoomID)
+		switch {
+		case plan.AccommodationID != nil:
+			q = tx.Where("accommodation_id = ?", plan.AccommodationID)
+		case plan.RoomTypeID != nil:
+			q = tx.Where("room_type_id = ?", plan.RoomTypeID)
+		}
+		err := q.Take(&existing).Error
+		switch {
+		case err == nil:
+			plan.ID = existing.ID
+			plan.CreatedAt = existing.CreatedAt
+			for _, child := range []interface{}{&models.RateDayPrice{}, &models.RateSeason{}, &models.RateOverride{}} {
+				if err := tx.Where("rate_plan_id = ?", existing.ID).Delete(child).Error; err != nil {
+					return err
+				}
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			plan.ID = 0
+		default:
+			return err
+		}
+
+		// Các mục con luôn được tạo mới
+		for i := range plan.DayPrices {
+			plan.DayPrices[i].ID = 0
+		}
+		for i := range plan.Seasons {
+			plan.Seasons[i].ID = 0
+		}
+		for i := range plan.Overrides {
+			plan.Overrides[i].ID = 0
+		}
+		return tx.Save(plan).Error
+	})
+}
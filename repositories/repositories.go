@@ -0,0 +1,64 @@
+// Package repositories gom các truy vấn database của controller sau các interface,
+// để controller nhận dependency qua constructor và có thể thay bằng bản giả khi test.
+package repositories
+
+import (
+	"context"
+	"new/services"
+
+	"gorm.io/gorm"
+)
+
+type Repositories struct {
+	Orders         OrderRepository
+	Accommodations AccommodationRepository
+	Rooms          RoomRepository
+	Invoices       InvoiceRepository
+	Users          UserRepository
+	Discounts      DiscountRepository
+	RatePlans      RatePlanRepository
+	RoomTypes      RoomTypeRepository
+	Blocks         BlockRepository
+	Restrictions   StayRestrictionRepository
+	Benefits       BenefitRepository
+	Galleries      GalleryRepository
+
+	db *gorm.DB
+}
+
+// TxRunner chạy fn trong một transaction, các repository truyền cho fn dùng chung transaction đó
+type TxRunner interface {
+	Transaction(ctx context.Context, fn func(tx Repositories) error) error
+}
+
+// New tạo các repository dùng GORM trên cùng một kết nối
+func New(db *gorm.DB) Repositories {
+	return Repositories{
+		Orders:         NewOrderRepository(db),
+		Accommodations: NewAccommodationRepository(db),
+		Rooms:          NewRoomRepository(db),
+		Invoices:       NewInvoiceRepository(db),
+		Users:          NewUserRepository(db),
+		Discounts:      NewDiscountRepository(db),
+		RatePlans:      NewRatePlanRepository(db),
+		RoomTypes:      NewRoomTypeRepository(db),
+		Blocks:         NewBlockRepository(db),
+		Restrictions:   NewStayRestrictionRepository(db),
+		Benefits:       NewBenefitRepository(db),
+		Galleries:      NewGalleryRepository(db),
+		db:             db,
+	}
+}
+
+func (r Repositories) Transaction(ctx context.Context, fn func(tx Repositories) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(New(tx))
+	})
+}
+
+// eq thêm điều kiện column = value nếu bộ lọc được truyền
+func eq[T any](q *services.ListQuery, column string, value *T) {
+	if value != nil {
+		q.Where(column, column+" = ?", *value)
+	}
+}
@@ -0,0 +1,123 @@
+package repositories
+
+import (
+	"context"
+	"new/models"
+	"new/services"
+
+	"gorm.io/gorm"
+)
+
+// RoomFilter là bộ lọc danh sách phòng, trường nil/rỗng được bỏ qua
+type RoomFilter struct {
+	// OwnerID khác 0 thì chỉ lấy phòng thuộc các chỗ ở của chủ này
+	OwnerID           uint   `json:"ownerId,omitempty"`
+	AccommodationID   *int   `json:"accommodationId,omitempty"`
+	AccommodationName string `json:"accommodation,omitempty"`
+	Province          string `json:"province,omitempty"`
+	Name              string `json:"name,omitempty"`
+	Type              *int   `json:"type,omitempty"`
+	Status            *int   `json:"status,omitempty"`
+	NumBed            *int   `json:"numBed,omitempty"`
+	NumTolet          *int   `json:"numTolet,omitempty"`
+	People            *int   `json:"people,omitempty"`
+	// AccommodationStatus lọc theo trạng thái duyệt tin của chỗ ở cha
+	AccommodationStatus *int `json:"accommodationStatus,omitempty"`
+}
+
+type RoomRepository interface {
+	// List lấy một trang phòng kèm chỗ ở cha
+	List(ctx context.Context, filter RoomFilter, page services.PageRequest) ([]models.Room, services.PageInfo, error)
+	FindByID(ctx context.Context, id uint) (models.Room, error)
+	// FindDetail kèm chỗ ở cha, tiện ích, ảnh và nội thất của phòng
+	FindDetail(ctx context.Context, id uint) (models.Room, error)
+	FindByIDs(ctx context.Context, ids []uint) ([]models.Room, error)
+	// ListByAccommodation lấy tất cả phòng của chỗ ở theo ID
+	ListByAccommodation(ctx context.Context, accommodationID uint) ([]models.Room, error)
+	Create(ctx context.Context, room *models.Room) error
+	Save(ctx context.Context, room *models.Room) error
+	ReplaceBenefits(ctx context.Context, room *models.Room, benefits []models.Benefit) error
+}
+
+type roomRepository struct {
+	db *gorm.DB
+}
+
+func NewRoomRepository(db *gorm.DB) RoomRepository {
+	return roomRepository{db: db}
+}
+
+// Danh sách phòng xếp theo cập nhật mới nhất, cần index (updated_at DESC, room_id DESC)
+var roomKeyset = services.Keyset{TimeColumn: "rooms.updated_at", IDColumn: "rooms.room_id"}
+
+func roomCursor(room models.Room) services.Cursor {
+	return services.Cursor{Time: room.UpdatedAt, ID: room.RoomId}
+}
+
+func (r roomRepository) List(ctx context.Context, filter RoomFilter, page services.PageRequest) ([]models.Room, services.PageInfo, error) {
+	q := services.NewListQuery(r.db.WithContext(ctx).Model(&models.Room{})).
+		Contains("room_name", filter.Name).
+		Preload("Parent")
+	// Lọc theo chỗ ở cha bằng subquery để không phải JOIN khi đếm
+	accommodations := func(query string, args ...interface{}) *gorm.DB {
+		return r.db.Model(&models.Accommodation{}).Select("id").Where(query, args...)
+	}
+	if filter.OwnerID != 0 {
+		q.Where("owner", "rooms.accommodation_id IN (?)", accommodations("user_id = ?", filter.OwnerID))
+	}
+	if filter.AccommodationName != "" {
+		q.Where("accommodation", "rooms.accommodation_id IN (?)",
+			accommodations("name ILIKE ?", services.ContainsPattern(filter.AccommodationName)))
+	}
+	if filter.Province != "" {
+		q.Where("province", "rooms.accommodation_id IN (?)",
+			accommodations("province ILIKE ?", services.ContainsPattern(filter.Province)))
+	}
+	eq(q, "rooms.accommodation_id", filter.AccommodationID)
+	eq(q, "rooms.type", filter.Type)
+	eq(q, "rooms.status", filter.Status)
+	eq(q, "rooms.num_bed", filter.NumBed)
+	eq(q, "rooms.num_tolet", filter.NumTolet)
+	eq(q, "rooms.people", filter.People)
+	if filter.AccommodationStatus != nil {
+		q.Where("accommodation_status", "rooms.accommodation_id IN (?)", accommodations("status = ?", *filter.AccommodationStatus))
+	}
+	return services.Find(q, page, roomKeyset, roomCursor)
+}
+
+func (r roomRepository) FindByID(ctx context.Context, id uint) (models.Room, error) {
+	var room models.Room
+	err := r.db.WithContext(ctx).First(&room, id).Error
+	return room, err
+}
+
+func (r roomRepository) FindDetail(ctx context.Context, id uint) (models.Room, error) {
+	var room models.Room
+	err := r.db.WithContext(ctx).Preload("Parent").Preload("Benefits").
+		Preload("Img", galleryOrder).Preload("Furniture", galleryOrder).First(&room, id).Error
+	return room, err
+}
+
+func (r roomRepository) FindByIDs(ctx context.Context, ids []uint) ([]models.Room, error) {
+	var rooms []models.Room
+	err := r.db.WithContext(ctx).Where("room_id IN ?", ids).Find(&rooms).Error
+	return rooms, err
+}
+
+func (r roomRepository) Create(ctx context.Context, room *models.Room) error {
+	return r.db.WithContext(ctx).Create(room).Error
+}
+
+func (r roomRepository) Save(ctx context.Context, room *models.Room) error {
+	return r.db.WithContext(ctx).Save(room).Error
+}
+
+func (r roomRepository) ListByAccommodation(ctx context.Context, accommodationID uint) ([]models.Room, error) {
+	var rooms []models.Room
+	err := r.db.WithContext(ctx).Where("accommodation_id = ?", accommodationID).Order("room_id").Find(&rooms).Error
+	return rooms, err
+}
+
+func (r roomRepository) ReplaceBenefits(ctx context.Context, room *models.Room, benefits []models.Benefit) error {
+	return r.db.WithContext(ctx).Model(room).Association("Benefits").Replace(benefits)
+}
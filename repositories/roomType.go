@@ -0,0 +1,148 @@
+package repositories
+
+import (
+	"context"
+	"new/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type RoomTypeRepository interface {
+	// ListByAccommodation lấy các hạng phòng của khách sạn kèm phòng thật, ảnh và nội thất
+	ListByAccommodation(ctx context.Context, accommodationID uint) ([]models.RoomType, error)
+	FindByID(ctx context.Context, id uint) (models.RoomType, error)
+	// FindDetail kèm phòng thật, ảnh và nội thất của hạng
+	FindDetail(ctx context.Context, id uint) (models.RoomType, error)
+	// Create tạo hạng phòng cùng các phòng thật trong một transaction
+	Create(ctx context.Context, roomType *models.RoomType, rooms []models.Room) error
+	// Save cập nhật hạng phòng, chép thông tin dùng chung sang các phòng thật và thêm phòng mới (nếu có).
+	// Ảnh và nội thất cập nhật qua GalleryRepository.
+	Save(ctx context.Context, roomType *models.RoomType, newRooms []models.Room) error
+
+	// FreeRooms trả về ID các phòng thật của hạng còn trống trong khoảng [from, to), trừ phòng bảo trì và phòng bị chặn lịch
+	FreeRooms(ctx context.Context, roomTypeID uint, from, to time.Time) ([]uint, error)
+	// PendingReservations là số phòng đã đặt theo hạng nhưng chưa gán phòng thật, trùng khoảng [from, to)
+	PendingReservations(ctx context.Context, roomTypeID uint, from, to time.Time) (int, error)
+	CreateReservations(ctx context.Context, reservations []models.OrderRoomType) error
+	ListReservations(ctx context.Context, orderID uint) ([]models.OrderRoomType, error)
+	SaveReservation(ctx context.Context, reservation *models.OrderRoomType) error
+}
+
+type roomTypeRepository struct {
+	db *gorm.DB
+}
+
+func NewRoomTypeRepository(db *gorm.DB) RoomTypeRepository {
+	return roomTypeRepository{db: db}
+}
+
+func (r roomTypeRepository) ListByAccommodation(ctx context.Context, accommodationID uint) ([]models.RoomType, error) {
+	var roomTypes []models.RoomType
+	err := r.db.WithContext(ctx).Preload("Rooms").
+		Preload("Img", galleryOrder).Preload("Furniture", galleryOrder).
+		Where("accommodation_id = ?", accommodationID).
+		Order("id").
+		Find(&roomTypes).Error
+	return roomTypes, err
+}
+
+func (r roomTypeRepository) FindByID(ctx context.Context, id uint) (models.RoomType, error) {
+	var roomType models.RoomType
+	err := r.db.WithContext(ctx).First(&roomType, id).Error
+	return roomType, err
+}
+
+func (r roomTypeRepository) FindDetail(ctx context.Context, id uint) (models.RoomType, error) {
+	var roomType models.RoomType
+	err := r.db.WithContext(ctx).Preload("Rooms").
+		Preload("Img", galleryOrder).Preload("Furniture", galleryOrder).First(&roomType, id).Error
+	return roomType, err
+}
+
+func (r roomTypeRepository) Create(ctx context.Context, roomType *models.RoomType, rooms []models.Room) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Rooms").Create(roomType).Error; err != nil {
+			return err
+		}
+		return r.createRooms(tx, roomType, rooms)
+	})
+}
+
+func (r roomTypeRepository) Save(ctx context.Context, roomType *models.RoomType, newRooms []models.Room) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Rooms", "Img", "Furniture").Save(roomType).Error; err != nil {
+			return err
+		}
+		err := tx.Model(&models.Room{}).Where("room_type_id = ?", roomType.ID).Updates(map[string]interface{}{
+			"type":              roomType.Type,
+			"num_bed":           roomType.NumBed,
+			"num_tolet":         roomType.NumTolet,
+			"acreage":           roomType.Acreage,
+			"price":             roomType.Price,
+			"description":       roomType.Description,
+			"short_description": roomType.ShortDescription,
+			"avatar":            roomType.Avatar,
+			"people":            roomType.People,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return r.createRooms(tx, roomType, newRooms)
+	})
+}
+
+func (r roomTypeRepository) createRooms(tx *gorm.DB, roomType *models.RoomType, rooms []models.Room) error {
+	if len(rooms) == 0 {
+		return nil
+	}
+	for i := range rooms {
+		roomType.SyncRoom(&rooms[i])
+	}
+	if err := tx.Omit("Parent").Create(&rooms).Error; err != nil {
+		return err
+	}
+	roomType.Rooms = append(roomType.Rooms, rooms...)
+	return nil
+}
+
+func (r roomTypeRepository) FreeRooms(ctx context.Context, roomTypeID uint, from, to time.Time) ([]uint, error) {
+	var ids []uint
+	booked := r.db.Model(&models.RoomStatus{}).Select("room_id").
+		Where("room_id IS NOT NULL AND status <> ? AND from_date < ? AND to_date > ?", models.CalendarAvailable, to, from)
+	blockedAccommodations := r.db.Model(&models.AccommodationStatus{}).Select("accommodation_id").
+		Where("accommodation_id IS NOT NULL AND status IN ? AND from_date < ? AND to_date > ?", models.CalendarBlockStatuses, to, from)
+	err := r.db.WithContext(ctx).Model(&models.Room{}).
+		Where("room_type_id = ? AND status <> 4 AND room_id NOT IN (?) AND accommodation_id NOT IN (?)", roomTypeID, booked, blockedAccommodations).
+		Order("room_id").
+		Pluck("room_id", &ids).Error
+	return ids, err
+}
+
+func (r roomTypeRepository) PendingReservations(ctx context.Context, roomTypeID uint, from, to time.Time) (int, error) {
+	var pending int
+	// Đơn đã hủy (status 2) không giữ tồn kho
+	err := r.db.WithContext(ctx).Model(&models.OrderRoomType{}).
+		Select("COALESCE(SUM(order_room_types.quantity - order_room_types.assigned), 0)").
+		Joins("JOIN orders ON orders.id = order_room_types.order_id AND orders.status <> 2").
+		Where("order_room_types.room_type_id = ? AND order_room_types.from_date < ? AND order_room_types.to_date > ?", roomTypeID, to, from).
+		Scan(&pending).Error
+	return pending, err
+}
+
+func (r roomTypeRepository) CreateReservations(ctx context.Context, reservations []models.OrderRoomType) error {
+	if len(reservations) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Omit("RoomType").Create(&reservations).Error
+}
+
+func (r roomTypeRepository) ListReservations(ctx context.Context, orderID uint) ([]models.OrderRoomType, error) {
+	var reservations []models.OrderRoomType
+	err := r.db.WithContext(ctx).Preload("RoomType").Where("order_id = ?", orderID).Order("id").Find(&reservations).Error
+	return reservations, err
+}
+
+func (r roomTypeRepository) SaveReservation(ctx context.Context, reservation *models.OrderRoomType) error {
+	return r.db.WithContext(ctx).Omit("RoomType").Save(reservation).Error
+}
@@ -0,0 +1,71 @@
+package repositories
+
+import (
+	"context"
+	"new/models"
+
+	"gorm.io/gorm"
+)
+
+type StayRestrictionRepository interface {
+	ListByAccommodation(ctx context.Context, accommodationID uint) ([]models.StayRestriction, error)
+	ListByRoom(ctx context.Context, roomID uint) ([]models.StayRestriction, error)
+	// ForStay lấy quy định của chỗ ở cùng quy định của các phòng được đặt
+	ForStay(ctx context.Context, accommodationID uint, roomIDs []uint) ([]models.StayRestriction, error)
+	// ReplaceForAccommodation/ReplaceForRoom ghi đè toàn bộ quy định trong một transaction
+	ReplaceForAccommodation(ctx context.Context, accommodationID uint, restrictions []models.StayRestriction) error
+	ReplaceForRoom(ctx context.Context, roomID uint, restrictions []models.StayRestriction) error
+}
+
+type stayRestrictionRepository struct {
+	db *gorm.DB
+}
+
+func NewStayRestrictionRepository(db *gorm.DB) StayRestrictionRepository {
+	return stayRestrictionRepository{db: db}
+}
+
+func (r stayRestrictionRepository) ListByAccommodation(ctx context.Context, accommodationID uint) ([]models.StayRestriction, error) {
+	var restrictions []models.StayRestriction
+	err := r.db.WithContext(ctx).Where("accommodation_id = ?", accommodationID).Order("id").Find(&restrictions).Error
+	return restrictions, err
+}
+
+func (r stayRestrictionRepository) ListByRoom(ctx context.Context, roomID uint) ([]models.StayRestriction, error) {
+	var restrictions []models.StayRestriction
+	err := r.db.WithContext(ctx).Where("room_id = ?", roomID).Order("id").Find(&restrictions).Error
+	return restrictions, err
+}
+
+func (r stayRestrictionRepository) ForStay(ctx context.Context, accommodationID uint, roomIDs []uint) ([]models.StayRestriction, error) {
+	var restrictions []models.StayRestriction
+	q := r.db.WithContext(ctx).Where("accommodation_id = ?", accommodationID)
+	if len(roomIDs) > 0 {
+		q = q.Or("room_id IN ?", roomIDs)
+	}
+	err := q.Order("id").Find(&restrictions).Error
+	return restrictions, err
+}
+
+func (r stayRestrictionRepository) ReplaceForAccommodation(ctx context.Context, accommodationID uint, restrictions []models.StayRestriction) error {
+	return r.replace(ctx, "accommodation_id = ?", accommodationID, restrictions)
+}
+
+func (r stayRestrictionRepository) ReplaceForRoom(ctx context.Context, roomID uint, restrictions []models.StayRestriction) error {
+	return r.replace(ctx, "room_id = ?", roomID, restrictions)
+}
+
+func (r stayRestrictionRepository) replace(ctx context.Context, query string, id uint, restrictions []models.StayRestriction) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where(query, id).Delete(&models.StayRestriction{}).Error; err != nil {
+			return err
+		}
+		if len(restrictions) == 0 {
+			return nil
+		}
+		for i := range restrictions {
+			restrictions[i].ID = 0
+		}
+		return tx.Create(&restrictions).Error
+	})
+}
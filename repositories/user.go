@@ -0,0 +1,125 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"new/models"
+	"new/services"
+
+	"gorm.io/gorm"
+)
+
+// UserFilter là bộ lọc danh sách người dùng, trường nil/rỗng được bỏ qua
+type UserFilter struct {
+	// Search tìm theo tên, email hoặc số điện thoại
+	Search string
+	Role   *int
+	Status *int
+	// ManagedBy khác 0 thì chỉ lấy lễ tân thuộc admin này
+	ManagedBy uint
+}
+
+type UserRepository interface {
+	// List lấy người dùng kèm ngân hàng và tài khoản con
+	List(ctx context.Context, filter UserFilter) ([]models.User, error)
+	FindByID(ctx context.Context, id uint) (models.User, error)
+	FindWithBanks(ctx context.Context, id uint) (models.User, error)
+	FindByPhone(ctx context.Context, phone string) (models.User, error)
+	// FindManaged tìm người dùng thuộc quyền quản lý của adminID
+	FindManaged(ctx context.Context, id, adminID uint) (models.User, error)
+	ListChildren(ctx context.Context, adminID uint) ([]models.User, error)
+	// AdminID trả về admin quản lý người dùng (lễ tân), 0 nếu không có
+	AdminID(ctx context.Context, userID uint) (uint, error)
+	Save(ctx context.Context, user *models.User) error
+
+	FindBankFake(ctx context.Context, bankID int, accountNumber string) (models.BankFake, error)
+	AccountNumberExists(ctx context.Context, accountNumber string) (bool, error)
+	CreateBank(ctx context.Context, bank *models.Bank) error
+}
+
+type userRepository struct {
+	db *gorm.DB
+}
+
+func NewUserRepository(db *gorm.DB) UserRepository {
+	return userRepository{db: db}
+}
+
+func (r userRepository) List(ctx context.Context, filter UserFilter) ([]models.User, error) {
+	q := services.NewListQuery(r.db.WithContext(ctx).Model(&models.User{})).
+		Preload("Banks", "Children")
+	if filter.Search != "" {
+		pattern := services.ContainsPattern(filter.Search)
+		q.Where("search", "(name ILIKE ? OR email ILIKE ? OR phone_number ILIKE ?)", pattern, pattern, pattern)
+	}
+	eq(q, "role", filter.Role)
+	eq(q, "status", filter.Status)
+	if filter.ManagedBy != 0 {
+		q.Where("admin_id", "role = 3 AND admin_id = ?", filter.ManagedBy)
+	}
+	return services.FindAll[models.User](q)
+}
+
+func (r userRepository) FindByID(ctx context.Context, id uint) (models.User, error) {
+	var user models.User
+	err := r.db.WithContext(ctx).First(&user, id).Error
+	return user, err
+}
+
+func (r userRepository) FindWithBanks(ctx context.Context, id uint) (models.User, error) {
+	var user models.User
+	err := r.db.WithContext(ctx).Preload("Banks").First(&user, id).Error
+	return user, err
+}
+
+func (r userRepository) FindByPhone(ctx context.Context, phone string) (models.User, error) {
+	var user models.User
+	// Số điện thoại rỗng không định danh ai, nhiều tài khoản mạng xã hội cùng có số rỗng
+	if phone == "" {
+		return user, gorm.ErrRecordNotFound
+	}
+	err := r.db.WithContext(ctx).Where("phone_number = ?", phone).First(&user).Error
+	return user, err
+}
+
+func (r userRepository) FindManaged(ctx context.Context, id, adminID uint) (models.User, error) {
+	var user models.User
+	err := r.db.WithContext(ctx).Where("id = ? AND admin_id = ?", id, adminID).First(&user).Error
+	return user, err
+}
+
+func (r userRepository) ListChildren(ctx context.Context, adminID uint) ([]models.User, error) {
+	var children []models.User
+	err := r.db.WithContext(ctx).Where("admin_id = ?", adminID).Find(&children).Error
+	return children, err
+}
+
+func (r userRepository) AdminID(ctx context.Context, userID uint) (uint, error) {
+	var adminID uint
+	err := r.db.WithContext(ctx).Model(&models.User{}).Select("COALESCE(admin_id, 0)").Where("id = ?", userID).Scan(&adminID).Error
+	return adminID, err
+}
+
+func (r userRepository) Save(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Save(user).Error
+}
+
+func (r userRepository) FindBankFake(ctx context.Context, bankID int, accountNumber string) (models.BankFake, error) {
+	var bankFake models.BankFake
+	err := r.db.WithContext(ctx).Where("id = ? AND account_numbers::jsonb @> ?", bankID, fmt.Sprintf(`["%s"]`, accountNumber)).First(&bankFake).Error
+	return bankFake, err
+}
+
+func (r userRepository) AccountNumberExists(ctx context.Context, accountNumber string) (bool, error) {
+	var bank models.Bank
+	err := r.db.WithContext(ctx).Where("account_number = ?", accountNumber).First(&bank).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r userRepository) CreateBank(ctx context.Context, bank *models.Bank) error {
+	return r.db.WithContext(ctx).Create(bank).Error
+}
@@ -2,23 +2,88 @@ package routes
 
 import (
 	"context"
-	"net/http"
+	"new/apperrors"
 	"new/config"
 	"new/controllers"
 	middlewares "new/middleware"
+	"new/repositories"
+	"new/services"
+	"slices"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/redis/go-redis/v9"
 
-	"github.com/cloudinary/cloudinary-go/v2"
-	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
 	"gorm.io/gorm"
 )
 
-func SetupRoutes(router *gin.Engine, db *gorm.DB, redisCli *redis.Client, cld *cloudinary.Cloudinary) {
+// SetupRoutes đăng ký route; các worker nền (probe Redis) dừng khi ctx bị hủy
+func SetupRoutes(ctx context.Context, router *gin.Engine, db *gorm.DB, redisCli *redis.Client, storage services.Storage) {
+	// Redis là tùy chọn: khi Redis lỗi, breaker ngắt các lời gọi, cache coi như trống
+	// và rate limit chuyển sang bộ nhớ, định kỳ thử lại để hồi phục.
+	var cache services.Cache
+	var limiter services.RateLimiter
+	var redisBreaker *services.CircuitBreaker
+	if redisCli != nil {
+		redisBreaker = services.NewCircuitBreaker("Redis", 3, 30*time.Second)
+		pingRedis := func(ctx context.Context) error { return redisCli.Ping(ctx).Err() }
+		if err := pingRedis(ctx); err != nil {
+			redisBreaker.Trip(err)
+		}
+		redisBreaker.StartProbe(ctx, 10*time.Second, pingRedis)
+
+		// Một cache dùng chung cho mọi controller, key được đặt trong namespace riêng
+		cache = services.NewBreakerCache(services.NewRedisCache(redisCli, "trothalo"), redisBreaker)
+		limiter = services.NewFallbackRateLimiter(services.NewRedisRateLimiter(redisCli), services.NewMemoryRateLimiter(), redisBreaker)
+	} else {
+		cache = services.NewMemoryCache()
+		limiter = services.NewMemoryRateLimiter()
+	}
+	cache = services.NewInstrumentedCache(cache)
+
+	// Ảnh lưu trong thư mục local được phục vụ trực tiếp (không liệt kê thư mục)
+	if local, ok := storage.(*services.LocalStorage); ok {
+		router.Static(local.URLPath(), local.Dir)
+	}
+
+	router.NoRoute(func(c *gin.Context) {
+		c.Error(apperrors.NotFound("Không tìm thấy đường dẫn"))
+	})
 
-	userController := controllers.NewUserController(db, redisCli)
+	healthController := controllers.NewHealthController(healthChecks(db, redisCli), config.Cfg.Health.Timeout, redisBreaker)
+	router.GET("/healthz", healthController.Healthz)
+	router.GET("/readyz", healthController.Readyz)
+	// Giữ /health cho các probe cũ
+	router.GET("/health", healthController.Readyz)
+
+	if sqlDB, err := db.DB(); err == nil {
+		services.RegisterDBMetrics(sqlDB, "postgres")
+	}
+	router.GET("/metrics", gin.WrapH(promhttp.HandlerFor(services.MetricsRegistry, promhttp.HandlerOpts{})))
+
+	repos := repositories.New(db)
+	userController := controllers.NewUserController(repos.Users, cache)
+	roomController := controllers.NewRoomController(repos.Rooms, repos.Accommodations, repos.Users, repos.RatePlans, repos.Benefits, repos.Galleries, cache, storage)
+	geocoder := services.NewGeocoder(config.Cfg.Geocoder, config.Cfg.Mapbox, cache)
+	accommodationController := controllers.NewAccommodationController(repos.Accommodations, repos.Users, repos.RatePlans, repos.Benefits, repos.Galleries, geocoder, cache, storage)
+	orderController := controllers.NewOrderController(repos.Orders, repos.Accommodations, repos.Rooms, repos.Invoices, repos.Users, repos.RatePlans, repos.RoomTypes, repos.Restrictions, repos.Discounts, repos, cache)
+	roomTypeController := controllers.NewRoomTypeController(repos.RoomTypes, repos.Accommodations, repos.RatePlans, repos.Galleries, repos.Users, cache, storage)
+	blockController := controllers.NewBlockController(repos.Blocks, repos.Rooms, repos.Accommodations, repos.Users)
+	restrictionController := controllers.NewStayRestrictionController(repos.Restrictions, repos.Rooms, repos.Accommodations, repos.Users, cache)
+	benefitController := controllers.NewBenefitController(repos.Benefits, cache)
+	holidayController := controllers.NewHolidayController(cache)
+	rateController := controllers.NewRateController(cache)
+	discountController := controllers.NewDiscountController(repos.Discounts, cache)
+	invoiceController := controllers.NewInvoiceController(repos.Invoices, repos.Orders, repos.Users, cache)
+	uploadCfg := config.Cfg.Upload
+	uploadController := controllers.NewUploadController(storage, services.ImageLimits{
+		MaxBytes:      uploadCfg.MaxBytes,
+		MaxPixels:     uploadCfg.MaxPixels,
+		MaxDimension:  uploadCfg.MaxDimension,
+		ThumbnailSize: uploadCfg.ThumbnailSize,
+	}, uploadCfg.MaxFiles)
 
 	v1 := router.Group("/api/v1")
 	v1.GET("/users", middlewares.AuthMiddleware(1, 2), userController.GetUsers)
@@ -27,130 +92,149 @@ func SetupRoutes(router *gin.Engine, db *gorm.DB, redisCli *redis.Client, cld *c
 	v1.PUT("/users", middlewares.AuthMiddleware(1, 2, 3, 0), userController.UpdateUser)
 	v1.PUT("/userStatus", middlewares.AuthMiddleware(1, 2), userController.ChangeUserStatus)
 
-	v1.GET("/verify-email", controllers.VerifyEmail)
-	v1.POST("/auth/login", controllers.Login)
+	v1.GET("/audit", middlewares.AuthMiddleware(1), controllers.GetAuditLogs)
+
+	// Giới hạn số lần thử cho đăng nhập và mã OTP
+	loginLimit := middlewares.RateLimitMiddleware(limiter, services.RateLimitPolicy{
+		Name: "login", Limit: 10, Window: time.Minute,
+		MaxFailures: 5, BaseLockout: time.Minute, MaxLockout: time.Hour, ResetOnSuccess: true,
+	}, "identifier")
+	otpLimit := middlewares.RateLimitMiddleware(limiter, services.RateLimitPolicy{
+		Name: "otp", Limit: 5, Window: time.Minute,
+		MaxFailures: 5, BaseLockout: 5 * time.Minute, MaxLockout: 24 * time.Hour, ResetOnSuccess: true,
+	}, "email", "identifier")
+	// Link xác thực email là GET không có body, đoán token thì mỗi lần một token khác nhau nên chỉ giới hạn theo IP
+	verifyEmailLimit := middlewares.RateLimitMiddleware(limiter, services.RateLimitPolicy{
+		Name: "verifyemail", Limit: 5, Window: time.Minute,
+		MaxFailures: 5, BaseLockout: 5 * time.Minute, MaxLockout: 24 * time.Hour,
+	})
+	sendCodeLimit := middlewares.RateLimitMiddleware(limiter, services.RateLimitPolicy{
+		Name: "sendcode", Limit: 3, Window: 10 * time.Minute,
+		MaxFailures: 10, BaseLockout: 15 * time.Minute, MaxLockout: 24 * time.Hour,
+	}, "identifier")
+
+	v1.GET("/verify-email", verifyEmailLimit, controllers.VerifyEmail)
+	v1.POST("/auth/login", loginLimit, controllers.Login)
 	v1.DELETE("/auth/logout", controllers.Logout)
 	v1.POST("/auth/register", controllers.RegisterUser)
-	v1.POST("/resendCode", controllers.ResendVerificationCode)
-	v1.POST("/forgetPassword", controllers.ForgetPassword)
-	v1.POST("/newPassword", controllers.ResetPassword)
-	v1.POST("/verifyCode", controllers.VerifyCode)
-	v1.POST("/auth/google", controllers.AuthGoogle)
-
-	v1.GET("/room", controllers.GetAllRooms)
-	v1.GET("/roomUser", controllers.GetAllRoomsUser)
-	v1.POST("/room", controllers.CreateRoom)
-	v1.GET("/room/:id", controllers.GetRoomDetail)
-	v1.PUT("/roomUpdate", controllers.UpdateRoom)
-	v1.PUT("/roomStatus", controllers.ChangeRoomStatus)
-
-	v1.GET("/accommodationUser", controllers.GetAllAccommodationsForUser)
-	v1.GET("/accommodation", controllers.GetAllAccommodations)
-	v1.POST("/accommodation", controllers.CreateAccommodation)
-	v1.GET("/accommodation/:id", controllers.GetAccommodationDetail)
-	v1.PUT("/accommodationUpdate", controllers.UpdateAccommodation)
-	v1.PUT("/accommodationStatus", controllers.ChangeAccommodationStatus)
+	v1.POST("/resendCode", sendCodeLimit, controllers.ResendVerificationCode)
+	v1.POST("/forgetPassword", sendCodeLimit, controllers.ForgetPassword)
+	v1.POST("/newPassword", otpLimit, controllers.ResetPassword)
+	v1.POST("/verifyCode", otpLimit, controllers.VerifyCode)
+	v1.POST("/auth/google", loginLimit, controllers.AuthGoogle)
+	v1.POST("/auth/social/:provider", loginLimit, controllers.AuthSocial)
+	v1.GET("/users/identities", middlewares.AuthMiddleware(1, 2, 3, 0), controllers.GetLinkedAccounts)
+	v1.POST("/users/identities/:provider", middlewares.AuthMiddleware(1, 2, 3, 0), controllers.LinkSocialAccount)
+	v1.DELETE("/users/identities/:provider", middlewares.AuthMiddleware(1, 2, 3, 0), controllers.UnlinkSocialAccount)
+	v1.PUT("/users/phone", middlewares.AuthMiddleware(1, 2, 3, 0), controllers.UpdatePhoneNumber)
+
+	v1.GET("/room", roomController.GetAllRooms)
+	v1.GET("/roomUser", roomController.GetAllRoomsUser)
+	v1.POST("/room", roomController.CreateRoom)
+	v1.GET("/room/:id", roomController.GetRoomDetail)
+	v1.PUT("/roomUpdate", middlewares.AuthMiddleware(1, 2, 3), roomController.UpdateRoom)
+	v1.PUT("/roomStatus", roomController.ChangeRoomStatus)
+
+	v1.GET("/room/:id/blocks", middlewares.AuthMiddleware(1, 2, 3), blockController.GetRoomBlocks)
+	v1.POST("/room/:id/blocks", middlewares.AuthMiddleware(1, 2, 3), blockController.CreateRoomBlock)
+	v1.DELETE("/room/:id/blocks/:blockId", middlewares.AuthMiddleware(1, 2, 3), blockController.DeleteRoomBlock)
+	v1.GET("/room/:id/restrictions", restrictionController.GetRoomRestrictions)
+	v1.PUT("/room/:id/restrictions", middlewares.AuthMiddleware(1, 2, 3), restrictionController.UpdateRoomRestrictions)
+
+	v1.GET("/roomType", roomTypeController.GetRoomTypes)
+	v1.GET("/roomType/:id", roomTypeController.GetRoomTypeDetail)
+	v1.POST("/roomType", middlewares.AuthMiddleware(1, 2), roomTypeController.CreateRoomType)
+	v1.PUT("/roomTypeUpdate", middlewares.AuthMiddleware(1, 2), roomTypeController.UpdateRoomType)
+
+	v1.GET("/accommodationUser", accommodationController.GetAllAccommodationsForUser)
+	v1.GET("/accommodation", accommodationController.GetAllAccommodations)
+	v1.POST("/accommodation", accommodationController.CreateAccommodation)
+	v1.GET("/accommodation/:id", accommodationController.GetAccommodationDetail)
+	v1.PUT("/accommodationUpdate", middlewares.AuthMiddleware(1, 2, 3), accommodationController.UpdateAccommodation)
+	v1.PUT("/accommodationStatus", accommodationController.ChangeAccommodationStatus)
+	v1.GET("/accommodation/:id/ratePlan", accommodationController.GetRatePlan)
+	v1.PUT("/accommodation/:id/ratePlan", middlewares.AuthMiddleware(1, 2, 3), accommodationController.UpdateRatePlan)
+	v1.GET("/accommodation/:id/blocks", middlewares.AuthMiddleware(1, 2, 3), blockController.GetAccommodationBlocks)
+	v1.POST("/accommodation/:id/blocks", middlewares.AuthMiddleware(1, 2, 3), blockController.CreateAccommodationBlock)
+	v1.DELETE("/accommodation/:id/blocks/:blockId", middlewares.AuthMiddleware(1, 2, 3), blockController.DeleteAccommodationBlock)
+	v1.GET("/accommodation/:id/restrictions", restrictionController.GetAccommodationRestrictions)
+	v1.PUT("/accommodation/:id/restrictions", middlewares.AuthMiddleware(1, 2, 3), restrictionController.UpdateAccommodationRestrictions)
+	v1.GET("/accommodation/:id/availability", orderController.GetAvailability)
 
 	v1.GET("/banks", controllers.GetAllBanks)
 	v1.POST("/add-banks", controllers.CreateBank)
 	v1.PUT("/update-banks", controllers.AddAccountNumbers)
 	v1.DELETE("/del-banks", controllers.DeleteAllBanks)
 
-	v1.GET("/benefit", controllers.GetAllBenefit)
-	v1.POST("/benefit", controllers.CreateBenefit)
-	v1.GET("/benefit/:id", controllers.GetBenefitDetail)
-	v1.PUT("/benefitUpdate", controllers.UpdateBenefit)
-	v1.PUT("/benefitStatus", controllers.ChangeBenefitStatus)
-
-	v1.GET("/rates", controllers.GetAllRates)
-	v1.POST("/rates", controllers.CreateRate)
-	v1.GET("/rates/:id", controllers.GetRateDetail)
-	v1.PUT("/ratesUpdate", controllers.UpdateRate)
-
-	v1.GET("/order", controllers.GetOrders)
-	v1.POST("/order", controllers.CreateOrder)
-	v1.PUT("/orderUpdate", controllers.ChangeOrderStatus)
-	v1.GET("/order/:id", controllers.GetOrderDetail)
-	v1.GET("/orderHistory", controllers.GetOrdersByUserId)
-
-	v1.GET("/holidays", controllers.GetHolidays)
-	v1.POST("/holidays", controllers.CreateHoliday)
-	v1.PUT("/holidaysUpdate", controllers.UpdateHoliday)
-	v1.GET("/holidays/:id", controllers.GetDetailHoliday)
-	v1.DELETE("/holidays", controllers.DeleteHoliday)
-
-	v1.GET("/discount", controllers.GetDiscounts)
-	v1.GET("/discount/:id", controllers.GetDiscountDetail)
-	v1.POST("/discount", controllers.CreateDiscount)
-	v1.PUT("/discountUpdate", controllers.UpdateDiscount)
-	v1.DELETE("/discount/:id", controllers.DeleteDiscount)
-	v1.PUT("/discountStatus", controllers.ChangeDiscountStatus)
-
-	v1.GET("/invoices", controllers.GetInvoices)
-	v1.GET("/invoices/:id", controllers.GetDetailInvoice)
-	v1.GET("/revenue", controllers.GetTotalRevenue)
-	v1.PUT("/paymentStatus", controllers.UpdatePaymentStatus)
-
-	v1.POST("/img/multi-upload", func(c *gin.Context) {
-		form, er := c.MultipartForm()
-		if er != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Không có file"})
-		}
-		files := form.File["files"]
-		if len(files) == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Không có file"})
-			return
-		}
-
-		var urls []string
-		for _, file := range files {
-			src, err := file.Open()
-			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Lỗi khi mở file"})
-				return
-			}
-			defer src.Close()
+	v1.GET("/benefit", benefitController.GetAllBenefit)
+	v1.POST("/benefit", benefitController.CreateBenefit)
+	v1.GET("/benefit/:id", benefitController.GetBenefitDetail)
+	v1.PUT("/benefitUpdate", benefitController.UpdateBenefit)
+	v1.PUT("/benefitStatus", benefitController.ChangeBenefitStatus)
+	v1.POST("/benefitMerge", middlewares.AuthMiddleware(1), benefitController.MergeBenefits)
+
+	v1.GET("/rates", rateController.GetAllRates)
+	v1.POST("/rates", rateController.CreateRate)
+	v1.GET("/rates/:id", rateController.GetRateDetail)
+	v1.PUT("/ratesUpdate", rateController.UpdateRate)
+
+	v1.GET("/order", orderController.GetOrders)
+	v1.POST("/order", orderController.CreateOrder)
+	v1.POST("/orderQuote", orderController.QuoteOrder)
+	v1.PUT("/orderUpdate", orderController.ChangeOrderStatus)
+	v1.PUT("/orderAssignRooms", middlewares.AuthMiddleware(1, 2, 3), orderController.AssignRooms)
+	v1.GET("/order/:id", orderController.GetOrderDetail)
+	v1.GET("/orderHistory", orderController.GetOrdersByUserId)
+
+	v1.GET("/holidays", holidayController.GetHolidays)
+	v1.POST("/holidays", holidayController.CreateHoliday)
+	v1.PUT("/holidaysUpdate", holidayController.UpdateHoliday)
+	v1.GET("/holidays/:id", holidayController.GetDetailHoliday)
+	v1.DELETE("/holidays", holidayController.DeleteHoliday)
+
+	v1.GET("/discount", discountController.GetDiscounts)
+	v1.GET("/discount/:id", discountController.GetDiscountDetail)
+	v1.POST("/discount", discountController.CreateDiscount)
+	v1.PUT("/discountUpdate", discountController.UpdateDiscount)
+	v1.DELETE("/discount/:id", discountController.DeleteDiscount)
+	v1.PUT("/discountStatus", discountController.ChangeDiscountStatus)
+
+	v1.GET("/invoices", invoiceController.GetInvoices)
+	v1.GET("/invoices/:id", invoiceController.GetDetailInvoice)
+	v1.GET("/revenue", invoiceController.GetTotalRevenue)
+	v1.PUT("/paymentStatus", invoiceController.UpdatePaymentStatus)
+
+	// Ảnh được kiểm tra định dạng/dung lượng, bỏ EXIF và tạo ảnh thu nhỏ trước khi lưu
+	v1.POST("/img/multi-upload", middlewares.AuthMiddleware(1, 2, 3, 0), uploadController.MultiUpload)
+	v1.POST("/img/upload", middlewares.AuthMiddleware(1, 2, 3, 0), uploadController.Upload)
+}
 
-			ctx := context.Background()
-			resp, err := config.Cloudinary.Upload.Upload(ctx, src, uploader.UploadParams{Folder: "uploads"})
+// healthChecks liệt kê các phụ thuộc cho /readyz, chỉ Postgres là bắt buộc
+func healthChecks(db *gorm.DB, redisCli *redis.Client) []services.HealthCheck {
+	checks := []services.HealthCheck{{
+		Name:     "postgres",
+		Critical: true,
+		Check: func(ctx context.Context) error {
+			sqlDB, err := db.DB()
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Upload thất bại"})
-				return
+				return err
 			}
-			urls = append(urls, resp.SecureURL)
-		}
-
-		c.JSON(http.StatusOK, gin.H{
-			"message": "Upload thành công",
-			"urls":    urls,
+			return sqlDB.PingContext(ctx)
+		},
+	}}
+	if redisCli != nil {
+		checks = append(checks, services.HealthCheck{
+			Name:  "redis",
+			Check: func(ctx context.Context) error { return redisCli.Ping(ctx).Err() },
 		})
-	})
-
-	v1.POST("/img/upload", func(c *gin.Context) {
-		file, err := c.FormFile("file")
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Không có file"})
-			return
-		}
-
-		src, err := file.Open()
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Lỗi khi mở file"})
-			return
+	}
+	if config.Cfg.Health.CheckExternal {
+		if config.Cfg.Storage.Driver == "cloudinary" {
+			checks = append(checks, services.HealthCheck{Name: "cloudinary", Check: services.HTTPReachable("https://api.cloudinary.com")})
 		}
-		defer src.Close()
-
-		ctx := context.Background()
-		resp, err := config.Cloudinary.Upload.Upload(ctx, src, uploader.UploadParams{Folder: "avatars"})
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Upload thất bại"})
-			return
+		if slices.Contains(config.Cfg.Geocoder.Providers, "mapbox") {
+			checks = append(checks, services.HealthCheck{Name: "mapbox", Check: services.HTTPReachable("https://api.mapbox.com")})
 		}
-
-		c.JSON(http.StatusOK, gin.H{
-			"message": "Upload avatar thành công",
-			"url":     resp.SecureURL,
-		})
-	})
-
+	}
+	return checks
 }
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"new/config"
+	"new/models"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// AuditEntry là thông tin người thực hiện và đối tượng bị thay đổi
+type AuditEntry struct {
+	ActorID    uint
+	ActorRole  int
+	Action     string
+	EntityType string
+	EntityID   string
+	IP         string
+}
+
+// Các trường không bao giờ được ghi giá trị vào nhật ký
+var auditSensitiveFields = map[string]bool{
+	"password":  true,
+	"code":      true,
+	"tokenhash": true,
+}
+
+type auditChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+func toAuditMap(v interface{}) (map[string]interface{}, error) {
+	result := map[string]interface{}{}
+	if v == nil {
+		return result, nil
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return result, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		// Không phải object: lưu nguyên giá trị
+		var raw interface{}
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"value": raw}, nil
+	}
+	return result, nil
+}
+
+// AuditDiff so sánh hai trạng thái (trước/sau) và chỉ giữ lại các trường thay đổi.
+// before = nil khi tạo mới, after = nil khi xóa.
+func AuditDiff(before, after interface{}) (json.RawMessage, error) {
+	beforeMap, err := toAuditMap(before)
+	if err != nil {
+		return nil, err
+	}
+	afterMap, err := toAuditMap(after)
+	if err != nil {
+		return nil, err
+	}
+
+	scrubAudit(beforeMap)
+	scrubAudit(afterMap)
+
+	changes := map[string]auditChange{}
+	for key, from := range beforeMap {
+		to, ok := afterMap[key]
+		if !ok || !reflect.DeepEqual(from, to) {
+			changes[key] = auditChange{From: from, To: to}
+		}
+	}
+	for key, to := range afterMap {
+		if _, ok := beforeMap[key]; !ok {
+			changes[key] = auditChange{To: to}
+		}
+	}
+
+	return json.Marshal(changes)
+}
+
+// Che giá trị các trường nhạy cảm, kể cả trong object lồng nhau (vd: user được preload)
+func scrubAudit(v interface{}) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			if auditSensitiveFields[strings.ToLower(key)] {
+				value[key] = redactAudit(item)
+				continue
+			}
+			scrubAudit(item)
+		}
+	case []interface{}:
+		for _, item := range value {
+			scrubAudit(item)
+		}
+	}
+}
+
+func redactAudit(v interface{}) interface{} {
+	if v == nil || v == "" {
+		return v
+	}
+	return "******"
+}
+
+// RecordAudit ghi một dòng nhật ký kiểm toán
+func RecordAudit(entry AuditEntry, before, after interface{}) error {
+	changes, err := AuditDiff(before, after)
+	if err != nil {
+		return fmt.Errorf("không thể tính thay đổi: %v", err)
+	}
+
+	return config.DB.Create(&models.AuditLog{
+		ActorID:    entry.ActorID,
+		ActorRole:  entry.ActorRole,
+		Action:     entry.Action,
+		EntityType: entry.EntityType,
+		EntityID:   entry.EntityID,
+		Changes:    changes,
+		IP:         entry.IP,
+	}).Error
+}
+
+type AuditFilter struct {
+	ActorID    *uint
+	Action     string
+	EntityType string
+	EntityID   string
+	From       *time.Time
+	To         *time.Time
+	Page       int
+	Limit      int
+}
+
+// ListAuditLogs trả về nhật ký mới nhất trước, kèm tổng số bản ghi khớp bộ lọc
+func ListAuditLogs(filter AuditFilter) ([]models.AuditLog, int64, error) {
+	tx := config.DB.Model(&models.AuditLog{})
+	if filter.ActorID != nil {
+		tx = tx.Where("actor_id = ?", *filter.ActorID)
+	}
+	if filter.Action != "" {
+		tx = tx.Where("action = ?", filter.Action)
+	}
+	if filter.EntityType != "" {
+		tx = tx.Where("entity_type = ?", filter.EntityType)
+	}
+	if filter.EntityID != "" {
+		tx = tx.Where("entity_id = ?", filter.EntityID)
+	}
+	if filter.From != nil {
+		tx = tx.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		tx = tx.Where("created_at < ?", *filter.To)
+	}
+
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var logs []models.AuditLog
+	if err := tx.Order("created_at DESC, id DESC").
+		Offset(filter.Page * filter.Limit).
+		Limit(filter.Limit).
+		Find(&logs).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return logs, total, nil
+}
+
+// PurgeAuditLogs xóa nhật ký cũ hơn mốc thời gian, chỉ dùng cho chính sách lưu trữ.
+// Dùng SQL trực tiếp vì model chặn mọi thao tác xóa.
+func PurgeAuditLogs(before time.Time) (int64, error) {
+	result := config.DB.Exec("DELETE FROM audit_logs WHERE created_at < ?", before)
+	return result.RowsAffected, result.Error
+}
+
+// StartAuditRetention chạy nền, định kỳ xóa nhật ký quá hạn lưu trữ
+func StartAuditRetention(ctx context.Context, retentionDays int, interval time.Duration) {
+	if retentionDays <= 0 {
+		return
+	}
+
+	purge := func() {
+		deleted, err := PurgeAuditLogs(time.Now().AddDate(0, 0, -retentionDays))
+		if err != nil {
+			slog.Error("Lỗi khi dọn nhật ký kiểm toán", "error", err)
+			return
+		}
+		if deleted > 0 {
+			slog.Info("Đã xóa nhật ký kiểm toán quá hạn", "deleted", deleted, "retention_days", retentionDays)
+		}
+	}
+
+	BackgroundWorkers.Go(func() {
+		purge()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				purge()
+			}
+		}
+	})
+}
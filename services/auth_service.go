@@ -1,11 +1,13 @@
 package services
 
 import (
+	"context"
 	"crypto/rand"
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	"math/big"
-	"net/smtp"
+	"new/apperrors"
 	"new/config"
 	"new/models"
 	"time"
@@ -26,6 +28,30 @@ type Claims struct {
 	jwt.StandardClaims
 }
 
+// Thời gian hiệu lực của mã OTP tính từ CodeCreatedAt
+const OTPExpiry = 5 * time.Minute
+
+var (
+	ErrInvalidCode = errors.New("Mã xác thực không hợp lệ")
+	ErrCodeExpired = errors.New("Mã xác thực đã hết hạn. Vui lòng yêu cầu mã mới.")
+)
+
+// IsCodeExpired kiểm tra mã OTP của người dùng đã hết hạn chưa
+func IsCodeExpired(user models.User) bool {
+	return user.CodeCreatedAt.IsZero() || time.Since(user.CodeCreatedAt) > OTPExpiry
+}
+
+// VerifyUserCode so khớp mã OTP (so sánh thời gian hằng) và kiểm tra hạn dùng
+func VerifyUserCode(user models.User, code string) error {
+	if user.Code == "" || subtle.ConstantTimeCompare([]byte(user.Code), []byte(code)) != 1 {
+		return ErrInvalidCode
+	}
+	if IsCodeExpired(user) {
+		return ErrCodeExpired
+	}
+	return nil
+}
+
 func generateVerificationCode() (string, error) {
 	code := ""
 
@@ -40,14 +66,8 @@ func generateVerificationCode() (string, error) {
 	return code, nil
 }
 
-func sendVerificationEmail(email string, token string) error {
-	from := "takieulong@gmail.com"
-	password := "audj brda qhbq lpxu"
-
-	host := "smtp.gmail.com"
-	port := "587"
-	to := []string{email}
-	subject := "Subject: Mã dùng một lần của bạn\n"
+func sendVerificationEmail(ctx context.Context, email string, token string) error {
+	subject := "Mã dùng một lần của bạn"
 	body := fmt.Sprintf(`
 		<!DOCTYPE html>
 		<html>
@@ -62,31 +82,20 @@ func sendVerificationEmail(email string, token string) error {
 			<p>Nếu không yêu cầu mã này thì bạn có thể bỏ qua email này một cách an toàn. Có thể ai đó khác đã nhập địa chỉ email của bạn do nhầm lẫn.</p>
 			<p>Bạn có thể bấm vào nút sau để xác nhận tài khoản</p>
 			<p>
-				<a href="http://localhost:3001/verify-email?token=%s" style="display: inline-block; padding: 10px 20px; background-color: #1a73e8; color: white; text-decoration: none; border-radius: 5px;">
+				<a href="%s/verify-email?token=%s" style="display: inline-block; padding: 10px 20px; background-color: #1a73e8; color: white; text-decoration: none; border-radius: 5px;">
 					Xác nhận email
 				</a>
 			</p>
 			<p>Xin cám ơn,<br>Nhóm tài khoản</p>
 		</body>
 		</html>
-	`, email, token, token)
+	`, email, token, config.Cfg.FrontendURL, token)
 
-	msg := []byte("MIME-Version: 1.0\r\nContent-Type: text/html; charset=UTF-8\r\n" + subject + "\n" + body)
-
-	auth := smtp.PlainAuth("", from, password, host)
-
-	err := smtp.SendMail(host+":"+port, auth, from, to, msg)
-	return err
+	return sendMail(ctx, email, subject, body)
 }
 
-func sendcodeEmail(email string, token string) error {
-	from := "takieulong@gmail.com"
-	password := "audj brda qhbq lpxu"
-
-	host := "smtp.gmail.com"
-	port := "587"
-	to := []string{email}
-	subject := "Subject: Mã đăng nhập\n"
+func sendcodeEmail(ctx context.Context, email string, token string) error {
+	subject := "Mã đăng nhập"
 	body := fmt.Sprintf(`
 		<!DOCTYPE html>
 		<html>
@@ -105,22 +114,37 @@ func sendcodeEmail(email string, token string) error {
 		</html>
 	`, email, token)
 
-	msg := []byte("MIME-Version: 1.0\r\nContent-Type: text/html; charset=UTF-8\r\n" + subject + "\n" + body)
+	return sendMail(ctx, email, subject, body)
+}
 
-	auth := smtp.PlainAuth("", from, password, host)
+func sendResetPasswordEmail(ctx context.Context, email string, token string) error {
+	subject := "Đặt lại mật khẩu"
+	body := fmt.Sprintf(`
+		<!DOCTYPE html>
+		<html>
+		<head>
+			<meta charset="UTF-8">
+			<title>Đặt lại mật khẩu</title>
+		</head>
+		<body>
+			<p>Xin chào %s,</p>
+			<p>Chúng tôi đã nhận yêu cầu đặt lại mật khẩu cho tài khoản của bạn. Liên kết dưới đây chỉ dùng được một lần và hết hạn sau %d phút.</p>
+			<p>
+				<a href="%s/reset-password?token=%s" style="display: inline-block; padding: 10px 20px; background-color: #1a73e8; color: white; text-decoration: none; border-radius: 5px;">
+					Đặt lại mật khẩu
+				</a>
+			</p>
+			<p>Nếu không yêu cầu đặt lại mật khẩu thì bạn có thể bỏ qua email này một cách an toàn.</p>
+			<p>Xin cám ơn,<br>Nhóm tài khoản</p>
+		</body>
+		</html>
+	`, email, int(ResetTokenTTL.Minutes()), config.Cfg.FrontendURL, token)
 
-	err := smtp.SendMail(host+":"+port, auth, from, to, msg)
-	return err
+	return sendMail(ctx, email, subject, body)
 }
 
-func sendUserEmail(email string, phone string, pass string) error {
-	from := "takieulong@gmail.com"
-	password := "audj brda qhbq lpxu"
-
-	host := "smtp.gmail.com"
-	port := "587"
-	to := []string{email}
-	subject := "Subject: Bạn đã tạo tài khoản mới\n"
+func sendUserEmail(ctx context.Context, email string, phone string, pass string) error {
+	subject := "Bạn đã tạo tài khoản mới"
 	body := fmt.Sprintf(`<!DOCTYPE html>
 	<html>
 	<head>
@@ -141,12 +165,7 @@ func sendUserEmail(email string, phone string, pass string) error {
 	</body>
 	</html>`, email, phone, pass)
 
-	msg := []byte("MIME-Version: 1.0\r\nContent-Type: text/html; charset=UTF-8\r\n" + subject + "\n" + body)
-
-	auth := smtp.PlainAuth("", from, password, host)
-
-	err := smtp.SendMail(host+":"+port, auth, from, to, msg)
-	return err
+	return sendMail(ctx, email, subject, body)
 }
 
 func GetUserByEmail(email string) (models.User, error) {
@@ -187,9 +206,6 @@ func HashPassword(password string) (string, error) {
 	return string(hashedPassword), nil
 }
 
-var secretKey = []byte(config.GetEnv("SECRET_KEY_ACCESS_TOKEN"))
-var refreshSecretKey = []byte(config.GetEnv("SECRET_KEY_REFRESH_TOKEN"))
-
 func GenerateToken(userInfo UserInfo, expiryMinutes int, isAccessToken bool) (string, error) {
 	claims := &Claims{
 		UserInfo: userInfo,
@@ -201,9 +217,9 @@ func GenerateToken(userInfo UserInfo, expiryMinutes int, isAccessToken bool) (st
 
 	var secretKeyToUse []byte
 	if isAccessToken {
-		secretKeyToUse = secretKey
+		secretKeyToUse = []byte(config.Cfg.JWT.AccessSecret.Value())
 	} else {
-		secretKeyToUse = refreshSecretKey
+		secretKeyToUse = []byte(config.Cfg.JWT.RefreshSecret.Value())
 	}
 
 	return token.SignedString(secretKeyToUse)
@@ -222,11 +238,15 @@ func SetTokenCookies(c *gin.Context, accessToken string) {
 
 }
 
-func CreateUser(input models.User) (models.User, error) {
+func CreateUser(ctx context.Context, input models.User) (models.User, error) {
 	if input.Email == "" || input.Password == "" || input.PhoneNumber == "" {
 		return models.User{}, errors.New("không được để trống email, password, phone")
 	}
 
+	if err := ValidatePasswordStrength(input.Password); err != nil {
+		return models.User{}, err
+	}
+
 	existingEmail, err := GetUserByEmail(input.Email)
 	if err == nil {
 		return models.User{}, fmt.Errorf("email %s đã được sử dụng", existingEmail.Email)
@@ -265,9 +285,9 @@ func CreateUser(input models.User) (models.User, error) {
 	}
 
 	if user.Role != 0 {
-		err = sendVerificationEmail(input.Email, token)
+		err = sendVerificationEmail(ctx, input.Email, token)
 	} else {
-		err = sendUserEmail(input.Email, input.PhoneNumber, input.Password)
+		err = sendUserEmail(ctx, input.Email, input.PhoneNumber, input.Password)
 	}
 
 	if err != nil {
@@ -277,7 +297,7 @@ func CreateUser(input models.User) (models.User, error) {
 	return user, nil
 }
 
-func RegenerateVerificationCode(userID uint) error {
+func RegenerateVerificationCode(ctx context.Context, userID uint) error {
 
 	var user models.User
 	result := config.DB.First(&user, userID)
@@ -301,29 +321,7 @@ func RegenerateVerificationCode(userID uint) error {
 	if err := config.DB.Save(&user).Error; err != nil {
 		return fmt.Errorf("không thể cập nhật mã xác minh: %v", err)
 	}
-	err = sendVerificationEmail(user.Email, newCode)
-	if err != nil {
-		return fmt.Errorf("không thể gửi email xác minh: %v", err)
-	}
-
-	return nil
-}
-
-func ResetPass(user models.User) error {
-
-	newCode, err := generateVerificationCode()
-	if err != nil {
-		return fmt.Errorf("không thể tạo mã xác minh mới: %v", err)
-	}
-
-	user.Code = newCode
-	user.CodeCreatedAt = time.Now()
-
-	if err := config.DB.Save(&user).Error; err != nil {
-		return fmt.Errorf("không thể cập nhật mã xác minh: %v", err)
-	}
-
-	err = sendVerificationEmail(user.Email, newCode)
+	err = sendVerificationEmail(ctx, user.Email, newCode)
 	if err != nil {
 		return fmt.Errorf("không thể gửi email xác minh: %v", err)
 	}
@@ -331,14 +329,8 @@ func ResetPass(user models.User) error {
 	return nil
 }
 
-func sendNews(email string, title string, mess string) error {
-	from := "takieulong@gmail.com"
-	password := "audj brda qhbq lpxu"
-
-	host := "smtp.gmail.com"
-	port := "587"
-	to := []string{email}
-	subject := fmt.Sprintf("Subject: %s\n", title)
+func sendNews(ctx context.Context, email string, title string, mess string) error {
+	subject := title
 	body := fmt.Sprintf(`
 		<!DOCTYPE html>
 		<html>
@@ -354,36 +346,10 @@ func sendNews(email string, title string, mess string) error {
 		</html>
 	`, title, email, mess)
 
-	msg := []byte("MIME-Version: 1.0\r\nContent-Type: text/html; charset=UTF-8\r\n" + subject + "\n" + body)
-
-	auth := smtp.PlainAuth("", from, password, host)
-
-	err := smtp.SendMail(host+":"+port, auth, from, to, msg)
-	return err
-}
-
-func NewPass(user models.User, newPassword string) error {
-
-	hashedPassword, err := HashPassword(newPassword)
-	if err != nil {
-		return fmt.Errorf("không thể băm mật khẩu: %v", err)
-	}
-
-	user.Password = hashedPassword
-
-	if err := config.DB.Save(&user).Error; err != nil {
-		return fmt.Errorf("không thể cập nhật mật khẩu mới: %v", err)
-	}
-
-	err = sendNews(user.Email, "Đổi mật khẩu", "Mật khẩu của bạn đã được cập nhật thành công.")
-	if err != nil {
-		return fmt.Errorf("không thể gửi email xác nhận: %v", err)
-	}
-
-	return nil
+	return sendMail(ctx, email, subject, body)
 }
 
-func LoginCode(user models.User) error {
+func LoginCode(ctx context.Context, user models.User) error {
 
 	if user.Role == 1 || user.Role == 2 || user.Role == 3 {
 		if !user.IsVerified {
@@ -398,12 +364,12 @@ func LoginCode(user models.User) error {
 				return fmt.Errorf("không thể cập nhật mã xác minh: %v", err)
 			}
 
-			err = sendVerificationEmail(user.Email, newCode)
+			err = sendVerificationEmail(ctx, user.Email, newCode)
 			if err != nil {
 				return fmt.Errorf("không thể gửi email xác minh: %v", err)
 			}
 
-			return fmt.Errorf("Bạn cần xác nhận email để Đăng nhập")
+			return apperrors.ErrVerificationRequired
 		}
 
 		newCode, err := generateVerificationCode()
@@ -417,7 +383,7 @@ func LoginCode(user models.User) error {
 			return fmt.Errorf("không thể cập nhật mã xác minh: %v", err)
 		}
 
-		err = sendcodeEmail(user.Email, newCode)
+		err = sendcodeEmail(ctx, user.Email, newCode)
 		if err != nil {
 			return fmt.Errorf("không thể gửi email xác minh: %v", err)
 		}
@@ -425,7 +391,7 @@ func LoginCode(user models.User) error {
 		return nil
 	}
 
-	return fmt.Errorf("Vai trò không hợp lệ")
+	return apperrors.Forbidden("Vai trò không hợp lệ")
 }
 
 func UpdateAccommodationRating(accommodationId uint) error {
@@ -457,89 +423,13 @@ func UpdateAccommodationRating(accommodationId uint) error {
 	return nil
 }
 
-func CreateGoogleUser(name, email, avatar string) (models.User, error) {
-
-	existingEmail, err := GetUserByEmail(email)
-	if err == nil {
-		return models.User{}, fmt.Errorf("email %s đã được sử dụng", existingEmail.Email)
-	}
-
-	user := models.User{
-		Name:       name,
-		Email:      email,
-		Password:   "",
-		Avatar:     avatar,
-		IsVerified: true,
-		Role:       0,
-	}
-
-	result := config.DB.Create(&user)
-	if result.Error != nil {
-		return user, result.Error
-	}
-
-	return user, nil
-}
-
-func ApplyDiscountForUser(user models.User) (float64, error) {
-	var discounts []models.Discount
-	var userDiscounts []models.UserDiscount
-
-	if err := config.DB.Where("status = ? AND quantity > 0 ", 1).Order("discount DESC").Find(&discounts).Error; err != nil {
-		return 0, fmt.Errorf("Không thể lấy danh sách mã giảm giá: %v", err)
-	}
-
-	if err := config.DB.Where("user_id = ?", user.ID).Find(&userDiscounts).Error; err != nil {
-		return 0, fmt.Errorf("Lỗi khi kiểm tra lịch sử sử dụng mã giảm giá: %v", err)
-	}
-
-	userDiscountUsage := make(map[uint]int)
-	for _, userDiscount := range userDiscounts {
-		userDiscountUsage[userDiscount.DiscountID] = userDiscount.UsageCount
-	}
-
-	var applicableDiscount models.Discount
-
+// PickDiscount chọn mã có mức giảm cao nhất mà người dùng chưa dùng hết lượt, discounts đã xếp theo mức giảm giảm dần.
+// Hàm không ghi gì, lượt dùng chỉ được ghi khi đơn được lưu
+func PickDiscount(discounts []models.Discount, usage map[uint]int) (models.Discount, bool) {
 	for _, discount := range discounts {
-		if usageCount, used := userDiscountUsage[discount.ID]; !used || usageCount < discount.Quantity {
-			applicableDiscount = discount
-			break
+		if usage[discount.ID] < discount.Quantity {
+			return discount, true
 		}
 	}
-
-	if applicableDiscount.ID == 0 {
-		return 0, nil
-	}
-
-	var userDiscount models.UserDiscount
-	if err := config.DB.Where("user_id = ? AND discount_id = ?", user.ID, applicableDiscount.ID).First(&userDiscount).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-		return 0, fmt.Errorf("Lỗi khi kiểm tra lịch sử sử dụng mã giảm giá: %v", err)
-	}
-
-	if userDiscount.ID == 0 {
-		userDiscount.UserID = user.ID
-		userDiscount.DiscountID = applicableDiscount.ID
-		userDiscount.UsageCount = 1
-	} else {
-		userDiscount.UsageCount += 1
-	}
-
-	if err := config.DB.Save(&userDiscount).Error; err != nil {
-		return 0, fmt.Errorf("Không thể cập nhật thông tin sử dụng mã giảm giá: %v", err)
-	}
-
-	return float64(applicableDiscount.Discount), nil
-}
-
-func CheckUserEligibilityForDiscount(userID uint) bool {
-	if userID == 0 {
-		return false
-	}
-
-	var user models.User
-	if err := config.DB.First(&user, userID).Error; err != nil {
-		return false
-	}
-
-	return true
+	return models.Discount{}, false
 }
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("dịch vụ tạm thời không khả dụng")
+
+const (
+	BreakerClosed   = "closed"    // hoạt động bình thường
+	BreakerOpen     = "open"      // đang lỗi, bỏ qua mọi lời gọi
+	BreakerHalfOpen = "half-open" // hết thời gian chờ, cho một lời gọi thử
+)
+
+// BreakerStatus dùng cho health check
+type BreakerStatus struct {
+	Name      string     `json:"name"`
+	State     string     `json:"state"`
+	Failures  int        `json:"failures"`
+	LastError string     `json:"lastError,omitempty"`
+	OpenedAt  *time.Time `json:"openedAt,omitempty"`
+}
+
+// CircuitBreaker ngắt các lời gọi tới một dịch vụ phụ (Redis) sau nhiều lỗi liên tiếp,
+// sau thời gian chờ sẽ cho một lời gọi thử để kiểm tra dịch vụ đã hồi phục chưa.
+type CircuitBreaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	state     string
+	failures  int
+	lastErr   error
+	openedAt  time.Time
+	onRecover []func()
+}
+
+func NewCircuitBreaker(name string, threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &CircuitBreaker{name: name, threshold: threshold, cooldown: cooldown, state: BreakerClosed}
+}
+
+// Allow trả về ErrCircuitOpen nếu không được gọi dịch vụ lúc này
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		// Chỉ cho đúng một lời gọi thử, các lời gọi khác chờ kết quả
+		b.state = BreakerHalfOpen
+		return nil
+	case BreakerHalfOpen:
+		return ErrCircuitOpen
+	}
+	return nil
+}
+
+// Record ghi nhận kết quả của lời gọi đã được Allow cho phép
+func (b *CircuitBreaker) Record(err error) {
+	if errors.Is(err, context.Canceled) {
+		// Client hủy request, không phải lỗi của dịch vụ
+		err = nil
+	}
+
+	b.mu.Lock()
+	var recovered []func()
+	if err == nil {
+		if b.state != BreakerClosed {
+			slog.Info("Dịch vụ đã hoạt động trở lại", "service", b.name)
+			recovered = b.onRecover
+		}
+		b.state = BreakerClosed
+		b.failures = 0
+		b.lastErr = nil
+	} else {
+		b.failures++
+		b.lastErr = err
+		if b.state == BreakerHalfOpen || (b.state == BreakerClosed && b.failures >= b.threshold) {
+			slog.Warn("Dịch vụ lỗi, tạm ngừng gọi", "service", b.name, "cooldown", b.cooldown.String(), "error", err)
+			b.state = BreakerOpen
+			b.openedAt = time.Now()
+		}
+	}
+	b.mu.Unlock()
+
+	for _, fn := range recovered {
+		fn()
+	}
+}
+
+// Trip mở breaker ngay, dùng khi đã biết dịch vụ lỗi (vd: không kết nối được lúc khởi động)
+func (b *CircuitBreaker) Trip(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	slog.Warn("Dịch vụ lỗi, tạm ngừng gọi", "service", b.name, "cooldown", b.cooldown.String(), "error", err)
+	b.state = BreakerOpen
+	b.failures++
+	b.lastErr = err
+	b.openedAt = time.Now()
+}
+
+// OnRecover đăng ký hàm chạy khi dịch vụ hồi phục, vd: dọn dữ liệu có thể đã cũ
+func (b *CircuitBreaker) OnRecover(fn func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.onRecover = append(b.onRecover, fn)
+}
+
+// Do chạy fn qua breaker
+func (b *CircuitBreaker) Do(fn func() error) error {
+	if err := b.Allow(); err != nil {
+		return err
+	}
+	err := fn()
+	b.Record(err)
+	return err
+}
+
+func (b *CircuitBreaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{Name: b.name, State: b.state, Failures: b.failures}
+	if b.lastErr != nil {
+		status.LastError = b.lastErr.Error()
+	}
+	if b.state != BreakerClosed {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+	return status
+}
+
+// StartProbe định kỳ gọi probe khi breaker đang mở, để hồi phục kể cả khi không có request nào
+func (b *CircuitBreaker) StartProbe(ctx context.Context, interval time.Duration, probe func(context.Context) error) {
+	BackgroundWorkers.Go(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if b.Status().State == BreakerClosed {
+					continue
+				}
+				_ = b.Do(func() error {
+					probeCtx, cancel := context.WithTimeout(ctx, interval)
+					defer cancel()
+					return probe(probeCtx)
+				})
+			}
+		}
+	})
+}
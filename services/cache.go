@@ -0,0 +1,316 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Cache lưu dữ liệu dạng JSON theo key, mỗi key có thể gắn nhiều tag.
+// Xóa một tag sẽ xóa toàn bộ key đã gắn tag đó.
+type Cache interface {
+	// Get trả về false nếu key không có trong cache
+	Get(ctx context.Context, key string, dest interface{}) (bool, error)
+	Set(ctx context.Context, key string, value interface{}, ttl time.Duration, tags ...string) error
+	Delete(ctx context.Context, keys ...string) error
+	InvalidateTags(ctx context.Context, tags ...string) error
+	// Flush xóa toàn bộ dữ liệu trong namespace của cache
+	Flush(ctx context.Context) error
+}
+
+// Tag cho một bản ghi cụ thể, vd: EntityTag("accommodation", 42) = "accommodation:42"
+func EntityTag(entity string, id interface{}) string {
+	return fmt.Sprintf("%s:%v", entity, id)
+}
+
+// Tag cho mọi danh sách của một loại dữ liệu, dùng khi thêm mới bản ghi
+func ListTag(entity string) string {
+	return entity + ":list"
+}
+
+// Tag set sống ít nhất bằng thời gian này để không mất liên kết với key còn hạn
+const minTagTTL = 24 * time.Hour
+
+type redisCache struct {
+	client    *redis.Client
+	namespace string
+}
+
+func NewRedisCache(client *redis.Client, namespace string) Cache {
+	return &redisCache{client: client, namespace: namespace}
+}
+
+func (r *redisCache) key(key string) string {
+	return r.namespace + ":cache:" + key
+}
+
+func (r *redisCache) tagKey(tag string) string {
+	return r.namespace + ":tag:" + tag
+}
+
+func (r *redisCache) Get(ctx context.Context, key string, dest interface{}) (bool, error) {
+	data, err := r.client.Get(ctx, r.key(key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(data, dest); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *redisCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration, tags ...string) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	tagTTL := ttl
+	if tagTTL < minTagTTL {
+		tagTTL = minTagTTL
+	}
+
+	fullKey := r.key(key)
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, fullKey, data, ttl)
+		for _, tag := range tags {
+			pipe.SAdd(ctx, r.tagKey(tag), fullKey)
+			pipe.Expire(ctx, r.tagKey(tag), tagTTL)
+		}
+		return nil
+	})
+	return err
+}
+
+func (r *redisCache) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	fullKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		fullKeys = append(fullKeys, r.key(key))
+	}
+	return r.client.Del(ctx, fullKeys...).Err()
+}
+
+func (r *redisCache) InvalidateTags(ctx context.Context, tags ...string) error {
+	for _, tag := range tags {
+		tagKey := r.tagKey(tag)
+		members, err := r.client.SMembers(ctx, tagKey).Result()
+		if err != nil {
+			return err
+		}
+		if err := r.client.Del(ctx, append(members, tagKey)...).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *redisCache) Flush(ctx context.Context) error {
+	iter := r.client.Scan(ctx, 0, r.namespace+":*", 500).Iterator()
+	keys := make([]string, 0, 500)
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) == cap(keys) {
+			if err := r.client.Del(ctx, keys...).Err(); err != nil {
+				return err
+			}
+			keys = keys[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		return r.client.Del(ctx, keys...).Err()
+	}
+	return nil
+}
+
+type memoryCacheEntry struct {
+	data      []byte
+	expiresAt time.Time
+}
+
+// memoryCache dùng khi không có Redis và trong kiểm thử
+type memoryCache struct {
+	mu      sync.Mutex
+	entries map[string]memoryCacheEntry
+	tags    map[string]map[string]struct{}
+}
+
+func NewMemoryCache() Cache {
+	return &memoryCache{
+		entries: map[string]memoryCacheEntry{},
+		tags:    map[string]map[string]struct{}{},
+	}
+}
+
+func (m *memoryCache) Get(ctx context.Context, key string, dest interface{}) (bool, error) {
+	m.mu.Lock()
+	entry, ok := m.entries[key]
+	if ok && !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		delete(m.entries, key)
+		ok = false
+	}
+	m.mu.Unlock()
+
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal(entry.data, dest); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (m *memoryCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration, tags ...string) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry := memoryCacheEntry{data: data}
+	if ttl > 0 {
+		entry.expiresAt = time.Now().Add(ttl)
+	}
+	m.entries[key] = entry
+	for _, tag := range tags {
+		if m.tags[tag] == nil {
+			m.tags[tag] = map[string]struct{}{}
+		}
+		m.tags[tag][key] = struct{}{}
+	}
+	return nil
+}
+
+func (m *memoryCache) Delete(ctx context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range keys {
+		delete(m.entries, key)
+	}
+	return nil
+}
+
+func (m *memoryCache) InvalidateTags(ctx context.Context, tags ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, tag := range tags {
+		for key := range m.tags[tag] {
+			delete(m.entries, key)
+		}
+		delete(m.tags, tag)
+	}
+	return nil
+}
+
+func (m *memoryCache) Flush(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = map[string]memoryCacheEntry{}
+	m.tags = map[string]map[string]struct{}{}
+	return nil
+}
+
+// breakerCache bọc cache phía sau circuit breaker: khi cache lỗi thì coi như không có dữ liệu
+// để request đọc thẳng từ DB, thay vì trả lỗi cho người dùng.
+type breakerCache struct {
+	inner   Cache
+	breaker *CircuitBreaker
+
+	mu    sync.Mutex
+	dirty bool // có thao tác xóa bị bỏ qua khi cache lỗi
+}
+
+// NewBreakerCache: khi cache hồi phục sau sự cố, toàn bộ dữ liệu cũ được xóa
+// vì các lần xóa theo tag trong lúc sự cố đã bị bỏ qua.
+func NewBreakerCache(inner Cache, breaker *CircuitBreaker) Cache {
+	c := &breakerCache{inner: inner, breaker: breaker}
+	breaker.OnRecover(c.flushIfDirty)
+	return c
+}
+
+func (c *breakerCache) markDirty() {
+	c.mu.Lock()
+	c.dirty = true
+	c.mu.Unlock()
+}
+
+func (c *breakerCache) flushIfDirty() {
+	c.mu.Lock()
+	dirty := c.dirty
+	c.dirty = false
+	c.mu.Unlock()
+	if !dirty {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := c.inner.Flush(ctx); err != nil {
+		slog.Warn("Không thể xóa cache cũ sau sự cố", "error", err)
+		c.markDirty()
+	}
+}
+
+func (c *breakerCache) Get(ctx context.Context, key string, dest interface{}) (bool, error) {
+	if c.breaker.Allow() != nil {
+		return false, nil
+	}
+	found, err := c.inner.Get(ctx, key, dest)
+	c.breaker.Record(err)
+	return found, err
+}
+
+func (c *breakerCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration, tags ...string) error {
+	if c.breaker.Allow() != nil {
+		return nil
+	}
+	err := c.inner.Set(ctx, key, value, ttl, tags...)
+	c.breaker.Record(err)
+	return err
+}
+
+func (c *breakerCache) Delete(ctx context.Context, keys ...string) error {
+	err := c.breaker.Do(func() error { return c.inner.Delete(ctx, keys...) })
+	if err != nil {
+		c.markDirty()
+	}
+	if errors.Is(err, ErrCircuitOpen) {
+		return nil
+	}
+	return err
+}
+
+func (c *breakerCache) InvalidateTags(ctx context.Context, tags ...string) error {
+	err := c.breaker.Do(func() error { return c.inner.InvalidateTags(ctx, tags...) })
+	if err != nil {
+		c.markDirty()
+	}
+	if errors.Is(err, ErrCircuitOpen) {
+		return nil
+	}
+	return err
+}
+
+func (c *breakerCache) Flush(ctx context.Context) error {
+	return c.breaker.Do(func() error { return c.inner.Flush(ctx) })
+}
+
+func (c *breakerCache) Status() BreakerStatus {
+	return c.breaker.Status()
+}
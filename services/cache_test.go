@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheInvalidateTags(t *testing.T) {
+	ctx := context.Background()
+	keys := []struct {
+		key  string
+		tags []string
+	}{
+		{"accommodation:1:detail", []string{EntityTag("accommodation", 1), EntityTag("user", 9)}},
+		{"accommodation:2:detail", []string{EntityTag("accommodation", 2)}},
+		{"accommodations:page", []string{ListTag("accommodation"), EntityTag("accommodation", 1), EntityTag("accommodation", 2)}},
+		{"benefits:all", []string{ListTag("benefit")}},
+	}
+
+	tests := []struct {
+		name      string
+		tags      []string
+		remaining []string
+	}{
+		{"tag của một chỗ ở xóa chi tiết và trang chứa nó", []string{EntityTag("accommodation", 1)}, []string{"accommodation:2:detail", "benefits:all"}},
+		{"tag danh sách chỉ xóa danh sách", []string{ListTag("accommodation")}, []string{"accommodation:1:detail", "accommodation:2:detail", "benefits:all"}},
+		{"nhiều tag cùng lúc", []string{EntityTag("user", 9), ListTag("benefit")}, []string{"accommodation:2:detail", "accommodations:page"}},
+		{"tag không có key", []string{EntityTag("room", 1)}, []string{"accommodation:1:detail", "accommodation:2:detail", "accommodations:page", "benefits:all"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewMemoryCache()
+			for _, k := range keys {
+				if err := cache.Set(ctx, k.key, k.key, time.Hour, k.tags...); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := cache.InvalidateTags(ctx, tt.tags...); err != nil {
+				t.Fatal(err)
+			}
+
+			remaining := map[string]bool{}
+			for _, key := range tt.remaining {
+				remaining[key] = true
+			}
+			for _, k := range keys {
+				var value string
+				found, err := cache.Get(ctx, k.key, &value)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if found != remaining[k.key] {
+					t.Errorf("key %s found = %v, want %v", k.key, found, remaining[k.key])
+				}
+				if found && value != k.key {
+					t.Errorf("key %s = %q", k.key, value)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryCacheExpiry(t *testing.T) {
+	ctx := context.Background()
+	cache := NewMemoryCache()
+	if err := cache.Set(ctx, "short", 1, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Set(ctx, "forever", 2, 0); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	var value int
+	if found, _ := cache.Get(ctx, "short", &value); found {
+		t.Error("expired key is still returned")
+	}
+	if found, _ := cache.Get(ctx, "forever", &value); !found || value != 2 {
+		t.Errorf("key without ttl = %v, %d; want true, 2", found, value)
+	}
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"new/config"
+)
+
+var ErrAddressNotFound = errors.New("không tìm thấy tọa độ của địa chỉ")
+
+// Coordinates là tọa độ WGS84 của một địa chỉ
+type Coordinates struct {
+	Longitude float64 `json:"longitude"`
+	Latitude  float64 `json:"latitude"`
+}
+
+// Valid kiểm tra tọa độ nằm trong phạm vi hợp lệ, (0, 0) được coi là chưa có tọa độ
+func (c Coordinates) Valid() bool {
+	return c.Latitude >= -90 && c.Latitude <= 90 && c.Longitude >= -180 && c.Longitude <= 180 &&
+		(c.Latitude != 0 || c.Longitude != 0)
+}
+
+// Address là địa chỉ cần tìm tọa độ, các phần trống được bỏ qua
+type Address struct {
+	Street   string
+	Ward     string
+	District string
+	Province string
+}
+
+func (a Address) String() string {
+	var parts []string
+	for _, part := range []string{a.Street, a.Ward, a.District, a.Province} {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Normalized là địa chỉ viết thường, bỏ dấu câu và khoảng trắng thừa, dùng làm key cache
+// để "12 Lê Lợi,  Quận 1" và "12 lê lợi, quận 1" dùng chung kết quả
+func (a Address) Normalized() string {
+	fields := strings.FieldsFunc(strings.ToLower(a.String()), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && !unicode.Is(unicode.Mn, r)
+	})
+	return strings.Join(fields, " ")
+}
+
+// Geocoder chuyển địa chỉ thành tọa độ, trả về ErrAddressNotFound nếu không có kết quả
+type Geocoder interface {
+	Geocode(ctx context.Context, address Address) (Coordinates, error)
+}
+
+// NewGeocoder tạo geocoder theo cấu hình: các nhà cung cấp nối tiếp nhau làm dự phòng, kết quả được lưu trong cache
+func NewGeocoder(cfg config.GeocoderConfig, mapbox config.MapboxConfig, cache Cache) Geocoder {
+	client := &http.Client{Timeout: cfg.Timeout}
+	var providers []Geocoder
+	for _, name := range cfg.Providers {
+		switch name {
+		case "mapbox":
+			providers = append(providers, NewMapboxGeocoder(mapbox.AccessToken.Value(), client))
+		case "nominatim":
+			providers = append(providers, NewNominatimGeocoder(cfg.NominatimURL, cfg.NominatimUserAgent, client))
+		case "stub":
+			providers = append(providers, &StubGeocoder{})
+		}
+	}
+	return NewCachedGeocoder(FallbackGeocoder(providers), cache, cfg.CacheTTL)
+}
+
+// FallbackGeocoder thử lần lượt từng nhà cung cấp tới khi có kết quả
+type FallbackGeocoder []Geocoder
+
+func (f FallbackGeocoder) Geocode(ctx context.Context, address Address) (Coordinates, error) {
+	err := ErrAddressNotFound
+	for _, provider := range f {
+		coordinates, providerErr := provider.Geocode(ctx, address)
+		if providerErr == nil {
+			return coordinates, nil
+		}
+		if ctx.Err() != nil {
+			return Coordinates{}, ctx.Err()
+		}
+		if !errors.Is(providerErr, ErrAddressNotFound) {
+			Logger(ctx).Warn("Lỗi khi gọi dịch vụ geocoding", "provider", fmt.Sprintf("%T", provider), "error", providerErr)
+			err = providerErr
+		}
+	}
+	return Coordinates{}, err
+}
+
+// Địa chỉ không tìm thấy được lưu ngắn hạn để không gọi lại liên tục, nhưng vẫn sớm thử lại
+const geocodeNotFoundTTL = 24 * time.Hour
+
+// CachedGeocoder lưu kết quả theo địa chỉ đã chuẩn hóa, lỗi cache được coi như không có dữ liệu
+type CachedGeocoder struct {
+	next  Geocoder
+	cache Cache
+	ttl   time.Duration
+}
+
+type cachedCoordinates struct {
+	Coordinates
+	Found bool `json:"found"`
+}
+
+func NewCachedGeocoder(next Geocoder, cache Cache, ttl time.Duration) *CachedGeocoder {
+	return &CachedGeocoder{next: next, cache: cache, ttl: ttl}
+}
+
+func geocodeCacheKey(address Address) string {
+	sum := sha256.Sum256([]byte(address.Normalized()))
+	return "geocode:" + hex.EncodeToString(sum[:16])
+}
+
+func (g *CachedGeocoder) Geocode(ctx context.Context, address Address) (Coordinates, error) {
+	if address.Normalized() == "" {
+		return Coordinates{}, ErrAddressNotFound
+	}
+	key := geocodeCacheKey(address)
+	var cached cachedCoordinates
+	if found, err := g.cache.Get(ctx, key, &cached); err != nil {
+		Logger(ctx).Warn("Lỗi khi đọc cache geocoding", "error", err)
+	} else if found {
+		if !cached.Found {
+			return Coordinates{}, ErrAddressNotFound
+		}
+		return cached.Coordinates, nil
+	}
+
+	coordinates, err := g.next.Geocode(ctx, address)
+	switch {
+	case err == nil:
+		if setErr := g.cache.Set(ctx, key, cachedCoordinates{Coordinates: coordinates, Found: true}, g.ttl); setErr != nil {
+			Logger(ctx).Warn("Lỗi khi lưu cache geocoding", "error", setErr)
+		}
+	case errors.Is(err, ErrAddressNotFound):
+		if setErr := g.cache.Set(ctx, key, cachedCoordinates{}, geocodeNotFoundTTL); setErr != nil {
+			Logger(ctx).Warn("Lỗi khi lưu cache geocoding", "error", setErr)
+		}
+	}
+	return coordinates, err
+}
+
+// StubGeocoder trả tọa độ cố định theo địa chỉ đã chuẩn hóa, dùng khi test hoặc chạy không có mạng
+type StubGeocoder struct {
+	Results map[string]Coordinates // key là Address.Normalized()
+	Err     error                  // Nếu khác nil, mọi lời gọi trả về lỗi này
+}
+
+func (s *StubGeocoder) Geocode(_ context.Context, address Address) (Coordinates, error) {
+	if s.Err != nil {
+		return Coordinates{}, s.Err
+	}
+	if coordinates, ok := s.Results[address.Normalized()]; ok {
+		return coordinates, nil
+	}
+	return Coordinates{}, ErrAddressNotFound
+}
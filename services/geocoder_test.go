@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// countingGeocoder đếm số lần được gọi để kiểm tra cache
+type countingGeocoder struct {
+	next  Geocoder
+	calls int
+}
+
+func (c *countingGeocoder) Geocode(ctx context.Context, address Address) (Coordinates, error) {
+	c.calls++
+	return c.next.Geocode(ctx, address)
+}
+
+var (
+	leLoi       = Address{Street: "12 Lê Lợi", District: "Quận 1", Province: "Hồ Chí Minh"}
+	leLoiCoords = Coordinates{Longitude: 106.7009, Latitude: 10.7769}
+	errProvider = errors.New("provider unavailable")
+)
+
+func TestAddressNormalized(t *testing.T) {
+	tests := []struct {
+		address Address
+		want    string
+	}{
+		{leLoi, "12 lê lợi quận 1 hồ chí minh"},
+		{Address{Street: "  12 LÊ LỢI,, ", District: "Quận   1", Province: "Hồ Chí Minh."}, "12 lê lợi quận 1 hồ chí minh"},
+		{Address{Ward: " ", Province: ""}, ""},
+	}
+	for _, tt := range tests {
+		if got := tt.address.Normalized(); got != tt.want {
+			t.Errorf("Normalized(%+v) = %q, want %q", tt.address, got, tt.want)
+		}
+	}
+}
+
+func TestFallbackGeocoder(t *testing.T) {
+	found := &StubGeocoder{Results: map[string]Coordinates{leLoi.Normalized(): leLoiCoords}}
+	tests := []struct {
+		name      string
+		providers FallbackGeocoder
+		want      Coordinates
+		wantErr   error
+	}{
+		{"nhà cung cấp đầu tiên có kết quả", FallbackGeocoder{found, &StubGeocoder{Err: errProvider}}, leLoiCoords, nil},
+		{"bỏ qua nhà cung cấp lỗi", FallbackGeocoder{&StubGeocoder{Err: errProvider}, found}, leLoiCoords, nil},
+		{"bỏ qua nhà cung cấp không tìm thấy", FallbackGeocoder{&StubGeocoder{}, found}, leLoiCoords, nil},
+		{"không ai tìm thấy", FallbackGeocoder{&StubGeocoder{}, &StubGeocoder{}}, Coordinates{}, ErrAddressNotFound},
+		{"lỗi được ưu tiên hơn không tìm thấy", FallbackGeocoder{&StubGeocoder{Err: errProvider}, &StubGeocoder{}}, Coordinates{}, errProvider},
+		{"không có nhà cung cấp", FallbackGeocoder{}, Coordinates{}, ErrAddressNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.providers.Geocode(context.Background(), leLoi)
+			if got != tt.want || !errors.Is(err, tt.wantErr) {
+				t.Errorf("Geocode = %v, %v; want %v, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCachedGeocoder(t *testing.T) {
+	stub := &StubGeocoder{Results: map[string]Coordinates{leLoi.Normalized(): leLoiCoords}}
+	unknown := Address{Street: "1 Đường Không Có", Province: "Hà Nội"}
+
+	tests := []struct {
+		name      string
+		next      *StubGeocoder
+		addresses []Address // Gọi lần lượt, cùng một địa chỉ đã chuẩn hóa
+		want      Coordinates
+		wantErr   error
+		wantCalls int
+	}{
+		{"kết quả được lưu theo địa chỉ chuẩn hóa", stub, []Address{leLoi, {Street: "12 LÊ LỢI", District: "quận 1", Province: "Hồ Chí Minh"}}, leLoiCoords, nil, 1},
+		{"không tìm thấy cũng được lưu", stub, []Address{unknown, unknown}, Coordinates{}, ErrAddressNotFound, 1},
+		{"lỗi nhà cung cấp không được lưu", &StubGeocoder{Err: errProvider}, []Address{leLoi, leLoi}, Coordinates{}, errProvider, 2},
+		{"địa chỉ rỗng không gọi nhà cung cấp", stub, []Address{{}, {Street: " , "}}, Coordinates{}, ErrAddressNotFound, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := &countingGeocoder{next: tt.next}
+			geocoder := NewCachedGeocoder(next, NewMemoryCache(), time.Hour)
+			for _, address := range tt.addresses {
+				got, err := geocoder.Geocode(context.Background(), address)
+				if got != tt.want || !errors.Is(err, tt.wantErr) {
+					t.Errorf("Geocode(%+v) = %v, %v; want %v, %v", address, got, err, tt.want, tt.wantErr)
+				}
+			}
+			if next.calls != tt.wantCalls {
+				t.Errorf("provider called %d times, want %d", next.calls, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HealthCheck là một phụ thuộc được kiểm tra trong /readyz.
+// Critical = true thì phụ thuộc lỗi làm dịch vụ chưa sẵn sàng, ngược lại chỉ bị "degraded".
+type HealthCheck struct {
+	Name     string
+	Critical bool
+	Check    func(ctx context.Context) error
+}
+
+type HealthResult struct {
+	Name      string `json:"name"`
+	State     string `json:"state"` // up hoặc down
+	Critical  bool   `json:"critical"`
+	LatencyMs int64  `json:"latencyMs"`
+	Error     string `json:"error,omitempty"`
+}
+
+// RunHealthChecks chạy song song các kiểm tra, mỗi kiểm tra có timeout riêng
+func RunHealthChecks(ctx context.Context, timeout time.Duration, checks []HealthCheck) []HealthResult {
+	results := make([]HealthResult, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check HealthCheck) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check.Check(checkCtx)
+			results[i] = HealthResult{Name: check.Name, State: "up", Critical: check.Critical, LatencyMs: time.Since(start).Milliseconds()}
+			if err != nil {
+				results[i].State = "down"
+				results[i].Error = err.Error()
+			}
+		}(i, check)
+	}
+	wg.Wait()
+	return results
+}
+
+// HTTPReachable kiểm tra dịch vụ bên ngoài còn phản hồi, chỉ lỗi 5xx hoặc lỗi mạng bị coi là down
+func HTTPReachable(url string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("HTTP %d", resp.StatusCode)
+		}
+		return nil
+	}
+}
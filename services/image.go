@@ -0,0 +1,249 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+)
+
+var (
+	ErrFileTooLarge     = errors.New("file vượt quá dung lượng cho phép")
+	ErrUnsupportedImage = errors.New("chỉ nhận ảnh JPEG hoặc PNG")
+	ErrImageTooLarge    = errors.New("ảnh có kích thước quá lớn")
+)
+
+// ImageLimits giới hạn ảnh upload, lấy từ config.UploadConfig
+type ImageLimits struct {
+	MaxBytes      int64
+	MaxPixels     int
+	MaxDimension  int
+	ThumbnailSize int
+}
+
+// ProcessedImage là ảnh đã mã hóa lại, sẵn sàng lưu vào Storage
+type ProcessedImage struct {
+	Data        []byte
+	ContentType string
+	Ext         string
+	Width       int
+	Height      int
+}
+
+// ProcessImage đọc ảnh upload và trả về ảnh gốc cùng ảnh thu nhỏ:
+// định dạng được nhận diện theo nội dung file (không tin Content-Type hay đuôi file), ảnh JPEG được xoay
+// theo EXIF Orientation, ảnh vượt MaxDimension được thu nhỏ. Ảnh luôn được mã hóa lại nên EXIF (vị trí GPS,
+// thiết bị chụp...) và mọi metadata khác bị loại bỏ.
+func ProcessImage(r io.Reader, limits ImageLimits) (original ProcessedImage, thumbnail ProcessedImage, err error) {
+	data, err := io.ReadAll(io.LimitReader(r, limits.MaxBytes+1))
+	if err != nil {
+		return original, thumbnail, err
+	}
+	if int64(len(data)) > limits.MaxBytes {
+		return original, thumbnail, ErrFileTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+	if contentType != "image/jpeg" && contentType != "image/png" {
+		return original, thumbnail, ErrUnsupportedImage
+	}
+
+	// Kiểm tra kích thước trước khi giải nén toàn bộ ảnh
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return original, thumbnail, ErrUnsupportedImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > limits.MaxPixels {
+		return original, thumbnail, ErrImageTooLarge
+	}
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return original, thumbnail, ErrUnsupportedImage
+	}
+
+	img := toRGBA(decoded)
+	if contentType == "image/jpeg" {
+		img = orient(img, jpegOrientation(data))
+	}
+	img = fit(img, limits.MaxDimension)
+
+	if original, err = encodeImage(img, contentType); err != nil {
+		return original, thumbnail, err
+	}
+	thumbnail, err = encodeImage(fit(img, limits.ThumbnailSize), contentType)
+	return original, thumbnail, err
+}
+
+func encodeImage(img *image.RGBA, contentType string) (ProcessedImage, error) {
+	var buf bytes.Buffer
+	result := ProcessedImage{ContentType: contentType, Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}
+	var err error
+	if contentType == "image/png" {
+		result.Ext = ".png"
+		err = png.Encode(&buf, img)
+	} else {
+		result.Ext = ".jpg"
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+	}
+	result.Data = buf.Bytes()
+	return result, err
+}
+
+func toRGBA(src image.Image) *image.RGBA {
+	bounds := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, bounds.Min, draw.Src)
+	return dst
+}
+
+// fit thu nhỏ ảnh để cạnh dài nhất không quá size, giữ tỉ lệ; ảnh đã nhỏ hơn được giữ nguyên
+func fit(src *image.RGBA, size int) *image.RGBA {
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	if w <= size && h <= size {
+		return src
+	}
+	if w >= h {
+		h = max(1, h*size/w)
+		w = size
+	} else {
+		w = max(1, w*size/h)
+		h = size
+	}
+	return resize(src, w, h)
+}
+
+// resize thu nhỏ bằng cách lấy trung bình các điểm ảnh gốc rơi vào mỗi điểm ảnh mới (box filter)
+func resize(src *image.RGBA, w, h int) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0, y1 := y*sh/h, max((y+1)*sh/h, y*sh/h+1)
+		for x := 0; x < w; x++ {
+			x0, x1 := x*sw/w, max((x+1)*sw/w, x*sw/w+1)
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r += uint64(p[0])
+					g += uint64(p[1])
+					b += uint64(p[2])
+					a += uint64(p[3])
+					n++
+				}
+			}
+			d := dst.Pix[y*dst.Stride+x*4 : y*dst.Stride+x*4+4]
+			d[0], d[1], d[2], d[3] = uint8(r/n), uint8(g/n), uint8(b/n), uint8(a/n)
+		}
+	}
+	return dst
+}
+
+// orient xoay/lật ảnh theo giá trị EXIF Orientation (1-8) để ảnh hiển thị đúng chiều sau khi bỏ EXIF
+func orient(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		// Các giá trị 5-8 xoay 90 độ nên đổi chiều rộng và cao
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // Lật ngang
+				sx, sy = w-1-x, y
+			case 3: // Xoay 180 độ
+				sx, sy = w-1-x, h-1-y
+			case 4: // Lật dọc
+				sx, sy = x, h-1-y
+			case 5: // Chuyển vị
+				sx, sy = y, x
+			case 6: // Xoay 90 độ theo chiều kim đồng hồ
+				sx, sy = y, h-1-x
+			case 7: // Chuyển vị ngược
+				sx, sy = w-1-y, h-1-x
+			case 8: // Xoay 90 độ ngược chiều kim đồng hồ
+				sx, sy = w-1-y, x
+			}
+			copy(dst.Pix[y*dst.Stride+x*4:y*dst.Stride+x*4+4], src.Pix[sy*src.Stride+sx*4:sy*src.Stride+sx*4+4])
+		}
+	}
+	return dst
+}
+
+// jpegOrientation đọc thẻ Orientation trong segment APP1 (EXIF) của ảnh JPEG, không có thì trả về 1
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		switch {
+		case marker == 0xFF: // Byte đệm
+			i++
+			continue
+		case marker == 0xDA || marker == 0xD9: // Bắt đầu dữ liệu ảnh hoặc hết file, EXIF luôn nằm trước
+			return 1
+		case marker >= 0xD0 && marker <= 0xD7 || marker == 0x01: // Marker không có dữ liệu
+			i += 2
+			continue
+		}
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if size < 2 || i+2+size > len(data) {
+			return 1
+		}
+		segment := data[i+4 : i+2+size]
+		if marker == 0xE1 && len(segment) > 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return exifOrientation(segment[6:])
+		}
+		i += 2 + size
+	}
+	return 1
+}
+
+// exifOrientation tìm thẻ 0x0112 trong IFD0 của khối TIFF
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	offset := int(order.Uint32(tiff[4:]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	for n := 0; n < count; n++ {
+		entry := offset + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			if value := int(order.Uint16(tiff[entry+8:])); value >= 1 && value <= 8 {
+				return value
+			}
+			return 1
+		}
+	}
+	return 1
+}
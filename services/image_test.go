@@ -0,0 +1,160 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+var testLimits = ImageLimits{MaxBytes: 1 << 20, MaxPixels: 1 << 20, MaxDimension: 200, ThumbnailSize: 20}
+
+// halfImage tạo ảnh w x h, nửa trái màu đỏ, nửa phải màu xanh dương
+func halfImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if x < w/2 {
+				img.Set(x, y, color.RGBA{R: 255, A: 255})
+			} else {
+				img.Set(x, y, color.RGBA{B: 255, A: 255})
+			}
+		}
+	}
+	return img
+}
+
+// exifJPEG mã hóa img thành JPEG có segment EXIF chỉ chứa thẻ Orientation
+func exifJPEG(t *testing.T, img image.Image, orientation uint16) []byte {
+	t.Helper()
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	tiff = binary.BigEndian.AppendUint16(tiff, 1)      // Số thẻ trong IFD0
+	tiff = binary.BigEndian.AppendUint16(tiff, 0x0112) // Orientation
+	tiff = binary.BigEndian.AppendUint16(tiff, 3)      // SHORT
+	tiff = binary.BigEndian.AppendUint32(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0) // Đệm giá trị và offset IFD tiếp theo
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xFF, 0xE1}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	data := encoded.Bytes()
+	return append(append(append([]byte{}, data[:2]...), segment...), data[2:]...)
+}
+
+func pngBytes(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decode(t *testing.T, data []byte) image.Image {
+	t.Helper()
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func isRed(c color.Color) bool {
+	r, _, b, _ := c.RGBA()
+	return r > 0xC000 && b < 0x4000
+}
+
+func TestProcessImage(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          []byte
+		contentType   string
+		width, height int
+		thumbW        int
+		thumbH        int
+		redAt         image.Point // Điểm ảnh phải có màu đỏ sau khi xoay
+	}{
+		{"PNG giữ nguyên kích thước", pngBytes(t, halfImage(40, 20)), "image/png", 40, 20, 20, 10, image.Pt(2, 10)},
+		{"PNG thu nhỏ theo MaxDimension", pngBytes(t, halfImage(400, 100)), "image/png", 200, 50, 20, 5, image.Pt(2, 25)},
+		{"JPEG không xoay", exifJPEG(t, halfImage(40, 20), 1), "image/jpeg", 40, 20, 20, 10, image.Pt(2, 10)},
+		{"JPEG xoay 180 độ", exifJPEG(t, halfImage(40, 20), 3), "image/jpeg", 40, 20, 20, 10, image.Pt(37, 10)},
+		{"JPEG xoay 90 độ theo chiều kim đồng hồ", exifJPEG(t, halfImage(40, 20), 6), "image/jpeg", 20, 40, 10, 20, image.Pt(10, 2)},
+		{"JPEG xoay 90 độ ngược chiều kim đồng hồ", exifJPEG(t, halfImage(40, 20), 8), "image/jpeg", 20, 40, 10, 20, image.Pt(10, 37)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original, thumbnail, err := ProcessImage(bytes.NewReader(tt.data), testLimits)
+			if err != nil {
+				t.Fatalf("ProcessImage: %v", err)
+			}
+			if original.ContentType != tt.contentType || thumbnail.ContentType != tt.contentType {
+				t.Errorf("content type = %s/%s, want %s", original.ContentType, thumbnail.ContentType, tt.contentType)
+			}
+			if original.Width != tt.width || original.Height != tt.height {
+				t.Errorf("original = %dx%d, want %dx%d", original.Width, original.Height, tt.width, tt.height)
+			}
+			if thumbnail.Width != tt.thumbW || thumbnail.Height != tt.thumbH {
+				t.Errorf("thumbnail = %dx%d, want %dx%d", thumbnail.Width, thumbnail.Height, tt.thumbW, tt.thumbH)
+			}
+			img := decode(t, original.Data)
+			if b := img.Bounds(); b.Dx() != tt.width || b.Dy() != tt.height {
+				t.Errorf("decoded = %dx%d, want %dx%d", b.Dx(), b.Dy(), tt.width, tt.height)
+			}
+			if !isRed(img.At(tt.redAt.X, tt.redAt.Y)) {
+				t.Errorf("pixel %v = %v, want red", tt.redAt, img.At(tt.redAt.X, tt.redAt.Y))
+			}
+			// Ảnh được mã hóa lại nên không còn EXIF
+			if bytes.Contains(original.Data, []byte("Exif\x00\x00")) || bytes.Contains(thumbnail.Data, []byte("Exif\x00\x00")) {
+				t.Error("processed image still contains EXIF data")
+			}
+		})
+	}
+}
+
+func TestProcessImageRejects(t *testing.T) {
+	small := pngBytes(t, halfImage(40, 20))
+	tests := []struct {
+		name   string
+		data   []byte
+		limits ImageLimits
+		want   error
+	}{
+		{"vượt dung lượng", small, ImageLimits{MaxBytes: int64(len(small) - 1), MaxPixels: 1 << 20, MaxDimension: 200, ThumbnailSize: 20}, ErrFileTooLarge},
+		{"không phải ảnh", []byte("GIF89a không phải JPEG hay PNG"), testLimits, ErrUnsupportedImage},
+		{"PNG hỏng", append([]byte{}, small[:40]...), testLimits, ErrUnsupportedImage},
+		{"quá nhiều điểm ảnh", small, ImageLimits{MaxBytes: 1 << 20, MaxPixels: 799, MaxDimension: 200, ThumbnailSize: 20}, ErrImageTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := ProcessImage(bytes.NewReader(tt.data), tt.limits); !errors.Is(err, tt.want) {
+				t.Errorf("ProcessImage error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	for orientation := uint16(1); orientation <= 8; orientation++ {
+		if got := jpegOrientation(exifJPEG(t, halfImage(4, 4), orientation)); got != int(orientation) {
+			t.Errorf("jpegOrientation = %d, want %d", got, orientation)
+		}
+	}
+	if got := jpegOrientation(exifJPEG(t, halfImage(4, 4), 9)); got != 1 {
+		t.Errorf("jpegOrientation with invalid value = %d, want 1", got)
+	}
+	if got := jpegOrientation(pngBytes(t, halfImage(4, 4))); got != 1 {
+		t.Errorf("jpegOrientation of PNG = %d, want 1", got)
+	}
+}
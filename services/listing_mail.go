@@ -0,0 +1,47 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"new/config"
+	"new/models"
+)
+
+// NotifyListingDecision báo cho chủ chỗ ở kết quả duyệt/tạm ngưng qua email.
+// Email được gửi nền, lỗi chỉ ghi log để không ảnh hưởng thao tác duyệt.
+func NotifyListingDecision(ctx context.Context, email, accommodationName string, status int, reason string) {
+	if email == "" {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	BackgroundWorkers.Go(func() {
+		if err := sendListingDecisionEmail(ctx, email, accommodationName, status, reason); err != nil {
+			Logger(ctx).Error("Không thể gửi email kết quả duyệt chỗ ở", "email", email, "error", err)
+		}
+	})
+}
+
+func sendListingDecisionEmail(ctx context.Context, email, accommodationName string, status int, reason string) error {
+	subject := fmt.Sprintf("Chỗ ở %s: %s", accommodationName, models.ListingStatusNames[status])
+	reasonHTML := ""
+	if reason != "" {
+		reasonHTML = fmt.Sprintf("<p>Lý do: <strong>%s</strong></p>", html.EscapeString(reason))
+	}
+	body := fmt.Sprintf(`<!DOCTYPE html>
+	<html>
+	<head>
+		<meta charset="UTF-8">
+		<title>Kết quả duyệt chỗ ở</title>
+	</head>
+	<body>
+		<p>Xin chào,</p>
+		<p>Chỗ ở <strong>%s</strong> của bạn đã chuyển sang trạng thái <strong>%s</strong>.</p>
+		%s
+		<p>Bạn có thể xem chi tiết tại <a href="%s">trang quản lý</a>.</p>
+		<p>Xin cảm ơn,<br>Nhóm kiểm duyệt</p>
+	</body>
+	</html>`, html.EscapeString(accommodationName), models.ListingStatusNames[status], reasonHTML, config.Cfg.FrontendURL)
+
+	return sendMail(ctx, email, subject, body)
+}
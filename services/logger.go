@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/url"
+	"strings"
+)
+
+const redacted = "******"
+
+// Các key chứa dữ liệu nhạy cảm, giá trị luôn bị che khi ghi log
+var sensitiveKeys = map[string]bool{
+	"password":      true,
+	"newpassword":   true,
+	"oldpassword":   true,
+	"token":         true,
+	"accesstoken":   true,
+	"refreshtoken":  true,
+	"idtoken":       true,
+	"access_token":  true,
+	"refresh_token": true,
+	"authorization": true,
+	"cookie":        true,
+	"secret":        true,
+	"otp":           true,
+	"code":          true,
+	"verifycode":    true,
+}
+
+// IsSensitiveKey không phân biệt hoa thường, vd: "Password", "refreshToken"
+func IsSensitiveKey(key string) bool {
+	return sensitiveKeys[strings.ToLower(key)]
+}
+
+// RedactQuery che giá trị của các tham số nhạy cảm trong query string (vd: ?token=...)
+func RedactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return redacted
+	}
+	for key := range values {
+		if IsSensitiveKey(key) {
+			values[key] = []string{redacted}
+		}
+	}
+	return values.Encode()
+}
+
+func redactAttr(groups []string, attr slog.Attr) slog.Attr {
+	if IsSensitiveKey(attr.Key) {
+		return slog.String(attr.Key, redacted)
+	}
+	return attr
+}
+
+// NewLogger tạo logger JSON, mọi attr có key nhạy cảm đều bị che
+func NewLogger(w io.Writer, level slog.Level) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redactAttr,
+	}))
+}
+
+type loggerKey struct{}
+
+// WithLogger gắn logger của request vào context
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// Logger trả về logger của request (có request_id, user_id...), không có thì dùng logger mặc định
+func Logger(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+			return logger
+		}
+	}
+	return slog.Default()
+}
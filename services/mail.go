@@ -0,0 +1,32 @@
+package services
+
+import (
+	"context"
+	"mime"
+	"net/smtp"
+	"new/config"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Hàm gửi email HTML qua SMTP theo cấu hình
+func sendMail(ctx context.Context, email string, subject string, body string) (err error) {
+	cfg := config.Cfg.SMTP
+	_, span := StartSpan(ctx, "smtp.send")
+	span.SetAttributes(attribute.String("server.address", cfg.Host))
+	defer func() { EndSpan(span, err) }()
+
+	headers := []string{
+		"From: " + cfg.From,
+		"To: " + email,
+		"Subject: " + mime.QEncoding.Encode("UTF-8", subject),
+		"MIME-Version: 1.0",
+		"Content-Type: text/html; charset=UTF-8",
+	}
+	msg := []byte(strings.Join(headers, "\r\n") + "\r\n\r\n" + body)
+
+	auth := smtp.PlainAuth("", cfg.Username, cfg.Password.Value(), cfg.Host)
+
+	return smtp.SendMail(cfg.Host+":"+cfg.Port, auth, cfg.From, []string{email}, msg)
+}
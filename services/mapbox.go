@@ -1,13 +1,16 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/goccy/go-json"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type GeocodingFeature struct {
@@ -26,7 +29,7 @@ func GetBestCoordinatesFromResponse(body io.Reader) (float64, float64, error) {
 	}
 
 	if len(response.Features) == 0 {
-		return 0, 0, errors.New("no results found")
+		return 0, 0, ErrAddressNotFound
 	}
 
 	// Chọn kết quả có relevance cao nhất
@@ -40,32 +43,52 @@ func GetBestCoordinatesFromResponse(body io.Reader) (float64, float64, error) {
 	return bestFeature.Center[0], bestFeature.Center[1], nil
 }
 
-// GetCoordinatesFromAddress gọi API và lấy tọa độ
-func GetCoordinatesFromAddress(address, district, province, ward, mapboxAccessToken string) (float64, float64, error) {
-	fullAddress := fmt.Sprintf("%s, %s, %s, %s", address, ward, district, province)
-	encodedAddress := url.QueryEscape(fullAddress)
-	log.Println("encodedAddress:", encodedAddress)
+// MapboxGeocoder gọi Mapbox Geocoding API, chỉ tìm trong Việt Nam
+type MapboxGeocoder struct {
+	accessToken string
+	baseURL     string
+	client      *http.Client
+}
+
+func NewMapboxGeocoder(accessToken string, client *http.Client) *MapboxGeocoder {
+	return &MapboxGeocoder{accessToken: accessToken, baseURL: "https://api.mapbox.com", client: client}
+}
+
+func (m *MapboxGeocoder) Geocode(ctx context.Context, address Address) (coordinates Coordinates, err error) {
+	// Không gắn URL vào span vì query chứa access_token
+	ctx, span := StartSpan(ctx, "mapbox.geocode")
+	span.SetAttributes(attribute.String("server.address", "api.mapbox.com"))
+	defer func() { EndSpan(span, err) }()
+
+	slog.Debug("Gọi Mapbox geocoding", "address", address.String())
 	apiURL := fmt.Sprintf(
-		"https://api.mapbox.com/geocoding/v5/mapbox.places/%s.json?access_token=%s&country=VN",
-		encodedAddress,
-		mapboxAccessToken,
+		"%s/geocoding/v5/mapbox.places/%s.json?access_token=%s&country=VN",
+		m.baseURL,
+		url.QueryEscape(address.String()),
+		url.QueryEscape(m.accessToken),
 	)
 
-	resp, err := http.Get(apiURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to send request: %w", err)
+		return coordinates, fmt.Errorf("failed to build request: %w", err)
 	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-
+	resp, err := m.client.Do(req)
+	if err != nil {
+		// Lỗi của net/http chứa URL, bỏ đi để access_token không lọt vào log
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
 		}
-	}(resp.Body)
+		return coordinates, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
 
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
 	if resp.StatusCode != http.StatusOK {
-		return 0, 0, fmt.Errorf("API returned status %d", resp.StatusCode)
+		return coordinates, fmt.Errorf("API returned status %d", resp.StatusCode)
 	}
 
 	// Lấy tọa độ tốt nhất
-	return GetBestCoordinatesFromResponse(resp.Body)
+	coordinates.Longitude, coordinates.Latitude, err = GetBestCoordinatesFromResponse(resp.Body)
+	return coordinates, err
 }
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// MetricsRegistry chứa mọi metric của ứng dụng, được xuất ra tại /metrics
+var MetricsRegistry = prometheus.NewRegistry()
+
+var (
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Thời gian xử lý request theo route, method và status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	CacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_requests_total",
+		Help: "Số lần đọc cache theo kết quả hit, miss hoặc error.",
+	}, []string{"result"})
+
+	OrdersCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "orders_created_total",
+		Help: "Số đơn đặt phòng được tạo.",
+	})
+
+	OrdersCancelled = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "orders_cancelled_total",
+		Help: "Số đơn đặt phòng bị hủy.",
+	})
+
+	RevenueRecorded = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "revenue_recorded_total",
+		Help: "Tổng giá trị hóa đơn đã ghi nhận (VND).",
+	})
+)
+
+func init() {
+	MetricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestDuration,
+		CacheRequests,
+		OrdersCreated,
+		OrdersCancelled,
+		RevenueRecorded,
+	)
+}
+
+// RegisterDBMetrics xuất thống kê connection pool của DB
+func RegisterDBMetrics(db *sql.DB, name string) {
+	MetricsRegistry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+type instrumentedCache struct {
+	Cache
+}
+
+// NewInstrumentedCache đếm hit/miss của cache cho /metrics
+func NewInstrumentedCache(inner Cache) Cache {
+	return instrumentedCache{Cache: inner}
+}
+
+func (c instrumentedCache) Get(ctx context.Context, key string, dest interface{}) (bool, error) {
+	found, err := c.Cache.Get(ctx, key, dest)
+	switch {
+	case err != nil:
+		CacheRequests.WithLabelValues("error").Inc()
+	case found:
+		CacheRequests.WithLabelValues("hit").Inc()
+	default:
+		CacheRequests.WithLabelValues("miss").Inc()
+	}
+	return found, err
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/goccy/go-json"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// NominatimGeocoder gọi API search của Nominatim (OpenStreetMap) hoặc dịch vụ tương thích.
+// Máy chủ công khai giới hạn 1 request/giây nên chỉ nên dùng làm dự phòng, kết quả luôn đi qua cache.
+type NominatimGeocoder struct {
+	baseURL   string
+	userAgent string
+	client    *http.Client
+}
+
+func NewNominatimGeocoder(baseURL, userAgent string, client *http.Client) *NominatimGeocoder {
+	return &NominatimGeocoder{baseURL: baseURL, userAgent: userAgent, client: client}
+}
+
+type nominatimPlace struct {
+	Lat string `json:"lat"`
+	Lon string `json:"lon"`
+}
+
+func (n *NominatimGeocoder) Geocode(ctx context.Context, address Address) (coordinates Coordinates, err error) {
+	ctx, span := StartSpan(ctx, "nominatim.geocode")
+	defer func() { EndSpan(span, err) }()
+
+	query := url.Values{}
+	query.Set("q", address.String())
+	query.Set("format", "jsonv2")
+	query.Set("countrycodes", "vn")
+	query.Set("limit", "1")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, n.baseURL+"/search?"+query.Encode(), nil)
+	if err != nil {
+		return coordinates, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("User-Agent", n.userAgent)
+	req.Header.Set("Accept-Language", "vi")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return coordinates, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode != http.StatusOK {
+		return coordinates, fmt.Errorf("API returned status %d", resp.StatusCode)
+	}
+
+	var places []nominatimPlace
+	if err := json.NewDecoder(resp.Body).Decode(&places); err != nil {
+		return coordinates, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if len(places) == 0 {
+		return coordinates, ErrAddressNotFound
+	}
+	if coordinates.Latitude, err = strconv.ParseFloat(places[0].Lat, 64); err != nil {
+		return coordinates, fmt.Errorf("invalid latitude %q: %w", places[0].Lat, err)
+	}
+	if coordinates.Longitude, err = strconv.ParseFloat(places[0].Lon, 64); err != nil {
+		return coordinates, fmt.Errorf("invalid longitude %q: %w", places[0].Lon, err)
+	}
+	return coordinates, nil
+}
@@ -0,0 +1,385 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"new/config"
+	"new/models"
+	"regexp"
+	"sync"
+	"time"
+
+	"google.golang.org/api/idtoken"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnknownProvider   = errors.New("Phương thức đăng nhập không được hỗ trợ")
+	ErrInvalidSocialAuth = errors.New("Token đăng nhập không hợp lệ")
+	ErrIdentityLinked    = errors.New("Tài khoản này đã được liên kết với người dùng khác")
+	ErrLastLoginMethod   = errors.New("Không thể hủy liên kết phương thức đăng nhập duy nhất")
+	ErrPhoneUsed         = errors.New("Số điện thoại đã được sử dụng")
+	ErrInvalidPhone      = errors.New("Số điện thoại không hợp lệ")
+	ErrStaffSocialLogin  = errors.New("Tài khoản quản trị phải đăng nhập bằng mật khẩu và mã xác thực")
+	ErrUnverifiedAccount = errors.New("Email đã được dùng cho một tài khoản chưa xác thực, hãy xác thực email trước khi đăng nhập bằng mạng xã hội")
+)
+
+// ExternalIdentity là thông tin người dùng đã được nhà cung cấp xác thực
+type ExternalIdentity struct {
+	Provider      string
+	Subject       string // ID người dùng phía nhà cung cấp
+	Email         string
+	EmailVerified bool
+	Name          string
+	Avatar        string
+}
+
+// IdentityProvider xác thực token do phía client nhận từ nhà cung cấp
+type IdentityProvider interface {
+	Name() string
+	Verify(ctx context.Context, token string) (*ExternalIdentity, error)
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = map[string]IdentityProvider{}
+)
+
+// RegisterIdentityProvider đăng ký thêm một nhà cung cấp, ghi đè nếu trùng tên
+func RegisterIdentityProvider(p IdentityProvider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[p.Name()] = p
+}
+
+func GetIdentityProvider(name string) (IdentityProvider, error) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	p, ok := providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return p, nil
+}
+
+// InitIdentityProviders đăng ký các nhà cung cấp đã có cấu hình
+func InitIdentityProviders(cfg *config.Config) {
+	if cfg.Google.ClientID != "" {
+		RegisterIdentityProvider(&googleProvider{clientID: cfg.Google.ClientID})
+	}
+	if cfg.Facebook.AppID != "" && cfg.Facebook.AppSecret != "" {
+		RegisterIdentityProvider(&facebookProvider{appID: cfg.Facebook.AppID, appSecret: cfg.Facebook.AppSecret.Value()})
+	}
+	if cfg.Zalo.AppID != "" && cfg.Zalo.AppSecret != "" {
+		RegisterIdentityProvider(&zaloProvider{appSecret: cfg.Zalo.AppSecret.Value()})
+	}
+}
+
+var oauthHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+func getJSON(ctx context.Context, rawURL string, header map[string]string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := oauthHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("nhà cung cấp trả về mã %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Google: client gửi ID token (JWT) đã ký bởi Google
+type googleProvider struct {
+	clientID string
+}
+
+func (p *googleProvider) Name() string { return models.ProviderGoogle }
+
+func (p *googleProvider) Verify(ctx context.Context, token string) (*ExternalIdentity, error) {
+	payload, err := idtoken.Validate(ctx, token, p.clientID)
+	if err != nil {
+		return nil, ErrInvalidSocialAuth
+	}
+
+	identity := &ExternalIdentity{Provider: p.Name(), Subject: payload.Subject}
+	identity.Email, _ = payload.Claims["email"].(string)
+	identity.EmailVerified, _ = payload.Claims["email_verified"].(bool)
+	identity.Name, _ = payload.Claims["name"].(string)
+	identity.Avatar, _ = payload.Claims["picture"].(string)
+	return identity, nil
+}
+
+// Facebook: client gửi user access token, kiểm tra token thuộc đúng ứng dụng qua debug_token
+type facebookProvider struct {
+	appID     string
+	appSecret string
+}
+
+func (p *facebookProvider) Name() string { return models.ProviderFacebook }
+
+func (p *facebookProvider) Verify(ctx context.Context, token string) (*ExternalIdentity, error) {
+	var debug struct {
+		Data struct {
+			AppID   string `json:"app_id"`
+			IsValid bool   `json:"is_valid"`
+			UserID  string `json:"user_id"`
+		} `json:"data"`
+	}
+	debugURL := "https://graph.facebook.com/debug_token?" + url.Values{
+		"input_token":  {token},
+		"access_token": {p.appID + "|" + p.appSecret},
+	}.Encode()
+	if err := getJSON(ctx, debugURL, nil, &debug); err != nil {
+		return nil, fmt.Errorf("không thể xác thực token Facebook: %v", err)
+	}
+	if !debug.Data.IsValid || debug.Data.AppID != p.appID || debug.Data.UserID == "" {
+		return nil, ErrInvalidSocialAuth
+	}
+
+	var me struct {
+		ID      string `json:"id"`
+		Name    string `json:"name"`
+		Email   string `json:"email"`
+		Picture struct {
+			Data struct {
+				URL string `json:"url"`
+			} `json:"data"`
+		} `json:"picture"`
+	}
+	meURL := "https://graph.facebook.com/me?" + url.Values{
+		"fields":       {"id,name,email,picture.type(large)"},
+		"access_token": {token},
+	}.Encode()
+	if err := getJSON(ctx, meURL, nil, &me); err != nil {
+		return nil, fmt.Errorf("không thể lấy thông tin Facebook: %v", err)
+	}
+	if me.ID != debug.Data.UserID {
+		return nil, ErrInvalidSocialAuth
+	}
+
+	// Facebook chỉ trả về email đã được xác nhận
+	return &ExternalIdentity{
+		Provider:      p.Name(),
+		Subject:       me.ID,
+		Email:         me.Email,
+		EmailVerified: me.Email != "",
+		Name:          me.Name,
+		Avatar:        me.Picture.Data.URL,
+	}, nil
+}
+
+// Zalo: client gửi access token, Zalo không cung cấp email.
+// Mỗi lời gọi kèm appsecret_proof ký bằng app secret; ứng dụng Zalo phải bật "Yêu cầu appsecret_proof"
+// để Zalo từ chối token cấp cho ứng dụng khác (proof không khớp).
+type zaloProvider struct {
+	appSecret string
+}
+
+// appSecretProof là HMAC-SHA256 của access token với khóa là app secret
+func (p *zaloProvider) appSecretProof(token string) string {
+	mac := hmac.New(sha256.New, []byte(p.appSecret))
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (p *zaloProvider) Name() string { return models.ProviderZalo }
+
+func (p *zaloProvider) Verify(ctx context.Context, token string) (*ExternalIdentity, error) {
+	var me struct {
+		ID      string `json:"id"`
+		Name    string `json:"name"`
+		Error   int    `json:"error"`
+		Message string `json:"message"`
+		Picture struct {
+			Data struct {
+				URL string `json:"url"`
+			} `json:"data"`
+		} `json:"picture"`
+	}
+	if err := getJSON(ctx, "https://graph.zalo.me/v2.0/me?fields=id,name,picture",
+		map[string]string{"access_token": token, "appsecret_proof": p.appSecretProof(token)}, &me); err != nil {
+		return nil, fmt.Errorf("không thể xác thực token Zalo: %v", err)
+	}
+	if me.Error != 0 || me.ID == "" {
+		return nil, ErrInvalidSocialAuth
+	}
+
+	return &ExternalIdentity{
+		Provider: p.Name(),
+		Subject:  me.ID,
+		Name:     me.Name,
+		Avatar:   me.Picture.Data.URL,
+	}, nil
+}
+
+// LoginWithIdentity tìm hoặc tạo người dùng cho danh tính bên ngoài.
+// Thứ tự: danh tính đã liên kết -> người dùng có cùng email đã được xác nhận -> tạo người dùng mới.
+func LoginWithIdentity(identity *ExternalIdentity) (models.User, error) {
+	var user models.User
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var linked models.UserIdentity
+		err := tx.Where("provider = ? AND provider_user_id = ?", identity.Provider, identity.Subject).First(&linked).Error
+		if err == nil {
+			if err := tx.Preload("Banks").First(&user, linked.UserID).Error; err != nil {
+				return err
+			}
+			if user.Role != 0 {
+				return ErrStaffSocialLogin
+			}
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		// Chỉ tự liên kết theo email khi nhà cung cấp đã xác nhận email đó và tài khoản cũng đã xác thực email
+		found := false
+		if identity.Email != "" && identity.EmailVerified {
+			err := tx.Preload("Banks").Where("email = ?", identity.Email).First(&user).Error
+			if err == nil {
+				found = true
+			} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+		}
+
+		if !found {
+			user = models.User{
+				Name:       identity.Name,
+				Password:   "",
+				IsVerified: identity.EmailVerified,
+				Role:       0,
+			}
+			if identity.EmailVerified {
+				user.Email = identity.Email
+			}
+			if identity.Avatar != "" {
+				user.Avatar = identity.Avatar
+			}
+			if user.Name == "" {
+				user.Name = "New User"
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+		} else if user.Role != 0 {
+			// Không cho phép bỏ qua bước xác thực mã của tài khoản quản trị
+			return ErrStaffSocialLogin
+		} else if !user.IsVerified {
+			// Tài khoản chưa xác thực có thể do người khác đăng ký trước bằng email này và vẫn giữ mật khẩu,
+			// liên kết vào đó sẽ cho kẻ đăng ký trước truy cập tài khoản của chủ email
+			return ErrUnverifiedAccount
+		}
+
+		return tx.Create(&models.UserIdentity{
+			UserID:         user.ID,
+			Provider:       identity.Provider,
+			ProviderUserID: identity.Subject,
+			Email:          identity.Email,
+		}).Error
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+// LinkIdentity liên kết thêm một phương thức đăng nhập cho người dùng đang đăng nhập
+func LinkIdentity(userID uint, identity *ExternalIdentity) error {
+	var linked models.UserIdentity
+	err := config.DB.Where("provider = ? AND provider_user_id = ?", identity.Provider, identity.Subject).First(&linked).Error
+	if err == nil {
+		if linked.UserID == userID {
+			return nil
+		}
+		return ErrIdentityLinked
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	return config.DB.Create(&models.UserIdentity{
+		UserID:         userID,
+		Provider:       identity.Provider,
+		ProviderUserID: identity.Subject,
+		Email:          identity.Email,
+	}).Error
+}
+
+// UnlinkIdentity hủy liên kết, luôn giữ lại ít nhất một cách đăng nhập
+func UnlinkIdentity(userID uint, provider string) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.First(&user, userID).Error; err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.UserIdentity{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+			return err
+		}
+
+		result := tx.Where("user_id = ? AND provider = ?", userID, provider).Delete(&models.UserIdentity{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		if user.Password == "" && count-result.RowsAffected <= 0 {
+			return ErrLastLoginMethod
+		}
+		return nil
+	})
+}
+
+var phoneRegex = regexp.MustCompile(`^0\d{9,10}$`)
+
+// AddPhoneNumber cập nhật số điện thoại, dùng cho tài khoản đăng ký qua mạng xã hội
+func AddPhoneNumber(userID uint, phone string) (models.User, error) {
+	var user models.User
+	if !phoneRegex.MatchString(phone) {
+		return user, ErrInvalidPhone
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&user, userID).Error; err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.User{}).Where("phone_number = ? AND id <> ?", phone, userID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrPhoneUsed
+		}
+
+		return tx.Model(&user).Update("phone_number", phone).Error
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+
+	user.PhoneNumber = phone
+	return user, nil
+}
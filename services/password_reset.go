@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"new/config"
+	"new/models"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// Thời gian hiệu lực của link đặt lại mật khẩu
+const ResetTokenTTL = 15 * time.Minute
+
+var ErrInvalidResetToken = errors.New("Liên kết đặt lại mật khẩu không hợp lệ hoặc đã hết hạn")
+
+// ValidatePasswordStrength kiểm tra độ mạnh mật khẩu: 8-72 ký tự, có chữ hoa, chữ thường và chữ số
+func ValidatePasswordStrength(password string) error {
+	if len(password) < 8 {
+		return errors.New("Mật khẩu phải có ít nhất 8 ký tự")
+	}
+	// bcrypt chỉ dùng 72 byte đầu tiên
+	if len(password) > 72 {
+		return errors.New("Mật khẩu không được dài quá 72 ký tự")
+	}
+
+	var hasUpper, hasLower, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	if !hasUpper || !hasLower || !hasDigit {
+		return errors.New("Mật khẩu phải có chữ hoa, chữ thường và chữ số")
+	}
+	return nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func generateToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Vô hiệu hóa toàn bộ token còn hiệu lực của người dùng theo mục đích
+func invalidateUserTokens(tx *gorm.DB, userID uint, purpose string) error {
+	return tx.Model(&models.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", time.Now()).Error
+}
+
+// ResetPass tạo token đặt lại mật khẩu dùng một lần và gửi link qua email
+func ResetPass(ctx context.Context, user models.User) error {
+	token, err := generateToken()
+	if err != nil {
+		return fmt.Errorf("không thể tạo token đặt lại mật khẩu: %v", err)
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// Mỗi lần yêu cầu mới sẽ làm mất hiệu lực các link cũ
+		if err := invalidateUserTokens(tx, user.ID, models.TokenPurposeResetPassword); err != nil {
+			return err
+		}
+
+		return tx.Create(&models.UserToken{
+			UserID:    user.ID,
+			Purpose:   models.TokenPurposeResetPassword,
+			TokenHash: hashToken(token),
+			ExpiresAt: time.Now().Add(ResetTokenTTL),
+		}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("không thể lưu token đặt lại mật khẩu: %v", err)
+	}
+
+	// Gửi nền để thời gian phản hồi không cho biết tài khoản có tồn tại hay không, lỗi chỉ ghi log
+	ctx = context.WithoutCancel(ctx)
+	BackgroundWorkers.Go(func() {
+		if err := sendResetPasswordEmail(ctx, user.Email, token); err != nil {
+			Logger(ctx).Error("Không thể gửi email đặt lại mật khẩu", "userId", user.ID, "error", err)
+		}
+	})
+
+	return nil
+}
+
+// ResetPasswordWithToken đổi mật khẩu bằng token hợp lệ, token chỉ dùng được một lần
+func ResetPasswordWithToken(ctx context.Context, token string, newPassword string) error {
+	if err := ValidatePasswordStrength(newPassword); err != nil {
+		return err
+	}
+
+	var user models.User
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var userToken models.UserToken
+		if err := tx.Where("token_hash = ? AND purpose = ?", hashToken(token), models.TokenPurposeResetPassword).
+			First(&userToken).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidResetToken
+			}
+			return err
+		}
+
+		// Đánh dấu đã dùng bằng một câu UPDATE có điều kiện: hai request cùng token thì chỉ một request cập nhật được
+		now := time.Now()
+		result := tx.Model(&models.UserToken{}).
+			Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", userToken.TokenHash, now).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return ErrInvalidResetToken
+		}
+
+		if err := tx.First(&user, userToken.UserID).Error; err != nil {
+			return ErrInvalidResetToken
+		}
+
+		hashedPassword, err := HashPassword(newPassword)
+		if err != nil {
+			return fmt.Errorf("không thể băm mật khẩu: %v", err)
+		}
+
+		if err := tx.Model(&user).Update("password", hashedPassword).Error; err != nil {
+			return fmt.Errorf("không thể cập nhật mật khẩu mới: %v", err)
+		}
+
+		// Đổi mật khẩu xong thì mọi token đặt lại mật khẩu khác cũng hết hiệu lực
+		return invalidateUserTokens(tx, user.ID, models.TokenPurposeResetPassword)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Mật khẩu đã đổi xong, email thông báo gửi nền và lỗi chỉ ghi log
+	ctx = context.WithoutCancel(ctx)
+	BackgroundWorkers.Go(func() {
+		err := sendNews(ctx, user.Email, "Mật khẩu đã được đặt lại",
+			fmt.Sprintf("Mật khẩu tài khoản của bạn đã được đặt lại lúc %s. Nếu không phải bạn thực hiện, hãy liên hệ với chúng tôi ngay.",
+				time.Now().Format("15:04 02/01/2006")))
+		if err != nil {
+			Logger(ctx).Error("Không thể gửi email xác nhận đặt lại mật khẩu", "email", user.Email, "error", err)
+		}
+	})
+
+	return nil
+}
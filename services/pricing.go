@@ -0,0 +1,68 @@
+package services
+
+import (
+	"new/models"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+// NightPrice là giá của một đêm lưu trú, Date theo định dạng 02/01/2006
+type NightPrice struct {
+	Date  string `json:"date"`
+	Price int    `json:"price"`
+}
+
+// PriceNight tính giá một đêm theo bảng giá, plan nil thì dùng basePrice (giá của phòng/chỗ ở)
+func PriceNight(plan *models.RatePlan, basePrice int, night time.Time) int {
+	if plan == nil {
+		return basePrice
+	}
+	day := night.Format(dateLayout)
+	for _, override := range plan.Overrides {
+		if override.Date.Format(dateLayout) == day {
+			return override.Price
+		}
+	}
+	for _, season := range plan.Seasons {
+		if day >= season.FromDate.Format(dateLayout) && day <= season.ToDate.Format(dateLayout) {
+			return season.Price
+		}
+	}
+	for _, dayPrice := range plan.DayPrices {
+		if dayPrice.Weekday == int(night.Weekday()) {
+			return dayPrice.Price
+		}
+	}
+	return basePrice
+}
+
+// PriceStay tính giá từng đêm từ ngày nhận phòng đến trước ngày trả phòng và tổng tiền
+func PriceStay(plan *models.RatePlan, basePrice int, checkIn, checkOut time.Time) (int, []NightPrice) {
+	total := 0
+	var nights []NightPrice
+	for night := checkIn; night.Before(checkOut); night = night.AddDate(0, 0, 1) {
+		price := PriceNight(plan, basePrice, night)
+		total += price
+		nights = append(nights, NightPrice{Date: night.Format("02/01/2006"), Price: price})
+	}
+	return total, nights
+}
+
+// GuestFee là số khách vượt sức chứa tiêu chuẩn và phụ thu cho cả kỳ lưu trú
+type GuestFee struct {
+	ExtraAdults   int `json:"extraAdults"`
+	ExtraChildren int `json:"extraChildren"`
+	Price         int `json:"price"`
+}
+
+// ExtraGuestFee tính phụ thu khách thêm, chỗ tiêu chuẩn (capacity) được xếp cho người lớn trước
+func ExtraGuestFee(policy models.ExtraGuestPolicy, adults, children, capacity, nights int) GuestFee {
+	extraAdults := max(adults-capacity, 0)
+	extraChildren := max(children-max(capacity-adults, 0), 0)
+	return GuestFee{
+		ExtraAdults:   extraAdults,
+		ExtraChildren: extraChildren,
+		Price:         (extraAdults*policy.ExtraAdultPrice + extraChildren*policy.ExtraChildPrice) * nights,
+	}
+}
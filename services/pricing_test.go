@@ -0,0 +1,115 @@
+package services
+
+import (
+	"new/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func date(day string) time.Time {
+	t, err := time.Parse(dateLayout, day)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Bảng giá mẫu: giá cuối tuần, mùa hè 01/07-31/07 và giá riêng ngày 15/07
+var testPlan = &models.RatePlan{
+	DayPrices: []models.RateDayPrice{
+		{Weekday: int(time.Friday), Price: 150},
+		{Weekday: int(time.Saturday), Price: 180},
+	},
+	Seasons: []models.RateSeason{
+		{FromDate: date("2025-07-01"), ToDate: date("2025-07-31"), Price: 200},
+	},
+	Overrides: []models.RateOverride{
+		{Date: date("2025-07-15"), Price: 500},
+	},
+}
+
+func TestPriceNight(t *testing.T) {
+	tests := []struct {
+		name  string
+		plan  *models.RatePlan
+		night string
+		want  int
+	}{
+		{"không có bảng giá", nil, "2025-06-06", 100},
+		{"ngày thường", testPlan, "2025-06-04", 100},
+		{"giá theo thứ", testPlan, "2025-06-06", 150},
+		{"mùa ưu tiên hơn thứ", testPlan, "2025-07-04", 200},
+		{"ngày đầu mùa", testPlan, "2025-07-01", 200},
+		{"ngày cuối mùa", testPlan, "2025-07-31", 200},
+		{"giá riêng ưu tiên hơn mùa", testPlan, "2025-07-15", 500},
+		{"sau mùa", testPlan, "2025-08-01", 150},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PriceNight(tt.plan, 100, date(tt.night)); got != tt.want {
+				t.Errorf("PriceNight(%s) = %d, want %d", tt.night, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPriceStay(t *testing.T) {
+	tests := []struct {
+		name      string
+		plan      *models.RatePlan
+		checkIn   string
+		checkOut  string
+		wantTotal int
+		want      []NightPrice
+	}{
+		{
+			name: "không tính đêm trả phòng", plan: testPlan, checkIn: "2025-06-05", checkOut: "2025-06-08", wantTotal: 430,
+			want: []NightPrice{{"05/06/2025", 100}, {"06/06/2025", 150}, {"07/06/2025", 180}},
+		},
+		{
+			name: "qua ngày đầu mùa", plan: testPlan, checkIn: "2025-06-30", checkOut: "2025-07-02", wantTotal: 300,
+			want: []NightPrice{{"30/06/2025", 100}, {"01/07/2025", 200}},
+		},
+		{name: "ngày trả trùng ngày nhận", plan: testPlan, checkIn: "2025-06-05", checkOut: "2025-06-05", wantTotal: 0},
+		{
+			name: "không có bảng giá", plan: nil, checkIn: "2025-07-14", checkOut: "2025-07-16", wantTotal: 200,
+			want: []NightPrice{{"14/07/2025", 100}, {"15/07/2025", 100}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			total, nights := PriceStay(tt.plan, 100, date(tt.checkIn), date(tt.checkOut))
+			if total != tt.wantTotal {
+				t.Errorf("total = %d, want %d", total, tt.wantTotal)
+			}
+			if !reflect.DeepEqual(nights, tt.want) {
+				t.Errorf("nights = %v, want %v", nights, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtraGuestFee(t *testing.T) {
+	policy := models.ExtraGuestPolicy{MaxExtraGuests: 2, ExtraAdultPrice: 100, ExtraChildPrice: 40}
+	tests := []struct {
+		name                       string
+		adults, children, capacity int
+		nights                     int
+		want                       GuestFee
+	}{
+		{name: "trong sức chứa", adults: 2, children: 0, capacity: 2, nights: 3, want: GuestFee{}},
+		{name: "trẻ em dùng chỗ còn trống", adults: 1, children: 1, capacity: 2, nights: 3, want: GuestFee{}},
+		{name: "thêm người lớn", adults: 3, children: 0, capacity: 2, nights: 2, want: GuestFee{ExtraAdults: 1, Price: 200}},
+		{name: "thêm trẻ em", adults: 2, children: 2, capacity: 2, nights: 2, want: GuestFee{ExtraChildren: 2, Price: 160}},
+		{name: "người lớn được xếp chỗ trước", adults: 3, children: 1, capacity: 2, nights: 1, want: GuestFee{ExtraAdults: 1, ExtraChildren: 1, Price: 140}},
+		{name: "một phần trẻ em vượt", adults: 1, children: 3, capacity: 2, nights: 1, want: GuestFee{ExtraChildren: 2, Price: 80}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtraGuestFee(policy, tt.adults, tt.children, tt.capacity, tt.nights); got != tt.want {
+				t.Errorf("ExtraGuestFee = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
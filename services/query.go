@@ -0,0 +1,224 @@
+package services
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultPageLimit = 10
+	MaxPageLimit     = 100
+)
+
+var ErrInvalidCursor = errors.New("cursor không hợp lệ")
+
+// Cursor là vị trí của bản ghi cuối cùng trang trước, dùng cho phân trang keyset
+type Cursor struct {
+	Time time.Time `json:"t"`
+	ID   uint      `json:"id"`
+}
+
+func EncodeCursor(c Cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// PageRequest: nếu có Cursor thì phân trang keyset và bỏ qua Page
+type PageRequest struct {
+	Page   int
+	Limit  int
+	Cursor *Cursor
+}
+
+func (p PageRequest) key() string {
+	if p.Cursor != nil {
+		return fmt.Sprintf("cursor=%d.%d:limit=%d", p.Cursor.Time.UnixNano(), p.Cursor.ID, p.Limit)
+	}
+	return fmt.Sprintf("page=%d:limit=%d", p.Page, p.Limit)
+}
+
+// PageInfo giữ nguyên các trường page/limit/total của response cũ, thêm nextCursor cho trang kế tiếp
+type PageInfo struct {
+	Page       int    `json:"page"`
+	Limit      int    `json:"limit"`
+	Total      int64  `json:"total"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// Keyset là cặp cột dùng để sắp xếp mới nhất trước và làm cursor, cần có index (time DESC, id DESC)
+type Keyset struct {
+	TimeColumn string
+	IDColumn   string
+}
+
+// ListQuery đẩy bộ lọc xuống SQL và ghi lại các bộ lọc đã áp dụng để sinh cache key cho từng trang
+type ListQuery struct {
+	tx       *gorm.DB
+	filters  []string
+	preloads []string
+}
+
+func NewListQuery(tx *gorm.DB) *ListQuery {
+	return &ListQuery{tx: tx}
+}
+
+// Where thêm điều kiện tùy ý, name dùng để phân biệt bộ lọc trong cache key
+func (q *ListQuery) Where(name string, query string, args ...interface{}) *ListQuery {
+	q.tx = q.tx.Where(query, args...)
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		values[i] = filterValue(arg)
+	}
+	q.filters = append(q.filters, fmt.Sprintf("%s=%v", name, values))
+	return q
+}
+
+// filterValue đổi truy vấn con thành câu SQL của nó, in *gorm.DB bằng %v ra địa chỉ con trỏ làm cache key khác nhau mỗi request
+func filterValue(arg interface{}) interface{} {
+	sub, ok := arg.(*gorm.DB)
+	if !ok {
+		return arg
+	}
+	return sub.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Find(&[]map[string]interface{}{})
+	})
+}
+
+// EqInt lọc column = raw, bỏ qua nếu raw rỗng hoặc không phải số
+func (q *ListQuery) EqInt(column string, raw string) *ListQuery {
+	if raw == "" {
+		return q
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		return q
+	}
+	return q.Where(column, column+" = ?", value)
+}
+
+// EqFloat lọc column = raw, bỏ qua nếu raw rỗng hoặc không phải số
+func (q *ListQuery) EqFloat(column string, raw string) *ListQuery {
+	if raw == "" {
+		return q
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return q
+	}
+	return q.Where(column, column+" = ?", value)
+}
+
+// Contains lọc không phân biệt hoa thường, ký tự đặc biệt của LIKE được escape
+func (q *ListQuery) Contains(column string, raw string) *ListQuery {
+	if raw == "" {
+		return q
+	}
+	return q.Where(column, column+" ILIKE ?", ContainsPattern(raw))
+}
+
+// DateRange lọc theo khoảng ngày trên hai cột chuỗi dd/mm/yyyy, from/to ở dạng yyyymmdd, bỏ qua nếu rỗng
+func (q *ListQuery) DateRange(fromColumn, toColumn, from, to string) *ListQuery {
+	if from != "" {
+		q.Where(fromColumn, comparableDate(fromColumn)+" >= ?", from)
+	}
+	if to != "" {
+		q.Where(toColumn, comparableDate(toColumn)+" <= ?", to)
+	}
+	return q
+}
+
+// comparableDate đổi chuỗi dd/mm/yyyy sang yyyymmdd để so sánh, migration 0013 index đúng biểu thức này nên không được sửa riêng
+func comparableDate(column string) string {
+	return fmt.Sprintf("SUBSTRING(%[1]s, 7, 4) || SUBSTRING(%[1]s, 4, 2) || SUBSTRING(%[1]s, 1, 2)", column)
+}
+
+// Preload chỉ áp dụng khi lấy dữ liệu trang, không áp dụng khi đếm
+func (q *ListQuery) Preload(names ...string) *ListQuery {
+	q.preloads = append(q.preloads, names...)
+	return q
+}
+
+// CacheKey sinh key cho một trang kết quả, bộ lọc được băm để key ngắn và không chứa ký tự lạ
+func (q *ListQuery) CacheKey(prefix string, p PageRequest) string {
+	sum := sha1.Sum([]byte(strings.Join(q.filters, "\x00")))
+	return fmt.Sprintf("%s:q=%s:%s", prefix, hex.EncodeToString(sum[:8]), p.key())
+}
+
+// PageCacheKey sinh key cho một trang kết quả từ struct bộ lọc đã parse, dùng khi truy vấn nằm trong repository
+func PageCacheKey(prefix string, filter interface{}, p PageRequest) string {
+	data, _ := json.Marshal(filter)
+	sum := sha1.Sum(data)
+	return fmt.Sprintf("%s:q=%s:%s", prefix, hex.EncodeToString(sum[:8]), p.key())
+}
+
+// Find đếm tổng số bản ghi khớp bộ lọc và lấy một trang theo thứ tự mới nhất trước
+func Find[T any](q *ListQuery, p PageRequest, keyset Keyset, cursorOf func(T) Cursor) ([]T, PageInfo, error) {
+	info := PageInfo{Page: p.Page, Limit: p.Limit}
+
+	if err := q.tx.Session(&gorm.Session{}).Count(&info.Total).Error; err != nil {
+		return nil, info, err
+	}
+
+	tx := q.tx.Session(&gorm.Session{})
+	for _, name := range q.preloads {
+		tx = tx.Preload(name)
+	}
+	tx = tx.Order(keyset.TimeColumn + " DESC").Order(keyset.IDColumn + " DESC")
+	if p.Cursor != nil {
+		info.Page = 0
+		tx = tx.Where(fmt.Sprintf("(%s, %s) < (?, ?)", keyset.TimeColumn, keyset.IDColumn), p.Cursor.Time, p.Cursor.ID)
+	} else {
+		tx = tx.Offset(p.Page * p.Limit)
+	}
+
+	// Lấy dư một bản ghi để biết còn trang sau hay không
+	items := make([]T, 0, p.Limit+1)
+	if err := tx.Limit(p.Limit + 1).Find(&items).Error; err != nil {
+		return nil, info, err
+	}
+	if len(items) > p.Limit {
+		items = items[:p.Limit]
+		info.NextCursor = EncodeCursor(cursorOf(items[len(items)-1]))
+	}
+
+	return items, info, nil
+}
+
+// FindAll lấy mọi bản ghi khớp bộ lọc, dùng cho danh sách nhỏ không phân trang trong SQL
+func FindAll[T any](q *ListQuery) ([]T, error) {
+	tx := q.tx.Session(&gorm.Session{})
+	for _, name := range q.preloads {
+		tx = tx.Preload(name)
+	}
+	var items []T
+	err := tx.Find(&items).Error
+	return items, err
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// ContainsPattern tạo mẫu ILIKE tìm chuỗi con
+func ContainsPattern(s string) string {
+	return "%" + likeEscaper.Replace(s) + "%"
+}
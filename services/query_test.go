@@ -0,0 +1,47 @@
+package services
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// Bộ lọc khoảng ngày chỉ dùng được index khi biểu thức khớp từng ký tự với index trong migration
+func TestComparableDateMatchesIndex(t *testing.T) {
+	migration, err := os.ReadFile("../migrations/sql/0013_date_range_indexes.up.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range []string{"holidays", "discounts"} {
+		for _, column := range []string{"from_date", "to_date"} {
+			index := "ON " + table + " ((" + comparableDate(column) + "))"
+			if !strings.Contains(string(migration), index) {
+				t.Errorf("migration 0013 has no index %s", index)
+			}
+		}
+	}
+}
+
+func TestListQueryCacheKeyWithSubquery(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=127.0.0.1"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := func(ownerID uint) string {
+		owned := db.Table("accommodations").Select("id").Where("user_id = ?", ownerID)
+		return NewListQuery(db.Table("orders")).
+			Where("owner", "orders.accommodation_id IN (?)", owned).
+			CacheKey("orders", PageRequest{Limit: 10})
+	}
+
+	// Truy vấn con giống nhau cho cùng key, khác tham số cho key khác
+	if key(1) != key(1) {
+		t.Error("same subquery produced different cache keys")
+	}
+	if key(1) == key(2) {
+		t.Error("different subqueries produced the same cache key")
+	}
+}
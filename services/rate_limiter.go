@@ -0,0 +1,325 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RateLimitPolicy mô tả giới hạn cho một nhóm endpoint (đăng nhập, OTP, quên mật khẩu...)
+type RateLimitPolicy struct {
+	Name           string        // Tiền tố key, ví dụ "login"
+	Limit          int           // Số request tối đa trong một cửa sổ
+	Window         time.Duration // Độ dài cửa sổ trượt
+	MaxFailures    int           // Số lần thất bại liên tiếp trước khi khóa
+	BaseLockout    time.Duration // Thời gian khóa lần đầu, nhân đôi sau mỗi lần thất bại tiếp theo
+	MaxLockout     time.Duration // Thời gian khóa tối đa
+	ResetOnSuccess bool          // Xóa bộ đếm thất bại khi request thành công
+}
+
+// LockoutFor tính thời gian khóa tương ứng với số lần thất bại
+func (p RateLimitPolicy) LockoutFor(failures int) time.Duration {
+	if p.MaxFailures <= 0 || failures < p.MaxFailures {
+		return 0
+	}
+
+	lockout := p.BaseLockout
+	for i := p.MaxFailures; i < failures; i++ {
+		lockout *= 2
+		if p.MaxLockout > 0 && lockout >= p.MaxLockout {
+			return p.MaxLockout
+		}
+	}
+	return lockout
+}
+
+// RateLimiter giới hạn số request theo cửa sổ trượt và khóa dần khi thất bại liên tiếp
+type RateLimiter interface {
+	// Allow ghi nhận một request, trả về false kèm thời gian chờ nếu vượt giới hạn
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, time.Duration, error)
+	// LockedFor trả về thời gian còn bị khóa của key (0 nếu không bị khóa)
+	LockedFor(ctx context.Context, key string) (time.Duration, error)
+	// RegisterFailure tăng bộ đếm thất bại và khóa key nếu vượt ngưỡng của policy
+	RegisterFailure(ctx context.Context, key string, policy RateLimitPolicy) (time.Duration, error)
+	// Reset xóa bộ đếm thất bại và trạng thái khóa của key
+	Reset(ctx context.Context, key string) error
+}
+
+// Thời gian giữ bộ đếm thất bại khi không có thêm lần thất bại nào
+const failureCounterTTL = 24 * time.Hour
+
+// Script cửa sổ trượt: xóa các request cũ, đếm, rồi thêm request mới nếu còn hạn mức
+var slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+local member = ARGV[4]
+
+redis.call("ZREMRANGEBYSCORE", key, 0, now - window)
+local count = redis.call("ZCARD", key)
+if count >= limit then
+	local oldest = redis.call("ZRANGE", key, 0, 0, "WITHSCORES")
+	local retry = window
+	if oldest[2] then
+		retry = tonumber(oldest[2]) + window - now
+	end
+	return {0, retry}
+end
+
+redis.call("ZADD", key, now, member)
+redis.call("PEXPIRE", key, window)
+return {1, 0}
+`)
+
+type redisRateLimiter struct {
+	rdb    *redis.Client
+	prefix string
+}
+
+// NewRedisRateLimiter tạo RateLimiter dùng Redis, dùng chung được giữa nhiều instance
+func NewRedisRateLimiter(rdb *redis.Client) RateLimiter {
+	return &redisRateLimiter{rdb: rdb, prefix: "ratelimit:"}
+}
+
+func (r *redisRateLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, time.Duration, error) {
+	now := time.Now().UnixMilli()
+	member := strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	res, err := slidingWindowScript.Run(ctx, r.rdb, []string{r.prefix + "window:" + key},
+		now, window.Milliseconds(), limit, member).Int64Slice()
+	if err != nil {
+		return true, 0, err
+	}
+
+	if res[0] == 1 {
+		return true, 0, nil
+	}
+	return false, time.Duration(res[1]) * time.Millisecond, nil
+}
+
+func (r *redisRateLimiter) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := r.rdb.PTTL(ctx, r.prefix+"lock:"+key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+func (r *redisRateLimiter) RegisterFailure(ctx context.Context, key string, policy RateLimitPolicy) (time.Duration, error) {
+	failKey := r.prefix + "fail:" + key
+
+	pipe := r.rdb.TxPipeline()
+	incr := pipe.Incr(ctx, failKey)
+	pipe.Expire(ctx, failKey, failureCounterTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+
+	lockout := policy.LockoutFor(int(incr.Val()))
+	if lockout > 0 {
+		if err := r.rdb.Set(ctx, r.prefix+"lock:"+key, incr.Val(), lockout).Err(); err != nil {
+			return 0, err
+		}
+	}
+	return lockout, nil
+}
+
+func (r *redisRateLimiter) Reset(ctx context.Context, key string) error {
+	return r.rdb.Del(ctx, r.prefix+"fail:"+key, r.prefix+"lock:"+key).Err()
+}
+
+// Chu kỳ dọn các key đã hết hạn của memoryRateLimiter, để map không lớn dần theo số IP/định danh đã gặp
+const memorySweepInterval = time.Minute
+
+type memoryRateLimiter struct {
+	mu        sync.Mutex
+	windows   map[string]memoryWindow
+	failures  map[string]memoryFailures
+	locks     map[string]time.Time
+	now       func() time.Time
+	nextSweep time.Time
+}
+
+// memoryWindow là các request trong cửa sổ trượt, hết hạn khi request cuối ra khỏi cửa sổ
+type memoryWindow struct {
+	hits    []time.Time
+	expires time.Time
+}
+
+// memoryFailures là bộ đếm thất bại, hết hạn sau failureCounterTTL kể từ lần thất bại cuối giống bản Redis
+type memoryFailures struct {
+	count   int
+	expires time.Time
+}
+
+// NewMemoryRateLimiter tạo RateLimiter lưu trong bộ nhớ, dùng cho test hoặc khi không có Redis
+func NewMemoryRateLimiter() RateLimiter {
+	return &memoryRateLimiter{
+		windows:  make(map[string]memoryWindow),
+		failures: make(map[string]memoryFailures),
+		locks:    make(map[string]time.Time),
+		now:      time.Now,
+	}
+}
+
+// sweep xóa các key đã hết hạn, chạy tối đa một lần mỗi memorySweepInterval; gọi khi đang giữ mu
+func (m *memoryRateLimiter) sweep(now time.Time) {
+	if now.Before(m.nextSweep) {
+		return
+	}
+	m.nextSweep = now.Add(memorySweepInterval)
+	for key, w := range m.windows {
+		if !now.Before(w.expires) {
+			delete(m.windows, key)
+		}
+	}
+	for key, f := range m.failures {
+		if !now.Before(f.expires) {
+			delete(m.failures, key)
+		}
+	}
+	for key, until := range m.locks {
+		if !now.Before(until) {
+			delete(m.locks, key)
+		}
+	}
+}
+
+func (m *memoryRateLimiter) Allow(_ context.Context, key string, limit int, window time.Duration) (bool, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.sweep(now)
+	hits := m.windows[key].hits[:0]
+	for _, hit := range m.windows[key].hits {
+		if now.Sub(hit) < window {
+			hits = append(hits, hit)
+		}
+	}
+
+	if len(hits) >= limit {
+		m.windows[key] = memoryWindow{hits: hits, expires: hits[len(hits)-1].Add(window)}
+		return false, hits[0].Add(window).Sub(now), nil
+	}
+
+	m.windows[key] = memoryWindow{hits: append(hits, now), expires: now.Add(window)}
+	return true, 0, nil
+}
+
+func (m *memoryRateLimiter) LockedFor(_ context.Context, key string) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.sweep(now)
+	until, ok := m.locks[key]
+	if !ok {
+		return 0, nil
+	}
+	remaining := until.Sub(now)
+	if remaining <= 0 {
+		delete(m.locks, key)
+		return 0, nil
+	}
+	return remaining, nil
+}
+
+func (m *memoryRateLimiter) RegisterFailure(_ context.Context, key string, policy RateLimitPolicy) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.sweep(now)
+	failures := m.failures[key]
+	if !now.Before(failures.expires) {
+		failures.count = 0
+	}
+	failures.count++
+	failures.expires = now.Add(failureCounterTTL)
+	m.failures[key] = failures
+
+	lockout := policy.LockoutFor(failures.count)
+	if lockout > 0 {
+		m.locks[key] = now.Add(lockout)
+	}
+	return lockout, nil
+}
+
+func (m *memoryRateLimiter) Reset(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.failures, key)
+	delete(m.locks, key)
+	return nil
+}
+
+// RateLimitKey ghép key theo policy, loại định danh (ip/id) và giá trị
+func RateLimitKey(policy RateLimitPolicy, kind, value string) string {
+	return fmt.Sprintf("%s:%s:%s", policy.Name, kind, value)
+}
+
+// fallbackRateLimiter dùng Redis khi khả dụng, khi Redis lỗi thì giới hạn tạm trong bộ nhớ
+// của từng instance để đăng nhập và OTP vẫn được bảo vệ.
+type fallbackRateLimiter struct {
+	primary  RateLimiter
+	fallback RateLimiter
+	breaker  *CircuitBreaker
+}
+
+func NewFallbackRateLimiter(primary, fallback RateLimiter, breaker *CircuitBreaker) RateLimiter {
+	return &fallbackRateLimiter{primary: primary, fallback: fallback, breaker: breaker}
+}
+
+func (f *fallbackRateLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, time.Duration, error) {
+	if f.breaker.Allow() == nil {
+		allowed, retryAfter, err := f.primary.Allow(ctx, key, limit, window)
+		f.breaker.Record(err)
+		if err == nil {
+			return allowed, retryAfter, nil
+		}
+	}
+	return f.fallback.Allow(ctx, key, limit, window)
+}
+
+func (f *fallbackRateLimiter) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	if f.breaker.Allow() == nil {
+		lockedFor, err := f.primary.LockedFor(ctx, key)
+		f.breaker.Record(err)
+		if err == nil {
+			return lockedFor, nil
+		}
+	}
+	return f.fallback.LockedFor(ctx, key)
+}
+
+func (f *fallbackRateLimiter) RegisterFailure(ctx context.Context, key string, policy RateLimitPolicy) (time.Duration, error) {
+	if f.breaker.Allow() == nil {
+		lockout, err := f.primary.RegisterFailure(ctx, key, policy)
+		f.breaker.Record(err)
+		if err == nil {
+			return lockout, nil
+		}
+	}
+	return f.fallback.RegisterFailure(ctx, key, policy)
+}
+
+func (f *fallbackRateLimiter) Reset(ctx context.Context, key string) error {
+	if f.breaker.Allow() == nil {
+		err := f.primary.Reset(ctx, key)
+		f.breaker.Record(err)
+		if err == nil {
+			return nil
+		}
+	}
+	return f.fallback.Reset(ctx, key)
+}
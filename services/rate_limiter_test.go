@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// fakeClock là đồng hồ điều khiển được cho memoryRateLimiter
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestLimiter() (*memoryRateLimiter, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)}
+	limiter := NewMemoryRateLimiter().(*memoryRateLimiter)
+	limiter.now = clock.now
+	return limiter, clock
+}
+
+func TestRateLimitPolicyLockoutFor(t *testing.T) {
+	policy := RateLimitPolicy{MaxFailures: 3, BaseLockout: time.Minute, MaxLockout: 5 * time.Minute}
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{2, 0},
+		{3, time.Minute},
+		{4, 2 * time.Minute},
+		{5, 4 * time.Minute},
+		{6, 5 * time.Minute},
+		{20, 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := policy.LockoutFor(tt.failures); got != tt.want {
+			t.Errorf("LockoutFor(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+	if got := (RateLimitPolicy{}).LockoutFor(100); got != 0 {
+		t.Errorf("LockoutFor without MaxFailures = %v, want 0", got)
+	}
+}
+
+func TestMemoryRateLimiterAllow(t *testing.T) {
+	ctx := context.Background()
+	limiter, clock := newTestLimiter()
+
+	steps := []struct {
+		advance   time.Duration
+		key       string
+		allowed   bool
+		wantRetry time.Duration
+	}{
+		{0, "a", true, 0},
+		{10 * time.Second, "a", true, 0},
+		{10 * time.Second, "a", true, 0},
+		{10 * time.Second, "a", false, 30 * time.Second}, // Hết hạn mức, chờ request đầu ra khỏi cửa sổ
+		{0, "b", true, 0},                                // Key khác có hạn mức riêng
+		{30 * time.Second, "a", true, 0},                 // Request đầu đã ra khỏi cửa sổ
+		{0, "a", false, 10 * time.Second},
+	}
+	for i, step := range steps {
+		clock.advance(step.advance)
+		allowed, retry, err := limiter.Allow(ctx, step.key, 3, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if allowed != step.allowed || retry != step.wantRetry {
+			t.Errorf("step %d: Allow = %v, %v; want %v, %v", i, allowed, retry, step.allowed, step.wantRetry)
+		}
+	}
+}
+
+func TestMemoryRateLimiterLockout(t *testing.T) {
+	ctx := context.Background()
+	limiter, clock := newTestLimiter()
+	policy := RateLimitPolicy{MaxFailures: 2, BaseLockout: time.Minute, MaxLockout: time.Hour}
+
+	if lockout, _ := limiter.RegisterFailure(ctx, "k", policy); lockout != 0 {
+		t.Fatalf("first failure lockout = %v, want 0", lockout)
+	}
+	if lockout, _ := limiter.RegisterFailure(ctx, "k", policy); lockout != time.Minute {
+		t.Fatalf("second failure lockout = %v, want 1m", lockout)
+	}
+	if locked, _ := limiter.LockedFor(ctx, "k"); locked != time.Minute {
+		t.Errorf("LockedFor = %v, want 1m", locked)
+	}
+
+	clock.advance(time.Minute)
+	if locked, _ := limiter.LockedFor(ctx, "k"); locked != 0 {
+		t.Errorf("LockedFor after lockout = %v, want 0", locked)
+	}
+	// Thất bại tiếp theo khóa lâu gấp đôi
+	if lockout, _ := limiter.RegisterFailure(ctx, "k", policy); lockout != 2*time.Minute {
+		t.Errorf("third failure lockout = %v, want 2m", lockout)
+	}
+
+	if err := limiter.Reset(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	if locked, _ := limiter.LockedFor(ctx, "k"); locked != 0 {
+		t.Errorf("LockedFor after reset = %v, want 0", locked)
+	}
+	if lockout, _ := limiter.RegisterFailure(ctx, "k", policy); lockout != 0 {
+		t.Errorf("failure after reset lockout = %v, want 0", lockout)
+	}
+
+	// Bộ đếm thất bại hết hạn sau failureCounterTTL giống bản Redis
+	clock.advance(failureCounterTTL)
+	if lockout, _ := limiter.RegisterFailure(ctx, "k", policy); lockout != 0 {
+		t.Errorf("failure after counter expiry lockout = %v, want 0", lockout)
+	}
+}
+
+func TestMemoryRateLimiterSweep(t *testing.T) {
+	ctx := context.Background()
+	limiter, clock := newTestLimiter()
+	policy := RateLimitPolicy{MaxFailures: 1, BaseLockout: time.Minute}
+
+	for _, key := range []string{"a", "b", "c"} {
+		limiter.Allow(ctx, key, 5, time.Minute)
+		limiter.RegisterFailure(ctx, key, policy)
+	}
+	if len(limiter.windows) != 3 || len(limiter.failures) != 3 || len(limiter.locks) != 3 {
+		t.Fatalf("before sweep: %d windows, %d failures, %d locks", len(limiter.windows), len(limiter.failures), len(limiter.locks))
+	}
+
+	// Cửa sổ và khóa đã hết hạn được dọn, bộ đếm thất bại còn giữ tới failureCounterTTL
+	clock.advance(2 * time.Minute)
+	limiter.Allow(ctx, "d", 5, time.Minute)
+	if len(limiter.windows) != 1 || len(limiter.failures) != 3 || len(limiter.locks) != 0 {
+		t.Errorf("after window expiry: %d windows, %d failures, %d locks; want 1, 3, 0", len(limiter.windows), len(limiter.failures), len(limiter.locks))
+	}
+
+	clock.advance(failureCounterTTL)
+	limiter.LockedFor(ctx, "d")
+	if len(limiter.windows) != 0 || len(limiter.failures) != 0 || len(limiter.locks) != 0 {
+		t.Errorf("after counter expiry: %d windows, %d failures, %d locks; want 0", len(limiter.windows), len(limiter.failures), len(limiter.locks))
+	}
+}
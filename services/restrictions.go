@@ -0,0 +1,63 @@
+package services
+
+import (
+	"fmt"
+	"new/models"
+	"time"
+)
+
+// Mã vi phạm quy định lưu trú, trả về trong details của lỗi STAY_RESTRICTED
+const (
+	RuleMinNights  = "MIN_NIGHTS"
+	RuleMaxNights  = "MAX_NIGHTS"
+	RuleNoCheckIn  = "NO_CHECK_IN"
+	RuleNoCheckOut = "NO_CHECK_OUT"
+	RuleMinAdvance = "MIN_ADVANCE"
+)
+
+var weekdayLabels = []string{"Chủ nhật", "Thứ hai", "Thứ ba", "Thứ tư", "Thứ năm", "Thứ sáu", "Thứ bảy"}
+
+type StayViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+	Value   int    `json:"value,omitempty"` // Giới hạn bị vi phạm (số đêm, số ngày)
+}
+
+// CheckStay kiểm tra kỳ lưu trú [checkIn, checkOut) với các quy định áp dụng, now dùng cho số ngày đặt trước.
+// Vi phạm giống nhau từ nhiều quy định (chỗ ở và phòng) chỉ trả về một lần.
+func CheckStay(restrictions []models.StayRestriction, checkIn, checkOut, now time.Time) []StayViolation {
+	nights := int(checkOut.Sub(checkIn).Hours() / 24)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, checkIn.Location())
+	advance := int(checkIn.Sub(today).Hours() / 24)
+
+	var violations []StayViolation
+	seen := map[StayViolation]bool{}
+	add := func(v StayViolation) {
+		if !seen[v] {
+			seen[v] = true
+			violations = append(violations, v)
+		}
+	}
+	for i := range restrictions {
+		r := &restrictions[i]
+		if !r.AppliesTo(checkIn) {
+			continue
+		}
+		if r.MinNights > 0 && nights < r.MinNights {
+			add(StayViolation{Rule: RuleMinNights, Value: r.MinNights, Message: fmt.Sprintf("Phải lưu trú tối thiểu %d đêm", r.MinNights)})
+		}
+		if r.MaxNights > 0 && nights > r.MaxNights {
+			add(StayViolation{Rule: RuleMaxNights, Value: r.MaxNights, Message: fmt.Sprintf("Chỉ được lưu trú tối đa %d đêm", r.MaxNights)})
+		}
+		if models.HasWeekday(r.NoCheckInDays, checkIn.Weekday()) {
+			add(StayViolation{Rule: RuleNoCheckIn, Message: fmt.Sprintf("Không nhận phòng vào %s", weekdayLabels[checkIn.Weekday()])})
+		}
+		if models.HasWeekday(r.NoCheckOutDays, checkOut.Weekday()) {
+			add(StayViolation{Rule: RuleNoCheckOut, Message: fmt.Sprintf("Không trả phòng vào %s", weekdayLabels[checkOut.Weekday()])})
+		}
+		if r.MinAdvanceDays > 0 && advance < r.MinAdvanceDays {
+			add(StayViolation{Rule: RuleMinAdvance, Value: r.MinAdvanceDays, Message: fmt.Sprintf("Phải đặt trước ít nhất %d ngày", r.MinAdvanceDays)})
+		}
+	}
+	return violations
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"new/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func rules(violations []StayViolation) []string {
+	var names []string
+	for _, v := range violations {
+		names = append(names, v.Rule)
+	}
+	return names
+}
+
+func TestCheckStay(t *testing.T) {
+	// 02/06/2025 là thứ hai
+	now := date("2025-06-02").Add(15 * time.Hour)
+	summerFrom, summerTo := date("2025-07-01"), date("2025-07-31")
+
+	tests := []struct {
+		name         string
+		restrictions []models.StayRestriction
+		checkIn      string
+		checkOut     string
+		want         []string
+	}{
+		{name: "không có quy định", checkIn: "2025-06-10", checkOut: "2025-06-11"},
+		{
+			name:         "đủ số đêm tối thiểu",
+			restrictions: []models.StayRestriction{{MinNights: 2}},
+			checkIn:      "2025-06-10",
+			checkOut:     "2025-06-12",
+		},
+		{
+			name:         "thiếu số đêm tối thiểu",
+			restrictions: []models.StayRestriction{{MinNights: 2}},
+			checkIn:      "2025-06-10",
+			checkOut:     "2025-06-11",
+			want:         []string{RuleMinNights},
+		},
+		{
+			name:         "quá số đêm tối đa",
+			restrictions: []models.StayRestriction{{MaxNights: 3}},
+			checkIn:      "2025-06-10",
+			checkOut:     "2025-06-14",
+			want:         []string{RuleMaxNights},
+		},
+		{
+			name:         "không nhận phòng thứ bảy, không trả phòng chủ nhật",
+			restrictions: []models.StayRestriction{{NoCheckInDays: models.WeekdayMask(time.Saturday), NoCheckOutDays: models.WeekdayMask(time.Sunday)}},
+			checkIn:      "2025-06-07",
+			checkOut:     "2025-06-08",
+			want:         []string{RuleNoCheckIn, RuleNoCheckOut},
+		},
+		{
+			name:         "đặt trước chưa đủ ngày",
+			restrictions: []models.StayRestriction{{MinAdvanceDays: 7}},
+			checkIn:      "2025-06-08",
+			checkOut:     "2025-06-09",
+			want:         []string{RuleMinAdvance},
+		},
+		{
+			name:         "đặt trước vừa đủ ngày",
+			restrictions: []models.StayRestriction{{MinAdvanceDays: 7}},
+			checkIn:      "2025-06-09",
+			checkOut:     "2025-06-10",
+		},
+		{
+			name:         "ngoài khoảng áp dụng",
+			restrictions: []models.StayRestriction{{FromDate: &summerFrom, ToDate: &summerTo, MinNights: 3}},
+			checkIn:      "2025-06-30",
+			checkOut:     "2025-07-01",
+		},
+		{
+			name:         "trong khoảng áp dụng",
+			restrictions: []models.StayRestriction{{FromDate: &summerFrom, ToDate: &summerTo, MinNights: 3}},
+			checkIn:      "2025-07-31",
+			checkOut:     "2025-08-01",
+			want:         []string{RuleMinNights},
+		},
+		{
+			name:         "chỉ áp dụng khi nhận phòng thứ sáu",
+			restrictions: []models.StayRestriction{{ArrivalDays: models.WeekdayMask(time.Friday), MinNights: 2}},
+			checkIn:      "2025-06-12",
+			checkOut:     "2025-06-13",
+		},
+		{
+			name: "vi phạm giống nhau của chỗ ở và phòng chỉ trả một lần",
+			restrictions: []models.StayRestriction{
+				{MinNights: 2},
+				{MinNights: 2, MaxNights: 5},
+			},
+			checkIn:  "2025-06-10",
+			checkOut: "2025-06-11",
+			want:     []string{RuleMinNights},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rules(CheckStay(tt.restrictions, date(tt.checkIn), date(tt.checkOut), now))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CheckStay = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"new/config"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// S3Storage lưu file trên S3 hoặc dịch vụ tương thích S3, ký request bằng AWS Signature V4
+type S3Storage struct {
+	cfg    config.S3Config
+	client *http.Client
+}
+
+func NewS3Storage(cfg config.S3Config) *S3Storage {
+	return &S3Storage{cfg: cfg, client: &http.Client{Timeout: 60 * time.Second}}
+}
+
+// objectURL là địa chỉ API của object, path-style hoặc virtual-hosted-style
+func (s *S3Storage) objectURL(key string) (*url.URL, error) {
+	u, err := url.Parse(s.cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("S3_ENDPOINT không hợp lệ: %w", err)
+	}
+	if s.cfg.PathStyle {
+		u.Path = "/" + s.cfg.Bucket + "/" + key
+	} else {
+		u.Host = s.cfg.Bucket + "." + u.Host
+		u.Path = "/" + key
+	}
+	return u, nil
+}
+
+// publicURL là URL trả cho client, ưu tiên S3_PUBLIC_URL (CDN) nếu có
+func (s *S3Storage) publicURL(key string) string {
+	if s.cfg.PublicURL != "" {
+		return s.cfg.PublicURL + "/" + key
+	}
+	u, _ := s.objectURL(key)
+	return u.String()
+}
+
+func (s *S3Storage) Put(ctx context.Context, key, contentType string, data []byte) (string, error) {
+	if err := validObjectKey(key); err != nil {
+		return "", err
+	}
+	headers := map[string]string{
+		"Content-Type":  contentType,
+		"Cache-Control": "public, max-age=31536000, immutable",
+	}
+	if err := s.do(ctx, http.MethodPut, key, data, headers); err != nil {
+		return "", err
+	}
+	return s.publicURL(key), nil
+}
+
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	if err := validObjectKey(key); err != nil {
+		return err
+	}
+	// S3 trả 204 kể cả khi object không tồn tại
+	return s.do(ctx, http.MethodDelete, key, nil, nil)
+}
+
+func (s *S3Storage) do(ctx context.Context, method, key string, body []byte, headers map[string]string) (err error) {
+	ctx, span := StartSpan(ctx, "s3."+strings.ToLower(method))
+	span.SetAttributes(attribute.String("s3.bucket", s.cfg.Bucket), attribute.String("s3.key", key))
+	defer func() { EndSpan(span, err) }()
+
+	u, err := s.objectURL(key)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = int64(len(body))
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	s.sign(req, body, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("lỗi khi gọi S3: %w", err)
+	}
+	defer resp.Body.Close()
+
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= 300 && !(method == http.MethodDelete && resp.StatusCode == http.StatusNotFound) {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("S3 trả về %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// sign thêm header Authorization theo AWS Signature V4
+func (s *S3Storage) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(req.Header.Get(name)) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsURIEncode(req.URL.Path),
+		"",
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + s.cfg.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey.Value()), day)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKey, scope, signedHeaders, signature))
+	// net/http lấy Host từ URL, bỏ header để không gửi trùng
+	req.Header.Del("Host")
+}
+
+// awsURIEncode mã hóa path theo quy tắc của SigV4: giữ nguyên A-Z a-z 0-9 - _ . ~ và /
+func awsURIEncode(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
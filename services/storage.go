@@ -0,0 +1,175 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"new/config"
+
+	"github.com/cloudinary/cloudinary-go/v2"
+	"github.com/cloudinary/cloudinary-go/v2/api"
+	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Storage là nơi lưu file upload. Key do server sinh (xem NewObjectKey), dạng thư mục/năm/tháng/tên.đuôi,
+// và được lưu vào Media.PublicID để xóa file khi ảnh không còn dùng.
+type Storage interface {
+	// Put ghi đè file có key, trả về URL công khai
+	Put(ctx context.Context, key, contentType string, data []byte) (string, error)
+	// Delete xóa file, file không tồn tại không tính là lỗi
+	Delete(ctx context.Context, key string) error
+}
+
+// NewStorage tạo Storage theo cfg.Driver, cld chỉ dùng cho driver cloudinary
+func NewStorage(cfg config.StorageConfig, cld *cloudinary.Cloudinary) (Storage, error) {
+	switch cfg.Driver {
+	case "cloudinary":
+		if cld == nil {
+			return nil, errors.New("chưa khởi tạo Cloudinary")
+		}
+		return CloudinaryStorage{cld: cld}, nil
+	case "local":
+		return NewLocalStorage(cfg.LocalDir, cfg.LocalBaseURL)
+	case "s3":
+		return NewS3Storage(cfg.S3), nil
+	}
+	return nil, fmt.Errorf("không hỗ trợ nơi lưu trữ %q", cfg.Driver)
+}
+
+// NewObjectKey sinh key ngẫu nhiên cho file mới trong folder, vd: uploads/2025/06/9f86d081884c7d65.jpg
+func NewObjectKey(folder, ext string) string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return path.Join(folder, time.Now().Format("2006/01"), hex.EncodeToString(b)+ext)
+}
+
+// ThumbnailKey là key của ảnh thu nhỏ đi kèm ảnh có key
+func ThumbnailKey(key string) string {
+	ext := path.Ext(key)
+	return strings.TrimSuffix(key, ext) + "_thumb" + ext
+}
+
+// validObjectKey chặn key có thể thoát khỏi thư mục/bucket
+func validObjectKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key || strings.HasPrefix(key, "..") {
+		return fmt.Errorf("key không hợp lệ: %q", key)
+	}
+	return nil
+}
+
+// CloudinaryStorage lưu ảnh trên Cloudinary, public_id là key bỏ đuôi file
+type CloudinaryStorage struct {
+	cld *cloudinary.Cloudinary
+}
+
+func cloudinaryPublicID(key string) string {
+	return strings.TrimSuffix(key, path.Ext(key))
+}
+
+func (s CloudinaryStorage) Put(ctx context.Context, key, contentType string, data []byte) (url string, err error) {
+	ctx, span := StartSpan(ctx, "cloudinary.upload")
+	span.SetAttributes(attribute.String("cloudinary.public_id", cloudinaryPublicID(key)))
+	defer func() { EndSpan(span, err) }()
+
+	resp, err := s.cld.Upload.Upload(ctx, bytes.NewReader(data), uploader.UploadParams{
+		PublicID:  cloudinaryPublicID(key),
+		Overwrite: api.Bool(true),
+	})
+	if err != nil {
+		return "", err
+	}
+	if resp.Error.Message != "" {
+		return "", errors.New(resp.Error.Message)
+	}
+	return resp.SecureURL, nil
+}
+
+func (s CloudinaryStorage) Delete(ctx context.Context, key string) (err error) {
+	ctx, span := StartSpan(ctx, "cloudinary.destroy")
+	span.SetAttributes(attribute.String("cloudinary.public_id", cloudinaryPublicID(key)))
+	defer func() { EndSpan(span, err) }()
+
+	resp, err := s.cld.Upload.Destroy(ctx, uploader.DestroyParams{PublicID: cloudinaryPublicID(key), Invalidate: api.Bool(true)})
+	if err != nil {
+		return err
+	}
+	if resp.Error.Message != "" {
+		return errors.New(resp.Error.Message)
+	}
+	return nil
+}
+
+// LocalStorage lưu file trong thư mục trên máy chủ, dùng khi phát triển hoặc chạy một instance
+type LocalStorage struct {
+	Dir     string
+	BaseURL string
+}
+
+func NewLocalStorage(dir, baseURL string) (*LocalStorage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("không thể tạo thư mục lưu file %s: %w", dir, err)
+	}
+	return &LocalStorage{Dir: dir, BaseURL: strings.TrimRight(baseURL, "/")}, nil
+}
+
+// URLPath là đường dẫn phục vụ file tĩnh, lấy từ BaseURL (mặc định /uploads)
+func (s *LocalStorage) URLPath() string {
+	u, err := url.Parse(s.BaseURL)
+	if err != nil || u.Path == "" || u.Path == "/" {
+		return "/uploads"
+	}
+	return u.Path
+}
+
+func (s *LocalStorage) Put(_ context.Context, key, _ string, data []byte) (string, error) {
+	if err := validObjectKey(key); err != nil {
+		return "", err
+	}
+	target := filepath.Join(s.Dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return "", err
+	}
+	// Ghi ra file tạm rồi đổi tên để không ai đọc được file ghi dở
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return "", err
+	}
+	return s.BaseURL + "/" + key, nil
+}
+
+func (s *LocalStorage) Delete(_ context.Context, key string) error {
+	if err := validObjectKey(key); err != nil {
+		return err
+	}
+	err := os.Remove(filepath.Join(s.Dir, filepath.FromSlash(key)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"new/config"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tên tracer cho các span do ứng dụng tự tạo (gọi Mapbox, Cloudinary, SMTP)
+const tracerName = "new/services"
+
+// InitTracing tạo exporter theo cấu hình và đăng ký tracer provider toàn cục.
+// Exporter "none" không làm gì, các span khi đó là no-op. Hàm trả về shutdown để flush span khi tắt server.
+func InitTracing(ctx context.Context, cfg config.TracingConfig) (func(context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Exporter {
+	case "otlp":
+		exporter, err = otlptracehttp.New(ctx)
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	default:
+		return func(context.Context) error { return nil }, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("không thể tạo exporter %s: %w", cfg.Exporter, err)
+	}
+	provider := SetupTracing(exporter, cfg.ServiceName)
+	return provider.Shutdown, nil
+}
+
+// SetupTracing đăng ký tracer provider với exporter cho trước,
+// test có thể truyền tracetest.NewInMemoryExporter() để kiểm tra span.
+func SetupTracing(exporter sdktrace.SpanExporter, serviceName string) *sdktrace.TracerProvider {
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider
+}
+
+// StartSpan tạo client span cho lời gọi ra dịch vụ bên ngoài
+func StartSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+}
+
+// EndSpan ghi lỗi (nếu có) vào span rồi kết thúc span
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestStartEndSpan(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := SetupTracing(exporter, "test")
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus codes.Code
+		wantEvents int
+	}{
+		{"thành công", nil, codes.Unset, 0},
+		{"lỗi được ghi vào span", errors.New("mapbox trả về 500"), codes.Error, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter.Reset()
+			ctx, parent := otel.Tracer("test").Start(context.Background(), "request")
+			_, span := StartSpan(ctx, "mapbox.geocode")
+			EndSpan(span, tt.err)
+			parent.End()
+			if err := provider.ForceFlush(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			spans := exporter.GetSpans()
+			if len(spans) != 2 {
+				t.Fatalf("exported %d spans, want 2", len(spans))
+			}
+			got := spans[0]
+			if got.Name != "mapbox.geocode" || got.SpanKind != trace.SpanKindClient {
+				t.Errorf("span = %s (%v), want mapbox.geocode (client)", got.Name, got.SpanKind)
+			}
+			if got.Parent.SpanID() != spans[1].SpanContext.SpanID() {
+				t.Error("span is not a child of the request span")
+			}
+			if got.Status.Code != tt.wantStatus || len(got.Events) != tt.wantEvents {
+				t.Errorf("status = %v, %d events; want %v, %d", got.Status.Code, len(got.Events), tt.wantStatus, tt.wantEvents)
+			}
+			if tt.err != nil && got.Status.Description != tt.err.Error() {
+				t.Errorf("status description = %q, want %q", got.Status.Description, tt.err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"context"
+	"sync"
+)
+
+// Workers theo dõi các goroutine chạy nền (dọn audit, probe Redis...)
+// để khi tắt server có thể chờ chúng dừng hẳn trước khi đóng DB và Redis.
+type Workers struct {
+	wg sync.WaitGroup
+}
+
+// BackgroundWorkers là nhóm dùng chung của ứng dụng, các worker dừng khi context truyền vào bị hủy
+var BackgroundWorkers = &Workers{}
+
+func (w *Workers) Go(fn func()) {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		fn()
+	}()
+}
+
+// Wait chờ mọi worker dừng, trả về lỗi nếu ctx hết hạn trước
+func (w *Workers) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}